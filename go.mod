@@ -28,8 +28,9 @@ require (
 	github.com/creack/goselect v0.1.2 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
+	golang.org/x/sync v0.8.0 // indirect
 	golang.org/x/term v0.22.0 // indirect
-	rsc.io/qr v0.2.0 // indirect
+	golang.org/x/xerrors v0.0.0-20240903120638-7835f813f4da // indirect
 )
 
 require (
@@ -43,7 +44,7 @@ require (
 	github.com/gocarina/gocsv v0.0.0-20230616125104-99d496ca653d
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/websocket v1.5.3
-	github.com/hsanjuan/go-ndef v0.0.1
+	github.com/makiuchi-d/gozxing v0.1.1
 	github.com/mdp/qrterminal/v3 v3.2.0
 	github.com/olahol/melody v1.2.1
 	github.com/pelletier/go-toml/v2 v2.2.3
@@ -51,6 +52,6 @@ require (
 	github.com/wizzomafizzo/mrext v0.0.0-20240804073054-39dcc9bccc81
 	go.bug.st/serial v1.6.2
 	go.etcd.io/bbolt v1.3.9
-	golang.org/x/sync v0.8.0
 	golang.org/x/text v0.19.0
+	rsc.io/qr v0.2.0
 )
@@ -3,14 +3,20 @@ package methods
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/assets"
 	"github.com/ZaparooProject/zaparoo-core/pkg/database/gamesdb"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -29,11 +35,21 @@ func (s *Index) Exists(platform platforms.Platform) bool {
 	return gamesdb.Exists(platform)
 }
 
+// IsIndexing reports if an index is currently running. The lock is held for
+// the whole run, so it's only checked when it can be taken without waiting.
+func (s *Index) IsIndexing() bool {
+	if !s.mu.TryLock() {
+		return true
+	}
+	defer s.mu.Unlock()
+	return s.Indexing
+}
+
 func (s *Index) GenerateIndex(
 	pl platforms.Platform,
 	cfg *config.Instance,
 	ns chan<- models.Notification,
-	systems []gamesdb.System,
+	opts gamesdb.IndexOptions,
 ) {
 	// TODO: this function should block until index is complete
 	// confirm that concurrent requests is working
@@ -63,7 +79,7 @@ func (s *Index) GenerateIndex(
 	go func() {
 		defer s.mu.Unlock()
 
-		total, err := gamesdb.NewNamesIndex(pl, cfg, systems, func(status gamesdb.IndexStatus) {
+		total, err := gamesdb.UpdateIndex(pl, cfg, opts, func(status gamesdb.IndexStatus) {
 			s.TotalSteps = status.Total
 			s.CurrentStep = status.Step
 			s.TotalFiles = status.Files
@@ -131,7 +147,7 @@ var IndexInstance = NewIndex()
 func HandleIndexMedia(env requests.RequestEnv) (any, error) {
 	log.Info().Msg("received index media request")
 
-	var systems []gamesdb.System
+	opts := gamesdb.IndexOptions{}
 	if len(env.Params) > 0 {
 		var params models.MediaIndexParams
 		err := json.Unmarshal(env.Params, &params)
@@ -139,27 +155,35 @@ func HandleIndexMedia(env requests.RequestEnv) (any, error) {
 			return nil, ErrInvalidParams
 		}
 
-		if params.Systems == nil || len(*params.Systems) == 0 {
-			systems = gamesdb.AllSystems()
-		}
+		if params.Systems != nil {
+			for _, s := range *params.Systems {
+				system, err := gamesdb.GetSystem(s)
+				if err != nil {
+					return nil, errors.New("error getting system: " + err.Error())
+				}
 
-		for _, s := range *params.Systems {
-			system, err := gamesdb.GetSystem(s)
-			if err != nil {
-				return nil, errors.New("error getting system: " + err.Error())
+				opts.Systems = append(opts.Systems, *system)
 			}
+		}
 
-			systems = append(systems, *system)
+		if params.Paths != nil {
+			opts.Paths = *params.Paths
 		}
-	} else {
-		systems = gamesdb.AllSystems()
+
+		if params.Full != nil {
+			opts.Full = *params.Full
+		}
+	}
+
+	if len(opts.Systems) == 0 {
+		opts.Systems = gamesdb.AllSystems()
 	}
 
 	IndexInstance.GenerateIndex(
 		env.Platform,
 		env.Config,
 		env.State.Notifications,
-		systems,
+		opts,
 	)
 	return nil, nil
 }
@@ -191,8 +215,16 @@ func HandleGames(env requests.RequestEnv) (any, error) {
 	system := params.Systems
 	query := params.Query
 
+	var filter gamesdb.SearchFilter
+	if params.Regions != nil {
+		filter.Regions = *params.Regions
+	}
+	if params.Exclude != nil {
+		filter.Exclude = *params.Exclude
+	}
+
 	if system == nil || len(*system) == 0 {
-		search, err = gamesdb.SearchNamesWords(env.Platform, gamesdb.AllSystems(), query)
+		search, err = gamesdb.SearchMedia(env.Platform, gamesdb.AllSystems(), query, filter)
 		if err != nil {
 			return nil, errors.New("error searching all media: " + err.Error())
 		}
@@ -207,7 +239,7 @@ func HandleGames(env requests.RequestEnv) (any, error) {
 			systems = append(systems, *system)
 		}
 
-		search, err = gamesdb.SearchNamesWords(env.Platform, systems, query)
+		search, err = gamesdb.SearchMedia(env.Platform, systems, query, filter)
 		if err != nil {
 			return nil, errors.New("error searching media: " + err.Error())
 		}
@@ -219,13 +251,25 @@ func HandleGames(env requests.RequestEnv) (any, error) {
 			continue
 		}
 
+		name := result.Title
+		if name == "" {
+			name = result.Name
+		}
+
 		results = append(results, models.SearchResultMedia{
 			System: models.System{
 				Id:   system.Id,
 				Name: system.Id,
 			},
-			Name: result.Name,
+			Name: name,
 			Path: env.Platform.NormalizePath(env.Config, result.Path),
+			Tags: models.MediaTags{
+				Regions:   result.Tags.Regions,
+				Languages: result.Tags.Languages,
+				Revision:  result.Tags.Revision,
+				Version:   result.Tags.Version,
+				Flags:     result.Tags.Flags,
+			},
 		})
 	}
 
@@ -240,3 +284,154 @@ func HandleGames(env requests.RequestEnv) (any, error) {
 		Total:   total,
 	}, nil
 }
+
+// Resolve the path of a DAT file to import, which must be inside the dats
+// folder so the API can't be used to read arbitrary files. Relative paths
+// are relative to the dats folder.
+func datPath(pl platforms.Platform, path string) (string, error) {
+	datsDir := filepath.Join(pl.DataDir(), platforms.DatsDir)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(datsDir, path)
+	}
+
+	// resolve symlinks so links can't point outside the dats folder
+	realDir, err := filepath.EvalSymlinks(datsDir)
+	if err != nil {
+		return "", errors.New("error opening dats folder: " + err.Error())
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", errors.New("error opening dat file: " + err.Error())
+	}
+
+	if !utils.PathWithin(realPath, realDir) || realPath == realDir {
+		return "", fmt.Errorf("dat file must be in the dats folder: %s", datsDir)
+	}
+
+	return realPath, nil
+}
+
+func HandleImportDat(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received media dat import request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.MediaDatImportParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	if params.System == "" || (params.Path == "") == (params.Data == "") {
+		return nil, ErrInvalidParams
+	}
+
+	if IndexInstance.IsIndexing() {
+		return nil, errors.New("media index is being generated")
+	}
+
+	var r io.Reader
+	if params.Data != "" {
+		r = strings.NewReader(params.Data)
+	} else {
+		path, err := datPath(env.Platform, params.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, errors.New("error opening dat file: " + err.Error())
+		}
+		defer func(f *os.File) {
+			err := f.Close()
+			if err != nil {
+				log.Warn().Err(err).Msg("error closing dat file")
+			}
+		}(f)
+		r = f
+	}
+
+	result, err := gamesdb.ImportDat(env.Platform, params.System, r)
+	if err != nil {
+		return nil, errors.New("error importing dat: " + err.Error())
+	}
+
+	log.Info().Msgf(
+		"imported dat %s for %s: %d games, %d media matched",
+		result.Source, params.System, result.Games, result.Matched,
+	)
+
+	return models.MediaDatImportResponse{
+		Source:  result.Source,
+		Games:   result.Games,
+		Roms:    result.Roms,
+		Matched: result.Matched,
+	}, nil
+}
+
+func HandleMediaVerify(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received media verify request")
+
+	var params models.MediaVerifyParams
+	if len(env.Params) > 0 {
+		err := json.Unmarshal(env.Params, &params)
+		if err != nil {
+			return nil, ErrInvalidParams
+		}
+	}
+
+	var systemIds []string
+	if params.Systems != nil && len(*params.Systems) > 0 {
+		for _, s := range *params.Systems {
+			system, err := gamesdb.GetSystem(s)
+			if err != nil {
+				return nil, errors.New("error getting system: " + err.Error())
+			}
+			systemIds = append(systemIds, system.Id)
+		}
+	} else {
+		indexed, err := gamesdb.IndexedSystems(env.Platform)
+		if err != nil {
+			return nil, errors.New("error getting indexed systems: " + err.Error())
+		}
+		systemIds = indexed
+	}
+
+	resp := models.MediaVerifyResponse{
+		Systems: make([]models.MediaVerifySystem, 0, len(systemIds)),
+	}
+
+	for _, id := range systemIds {
+		report, err := gamesdb.VerifySystem(env.Platform, id)
+		if err != nil {
+			return nil, errors.New("error verifying media: " + err.Error())
+		}
+
+		vs := models.MediaVerifySystem{
+			System: models.System{
+				Id:   id,
+				Name: id,
+			},
+			Dats:       make([]string, 0, len(report.Sources)),
+			Total:      report.Total,
+			Verified:   report.Verified,
+			Unverified: report.Unverified,
+			Unknown:    make([]string, 0, len(report.Unknown)),
+			Bad:        make([]string, 0, len(report.Bad)),
+		}
+		vs.Dats = append(vs.Dats, report.Sources...)
+		for _, p := range report.Unknown {
+			vs.Unknown = append(vs.Unknown, env.Platform.NormalizePath(env.Config, p))
+		}
+		for _, p := range report.Bad {
+			vs.Bad = append(vs.Bad, env.Platform.NormalizePath(env.Config, p))
+		}
+
+		resp.Systems = append(resp.Systems, vs)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,97 @@
+package methods
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+)
+
+type testPlatform struct {
+	platforms.Platform
+	dataDir string
+}
+
+func (p *testPlatform) DataDir() string {
+	return p.dataDir
+}
+
+func TestDatPath(t *testing.T) {
+	pl := &testPlatform{dataDir: t.TempDir()}
+	datsDir := filepath.Join(pl.dataDir, platforms.DatsDir)
+	outside := filepath.Join(pl.dataDir, "secret.dat")
+
+	for _, p := range []string{
+		filepath.Join(datsDir, "nes.dat"),
+		filepath.Join(datsDir, "redump", "psx.dat"),
+		outside,
+	} {
+		err := os.MkdirAll(filepath.Dir(p), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(p, []byte("<datafile/>"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := os.Symlink(outside, filepath.Join(datsDir, "link.dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	realDats, err := filepath.EvalSymlinks(datsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "nes.dat", want: filepath.Join(realDats, "nes.dat")},
+		{path: filepath.Join("redump", "psx.dat"), want: filepath.Join(realDats, "redump", "psx.dat")},
+		{path: filepath.Join(datsDir, "nes.dat"), want: filepath.Join(realDats, "nes.dat")},
+		{path: filepath.Join("..", "secret.dat"), wantErr: true},
+		{path: outside, wantErr: true},
+		{path: "link.dat", wantErr: true},
+		{path: "missing.dat", wantErr: true},
+		{path: datsDir, wantErr: true},
+		{path: "/etc/passwd", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := datPath(pl, tc.path)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("%q: expected error, got: %s", tc.path, got)
+			}
+		} else if err != nil {
+			t.Fatalf("%q: %s", tc.path, err)
+		} else if got != tc.want {
+			t.Fatalf("%q: expected: %s, got: %s", tc.path, tc.want, got)
+		}
+	}
+}
+
+func TestIndexIsIndexing(t *testing.T) {
+	idx := NewIndex()
+	if idx.IsIndexing() {
+		t.Fatal("expected new index not to be indexing")
+	}
+
+	// the lock is held while an index runs
+	idx.mu.Lock()
+	if !idx.IsIndexing() {
+		t.Fatal("expected locked index to be indexing")
+	}
+	idx.mu.Unlock()
+
+	idx.Indexing = true
+	if !idx.IsIndexing() {
+		t.Fatal("expected index to be indexing")
+	}
+}
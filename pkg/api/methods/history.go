@@ -1,16 +1,70 @@
 package methods
 
 import (
+	"encoding/json"
 	"errors"
+	"strings"
+
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	defaultMaxHistory = 25
+	maxHistory        = 1000
+)
+
+func historyFilter(p models.HistoryFilterParams) database.HistoryFilter {
+	var f database.HistoryFilter
+
+	if p.From != nil {
+		f.From = *p.From
+	}
+	if p.To != nil {
+		f.To = *p.To
+	}
+	if p.UID != nil {
+		f.UID = *p.UID
+	}
+	if p.Source != nil {
+		f.Source = *p.Source
+	}
+	if p.Type != nil {
+		f.Type = *p.Type
+	}
+	f.Success = p.Success
+
+	return f
+}
+
 func HandleHistory(env requests.RequestEnv) (any, error) {
 	log.Info().Msg("received history request")
 
-	entries, err := env.Database.GetHistory()
+	var params models.HistoryParams
+	if len(env.Params) > 0 {
+		err := json.Unmarshal(env.Params, &params)
+		if err != nil {
+			return nil, ErrInvalidParams
+		}
+	}
+
+	maxResults := defaultMaxHistory
+	if params.MaxResults != nil && *params.MaxResults > 0 {
+		maxResults = min(*params.MaxResults, maxHistory)
+	}
+
+	var cursor uint64
+	if params.Cursor != nil {
+		cursor = *params.Cursor
+	}
+
+	entries, next, err := env.Database.GetHistory(
+		historyFilter(params.HistoryFilterParams),
+		cursor,
+		maxResults,
+	)
 	if err != nil {
 		log.Error().Err(err).Msgf("error getting history")
 		return nil, errors.New("error getting history")
@@ -20,16 +74,61 @@ func HandleHistory(env requests.RequestEnv) (any, error) {
 		Entries: make([]models.HistoryReponseEntry, len(entries)),
 	}
 
+	if next != 0 {
+		resp.NextCursor = &next
+	}
+
 	for i, e := range entries {
 		resp.Entries[i] = models.HistoryReponseEntry{
-			Time:    e.Time,
-			Type:    e.Type,
-			UID:     e.UID,
-			Text:    e.Text,
-			Data:    e.Data,
-			Success: e.Success,
+			Id:        e.Id,
+			Time:      e.Time,
+			Type:      e.Type,
+			UID:       e.UID,
+			Text:      e.Text,
+			Data:      e.Data,
+			Source:    e.Source,
+			Device:    e.Device,
+			Origin:    e.Origin,
+			Remote:    e.Remote,
+			Mapping:   e.Mapping,
+			ZapScript: e.ZapScript,
+			SystemId:  e.SystemId,
+			MediaPath: e.MediaPath,
+			Success:   e.Success,
+			Error:     e.Error,
 		}
 	}
 
 	return resp, nil
 }
+
+func HandleHistoryExport(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received history export request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.HistoryExportParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	format := strings.ToLower(params.Format)
+	if format != database.HistoryFormatCsv && format != database.HistoryFormatJson {
+		return nil, ErrInvalidParams
+	}
+
+	sb := &strings.Builder{}
+	err = env.Database.ExportHistory(sb, format, historyFilter(params.HistoryFilterParams))
+	if err != nil {
+		log.Error().Err(err).Msgf("error exporting history")
+		return nil, errors.New("error exporting history")
+	}
+
+	return models.HistoryExportResponse{
+		Format: format,
+		Data:   sb.String(),
+	}, nil
+}
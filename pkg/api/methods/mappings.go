@@ -35,14 +35,15 @@ func HandleMappings(env requests.RequestEnv) (any, error) {
 		t := time.Unix(0, m.Added*int64(time.Millisecond))
 
 		mr := models.MappingResponse{
-			Id:       m.Id,
-			Added:    t.Format(time.RFC3339),
-			Label:    m.Label,
-			Enabled:  m.Enabled,
-			Type:     m.Type,
-			Match:    m.Match,
-			Pattern:  m.Pattern,
-			Override: m.Override,
+			Id:           m.Id,
+			Added:        t.Format(time.RFC3339),
+			Label:        m.Label,
+			Enabled:      m.Enabled,
+			Type:         m.Type,
+			Match:        m.Match,
+			Pattern:      m.Pattern,
+			Override:     m.Override,
+			VerifiedOnly: m.VerifiedOnly,
 		}
 
 		mrs = append(mrs, mr)
@@ -96,12 +97,13 @@ func HandleAddMapping(env requests.RequestEnv) (any, error) {
 	}
 
 	m := database.Mapping{
-		Label:    params.Label,
-		Enabled:  params.Enabled,
-		Type:     params.Type,
-		Match:    params.Match,
-		Pattern:  params.Pattern,
-		Override: params.Override,
+		Label:        params.Label,
+		Enabled:      params.Enabled,
+		Type:         params.Type,
+		Match:        params.Match,
+		Pattern:      params.Pattern,
+		Override:     params.Override,
+		VerifiedOnly: params.VerifiedOnly,
 	}
 
 	err = env.Database.AddMapping(m)
@@ -134,7 +136,7 @@ func HandleDeleteMapping(env requests.RequestEnv) (any, error) {
 }
 
 func validateUpdateMappingParams(umr *models.UpdateMappingParams) error {
-	if umr.Label == nil && umr.Enabled == nil && umr.Type == nil && umr.Match == nil && umr.Pattern == nil && umr.Override == nil {
+	if umr.Label == nil && umr.Enabled == nil && umr.Type == nil && umr.Match == nil && umr.Pattern == nil && umr.Override == nil && umr.VerifiedOnly == nil {
 		return errors.New("missing fields")
 	}
 
@@ -210,6 +212,10 @@ func HandleUpdateMapping(env requests.RequestEnv) (any, error) {
 		newMapping.Override = *params.Override
 	}
 
+	if params.VerifiedOnly != nil {
+		newMapping.VerifiedOnly = *params.VerifiedOnly
+	}
+
 	err = env.Database.UpdateMapping(strconv.Itoa(params.Id), newMapping)
 	if err != nil {
 		return nil, err
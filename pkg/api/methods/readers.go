@@ -1,13 +1,82 @@
 package methods
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
+
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/qr"
 	"github.com/rs/zerolog/log"
 )
 
+// Pick the reader to write to, preferring the one which last scanned a
+// token. Falls back to the first connected reader which supports writing.
+func writeReader(env requests.RequestEnv) (readers.Reader, error) {
+	rs := env.State.ListReaders()
+	if len(rs) == 0 {
+		return nil, errors.New("no readers connected")
+	}
+	sort.Strings(rs)
+
+	lt := env.State.GetLastScanned()
+	if !lt.ScanTime.IsZero() && !lt.Remote {
+		rs = append([]string{lt.Source}, rs...)
+	}
+
+	for _, rid := range rs {
+		reader, ok := env.State.GetReader(rid)
+		if !ok || reader == nil || !reader.Connected() {
+			continue
+		} else if !reader.Writable() {
+			log.Debug().Msgf("reader does not support writing: %s", rid)
+			continue
+		}
+
+		return reader, nil
+	}
+
+	return nil, errors.New("no connected readers support writing")
+}
+
+func buildRecord(r models.ReaderWriteRecord) (ndef.Record, error) {
+	switch r.Kind {
+	case ndef.KindText, "":
+		lang := r.Lang
+		if lang == "" {
+			lang = "en"
+		}
+		return ndef.NewTextRecord(r.Text, lang), nil
+	case ndef.KindURI:
+		return ndef.NewURIRecord(r.Text), nil
+	case ndef.KindJSON:
+		return ndef.NewJSONRecord(ndef.ZapScript{ZapScript: r.Text})
+	case ndef.KindZapScript:
+		return ndef.NewZapScriptRecord(r.Text), nil
+	case "mime", "external":
+		if r.Type == "" {
+			return ndef.Record{}, fmt.Errorf("%s record has no type", r.Kind)
+		}
+
+		data, err := base64.StdEncoding.DecodeString(r.Data)
+		if err != nil {
+			return ndef.Record{}, fmt.Errorf("invalid %s record data: %w", r.Kind, err)
+		}
+
+		if r.Kind == "mime" {
+			return ndef.NewMimeRecord(r.Type, data), nil
+		}
+		return ndef.NewExternalRecord(r.Type, data), nil
+	default:
+		return ndef.Record{}, fmt.Errorf("unknown record kind: %s", r.Kind)
+	}
+}
+
 func HandleReaderWrite(env requests.RequestEnv) (any, error) {
 	log.Info().Msg("received reader write request")
 
@@ -21,27 +90,40 @@ func HandleReaderWrite(env requests.RequestEnv) (any, error) {
 		return nil, ErrInvalidParams
 	}
 
-	rs := env.State.ListReaders()
-	if len(rs) == 0 {
-		return nil, errors.New("no readers connected")
+	var records []ndef.Record
+	if params.Text != "" {
+		records = append(records, ndef.NewTextRecord(params.Text, "en"))
+	}
+	for _, r := range params.Records {
+		record, err := buildRecord(r)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
 	}
 
-	rid := rs[0]
-	lt := env.State.GetLastScanned()
+	if len(records) == 0 {
+		return nil, ErrMissingParams
+	}
 
-	if !lt.ScanTime.IsZero() && !lt.Remote {
-		rid = lt.Source
+	verify := true
+	if params.Verify != nil {
+		verify = *params.Verify
 	}
 
-	reader, ok := env.State.GetReader(rid)
-	if !ok || reader == nil {
-		return nil, errors.New("reader not connected: " + rs[0])
+	reader, err := writeReader(env)
+	if err != nil {
+		return nil, err
 	}
 
-	t, err := reader.Write(params.Text)
+	t, err := reader.WriteMessage(readers.WriteRequest{
+		Records: records,
+		Verify:  verify,
+		Lock:    params.Lock,
+	})
 	if err != nil {
 		log.Error().Err(err).Msg("error writing to reader")
-		return nil, errors.New("error writing to reader")
+		return nil, fmt.Errorf("error writing to reader: %w", err)
 	}
 
 	if t != nil {
@@ -50,3 +132,75 @@ func HandleReaderWrite(env requests.RequestEnv) (any, error) {
 
 	return nil, nil
 }
+
+func HandleReaderErase(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received reader erase request")
+
+	reader, err := writeReader(env)
+	if err != nil {
+		return nil, err
+	}
+
+	err = reader.Erase()
+	if err != nil {
+		log.Error().Err(err).Msg("error erasing token")
+		return nil, fmt.Errorf("error erasing token: %w", err)
+	}
+
+	return nil, nil
+}
+
+func HandleReaderInspect(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received reader inspect request")
+
+	reader, err := writeReader(env)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := reader.Inspect()
+	if err != nil {
+		log.Error().Err(err).Msg("error inspecting token")
+		return nil, fmt.Errorf("error inspecting token: %w", err)
+	}
+
+	return models.ReaderInspectResponse{
+		UID:      info.UID,
+		Type:     info.Type,
+		Capacity: info.Capacity,
+		Used:     info.Used,
+		ReadOnly: info.ReadOnly,
+	}, nil
+}
+
+// HandleTokensQr generates a PNG image of a QR code containing the given
+// text, which can be printed and scanned with a QR reader.
+func HandleTokensQr(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received tokens qr request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.TokensQrParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil || params.Text == "" {
+		return nil, ErrInvalidParams
+	}
+
+	scale := 0
+	if params.Scale != nil {
+		scale = *params.Scale
+	}
+
+	data, err := qr.Generate(params.Text, scale)
+	if err != nil {
+		log.Error().Err(err).Msg("error generating qr code")
+		return nil, fmt.Errorf("error generating qr code: %w", err)
+	}
+
+	return models.TokensQrResponse{
+		Format: "png",
+		Data:   base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
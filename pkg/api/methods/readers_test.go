@@ -0,0 +1,218 @@
+package methods
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+// testReader implements only the parts of a reader used when picking a
+// reader to write to, calling anything else will panic.
+type testReader struct {
+	readers.Reader
+	connected bool
+	writable  bool
+}
+
+func (r *testReader) Connected() bool {
+	return r.connected
+}
+
+func (r *testReader) Writable() bool {
+	return r.writable
+}
+
+func (r *testReader) Close() error {
+	return nil
+}
+
+func TestWriteReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		readers map[string]*testReader
+		last    string
+		want    string
+	}{
+		{
+			name:    "no readers",
+			readers: map[string]*testReader{},
+		},
+		{
+			name: "last scanned",
+			readers: map[string]*testReader{
+				"libnfc:a": {connected: true, writable: true},
+				"libnfc:b": {connected: true, writable: true},
+			},
+			last: "libnfc:b",
+			want: "libnfc:b",
+		},
+		{
+			name: "last scanned can't write",
+			readers: map[string]*testReader{
+				"qr_folder:/tmp/qr": {connected: true},
+				"hid_wedge:kbd":     {connected: true},
+				"libnfc:a":          {connected: true, writable: true},
+			},
+			last: "qr_folder:/tmp/qr",
+			want: "libnfc:a",
+		},
+		{
+			name: "last scanned disconnected",
+			readers: map[string]*testReader{
+				"libnfc:a":      {connected: true, writable: true},
+				"acr122_pcsc:b": {writable: true},
+			},
+			last: "acr122_pcsc:b",
+			want: "libnfc:a",
+		},
+		{
+			name: "no writable readers",
+			readers: map[string]*testReader{
+				"qr_video:/dev/video0": {connected: true},
+				"hid_wedge:kbd":        {connected: true},
+			},
+			last: "hid_wedge:kbd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ns := state.NewState(&testPlatform{})
+			go func() {
+				for range ns {
+				}
+			}()
+
+			for device, r := range tt.readers {
+				st.SetReader(device, r)
+			}
+			if tt.last != "" {
+				st.SetActiveCard(tokens.Token{
+					UID:      "04aabbcc",
+					ScanTime: time.Now(),
+					Source:   tt.last,
+				})
+			}
+
+			got, err := writeReader(requests.RequestEnv{State: st})
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("expected error, got: %v", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != readers.Reader(tt.readers[tt.want]) {
+				t.Fatalf("expected: %q, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBuildRecord(t *testing.T) {
+	payload := []byte{0x01, 0x02, 0x03}
+	data := base64.StdEncoding.EncodeToString(payload)
+
+	tests := []struct {
+		name string
+		r    models.ReaderWriteRecord
+		kind string
+		text string
+		want ndef.Record
+	}{
+		{
+			name: "default kind is text",
+			r:    models.ReaderWriteRecord{Text: "**launch.random:snes"},
+			kind: ndef.KindText,
+			text: "**launch.random:snes",
+		},
+		{
+			name: "text with language",
+			r:    models.ReaderWriteRecord{Kind: ndef.KindText, Text: "Mario", Lang: "de"},
+			want: ndef.NewTextRecord("Mario", "de"),
+		},
+		{
+			name: "text defaults to english",
+			r:    models.ReaderWriteRecord{Kind: ndef.KindText, Text: "Mario"},
+			want: ndef.NewTextRecord("Mario", "en"),
+		},
+		{
+			name: "uri",
+			r:    models.ReaderWriteRecord{Kind: ndef.KindURI, Text: "https://zaparoo.org"},
+			kind: ndef.KindURI,
+			text: "https://zaparoo.org",
+		},
+		{
+			name: "json",
+			r:    models.ReaderWriteRecord{Kind: ndef.KindJSON, Text: "SNES/Mario.sfc"},
+			kind: ndef.KindJSON,
+			text: "SNES/Mario.sfc",
+		},
+		{
+			name: "zapscript",
+			r:    models.ReaderWriteRecord{Kind: ndef.KindZapScript, Text: "**launch.random:nes"},
+			kind: ndef.KindZapScript,
+			text: "**launch.random:nes",
+		},
+		{
+			name: "mime",
+			r:    models.ReaderWriteRecord{Kind: "mime", Type: "application/octet-stream", Data: data},
+			want: ndef.NewMimeRecord("application/octet-stream", payload),
+		},
+		{
+			name: "external",
+			r:    models.ReaderWriteRecord{Kind: "external", Type: "example.com:x", Data: data},
+			want: ndef.NewExternalRecord("example.com:x", payload),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := buildRecord(tc.r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.kind == "" {
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+				}
+				return
+			}
+
+			tr, err := ndef.TokenFromRecords([]ndef.Record{got})
+			if err != nil {
+				t.Fatal(err)
+			} else if tr.Kind != tc.kind || tr.Text != tc.text {
+				t.Fatalf("expected: %s %q, got: %s %q", tc.kind, tc.text, tr.Kind, tr.Text)
+			}
+		})
+	}
+}
+
+func TestBuildRecordInvalid(t *testing.T) {
+	tests := map[string]models.ReaderWriteRecord{
+		"unknown kind":      {Kind: "smartposter", Text: "x"},
+		"mime no type":      {Kind: "mime", Data: "AQID"},
+		"external no type":  {Kind: "external", Data: "AQID"},
+		"mime invalid data": {Kind: "mime", Type: "text/plain", Data: "not base64!"},
+	}
+
+	for name, r := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := buildRecord(r); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -87,6 +87,7 @@ func HandleRun(env requests.RequestEnv) (any, error) {
 
 	t.ScanTime = time.Now()
 	t.Remote = true // TODO: check if this is still necessary after api update
+	t.Source = tokens.SourceApi
 
 	// TODO: how do we report back errors? put channel in queue
 	env.State.SetActiveCard(t)
@@ -123,6 +124,7 @@ func HandleRunRest(
 			Text:     norm.NFC.String(text),
 			ScanTime: time.Now(),
 			Remote:   true,
+			Source:   tokens.SourceApi,
 		}
 
 		st.SetActiveCard(t)
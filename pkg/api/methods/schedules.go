@@ -0,0 +1,116 @@
+package methods
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/schedules"
+	"github.com/rs/zerolog/log"
+)
+
+func HandleSchedules(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received schedules request")
+
+	ss, err := env.Database.GetAllSchedules()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting schedules")
+		return nil, errors.New("error getting schedules")
+	}
+
+	srs := make([]models.ScheduleResponse, 0)
+	for _, s := range ss {
+		srs = append(srs, models.ScheduleResponse{
+			Id:        s.Id,
+			Added:     time.Unix(s.Added, 0).Format(time.RFC3339),
+			Label:     s.Label,
+			Enabled:   s.Enabled,
+			Cron:      s.Cron,
+			Idle:      s.Idle,
+			ZapScript: s.ZapScript,
+		})
+	}
+
+	return models.AllSchedulesResponse{
+		Schedules: srs,
+	}, nil
+}
+
+func validateAddScheduleParams(asp *models.AddScheduleParams) error {
+	if asp.ZapScript == "" {
+		return errors.New("missing zapscript")
+	}
+
+	if asp.Cron != "" && asp.Idle != 0 {
+		return errors.New("cron and idle cannot both be set")
+	}
+
+	if asp.Cron != "" {
+		_, err := schedules.ParseCron(asp.Cron)
+		if err != nil {
+			return err
+		}
+	} else if asp.Idle <= 0 {
+		return errors.New("missing cron or idle")
+	}
+
+	return nil
+}
+
+func HandleAddSchedule(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received add schedule request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.AddScheduleParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	err = validateAddScheduleParams(&params)
+	if err != nil {
+		log.Error().Err(err).Msg("invalid params")
+		return nil, ErrInvalidParams
+	}
+
+	id, err := env.Database.AddSchedule(database.Schedule{
+		Label:     params.Label,
+		Enabled:   params.Enabled,
+		Cron:      params.Cron,
+		Idle:      params.Idle,
+		ZapScript: params.ZapScript,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return models.NewScheduleResponse{Id: id}, nil
+}
+
+func HandleDeleteSchedule(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received delete schedule request")
+
+	if len(env.Params) == 0 {
+		return nil, ErrMissingParams
+	}
+
+	var params models.DeleteScheduleParams
+	err := json.Unmarshal(env.Params, &params)
+	if err != nil {
+		return nil, ErrInvalidParams
+	}
+
+	err = env.Database.DeleteSchedule(strconv.Itoa(params.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
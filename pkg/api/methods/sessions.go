@@ -0,0 +1,178 @@
+package methods
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultMaxSessions = 25
+
+func HandleMediaHistory(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received media history request")
+
+	var params models.MediaHistoryParams
+	if len(env.Params) > 0 {
+		err := json.Unmarshal(env.Params, &params)
+		if err != nil {
+			return nil, ErrInvalidParams
+		}
+	}
+
+	maxResults := defaultMaxSessions
+	if params.MaxResults != nil && *params.MaxResults > 0 {
+		maxResults = *params.MaxResults
+	}
+
+	var uid string
+	if params.UID != nil {
+		uid = database.NormalizeUid(*params.UID)
+	}
+
+	ss, err := env.Database.GetSessions(func(s database.Session) bool {
+		if params.SystemId != nil && s.SystemId != *params.SystemId {
+			return false
+		}
+		if uid != "" && database.NormalizeUid(s.TokenUID) != uid {
+			return false
+		}
+		return true
+	}, maxResults)
+	if err != nil {
+		log.Error().Err(err).Msg("error getting sessions")
+		return nil, errors.New("error getting sessions")
+	}
+
+	resp := models.MediaHistoryResponse{
+		Sessions: make([]models.MediaSessionResponse, len(ss)),
+	}
+
+	for i, s := range ss {
+		sr := models.MediaSessionResponse{
+			SystemId:   s.SystemId,
+			SystemName: s.SystemName,
+			MediaPath:  s.MediaPath,
+			MediaName:  s.MediaName,
+			Start:      s.Start,
+			Duration:   int(s.Duration().Seconds()),
+			TokenUID:   s.TokenUID,
+		}
+		if !s.End.IsZero() {
+			end := s.End
+			sr.End = &end
+		}
+		resp.Sessions[i] = sr
+	}
+
+	return resp, nil
+}
+
+func HandleMediaStats(env requests.RequestEnv) (any, error) {
+	log.Info().Msg("received media stats request")
+
+	var params models.MediaStatsParams
+	if len(env.Params) > 0 {
+		err := json.Unmarshal(env.Params, &params)
+		if err != nil {
+			return nil, ErrInvalidParams
+		}
+	}
+
+	maxResults := defaultMaxSessions
+	if params.MaxResults != nil && *params.MaxResults > 0 {
+		maxResults = *params.MaxResults
+	}
+
+	ss, err := env.Database.GetSessions(nil, 0)
+	if err != nil {
+		log.Error().Err(err).Msg("error getting sessions")
+		return nil, errors.New("error getting sessions")
+	}
+
+	media := make(map[string]*models.MediaStatsMedia)
+	systems := make(map[string]*models.MediaStatsSystem)
+	tokens := make(map[string]*models.MediaStatsToken)
+
+	// sessions are newest first, so the first session seen for any key is
+	// also the last played
+	for _, s := range ss {
+		secs := int(s.Duration().Seconds())
+
+		m, ok := media[s.MediaPath]
+		if !ok {
+			m = &models.MediaStatsMedia{
+				SystemId:   s.SystemId,
+				MediaPath:  s.MediaPath,
+				MediaName:  s.MediaName,
+				LastPlayed: s.Start,
+			}
+			media[s.MediaPath] = m
+		}
+		m.Plays++
+		m.TotalTime += secs
+
+		sys, ok := systems[s.SystemId]
+		if !ok {
+			sys = &models.MediaStatsSystem{
+				SystemId:   s.SystemId,
+				SystemName: s.SystemName,
+			}
+			systems[s.SystemId] = sys
+		}
+		sys.Plays++
+		sys.TotalTime += secs
+
+		if s.TokenUID != "" {
+			if _, ok := tokens[s.TokenUID]; !ok {
+				tokens[s.TokenUID] = &models.MediaStatsToken{
+					UID:        s.TokenUID,
+					SystemId:   s.SystemId,
+					MediaPath:  s.MediaPath,
+					MediaName:  s.MediaName,
+					LastPlayed: s.Start,
+				}
+			}
+		}
+	}
+
+	resp := models.MediaStatsResponse{
+		TopPlayed: make([]models.MediaStatsMedia, 0, len(media)),
+		Systems:   make([]models.MediaStatsSystem, 0, len(systems)),
+		Tokens:    make([]models.MediaStatsToken, 0, len(tokens)),
+	}
+
+	for _, m := range media {
+		resp.TopPlayed = append(resp.TopPlayed, *m)
+	}
+	sort.Slice(resp.TopPlayed, func(i, j int) bool {
+		a, b := resp.TopPlayed[i], resp.TopPlayed[j]
+		if a.TotalTime != b.TotalTime {
+			return a.TotalTime > b.TotalTime
+		}
+		return a.Plays > b.Plays
+	})
+	if len(resp.TopPlayed) > maxResults {
+		resp.TopPlayed = resp.TopPlayed[:maxResults]
+	}
+
+	for _, s := range systems {
+		resp.Systems = append(resp.Systems, *s)
+	}
+	sort.Slice(resp.Systems, func(i, j int) bool {
+		return resp.Systems[i].TotalTime > resp.Systems[j].TotalTime
+	})
+
+	for _, t := range tokens {
+		resp.Tokens = append(resp.Tokens, *t)
+	}
+	sort.Slice(resp.Tokens, func(i, j int) bool {
+		return resp.Tokens[i].LastPlayed.After(resp.Tokens[j].LastPlayed)
+	})
+
+	return resp, nil
+}
@@ -0,0 +1,179 @@
+package methods
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models/requests"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+)
+
+var sessionsStart = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+type testSession struct {
+	system string
+	path   string
+	uid    string
+	// length in seconds, 0 leaves the session open
+	length int
+}
+
+// Add sessions in the order given, each starting a minute after the last.
+func sessionsEnv(t *testing.T, ss ...testSession) requests.RequestEnv {
+	t.Helper()
+
+	db, err := database.Open(&testPlatform{dataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	for i, ts := range ss {
+		s := database.Session{
+			SystemId:  ts.system,
+			MediaPath: ts.path,
+			TokenUID:  ts.uid,
+			Start:     sessionsStart.Add(time.Duration(i) * time.Minute),
+		}
+		if ts.length > 0 {
+			s.End = s.Start.Add(time.Duration(ts.length) * time.Second)
+		}
+
+		_, err := db.AddSession(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return requests.RequestEnv{Database: db}
+}
+
+func TestHandleMediaHistory(t *testing.T) {
+	env := sessionsEnv(t,
+		testSession{"SNES", "mario.sfc", "04aabbcc", 30},
+		testSession{"NES", "zelda.nes", "", 45},
+		testSession{"SNES", "metroid.sfc", "04AA:BB:CC", 0},
+	)
+
+	tests := []struct {
+		name   string
+		params string
+		want   []string
+	}{
+		{name: "all", want: []string{"metroid.sfc", "zelda.nes", "mario.sfc"}},
+		{name: "system", params: `{"systemId":"SNES"}`, want: []string{"metroid.sfc", "mario.sfc"}},
+		{name: "uid normalized", params: `{"uid":"04:aa:bb:cc"}`, want: []string{"metroid.sfc", "mario.sfc"}},
+		{name: "max results", params: `{"maxResults":1}`, want: []string{"metroid.sfc"}},
+		{name: "no matches", params: `{"systemId":"N64"}`, want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env.Params = []byte(tt.params)
+			res, err := HandleMediaHistory(env)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ss := res.(models.MediaHistoryResponse).Sessions
+			if len(ss) != len(tt.want) {
+				t.Fatalf("expected: %d sessions, got: %d", len(tt.want), len(ss))
+			}
+			for i, s := range ss {
+				if s.MediaPath != tt.want[i] {
+					t.Fatalf("expected: %q, got: %q", tt.want[i], s.MediaPath)
+				}
+			}
+		})
+	}
+
+	env.Params = nil
+	res, err := HandleMediaHistory(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := res.(models.MediaHistoryResponse).Sessions
+	if ss[0].End != nil || ss[0].Duration != 0 {
+		t.Fatalf("expected open session, got: %v", ss[0])
+	} else if ss[1].End == nil || ss[1].Duration != 45 {
+		t.Fatalf("expected: %d seconds, got: %v", 45, ss[1])
+	}
+
+	env.Params = []byte(`{"maxResults":"x"}`)
+	_, err = HandleMediaHistory(env)
+	if err != ErrInvalidParams {
+		t.Fatalf("expected: %v, got: %v", ErrInvalidParams, err)
+	}
+}
+
+func TestHandleMediaStats(t *testing.T) {
+	env := sessionsEnv(t,
+		testSession{"SNES", "mario.sfc", "04aabbcc", 30},
+		testSession{"NES", "zelda.nes", "", 50},
+		testSession{"SNES", "mario.sfc", "", 40},
+		testSession{"SNES", "metroid.sfc", "04ddeeff", 10},
+		testSession{"SNES", "metroid.sfc", "04aabbcc", 0},
+	)
+
+	res, err := HandleMediaStats(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := res.(models.MediaStatsResponse)
+
+	wantMedia := []models.MediaStatsMedia{
+		{MediaPath: "mario.sfc", Plays: 2, TotalTime: 70, LastPlayed: sessionsStart.Add(2 * time.Minute)},
+		{MediaPath: "zelda.nes", Plays: 1, TotalTime: 50, LastPlayed: sessionsStart.Add(time.Minute)},
+		{MediaPath: "metroid.sfc", Plays: 2, TotalTime: 10, LastPlayed: sessionsStart.Add(4 * time.Minute)},
+	}
+	if len(stats.TopPlayed) != len(wantMedia) {
+		t.Fatalf("expected: %d media, got: %d", len(wantMedia), len(stats.TopPlayed))
+	}
+	for i, w := range wantMedia {
+		m := stats.TopPlayed[i]
+		if m.MediaPath != w.MediaPath ||
+			m.Plays != w.Plays ||
+			m.TotalTime != w.TotalTime ||
+			!m.LastPlayed.Equal(w.LastPlayed) {
+			t.Fatalf("expected: %v, got: %v", w, m)
+		}
+	}
+
+	wantSystems := []models.MediaStatsSystem{
+		{SystemId: "SNES", Plays: 4, TotalTime: 80},
+		{SystemId: "NES", Plays: 1, TotalTime: 50},
+	}
+	if len(stats.Systems) != len(wantSystems) {
+		t.Fatalf("expected: %d systems, got: %d", len(wantSystems), len(stats.Systems))
+	}
+	for i, w := range wantSystems {
+		if stats.Systems[i] != w {
+			t.Fatalf("expected: %v, got: %v", w, stats.Systems[i])
+		}
+	}
+
+	// each token reports the last media it launched
+	wantTokens := []models.MediaStatsToken{
+		{UID: "04aabbcc", SystemId: "SNES", MediaPath: "metroid.sfc", LastPlayed: sessionsStart.Add(4 * time.Minute)},
+		{UID: "04ddeeff", SystemId: "SNES", MediaPath: "metroid.sfc", LastPlayed: sessionsStart.Add(3 * time.Minute)},
+	}
+	if len(stats.Tokens) != len(wantTokens) {
+		t.Fatalf("expected: %d tokens, got: %d", len(wantTokens), len(stats.Tokens))
+	}
+	for i, w := range wantTokens {
+		if stats.Tokens[i] != w {
+			t.Fatalf("expected: %v, got: %v", w, stats.Tokens[i])
+		}
+	}
+
+	env.Params = []byte(`{"maxResults":1}`)
+	res, err = HandleMediaStats(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top := res.(models.MediaStatsResponse).TopPlayed; len(top) != 1 || top[0].MediaPath != "mario.sfc" {
+		t.Fatalf("expected only %q, got: %v", "mario.sfc", top)
+	}
+}
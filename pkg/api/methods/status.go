@@ -54,6 +54,7 @@ func newStatus(
 			Text:     active.Text,
 			Data:     active.Data,
 			ScanTime: active.ScanTime,
+			Verified: active.Verified,
 		},
 		LastToken: models.TokenResponse{
 			Type:     last.Type,
@@ -61,6 +62,7 @@ func newStatus(
 			Text:     last.Text,
 			Data:     last.Data,
 			ScanTime: last.ScanTime,
+			Verified: last.Verified,
 		},
 		GamesIndex: models.IndexResponse{
 			Exists:      IndexInstance.Exists(pl),
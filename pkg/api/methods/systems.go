@@ -30,8 +30,17 @@ func HandleSystems(env requests.RequestEnv) (any, error) {
 			continue
 		}
 
+		// folders and extensions are what's indexed on this platform, not
+		// the system defaults
+		merged := sys.WithOverrides(env.Config)
 		sr := models.System{
-			Id: sys.Id,
+			Id:         merged.Id,
+			Aliases:    merged.Aliases,
+			Parent:     merged.Parent,
+			MediaType:  merged.GetMediaType(),
+			Players:    merged.Players,
+			Folders:    gamesdb.SystemFolders(env.Config, env.Platform, id),
+			Extensions: gamesdb.SystemExtensions(env.Config, env.Platform, id),
 		}
 
 		sm, err := assets.GetSystemMetadata(id)
@@ -41,6 +50,8 @@ func HandleSystems(env requests.RequestEnv) (any, error) {
 
 		sr.Name = sm.Name
 		sr.Category = sm.Category
+		sr.ReleaseDate = sm.ReleaseDate
+		sr.Manufacturer = sm.Manufacturer
 
 		systems = append(systems, sr)
 	}
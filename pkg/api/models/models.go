@@ -15,6 +15,10 @@ const (
 	MethodStop           = "stop"
 	MethodMediaIndex     = "media.index"
 	MethodMediaSearch    = "media.search"
+	MethodMediaHistory   = "media.history"
+	MethodMediaStats     = "media.stats"
+	MethodMediaDatImport = "media.dat.import"
+	MethodMediaVerify    = "media.verify"
 	MethodSettings       = "settings"
 	MethodSettingsUpdate = "settings.update"
 	MethodClients        = "clients"
@@ -22,12 +26,19 @@ const (
 	MethodClientsDelete  = "clients.delete"
 	MethodSystems        = "systems"
 	MethodHistory        = "tokens.history"
+	MethodHistoryExport  = "tokens.history.export"
 	MethodMappings       = "mappings"
 	MethodMappingsNew    = "mappings.new"
 	MethodMappingsDelete = "mappings.delete"
 	MethodMappingsUpdate = "mappings.update"
 	MethodMappingsReload = "mappings.reload"
 	MethodReadersWrite   = "readers.write"
+	MethodReadersErase   = "readers.erase"
+	MethodReadersInspect = "readers.inspect"
+	MethodTokensQr       = "tokens.qr"
+	MethodSchedules      = "schedules.list"
+	MethodSchedulesNew   = "schedules.new"
+	MethodSchedulesDel   = "schedules.delete"
 	MethodStatus         = "status" // DEPRECATED
 	MethodVersion        = "version"
 )
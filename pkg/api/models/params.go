@@ -1,13 +1,62 @@
 package models
 
+import "time"
+
 type SearchParams struct {
 	Query      string    `json:"query"`
 	Systems    *[]string `json:"systems"`
 	MaxResults *int      `json:"maxResults"`
+	Regions    *[]string `json:"regions"`
+	Exclude    *[]string `json:"exclude"`
 }
 
 type MediaIndexParams struct {
 	Systems *[]string `json:"systems"`
+	Paths   *[]string `json:"paths"`
+	Full    *bool     `json:"full"`
+}
+
+// MediaDatImportParams imports either the contents of a DAT file, or a DAT
+// file by path. Paths must be in the dats folder of the data folder and may
+// be relative to it.
+type MediaDatImportParams struct {
+	System string `json:"system"`
+	Path   string `json:"path"`
+	Data   string `json:"data"`
+}
+
+type MediaVerifyParams struct {
+	Systems *[]string `json:"systems"`
+}
+
+type HistoryFilterParams struct {
+	From    *time.Time `json:"from"`
+	To      *time.Time `json:"to"`
+	UID     *string    `json:"uid"`
+	Success *bool      `json:"success"`
+	Source  *string    `json:"source"`
+	Type    *string    `json:"type"`
+}
+
+type HistoryParams struct {
+	HistoryFilterParams
+	Cursor     *uint64 `json:"cursor"`
+	MaxResults *int    `json:"maxResults"`
+}
+
+type HistoryExportParams struct {
+	HistoryFilterParams
+	Format string `json:"format"`
+}
+
+type MediaHistoryParams struct {
+	SystemId   *string `json:"systemId"`
+	UID        *string `json:"uid"`
+	MaxResults *int    `json:"maxResults"`
+}
+
+type MediaStatsParams struct {
+	MaxResults *int `json:"maxResults"`
 }
 
 type RunParams struct {
@@ -24,6 +73,8 @@ type AddMappingParams struct {
 	Match    string `json:"match"`
 	Pattern  string `json:"pattern"`
 	Override string `json:"override"`
+	// Only match tokens from verified secure tags.
+	VerifiedOnly bool `json:"verifiedOnly"`
 }
 
 type DeleteMappingParams struct {
@@ -38,10 +89,49 @@ type UpdateMappingParams struct {
 	Match    *string `json:"match"`
 	Pattern  *string `json:"pattern"`
 	Override *string `json:"override"`
+	// Only match tokens from verified secure tags.
+	VerifiedOnly *bool `json:"verifiedOnly"`
+}
+
+type AddScheduleParams struct {
+	Label     string `json:"label"`
+	Enabled   bool   `json:"enabled"`
+	Cron      string `json:"cron"`
+	Idle      int    `json:"idle"`
+	ZapScript string `json:"zapscript"`
+}
+
+type DeleteScheduleParams struct {
+	Id int `json:"id"`
+}
+
+type ReaderWriteRecord struct {
+	// One of: text, uri, json, zapscript, mime or external.
+	Kind string `json:"kind"`
+	// Text, URI or ZapScript to write for all kinds except mime and
+	// external.
+	Text string `json:"text"`
+	// Language code of a text record, defaults to "en".
+	Lang string `json:"lang"`
+	// MIME type or external type name.
+	Type string `json:"type"`
+	// Base64 encoded payload of a mime or external record.
+	Data string `json:"data"`
 }
 
 type ReaderWriteParams struct {
+	Text    string              `json:"text"`
+	Records []ReaderWriteRecord `json:"records"`
+	// Read the token back after writing, defaults to true.
+	Verify *bool `json:"verify"`
+	// Make the token permanently read-only after writing.
+	Lock bool `json:"lock"`
+}
+
+type TokensQrParams struct {
 	Text string `json:"text"`
+	// Size in pixels of each module of the code.
+	Scale *int `json:"scale"`
 }
 
 type UpdateSettingsParams struct {
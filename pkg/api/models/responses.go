@@ -4,10 +4,19 @@ import (
 	"time"
 )
 
+type MediaTags struct {
+	Regions   []string `json:"regions"`
+	Languages []string `json:"languages"`
+	Revision  string   `json:"revision,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	Flags     []string `json:"flags"`
+}
+
 type SearchResultMedia struct {
-	System System `json:"system"`
-	Name   string `json:"name"`
-	Path   string `json:"path"`
+	System System    `json:"system"`
+	Name   string    `json:"name"`
+	Path   string    `json:"path"`
+	Tags   MediaTags `json:"tags"`
 }
 
 type SearchResults struct {
@@ -15,6 +24,27 @@ type SearchResults struct {
 	Total   int                 `json:"total"`
 }
 
+type MediaDatImportResponse struct {
+	Source  string `json:"source"`
+	Games   int    `json:"games"`
+	Roms    int    `json:"roms"`
+	Matched int    `json:"matched"`
+}
+
+type MediaVerifySystem struct {
+	System     System   `json:"system"`
+	Dats       []string `json:"dats"`
+	Total      int      `json:"total"`
+	Verified   int      `json:"verified"`
+	Unverified int      `json:"unverified"`
+	Unknown    []string `json:"unknown"`
+	Bad        []string `json:"bad"`
+}
+
+type MediaVerifyResponse struct {
+	Systems []MediaVerifySystem `json:"systems"`
+}
+
 type IndexStatusResponse struct {
 	Exists      bool   `json:"exists"`
 	Indexing    bool   `json:"indexing"`
@@ -24,6 +54,51 @@ type IndexStatusResponse struct {
 	TotalFiles  int    `json:"totalFiles"`
 }
 
+type MediaSessionResponse struct {
+	SystemId   string     `json:"systemId"`
+	SystemName string     `json:"systemName"`
+	MediaPath  string     `json:"mediaPath"`
+	MediaName  string     `json:"mediaName"`
+	Start      time.Time  `json:"start"`
+	End        *time.Time `json:"end"`
+	Duration   int        `json:"duration"`
+	TokenUID   string     `json:"tokenUid"`
+}
+
+type MediaHistoryResponse struct {
+	Sessions []MediaSessionResponse `json:"sessions"`
+}
+
+type MediaStatsMedia struct {
+	SystemId   string    `json:"systemId"`
+	MediaPath  string    `json:"mediaPath"`
+	MediaName  string    `json:"mediaName"`
+	Plays      int       `json:"plays"`
+	TotalTime  int       `json:"totalTime"`
+	LastPlayed time.Time `json:"lastPlayed"`
+}
+
+type MediaStatsSystem struct {
+	SystemId   string `json:"systemId"`
+	SystemName string `json:"systemName"`
+	Plays      int    `json:"plays"`
+	TotalTime  int    `json:"totalTime"`
+}
+
+type MediaStatsToken struct {
+	UID        string    `json:"uid"`
+	SystemId   string    `json:"systemId"`
+	MediaPath  string    `json:"mediaPath"`
+	MediaName  string    `json:"mediaName"`
+	LastPlayed time.Time `json:"lastPlayed"`
+}
+
+type MediaStatsResponse struct {
+	TopPlayed []MediaStatsMedia  `json:"topPlayed"`
+	Systems   []MediaStatsSystem `json:"systems"`
+	Tokens    []MediaStatsToken  `json:"tokens"`
+}
+
 type SettingsResponse struct {
 	RunZapScript            bool     `json:"runZapScript"`
 	DebugLogging            bool     `json:"debugLogging"`
@@ -35,9 +110,17 @@ type SettingsResponse struct {
 }
 
 type System struct {
-	Id       string `json:"id"`
-	Name     string `json:"name"`
-	Category string `json:"category"`
+	Id           string   `json:"id"`
+	Name         string   `json:"name"`
+	Category     string   `json:"category"`
+	ReleaseDate  string   `json:"releaseDate,omitempty"`
+	Manufacturer string   `json:"manufacturer,omitempty"`
+	Aliases      []string `json:"aliases,omitempty"`
+	Parent       string   `json:"parent,omitempty"`
+	MediaType    string   `json:"mediaType,omitempty"`
+	Players      int      `json:"players,omitempty"`
+	Folders      []string `json:"folders,omitempty"`
+	Extensions   []string `json:"extensions,omitempty"`
 }
 
 type SystemsResponse struct {
@@ -45,16 +128,32 @@ type SystemsResponse struct {
 }
 
 type HistoryReponseEntry struct {
-	Time    time.Time `json:"time"`
-	Type    string    `json:"type"`
-	UID     string    `json:"uid"`
-	Text    string    `json:"text"`
-	Data    string    `json:"data"`
-	Success bool      `json:"success"`
+	Id        uint64    `json:"id"`
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	UID       string    `json:"uid"`
+	Text      string    `json:"text"`
+	Data      string    `json:"data"`
+	Source    string    `json:"source"`
+	Device    string    `json:"device"`
+	Origin    string    `json:"origin"`
+	Remote    bool      `json:"remote"`
+	Mapping   string    `json:"mapping"`
+	ZapScript string    `json:"zapscript"`
+	SystemId  string    `json:"systemId"`
+	MediaPath string    `json:"mediaPath"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error"`
 }
 
 type HistoryResponse struct {
-	Entries []HistoryReponseEntry `json:"entries"`
+	Entries    []HistoryReponseEntry `json:"entries"`
+	NextCursor *uint64               `json:"nextCursor"`
+}
+
+type HistoryExportResponse struct {
+	Format string `json:"format"`
+	Data   string `json:"data"`
 }
 
 type AllMappingsResponse struct {
@@ -70,6 +169,26 @@ type MappingResponse struct {
 	Match    string `json:"match"`
 	Pattern  string `json:"pattern"`
 	Override string `json:"override"`
+	// Only match tokens from verified secure tags.
+	VerifiedOnly bool `json:"verifiedOnly"`
+}
+
+type AllSchedulesResponse struct {
+	Schedules []ScheduleResponse `json:"schedules"`
+}
+
+type ScheduleResponse struct {
+	Id        string `json:"id"`
+	Added     string `json:"added"`
+	Label     string `json:"label"`
+	Enabled   bool   `json:"enabled"`
+	Cron      string `json:"cron"`
+	Idle      int    `json:"idle"`
+	ZapScript string `json:"zapscript"`
+}
+
+type NewScheduleResponse struct {
+	Id string `json:"id"`
 }
 
 type TokenResponse struct {
@@ -78,6 +197,7 @@ type TokenResponse struct {
 	Text     string    `json:"text"`
 	Data     string    `json:"data"`
 	ScanTime time.Time `json:"scanTime"`
+	Verified bool      `json:"verified"`
 }
 
 type IndexResponse struct {
@@ -102,6 +222,20 @@ type ReaderResponse struct {
 	Info      string `json:"info"`
 }
 
+type ReaderInspectResponse struct {
+	UID      string `json:"uid"`
+	Type     string `json:"type"`
+	Capacity int    `json:"capacity"`
+	Used     int    `json:"used"`
+	ReadOnly bool   `json:"readOnly"`
+}
+
+type TokensQrResponse struct {
+	Format string `json:"format"`
+	// Base64 encoded image.
+	Data string `json:"data"`
+}
+
 type PlayingResponse struct {
 	System     string `json:"system"`
 	SystemName string `json:"systemName"`
@@ -37,23 +37,36 @@ var methodMap = map[string]func(requests.RequestEnv) (any, error){
 	models.MethodRun:    methods.HandleRun,
 	models.MethodStop:   methods.HandleStop,
 	// media
-	models.MethodMediaIndex:  methods.HandleIndexMedia,
-	models.MethodMediaSearch: methods.HandleGames,
+	models.MethodMediaIndex:     methods.HandleIndexMedia,
+	models.MethodMediaSearch:    methods.HandleGames,
+	models.MethodMediaHistory:   methods.HandleMediaHistory,
+	models.MethodMediaStats:     methods.HandleMediaStats,
+	models.MethodMediaDatImport: methods.HandleImportDat,
+	models.MethodMediaVerify:    methods.HandleMediaVerify,
 	// settings
 	models.MethodSettings:       methods.HandleSettings,
 	models.MethodSettingsUpdate: methods.HandleSettingsUpdate,
 	// systems
 	models.MethodSystems: methods.HandleSystems,
 	// history
-	models.MethodHistory: methods.HandleHistory,
+	models.MethodHistory:       methods.HandleHistory,
+	models.MethodHistoryExport: methods.HandleHistoryExport,
 	// mappings
 	models.MethodMappings:       methods.HandleMappings,
 	models.MethodMappingsNew:    methods.HandleAddMapping,
 	models.MethodMappingsDelete: methods.HandleDeleteMapping,
 	models.MethodMappingsUpdate: methods.HandleUpdateMapping,
 	models.MethodMappingsReload: methods.HandleReloadMappings,
+	// schedules
+	models.MethodSchedules:    methods.HandleSchedules,
+	models.MethodSchedulesNew: methods.HandleAddSchedule,
+	models.MethodSchedulesDel: methods.HandleDeleteSchedule,
 	// readers
-	models.MethodReadersWrite: methods.HandleReaderWrite,
+	models.MethodReadersWrite:   methods.HandleReaderWrite,
+	models.MethodReadersErase:   methods.HandleReaderErase,
+	models.MethodReadersInspect: methods.HandleReaderInspect,
+	// tokens
+	models.MethodTokensQr: methods.HandleTokensQr,
 	// utils
 	models.MethodStatus:  methods.HandleStatus, // TODO: remove, convert to individual methods
 	models.MethodVersion: methods.HandleVersion,
@@ -20,6 +20,16 @@ var FailSound []byte
 //go:embed systems/*
 var Systems embed.FS
 
+// Toys is the databases used to name toys-to-life figures. The Amiibo
+// database is from AmiiboAPI (https://github.com/N3evin/AmiiboAPI) and the
+// LEGO Dimensions database is from LD-ToyPad-Emulator
+// (https://github.com/Berny23/LD-ToyPad-Emulator), both MIT licence.
+// Regenerate them with go generate.
+//
+//go:generate go run ../../scripts/toys -out toys
+//go:embed toys/*
+var Toys embed.FS
+
 type SystemMetadata struct {
 	Id           string `json:"id"`
 	Name         string `json:"name"`
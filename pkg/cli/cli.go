@@ -8,6 +8,7 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/qr"
 	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/google/uuid"
 	"github.com/mdp/qrterminal/v3"
@@ -30,6 +31,8 @@ type Flags struct {
 	NewClient    *string
 	DeleteClient *string
 	Qr           *bool
+	QrCode       *string
+	QrOut        *string
 	Version      *bool
 }
 
@@ -81,6 +84,16 @@ func SetupFlags() *Flags {
 		//	false,
 		//	"output a connection QR code along with client details",
 		//),
+		QrCode: flag.String(
+			"qr-code",
+			"",
+			"print a QR code containing value, to be scanned by a QR reader",
+		),
+		QrOut: flag.String(
+			"qr-out",
+			"",
+			"save the QR code from -qr-code as a PNG image to this path",
+		),
 		Version: flag.Bool(
 			"version",
 			false,
@@ -98,6 +111,28 @@ func (f *Flags) Pre(pl platforms.Platform) {
 		fmt.Printf("Zaparoo v%s (%s)\n", config.AppVersion, pl.Id())
 		os.Exit(0)
 	}
+
+	if *f.QrCode != "" {
+		if *f.QrOut == "" {
+			qrterminal.Generate(*f.QrCode, qrterminal.M, os.Stdout)
+			os.Exit(0)
+		}
+
+		data, err := qr.Generate(*f.QrCode, qr.DefaultScale)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error generating QR code: %v\n", err)
+			os.Exit(1)
+		}
+
+		err = os.WriteFile(*f.QrOut, data, 0644)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error saving QR code: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("QR code saved to: %s\n", *f.QrOut)
+		os.Exit(0)
+	}
 }
 
 type ConnQr struct {
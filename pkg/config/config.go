@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/hex"
 	"errors"
 	"github.com/google/uuid"
 	"github.com/pelletier/go-toml/v2"
@@ -9,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
 	"sync"
 )
 
@@ -30,6 +32,9 @@ type Values struct {
 	ZapScript    ZapScript `toml:"zapscript,omitempty"`
 	Service      Service   `toml:"service,omitempty"`
 	Mappings     Mappings  `toml:"mappings,omitempty"`
+	Schedules    Schedules `toml:"schedules,omitempty"`
+	History      History   `toml:"history,omitempty"`
+	Media        Media     `toml:"media,omitempty"`
 }
 
 type Audio struct {
@@ -40,12 +45,28 @@ type Readers struct {
 	AutoDetect bool             `toml:"auto_detect"`
 	Scan       ReadersScan      `toml:"scan,omitempty"`
 	Connect    []ReadersConnect `toml:"connect,omitempty"`
+	// Extra MIFARE Classic keys to try, as 12 character hex strings.
+	MifareKeys []string `toml:"mifare_keys,omitempty"`
+	// Trust secure tags which don't mirror their read counter, or are read
+	// without their UID. Their messages can be copied and replayed.
+	SdmAllowReplay bool         `toml:"sdm_allow_replay,omitempty"`
+	Sdm            []ReadersSdm `toml:"sdm,omitempty"`
+}
+
+// ReadersSdm is a pair of NTAG 424 DNA SDM keys used to verify secure tags,
+// as 32 character hex strings.
+type ReadersSdm struct {
+	// Only needed if the tags encrypt their UID and read counter.
+	MetaReadKey string `toml:"meta_read_key,omitempty"`
+	FileReadKey string `toml:"file_read_key"`
 }
 
 type ReadersScan struct {
 	Mode         string   `toml:"mode"`
 	ExitDelay    float32  `toml:"exit_delay,omitempty"`
 	IgnoreSystem []string `toml:"ignore_system,omitempty"`
+	// Only launch tokens from verified secure tags.
+	VerifiedOnly bool `toml:"verified_only,omitempty"`
 }
 
 type ReadersConnect struct {
@@ -54,7 +75,8 @@ type ReadersConnect struct {
 }
 
 type Systems struct {
-	Default []SystemsDefault `toml:"default,omitempty"`
+	Default  []SystemsDefault  `toml:"default,omitempty"`
+	Override []SystemsOverride `toml:"override,omitempty"`
 }
 
 type SystemsDefault struct {
@@ -62,12 +84,39 @@ type SystemsDefault struct {
 	Launcher string `toml:"launcher,omitempty"`
 }
 
+// SystemsOverride adds to the built-in definition of a system.
+type SystemsOverride struct {
+	System     string   `toml:"system"`
+	Aliases    []string `toml:"aliases,omitempty"`
+	Folders    []string `toml:"folders,omitempty"`
+	Extensions []string `toml:"extensions,omitempty"`
+}
+
 type Launchers struct {
-	IndexRoot   []string `toml:"index_root,omitempty,multiline"`
-	AllowFile   []string `toml:"allow_file,omitempty,multiline"`
+	IndexRoot   []string          `toml:"index_root,omitempty,multiline"`
+	AllowFile   []string          `toml:"allow_file,omitempty,multiline"`
+	Custom      []LaunchersCustom `toml:"custom,omitempty"`
 	allowFileRe []*regexp.Regexp
 }
 
+// LaunchersCustom is a user-defined launcher which runs a command to launch
+// media. Commands can use the placeholders {path}, {dir}, {file}, {name},
+// {ext} and {system}.
+type LaunchersCustom struct {
+	Id         string   `toml:"id"`
+	System     string   `toml:"system,omitempty"`
+	Folders    []string `toml:"folders,omitempty"`
+	Extensions []string `toml:"extensions,omitempty"`
+	Schemes    []string `toml:"schemes,omitempty"`
+	Launch     string   `toml:"launch"`
+	Kill       string   `toml:"kill,omitempty"`
+	// Only launch files in the allow_file list. Always enabled for
+	// launchers with extensions but no folders. Otherwise, launchers launch
+	// files inside their folders and paths matching their schemes, and
+	// anything else must be in the allow_file list.
+	AllowListOnly bool `toml:"allow_list_only,omitempty"`
+}
+
 type ZapScript struct {
 	AllowExecute   []string `toml:"allow_execute,omitempty,multiline"`
 	allowExecuteRe []*regexp.Regexp
@@ -84,12 +133,50 @@ type MappingsEntry struct {
 	TokenKey     string `toml:"token_key,omitempty"`
 	MatchPattern string `toml:"match_pattern"`
 	ZapScript    string `toml:"zapscript"`
+	// Only match tokens from verified secure tags.
+	VerifiedOnly bool `toml:"verified_only,omitempty"`
 }
 
 type Mappings struct {
 	Entry []MappingsEntry `toml:"entry,omitempty"`
 }
 
+type SchedulesEntry struct {
+	Label string `toml:"label,omitempty"`
+	// Standard 5 field cron expression.
+	Cron string `toml:"cron,omitempty"`
+	// Minutes without a token scan before running.
+	Idle      int    `toml:"idle,omitempty"`
+	ZapScript string `toml:"zapscript"`
+}
+
+type Schedules struct {
+	Entry []SchedulesEntry `toml:"entry,omitempty"`
+}
+
+type History struct {
+	// Days to keep history entries for, 0 keeps them forever.
+	MaxAge int `toml:"max_age,omitempty"`
+	// Max number of history entries to keep, 0 is unlimited.
+	MaxEntries int `toml:"max_entries,omitempty"`
+}
+
+type Media struct {
+	// Watch media folders for changes and update the index automatically.
+	Watch bool `toml:"watch,omitempty"`
+	// Regions to prefer when a search matches multiple versions of the same
+	// media, most preferred first.
+	PreferRegion []string `toml:"prefer_region,omitempty"`
+	// Language codes to prefer, most preferred first.
+	PreferLanguage []string `toml:"prefer_language,omitempty"`
+	// Prefer verified good dumps.
+	PreferVerified bool `toml:"prefer_verified,omitempty"`
+	// Flags to avoid unless there's no other match, e.g. unlicensed or beta.
+	Avoid []string `toml:"avoid,omitempty"`
+	// Content hashes to calculate while indexing: crc32, md5 or sha1.
+	Hash []string `toml:"hash,omitempty"`
+}
+
 var BaseDefaults = Values{
 	ConfigSchema: SchemaVersion,
 	Audio: Audio{
@@ -325,6 +412,37 @@ func (c *Instance) Readers() Readers {
 	return c.vals.Readers
 }
 
+// MifareKeys returns the extra MIFARE Classic keys to try when reading
+// cards. Invalid keys are logged and skipped.
+func (c *Instance) MifareKeys() [][]byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var keys [][]byte
+	for _, k := range c.vals.Readers.MifareKeys {
+		key, err := hex.DecodeString(strings.ReplaceAll(k, ":", ""))
+		if err != nil || len(key) != 6 {
+			log.Warn().Msgf("invalid mifare key: %s", k)
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func (c *Instance) SdmKeys() []ReadersSdm {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Readers.Sdm
+}
+
+func (c *Instance) SdmAllowReplay() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Readers.SdmAllowReplay
+}
+
 func (c *Instance) SetAutoConnect(enabled bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -343,6 +461,24 @@ func (c *Instance) SystemDefaults() []SystemsDefault {
 	return c.vals.Systems.Default
 }
 
+// SystemOverride returns the extra folders and extensions configured for a
+// system, merged from all its override entries.
+func (c *Instance) SystemOverride(systemId string) SystemsOverride {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	merged := SystemsOverride{System: systemId}
+	for _, o := range c.vals.Systems.Override {
+		if strings.EqualFold(o.System, systemId) {
+			merged.Aliases = append(merged.Aliases, o.Aliases...)
+			merged.Folders = append(merged.Folders, o.Folders...)
+			merged.Extensions = append(merged.Extensions, o.Extensions...)
+		}
+	}
+
+	return merged
+}
+
 func (c *Instance) IndexRoots() []string {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -364,6 +500,12 @@ func checkAllow(allow []string, allowRe []*regexp.Regexp, s string) bool {
 	return false
 }
 
+func (c *Instance) CustomLaunchers() []LaunchersCustom {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Launchers.Custom
+}
+
 func (c *Instance) IsLauncherFileAllowed(s string) bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -444,3 +586,33 @@ func (c *Instance) IsRunAllowed(s string) bool {
 	defer c.mu.RUnlock()
 	return checkAllow(c.vals.Service.AllowRun, c.vals.Service.allowRunRe, s)
 }
+
+func (c *Instance) Schedules() []SchedulesEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Schedules.Entry
+}
+
+func (c *Instance) HistoryRetention() History {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.History
+}
+
+func (c *Instance) MediaWatch() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Media.Watch
+}
+
+func (c *Instance) MediaPreferences() Media {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Media
+}
+
+func (c *Instance) MediaHashes() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.vals.Media.Hash
+}
@@ -0,0 +1,427 @@
+package gamesdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DatVerified means the file's hash matches a known good dump.
+	DatVerified = "verified"
+	// DatNameOnly means the file's name matches a DAT entry, but there was
+	// no hash to check it with.
+	DatNameOnly = "name"
+	// DatBad means the file matches a known bad dump, or its name matches a
+	// DAT entry but its hash doesn't.
+	DatBad = "bad"
+	// DatUnknown means the file isn't in the DAT.
+	DatUnknown = "unknown"
+)
+
+// DatMatch is the DAT entry matched to a media file.
+type DatMatch struct {
+	// Canonical title of the game from the DAT.
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	// Name of the DAT the match came from.
+	Source string `json:"source,omitempty"`
+}
+
+// DatEntry is a single ROM from an imported DAT file.
+type DatEntry struct {
+	Title  string `json:"title"`
+	Rom    string `json:"rom"`
+	Size   int64  `json:"size,omitempty"`
+	CRC32  string `json:"crc32,omitempty"`
+	MD5    string `json:"md5,omitempty"`
+	SHA1   string `json:"sha1,omitempty"`
+	Status string `json:"status,omitempty"`
+	Source string `json:"source,omitempty"`
+}
+
+func (e DatEntry) hash(algo string) string {
+	switch algo {
+	case HashCRC32:
+		return e.CRC32
+	case HashMD5:
+		return e.MD5
+	case HashSHA1:
+		return e.SHA1
+	default:
+		return ""
+	}
+}
+
+// Logiqx XML DAT format, as used by No-Intro, Redump and MAME.
+type datFile struct {
+	Header struct {
+		Name        string `xml:"name"`
+		Description string `xml:"description"`
+		Version     string `xml:"version"`
+	} `xml:"header"`
+	Games    []datGame `xml:"game"`
+	Machines []datGame `xml:"machine"`
+}
+
+type datGame struct {
+	Name        string   `xml:"name,attr"`
+	Description string   `xml:"description"`
+	Roms        []datRom `xml:"rom"`
+}
+
+type datRom struct {
+	Name   string `xml:"name,attr"`
+	Size   int64  `xml:"size,attr"`
+	CRC    string `xml:"crc,attr"`
+	MD5    string `xml:"md5,attr"`
+	SHA1   string `xml:"sha1,attr"`
+	Status string `xml:"status,attr"`
+}
+
+// DatImport is a summary of an imported DAT file.
+type DatImport struct {
+	Source  string
+	Games   int
+	Roms    int
+	Matched int
+}
+
+// Parse a Logiqx XML DAT file into its ROM entries.
+func parseDat(r io.Reader) (string, []DatEntry, error) {
+	var df datFile
+	err := xml.NewDecoder(r).Decode(&df)
+	if err != nil {
+		return "", nil, fmt.Errorf("error parsing dat: %w", err)
+	}
+
+	source := df.Header.Name
+	if source == "" {
+		source = df.Header.Description
+	}
+	if df.Header.Version != "" {
+		source += " (" + df.Header.Version + ")"
+	}
+
+	var entries []DatEntry
+	for _, g := range append(df.Games, df.Machines...) {
+		title := g.Name
+		if title == "" {
+			title = g.Description
+		}
+
+		for _, rom := range g.Roms {
+			entries = append(entries, DatEntry{
+				Title:  title,
+				Rom:    rom.Name,
+				Size:   rom.Size,
+				CRC32:  strings.ToLower(rom.CRC),
+				MD5:    strings.ToLower(rom.MD5),
+				SHA1:   strings.ToLower(rom.SHA1),
+				Status: rom.Status,
+				Source: source,
+			})
+		}
+	}
+
+	if len(entries) == 0 {
+		return "", nil, fmt.Errorf("no roms found in dat")
+	}
+
+	return source, entries, nil
+}
+
+func datHashKey(systemId string, algo string, hash string) []byte {
+	return []byte(systemId + ":hash:" + algo + ":" + strings.ToLower(hash))
+}
+
+func datNameKey(systemId string, name string) []byte {
+	return []byte(systemId + ":name:" + strings.ToLower(name))
+}
+
+// Strip the folder and extension from a file name.
+func datBaseName(name string) string {
+	name = filepath.Base(filepath.FromSlash(name))
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func getDatEntry(tx *bolt.Tx, key []byte) (*DatEntry, error) {
+	v := tx.Bucket([]byte(BucketDat)).Get(key)
+	if v == nil {
+		return nil, nil
+	}
+
+	var e DatEntry
+	err := json.Unmarshal(v, &e)
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Replace all DAT entries for a system.
+func putDatEntries(tx *bolt.Tx, systemId string, entries []DatEntry) error {
+	b := tx.Bucket([]byte(BucketDat))
+
+	var keys [][]byte
+	pre := systemPrefix(systemId)
+	c := b.Cursor()
+	for k, _ := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, _ = c.Next() {
+		keys = append(keys, append([]byte{}, k...))
+	}
+	for _, k := range keys {
+		err := b.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		for _, algo := range []string{HashCRC32, HashMD5, HashSHA1} {
+			if h := e.hash(algo); h != "" {
+				err := b.Put(datHashKey(systemId, algo, h), data)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// files are usually named after the rom, but may be renamed to
+		// the game name when the rom has a different extension
+		for _, name := range []string{datBaseName(e.Rom), e.Title} {
+			err := b.Put(datNameKey(systemId, name), data)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Find the DAT entry for a media record. Hashes are checked first, strongest
+// first, and then the file name. Returns nil if the file isn't in the DAT.
+func matchDat(tx *bolt.Tx, r MediaRecord) (*DatMatch, error) {
+	if r.Hashes != nil {
+		for _, algo := range []string{HashSHA1, HashMD5, HashCRC32} {
+			h := r.Hashes.get(algo)
+			if h == "" {
+				continue
+			}
+
+			e, err := getDatEntry(tx, datHashKey(r.SystemId, algo, h))
+			if err != nil {
+				return nil, err
+			} else if e == nil {
+				continue
+			}
+
+			status := DatVerified
+			if e.Status == "baddump" {
+				status = DatBad
+			}
+
+			return &DatMatch{Title: e.Title, Status: status, Source: e.Source}, nil
+		}
+	}
+
+	e, err := getDatEntry(tx, datNameKey(r.SystemId, r.Name))
+	if err != nil || e == nil {
+		return nil, err
+	}
+
+	status := DatNameOnly
+	if e.Status == "baddump" {
+		status = DatBad
+	} else if r.Hashes != nil {
+		for _, algo := range []string{HashSHA1, HashMD5, HashCRC32} {
+			if h := r.Hashes.get(algo); h != "" && e.hash(algo) != "" {
+				// the hash lookup above would have found it if it matched
+				status = DatBad
+				break
+			}
+		}
+	}
+
+	return &DatMatch{Title: e.Title, Status: status, Source: e.Source}, nil
+}
+
+// ImportDat imports a Logiqx XML DAT file (No-Intro, Redump, etc.) for a
+// system, replacing any DAT previously imported for it. Indexed media for
+// the system is matched against the DAT straight away, and media indexed
+// later is matched as it's added.
+func ImportDat(platform platforms.Platform, systemId string, r io.Reader) (DatImport, error) {
+	var result DatImport
+
+	system, err := GetSystem(systemId)
+	if err != nil {
+		return result, err
+	}
+
+	source, entries, err := parseDat(r)
+	if err != nil {
+		return result, err
+	}
+
+	games := make(map[string]struct{})
+	for _, e := range entries {
+		games[e.Title] = struct{}{}
+	}
+	result.Source = source
+	result.Games = len(games)
+	result.Roms = len(entries)
+
+	db, err := openForGenerate(platform)
+	if err != nil {
+		return result, err
+	}
+	defer func(db *bolt.DB) {
+		err := db.Close()
+		if err != nil {
+			log.Warn().Err(err).Msg("closing database")
+		}
+	}(db)
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := putDatEntries(tx, system.Id, entries)
+		if err != nil {
+			return err
+		}
+
+		var records []MediaRecord
+		err = eachSystemMedia(tx, system.Id, func(r MediaRecord) (bool, error) {
+			records = append(records, r)
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, r := range records {
+			r.Dat, err = matchDat(tx, r)
+			if err != nil {
+				return err
+			}
+			if r.Dat != nil {
+				result.Matched++
+			}
+
+			err = putMedia(tx, r)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// VerifyReport is the DAT verification status of a system's media.
+type VerifyReport struct {
+	SystemId string
+	// Names of the DATs imported for the system.
+	Sources    []string
+	Total      int
+	Verified   int
+	Unverified int
+	Unknown    []string
+	Bad        []string
+}
+
+// VerifySystem returns the DAT verification status of all indexed media for
+// a system, listing the paths of unknown and bad dumps.
+func VerifySystem(platform platforms.Platform, systemId string) (VerifyReport, error) {
+	report := VerifyReport{SystemId: systemId}
+
+	if !Exists(platform) {
+		return report, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(platform, &bolt.Options{})
+	if err != nil {
+		return report, err
+	}
+	defer func(db *bolt.DB) {
+		err := db.Close()
+		if err != nil {
+			log.Warn().Err(err).Msg("closing database")
+		}
+	}(db)
+
+	sources := make(map[string]struct{})
+
+	err = db.View(func(tx *bolt.Tx) error {
+		return eachSystemMedia(tx, systemId, func(r MediaRecord) (bool, error) {
+			report.Total++
+
+			status := DatUnknown
+			if r.Dat != nil {
+				status = r.Dat.Status
+				if _, ok := sources[r.Dat.Source]; !ok && r.Dat.Source != "" {
+					sources[r.Dat.Source] = struct{}{}
+					report.Sources = append(report.Sources, r.Dat.Source)
+				}
+			}
+
+			switch status {
+			case DatVerified:
+				report.Verified++
+			case DatNameOnly:
+				report.Unverified++
+			case DatBad:
+				report.Bad = append(report.Bad, r.Path)
+			default:
+				report.Unknown = append(report.Unknown, r.Path)
+			}
+
+			return true, nil
+		})
+	})
+
+	return report, err
+}
+
+// GetMedia returns the indexed media record for a file, or nil if the file
+// isn't indexed.
+func GetMedia(platform platforms.Platform, systemId string, path string) (*MediaRecord, error) {
+	if !Exists(platform) {
+		return nil, fmt.Errorf("gamesdb does not exist")
+	}
+
+	// don't hold up callers while the index is being written
+	db, err := open(platform, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	defer func(db *bolt.DB) {
+		err := db.Close()
+		if err != nil {
+			log.Warn().Err(err).Msg("closing database")
+		}
+	}(db)
+
+	var r *MediaRecord
+	err = db.View(func(tx *bolt.Tx) error {
+		r, err = getMedia(tx, systemId, path)
+		return err
+	})
+
+	return r, err
+}
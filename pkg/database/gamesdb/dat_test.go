@@ -0,0 +1,280 @@
+package gamesdb
+
+import (
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	bolt "go.etcd.io/bbolt"
+)
+
+const testFoxData = "The quick brown fox jumps over the lazy dog"
+
+var testDat = fmt.Sprintf(`<?xml version="1.0"?>
+<!DOCTYPE datafile PUBLIC "-//Logiqx//DTD ROM Management Datafile//EN" "http://www.logiqx.com/Dats/datafile.dtd">
+<datafile>
+	<header>
+		<name>Nintendo - Nintendo Entertainment System</name>
+		<version>20240101-000000</version>
+	</header>
+	<game name="Fox (USA)">
+		<description>Fox (USA)</description>
+		<rom name="Fox (USA).nes" size="43" crc="%s" md5="%s" sha1="%s"/>
+	</game>
+	<game name="Renamed (USA)">
+		<description>Renamed (USA)</description>
+		<rom name="Renamed (USA).unf" size="4" crc="DEADBEEF"/>
+	</game>
+	<game name="Broken (USA)">
+		<description>Broken (USA)</description>
+		<rom name="Broken (USA).nes" size="6" crc="%08X" status="baddump"/>
+	</game>
+	<game name="Sequel (USA)">
+		<rom name="Sequel (USA).nes" size="6" crc="%08x"/>
+	</game>
+</datafile>
+`,
+	strings.ToUpper(foxHashes.CRC32), foxHashes.MD5, foxHashes.SHA1,
+	crc32.ChecksumIEEE([]byte("broken")),
+	crc32.ChecksumIEEE([]byte("sequel")),
+)
+
+func TestParseDat(t *testing.T) {
+	source, entries, err := parseDat(strings.NewReader(testDat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if source != "Nintendo - Nintendo Entertainment System (20240101-000000)" {
+		t.Fatalf("unexpected source: %s", source)
+	} else if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got: %v", entries)
+	}
+
+	want := DatEntry{
+		Title:  "Fox (USA)",
+		Rom:    "Fox (USA).nes",
+		Size:   43,
+		CRC32:  foxHashes.CRC32,
+		MD5:    foxHashes.MD5,
+		SHA1:   foxHashes.SHA1,
+		Source: source,
+	}
+	if entries[0] != want {
+		t.Fatalf("expected: %+v, got: %+v", want, entries[0])
+	} else if entries[2].Status != "baddump" {
+		t.Fatalf("expected bad dump status, got: %+v", entries[2])
+	}
+
+	// mame dats use machine elements and no version
+	source, entries, err = parseDat(strings.NewReader(`<datafile>
+		<header><description>MAME</description></header>
+		<machine name="pacman"><rom name="pacman.6e" crc="c1e6ab10"/></machine>
+	</datafile>`))
+	if err != nil {
+		t.Fatal(err)
+	} else if source != "MAME" || len(entries) != 1 || entries[0].Title != "pacman" {
+		t.Fatalf("unexpected mame dat: %s, %+v", source, entries)
+	}
+
+	for _, dat := range []string{"", "not xml", "<datafile><header/></datafile>"} {
+		if _, _, err := parseDat(strings.NewReader(dat)); err == nil {
+			t.Fatalf("%q: expected error", dat)
+		}
+	}
+}
+
+func TestMatchDat(t *testing.T) {
+	pl := newTestPlatform(t)
+	db, err := open(pl, &bolt.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	_, entries, err := parseDat(strings.NewReader(testDat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		record MediaRecord
+		want   *DatMatch
+	}{
+		{
+			name:   "hash match with any name",
+			record: MediaRecord{Name: "fox", Hashes: &MediaHashes{SHA1: foxHashes.SHA1}},
+			want:   &DatMatch{Title: "Fox (USA)", Status: DatVerified},
+		},
+		{
+			name:   "crc match",
+			record: MediaRecord{Name: "fox", Hashes: &MediaHashes{CRC32: foxHashes.CRC32}},
+			want:   &DatMatch{Title: "Fox (USA)", Status: DatVerified},
+		},
+		{
+			name:   "name without hashes",
+			record: MediaRecord{Name: "Fox (USA)"},
+			want:   &DatMatch{Title: "Fox (USA)", Status: DatNameOnly},
+		},
+		{
+			name:   "name with different extension",
+			record: MediaRecord{Name: "renamed (usa)"},
+			want:   &DatMatch{Title: "Renamed (USA)", Status: DatNameOnly},
+		},
+		{
+			name:   "name with wrong hash",
+			record: MediaRecord{Name: "Renamed (USA)", Hashes: &MediaHashes{CRC32: "00000000"}},
+			want:   &DatMatch{Title: "Renamed (USA)", Status: DatBad},
+		},
+		{
+			name:   "name with hash the dat doesn't have",
+			record: MediaRecord{Name: "Renamed (USA)", Hashes: &MediaHashes{MD5: strings.Repeat("0", 32)}},
+			want:   &DatMatch{Title: "Renamed (USA)", Status: DatNameOnly},
+		},
+		{
+			name: "known bad dump",
+			record: MediaRecord{
+				Name:   "whatever",
+				Hashes: &MediaHashes{CRC32: fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte("broken")))},
+			},
+			want: &DatMatch{Title: "Broken (USA)", Status: DatBad},
+		},
+		{
+			name:   "unknown",
+			record: MediaRecord{Name: "Unknown (USA)", Hashes: &MediaHashes{CRC32: "00000000"}},
+		},
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		err := putDatEntries(tx, "NES", entries)
+		if err != nil {
+			return err
+		}
+
+		for _, tc := range tests {
+			tc.record.SystemId = "NES"
+			got, err := matchDat(tx, tc.record)
+			if err != nil {
+				return err
+			}
+
+			if got != nil {
+				got.Source = ""
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("%s: expected: %+v, got: %+v", tc.name, tc.want, got)
+			}
+		}
+
+		// entries are per system
+		got, err := matchDat(tx, MediaRecord{SystemId: "SNES", Name: "Fox (USA)"})
+		if err != nil {
+			return err
+		} else if got != nil {
+			t.Fatalf("expected no match for other system, got: %+v", got)
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportDatVerify(t *testing.T) {
+	pl := newTestPlatform(t, testNesLauncher)
+	cfg := newTestConfig(t, config.Values{
+		Media: config.Media{Hash: []string{HashCRC32}},
+	})
+
+	dir := filepath.Join(pl.roots[0], "NES")
+	writeFile(t, filepath.Join(dir, "My Fox.nes"), testFoxData)
+	writeFile(t, filepath.Join(dir, "Renamed (USA).nes"), "nope")
+	writeFile(t, filepath.Join(dir, "Broken (USA).nes"), "broken")
+	writeFile(t, filepath.Join(dir, "Homebrew.nes"), "homebrew")
+
+	_, err := indexSystem(pl, cfg, IndexOptions{}, "NES", []string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ImportDat(pl, "NotASystem", strings.NewReader(testDat)); err == nil {
+		t.Fatalf("expected error for unknown system")
+	}
+	if _, err := ImportDat(pl, "NES", strings.NewReader("<datafile/>")); err == nil {
+		t.Fatalf("expected error for empty dat")
+	}
+
+	result, err := ImportDat(pl, "NES", strings.NewReader(testDat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := DatImport{
+		Source:  "Nintendo - Nintendo Entertainment System (20240101-000000)",
+		Games:   4,
+		Roms:    4,
+		Matched: 3,
+	}
+	if result != want {
+		t.Fatalf("expected: %+v, got: %+v", want, result)
+	}
+
+	r, err := GetMedia(pl, "NES", filepath.Join(dir, "My Fox.nes"))
+	if err != nil {
+		t.Fatal(err)
+	} else if r == nil || r.Dat == nil || r.Dat.Status != DatVerified || r.Title() != "Fox (USA)" {
+		t.Fatalf("expected verified fox, got: %+v", r)
+	}
+
+	// media indexed after the import is matched as it's added
+	sequel := filepath.Join(dir, "Sequel.nes")
+	writeFile(t, sequel, "sequel")
+	_, err = indexSystem(pl, cfg, IndexOptions{}, "NES", []string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := VerifySystem(pl, "NES")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(report.Bad)
+	wantReport := VerifyReport{
+		SystemId: "NES",
+		Sources:  []string{want.Source},
+		Total:    5,
+		Verified: 2,
+		Unknown:  []string{filepath.Join(dir, "Homebrew.nes")},
+		Bad: []string{
+			filepath.Join(dir, "Broken (USA).nes"),
+			filepath.Join(dir, "Renamed (USA).nes"),
+		},
+	}
+	if !reflect.DeepEqual(report, wantReport) {
+		t.Fatalf("expected: %+v, got: %+v", wantReport, report)
+	}
+
+	// importing again replaces the previous dat
+	_, err = ImportDat(pl, "NES", strings.NewReader(`<datafile>
+		<header><name>Other</name></header>
+		<game name="Homebrew"><rom name="Homebrew.nes"/></game>
+	</datafile>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = VerifySystem(pl, "NES")
+	if err != nil {
+		t.Fatal(err)
+	} else if report.Verified != 0 || report.Unverified != 1 || len(report.Unknown) != 4 {
+		t.Fatalf("expected previous dat to be replaced, got: %+v", report)
+	}
+}
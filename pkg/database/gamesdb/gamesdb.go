@@ -2,15 +2,16 @@ package gamesdb
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 
 	bolt "go.etcd.io/bbolt"
-	"golang.org/x/sync/errgroup"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
@@ -20,15 +21,18 @@ import (
 )
 
 const (
+	BucketMedia       = "media"
 	BucketNames       = "names"
+	BucketMeta        = "meta"
+	BucketScanFiles   = "scan_files"
+	BucketScanDirs    = "scan_dirs"
+	BucketHashes      = "hashes"
+	BucketDat         = "dat"
 	indexedSystemsKey = "meta:indexedSystems"
+	versionKey        = "meta:version"
+	dbVersion         = "3"
 )
 
-// NameKey returns the key for a file name in the names index.
-func NameKey(systemId string, name string) string {
-	return systemId + ":" + name
-}
-
 // Exists returns true if the media database exists on disk.
 func Exists(platform platforms.Platform) bool {
 	_, err := os.Stat(filepath.Join(platform.DataDir(), config.GamesDbFile))
@@ -49,14 +53,22 @@ func open(platform platforms.Platform, options *bolt.Options) (*bolt.DB, error)
 	}
 
 	err = db.Update(func(txn *bolt.Tx) error {
-		for _, bucket := range []string{BucketNames} {
+		for _, bucket := range []string{
+			BucketMedia,
+			BucketNames,
+			BucketMeta,
+			BucketScanFiles,
+			BucketScanDirs,
+			BucketHashes,
+			BucketDat,
+		} {
 			_, err := txn.CreateBucketIfNotExists([]byte(bucket))
 			if err != nil {
 				return err
 			}
 		}
 
-		return nil
+		return migrate(txn)
 	})
 	if err != nil {
 		return nil, err
@@ -73,11 +85,137 @@ func openForGenerate(platform platforms.Platform) (*bolt.DB, error) {
 	})
 }
 
+// Upgrade the database from older versions.
+func migrate(txn *bolt.Tx) error {
+	bmeta := txn.Bucket([]byte(BucketMeta))
+	v := string(bmeta.Get([]byte(versionKey)))
+	if v == dbVersion {
+		return nil
+	}
+
+	// a missing version is either a new or the original database
+	version, _ := strconv.Atoi(v)
+
+	if version < 2 {
+		err := migrateNames(txn)
+		if err != nil {
+			return err
+		}
+	}
+
+	if version < 3 {
+		err := migrateTags(txn)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bmeta.Put([]byte(versionKey), []byte(dbVersion))
+}
+
+// The original names index stored a single path per name, keyed by system
+// and name, with metadata in the same bucket. Convert it to media records.
+func migrateNames(txn *bolt.Tx) error {
+	bmeta := txn.Bucket([]byte(BucketMeta))
+	bn := txn.Bucket([]byte(BucketNames))
+	var records []MediaRecord
+	var indexed []byte
+
+	err := bn.ForEach(func(k, v []byte) error {
+		if string(k) == indexedSystemsKey {
+			indexed = append([]byte{}, v...)
+			return nil
+		}
+
+		systemId, name, ok := strings.Cut(string(k), ":")
+		if !ok || bytes.IndexByte(k, 0) != -1 {
+			return nil
+		}
+
+		records = append(records, MediaRecord{
+			SystemId: systemId,
+			Path:     string(v),
+			Name:     name,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 && indexed == nil {
+		return nil
+	}
+
+	log.Info().Msgf("migrating %d media db names to records", len(records))
+
+	err = txn.DeleteBucket([]byte(BucketNames))
+	if err != nil {
+		return err
+	}
+	_, err = txn.CreateBucket([]byte(BucketNames))
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		err := putMedia(txn, r)
+		if err != nil {
+			return err
+		}
+	}
+
+	if indexed != nil {
+		return bmeta.Put([]byte(indexedSystemsKey), indexed)
+	}
+
+	return nil
+}
+
+// Parse the filename tags of existing media records.
+func migrateTags(txn *bolt.Tx) error {
+	bm := txn.Bucket([]byte(BucketMedia))
+	var records []MediaRecord
+
+	err := bm.ForEach(func(k, v []byte) error {
+		var r MediaRecord
+		err := json.Unmarshal(v, &r)
+		if err != nil {
+			return err
+		}
+
+		r.Tags = ParseTags(r.Name)
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(records) > 0 {
+		log.Info().Msgf("parsing tags of %d media records", len(records))
+	}
+
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+
+		err = bm.Put(MediaKey(r.SystemId, r.Path), data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func readIndexedSystems(db *bolt.DB) ([]string, error) {
 	var systems []string
 
 	err := db.View(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(BucketNames))
+		b := tx.Bucket([]byte(BucketMeta))
 		v := b.Get([]byte(indexedSystemsKey))
 		if v != nil {
 			systems = strings.Split(string(v), ",")
@@ -88,71 +226,85 @@ func readIndexedSystems(db *bolt.DB) ([]string, error) {
 	return systems, err
 }
 
-func writeIndexedSystems(db *bolt.DB, systems []string) error {
-	return db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket([]byte(BucketNames))
-		v := b.Get([]byte(indexedSystemsKey))
-		if v == nil {
-			v = []byte(strings.Join(systems, ","))
-			return b.Put([]byte(indexedSystemsKey), v)
-		} else {
-			existing := strings.Split(string(v), ",")
-			for _, s := range systems {
-				if !utils.Contains(existing, s) {
-					existing = append(existing, s)
-				}
-			}
-			return b.Put([]byte(indexedSystemsKey), []byte(strings.Join(existing, ",")))
+// Update the list of indexed systems, adding and removing the given IDs.
+func writeIndexedSystems(tx *bolt.Tx, add []string, remove []string) error {
+	b := tx.Bucket([]byte(BucketMeta))
+
+	var existing []string
+	if v := b.Get([]byte(indexedSystemsKey)); len(v) > 0 {
+		existing = strings.Split(string(v), ",")
+	}
+
+	systems := make([]string, 0, len(existing)+len(add))
+	for _, s := range existing {
+		if !utils.Contains(remove, s) {
+			systems = append(systems, s)
 		}
-	})
+	}
+	for _, s := range add {
+		if !utils.Contains(systems, s) {
+			systems = append(systems, s)
+		}
+	}
+
+	return b.Put([]byte(indexedSystemsKey), []byte(strings.Join(systems, ",")))
 }
 
-type fileInfo struct {
-	SystemId string
-	Path     string
-	Name     string
+func mediaFromResult(systemId string, r platforms.ScanResult) MediaRecord {
+	name := r.Name
+	if name == "" {
+		base := filepath.Base(r.Path)
+		name = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	return MediaRecord{
+		SystemId: systemId,
+		Path:     r.Path,
+		Name:     name,
+		Tags:     ParseTags(name),
+	}
 }
 
-// Delete all names in index for the given system.
-func deleteSystemNames(db *bolt.DB, systemId string) (int, error) {
-	deleted := 0
-	err := db.Batch(func(tx *bolt.Tx) error {
-		bns := tx.Bucket([]byte(BucketNames))
-		c := bns.Cursor()
-		p := []byte(systemId + ":")
-		for k, _ := c.Seek(p); k != nil && strings.HasPrefix(string(k), string(p)); k, _ = c.Next() {
-			err := bns.Delete(k)
-			if err != nil {
-				return err
-			}
-			deleted++
+// Delete all media records, names and hashes in index for the given system.
+func deleteSystemMedia(tx *bolt.Tx, systemId string) error {
+	p := systemPrefix(systemId)
+
+	var hashed []MediaRecord
+	err := eachSystemMedia(tx, systemId, func(r MediaRecord) (bool, error) {
+		if r.Hashes != nil {
+			hashed = append(hashed, r)
 		}
-		return nil
+		return true, nil
 	})
-	return deleted, err
-}
+	if err != nil {
+		return err
+	}
 
-// Update the names index with the given files.
-func updateNames(db *bolt.DB, files []fileInfo) error {
-	return db.Batch(func(tx *bolt.Tx) error {
-		bns := tx.Bucket([]byte(BucketNames))
+	for _, r := range hashed {
+		err := deleteHashes(tx, r)
+		if err != nil {
+			return err
+		}
+	}
 
-		for _, file := range files {
-			base := filepath.Base(file.Path)
-			name := file.Name
-			if name == "" {
-				name = strings.TrimSuffix(base, filepath.Ext(base))
-			}
+	for _, bucket := range []string{BucketMedia, BucketNames} {
+		b := tx.Bucket([]byte(bucket))
+
+		var keys [][]byte
+		c := b.Cursor()
+		for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+			keys = append(keys, append([]byte{}, k...))
+		}
 
-			nk := NameKey(file.SystemId, name)
-			err := bns.Put([]byte(nk), []byte(file.Path))
+		for _, k := range keys {
+			err := b.Delete(k)
 			if err != nil {
 				return err
 			}
 		}
+	}
 
-		return nil
-	})
+	return nil
 }
 
 type IndexStatus struct {
@@ -162,25 +314,148 @@ type IndexStatus struct {
 	Files    int
 }
 
+type IndexOptions struct {
+	// Systems to index.
+	Systems []System
+	// Limit indexing to these folders. Only systems with media folders
+	// containing, or inside, one of these folders are updated.
+	Paths []string
+	// Ignore cached folder state and rescan every file.
+	Full bool
+}
+
+// Work out which folders need scanning for each system.
+func indexRoots(
+	platform platforms.Platform,
+	cfg *config.Instance,
+	opts IndexOptions,
+) (map[string][]string, error) {
+	systemPaths := make(map[string][]string)
+	for _, v := range GetSystemPaths(cfg, platform, platform.RootDirs(cfg), opts.Systems) {
+		systemPaths[v.System.Id] = append(systemPaths[v.System.Id], v.Path)
+	}
+
+	if len(opts.Paths) == 0 {
+		return systemPaths, nil
+	}
+
+	roots := make(map[string][]string)
+	for _, p := range opts.Paths {
+		p = filepath.Clean(p)
+		found := false
+
+		for systemId, sps := range systemPaths {
+			for _, sp := range sps {
+				var root string
+				if utils.PathWithin(p, sp) {
+					root = p
+				} else if utils.PathWithin(sp, p) {
+					root = sp
+				} else {
+					continue
+				}
+
+				found = true
+				if !utils.Contains(roots[systemId], root) {
+					roots[systemId] = append(roots[systemId], root)
+				}
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("path is not in a media folder: %s", p)
+		}
+	}
+
+	return roots, nil
+}
+
 // Given a list of systems, index all valid game files on disk and write a
-// names index to the DB. Overwrites any existing names index, but does not
-// clean up old missing files.
+// media record for each one to the DB.
+//
+// Indexing is incremental, only folders and zip files which have changed
+// since the last index are rescanned and records for deleted files are
+// removed. Systems with custom launcher scanners are always fully rewritten
+// from the scanner results, but their folders are still scanned
+// incrementally. The database is only held open while reading and writing
+// each system, so searches can continue during an index.
 //
 // Takes a function which will be called with the current status of the index
 // during key steps.
 //
 // Returns the total number of files indexed.
-func NewNamesIndex(
+func UpdateIndex(
 	platform platforms.Platform,
 	cfg *config.Instance,
-	systems []System,
+	opts IndexOptions,
 	update func(IndexStatus),
 ) (int, error) {
 	status := IndexStatus{
-		Total: len(systems) + 2, // estimate steps
+		Total: len(opts.Systems) + 2, // estimate steps
 		Step:  1,
 	}
 
+	update(status)
+	roots, err := indexRoots(platform, cfg, opts)
+	if err != nil {
+		return status.Files, err
+	}
+
+	// launcher scanners with no system defined are run against every system
+	var anyScanners []platforms.Launcher
+	for _, l := range platform.Launchers(cfg) {
+		if l.SystemId == "" && l.Scanner != nil {
+			anyScanners = append(anyScanners, l)
+		}
+	}
+
+	// run each custom scanner at least once, even if there are no paths
+	// defined or results from regular index
+	systemIds := utils.AlphaMapKeys(roots)
+	if len(opts.Paths) == 0 {
+		for _, s := range opts.Systems {
+			if utils.Contains(systemIds, s.Id) {
+				continue
+			}
+
+			for _, l := range platform.Launchers(cfg) {
+				if (l.SystemId == s.Id && l.Scanner != nil) || len(anyScanners) > 0 {
+					systemIds = append(systemIds, s.Id)
+					break
+				}
+			}
+		}
+	}
+
+	// update steps with true count
+	status.Total = len(systemIds) + 2
+
+	var indexed, emptied []string
+
+	for _, systemId := range systemIds {
+		status.SystemId = systemId
+		status.Step++
+		update(status)
+
+		count, err := indexSystem(platform, cfg, opts, systemId, roots[systemId], anyScanners)
+		if err != nil {
+			return status.Files, err
+		}
+
+		log.Debug().Msgf("indexed %d files for system: %s", count, systemId)
+		status.Files += count
+
+		if count > 0 {
+			indexed = append(indexed, systemId)
+		} else {
+			emptied = append(emptied, systemId)
+		}
+	}
+
+	status.Step++
+	status.SystemId = ""
+	update(status)
+
 	db, err := openForGenerate(platform)
 	if err != nil {
 		return status.Files, fmt.Errorf("error opening gamesdb: %s", err)
@@ -192,192 +467,190 @@ func NewNamesIndex(
 		}
 	}(db)
 
-	filteredIds := make([]string, 0)
-	for _, s := range systems {
-		filteredIds = append(filteredIds, s.Id)
+	log.Debug().Msgf("indexed systems: %v, empty systems: %v", indexed, emptied)
+	err = db.Update(func(tx *bolt.Tx) error {
+		return writeIndexedSystems(tx, indexed, emptied)
+	})
+	if err != nil {
+		return status.Files, fmt.Errorf("error writing indexed systems: %s", err)
 	}
 
-	indexed, err := readIndexedSystems(db)
+	err = db.Sync()
 	if err != nil {
-		log.Info().Msg("no indexed systems found")
+		return status.Files, fmt.Errorf("error syncing database: %s", err)
 	}
 
-	for _, v := range indexed {
-		if !utils.Contains(filteredIds, v) {
-			continue
-		}
+	return status.Files, nil
+}
 
-		count, err := deleteSystemNames(db, v)
-		if err != nil {
-			return status.Files, fmt.Errorf("error deleting system names: %s", err)
-		} else {
-			log.Debug().Msgf("deleted names for %s: %d", v, count)
-		}
+// Scan and write the media records for a single system. Returns the total
+// number of media records for the system.
+func indexSystem(
+	platform platforms.Platform,
+	cfg *config.Instance,
+	opts IndexOptions,
+	systemId string,
+	roots []string,
+	anyScanners []platforms.Launcher,
+) (int, error) {
+	db, err := openForGenerate(platform)
+	if err != nil {
+		return 0, fmt.Errorf("error opening gamesdb: %s", err)
 	}
 
-	update(status)
-	systemPaths := make(map[string][]string)
-	for _, v := range GetSystemPaths(platform, platform.RootDirs(cfg), systems) {
-		systemPaths[v.System.Id] = append(systemPaths[v.System.Id], v.Path)
+	s, err := loadSystemScan(db, cfg, platform, systemId, opts.Full)
+	closeErr := db.Close()
+	if err != nil {
+		return 0, fmt.Errorf("error reading scan state: %s", err)
+	} else if closeErr != nil {
+		log.Warn().Err(closeErr).Msg("closing gamesdb")
 	}
 
-	g := new(errgroup.Group)
-	scanned := make(map[string]bool)
-	for _, s := range AllSystems() {
-		scanned[s.Id] = false
+	if len(opts.Paths) > 0 {
+		s.keepOutside(roots)
 	}
 
-	sysPathIds := utils.AlphaMapKeys(systemPaths)
-	// update steps with true count
-	status.Total = len(sysPathIds) + 2
-
-	for _, k := range sysPathIds {
-		systemId := k
-		files := make([]platforms.ScanResult, 0)
+	for _, root := range roots {
+		s.scan(root)
+	}
 
-		status.SystemId = systemId
-		status.Step++
-		update(status)
+	if algos := hashAlgos(cfg); len(algos) > 0 {
+		s.hash(algos)
+	}
 
-		for _, path := range systemPaths[k] {
-			pathFiles, err := GetFiles(cfg, platform, k, path)
-			if err != nil {
-				return status.Files, fmt.Errorf("error getting files: %s", err)
-			}
-			for _, f := range pathFiles {
-				files = append(files, platforms.ScanResult{Path: f})
-			}
+	var scanners []platforms.Launcher
+	for _, l := range platform.Launchers(cfg) {
+		if l.SystemId == systemId && l.Scanner != nil {
+			scanners = append(scanners, l)
 		}
+	}
 
-		// for each system launcher in platform, run the results through its
-		// custom scan function if one exists
-		for _, l := range platform.Launchers() {
-			if l.SystemId == k && l.Scanner != nil {
-				log.Debug().Msgf("running %s scanner for system: %s", l.Id, systemId)
-				files, err = l.Scanner(cfg, systemId, files)
-				if err != nil {
-					return status.Files, err
-				}
-			}
-		}
+	// without any custom scanners the media records are the scanned files,
+	// so only changes need to be written. an empty scan state means this
+	// system has never been indexed incrementally and may have stale records
+	incremental := len(scanners) == 0 &&
+		len(anyScanners) == 0 &&
+		len(s.prevFiles) > 0 &&
+		!opts.Full
 
-		if len(files) == 0 {
-			log.Debug().Msgf("no files found for system: %s", systemId)
-			continue
+	var results []platforms.ScanResult
+	if !incremental {
+		for _, p := range utils.AlphaMapKeys(s.files) {
+			results = append(results, platforms.ScanResult{Path: p})
 		}
 
-		status.Files += len(files)
-		log.Debug().Msgf("scanned %d files for system: %s", len(files), systemId)
-		scanned[systemId] = true
-
-		g.Go(func() error {
-			fis := make([]fileInfo, 0)
-			for _, p := range files {
-				fis = append(fis, fileInfo{SystemId: systemId, Path: p.Path, Name: p.Name})
-			}
-			return updateNames(db, fis)
-		})
-	}
-
-	// run each custom scanner at least once, even if there are no paths
-	// defined or results from regular index
-	for _, l := range platform.Launchers() {
-		systemId := l.SystemId
-		if !scanned[systemId] && l.Scanner != nil {
+		for _, l := range scanners {
 			log.Debug().Msgf("running %s scanner for system: %s", l.Id, systemId)
-			results, err := l.Scanner(cfg, systemId, []platforms.ScanResult{})
+			results, err = l.Scanner(cfg, systemId, results)
 			if err != nil {
-				return status.Files, err
+				return 0, err
 			}
+		}
 
-			log.Debug().Msgf("scanned %d files for system: %s", len(results), systemId)
-
-			status.Files += len(results)
-			scanned[systemId] = true
-
-			if len(results) > 0 {
-				g.Go(func() error {
-					fis := make([]fileInfo, 0)
-					for _, p := range results {
-						fis = append(fis, fileInfo{SystemId: systemId, Path: p.Path, Name: p.Name})
-					}
-					log.Debug().Msgf("updating names for system: %s", systemId)
-					return updateNames(db, fis)
-				})
+		for _, l := range anyScanners {
+			log.Debug().Msgf("running %s scanner for system: %s", l.Id, systemId)
+			anyResults, err := l.Scanner(cfg, systemId, []platforms.ScanResult{})
+			if err != nil {
+				return 0, err
 			}
+			results = append(results, anyResults...)
 		}
 	}
 
-	// launcher scanners with no system defined are run against every system
-	var anyScanners []platforms.Launcher
-	for _, l := range platform.Launchers() {
-		if l.SystemId == "" && l.Scanner != nil {
-			anyScanners = append(anyScanners, l)
-		}
+	db, err = openForGenerate(platform)
+	if err != nil {
+		return 0, fmt.Errorf("error opening gamesdb: %s", err)
 	}
+	defer func(db *bolt.DB) {
+		err := db.Close()
+		if err != nil {
+			log.Warn().Err(err).Msg("closing gamesdb")
+		}
+	}(db)
 
-	for _, l := range anyScanners {
-		for _, s := range systems {
-			log.Debug().Msgf("running %s scanner for system: %s", l.Id, s.Id)
-			results, err := l.Scanner(cfg, s.Id, []platforms.ScanResult{})
-			if err != nil {
-				return status.Files, err
-			}
-
-			log.Debug().Msgf("scanned %d files for system: %s", len(results), s.Id)
-
-			if len(results) > 0 {
-				status.Files += len(results)
-				scanned[s.Id] = true
+	err = db.Update(func(tx *bolt.Tx) error {
+		if incremental {
+			removed, changed := s.removed(), s.changed()
+			log.Debug().Msgf(
+				"%s: %d files removed, %d files changed",
+				systemId, len(removed), len(changed),
+			)
 
-				systemId := s.Id
-				g.Go(func() error {
-					fis := make([]fileInfo, 0)
-					for _, p := range results {
-						fis = append(fis, fileInfo{SystemId: systemId, Path: p.Path, Name: p.Name})
-					}
-					log.Debug().Msgf("updating names for system: %s", systemId)
-					return updateNames(db, fis)
-				})
+			for _, p := range removed {
+				err := deleteMedia(tx, systemId, p)
+				if err != nil {
+					return err
+				}
 			}
-		}
-	}
 
-	status.Step++
-	status.SystemId = ""
-	update(status)
+			// files which changed or had new hashes calculated
+			changedSet := make(map[string]struct{}, len(changed))
+			for _, p := range changed {
+				changedSet[p] = struct{}{}
+			}
+			for _, p := range s.rehashed {
+				if _, ok := changedSet[p]; !ok {
+					changed = append(changed, p)
+				}
+			}
 
-	err = g.Wait()
-	if err != nil {
-		return status.Files, fmt.Errorf("error updating names index: %s", err)
-	}
+			for _, p := range changed {
+				r := mediaFromResult(systemId, platforms.ScanResult{Path: p})
+				r.Hashes = s.hashes[p]
+				dat, err := matchDat(tx, r)
+				if err != nil {
+					return err
+				}
+				r.Dat = dat
+				err = putMedia(tx, r)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			err := deleteSystemMedia(tx, systemId)
+			if err != nil {
+				return err
+			}
 
-	indexedSystems := make([]string, 0)
-	log.Debug().Msgf("scanned systems: %v", scanned)
-	for k, v := range scanned {
-		if v {
-			indexedSystems = append(indexedSystems, k)
+			for _, res := range results {
+				r := mediaFromResult(systemId, res)
+				r.Hashes = s.hashes[res.Path]
+				dat, err := matchDat(tx, r)
+				if err != nil {
+					return err
+				}
+				r.Dat = dat
+				err = putMedia(tx, r)
+				if err != nil {
+					return err
+				}
+			}
 		}
-	}
-	log.Debug().Msgf("indexed systems: %v", indexedSystems)
 
-	err = writeIndexedSystems(db, indexedSystems)
+		return s.save(tx)
+	})
 	if err != nil {
-		return status.Files, fmt.Errorf("error writing indexed systems: %s", err)
+		return 0, fmt.Errorf("error updating media index: %s", err)
 	}
 
-	err = db.Sync()
-	if err != nil {
-		return status.Files, fmt.Errorf("error syncing database: %s", err)
+	if incremental {
+		return len(s.files), nil
 	}
 
-	return status.Files, nil
+	return len(results), nil
 }
 
 type SearchResult struct {
 	SystemId string
 	Name     string
 	Path     string
+	Tags     MediaTags
+	// Display title, the canonical title from an imported DAT or else the
+	// file name. May be empty for results not read from the media records.
+	Title string
+	// Relevance to the search query, higher is better.
+	Score int
 }
 
 // Iterate all indexed names and return matches to test func against query.
@@ -408,18 +681,17 @@ func searchNamesGeneric(
 		bn := tx.Bucket([]byte(BucketNames))
 
 		for _, system := range systems {
-			pre := []byte(system.Id + ":")
-			nameIdx := bytes.Index(pre, []byte(":"))
+			pre := systemPrefix(system.Id)
 
 			c := bn.Cursor()
 			for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
-				keyName := string(k[nameIdx+1:])
+				keyName := string(v)
 
 				if test(query, keyName) {
 					results = append(results, SearchResult{
 						SystemId: system.Id,
 						Name:     keyName,
-						Path:     string(v),
+						Path:     nameKeyPath(k),
 					})
 				}
 			}
@@ -438,9 +710,41 @@ func searchNamesGeneric(
 
 // Return indexed names matching exact query (case insensitive).
 func SearchNamesExact(platform platforms.Platform, systems []System, query string) ([]SearchResult, error) {
-	return searchNamesGeneric(platform, systems, query, func(query, keyName string) bool {
-		return strings.EqualFold(query, keyName)
+	if !Exists(platform) {
+		return nil, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(platform, &bolt.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer func(db *bolt.DB) {
+		err := db.Close()
+		if err != nil {
+			log.Warn().Err(err).Msg("closing database")
+		}
+	}(db)
+
+	var results []SearchResult
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(BucketNames)).Cursor()
+
+		for _, system := range systems {
+			pre := namePrefix(system.Id, query)
+			for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+				results = append(results, SearchResult{
+					SystemId: system.Id,
+					Name:     string(v),
+					Path:     nameKeyPath(k),
+				})
+			}
+		}
+
+		return nil
 	})
+
+	return results, err
 }
 
 // Return indexed names partially matching query (case insensitive).
@@ -555,8 +859,9 @@ func IndexedSystems(platform platforms.Platform) ([]string, error) {
 	return systems, nil
 }
 
-// Return a random game from specified systems.
-func RandomGame(platform platforms.Platform, systems []System) (SearchResult, error) {
+// Return a random game from specified systems, limited to media matching the
+// filter's tags.
+func RandomGame(platform platforms.Platform, systems []System, filter SearchFilter) (SearchResult, error) {
 	if !Exists(platform) {
 		return SearchResult{}, fmt.Errorf("gamesdb does not exist")
 	}
@@ -582,22 +887,20 @@ func RandomGame(platform platforms.Platform, systems []System) (SearchResult, er
 	possible := make([]SearchResult, 0)
 
 	err = db.View(func(tx *bolt.Tx) error {
-		bn := tx.Bucket([]byte(BucketNames))
-
-		pre := []byte(system.Id + ":")
-		nameIdx := bytes.Index(pre, []byte(":"))
+		return eachSystemMedia(tx, system.Id, func(r MediaRecord) (bool, error) {
+			if !filter.Match(r.Tags) {
+				return true, nil
+			}
 
-		c := bn.Cursor()
-		for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
-			keyName := string(k[nameIdx+1:])
 			possible = append(possible, SearchResult{
-				SystemId: system.Id,
-				Name:     keyName,
-				Path:     string(v),
+				SystemId: r.SystemId,
+				Name:     r.Name,
+				Path:     r.Path,
+				Tags:     r.Tags,
+				Title:    r.Title(),
 			})
-		}
-
-		return nil
+			return true, nil
+		})
 	})
 	if err != nil {
 		return result, err
@@ -0,0 +1,92 @@
+package gamesdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	bolt "go.etcd.io/bbolt"
+)
+
+// testPlatform implements only the parts of a platform used by the
+// gamesdb, calling anything else will panic.
+type testPlatform struct {
+	platforms.Platform
+	dataDir   string
+	roots     []string
+	zips      bool
+	launchers []platforms.Launcher
+}
+
+func (p *testPlatform) DataDir() string {
+	return p.dataDir
+}
+
+func (p *testPlatform) RootDirs(*config.Instance) []string {
+	return p.roots
+}
+
+func (p *testPlatform) ZipsAsDirs() bool {
+	return p.zips
+}
+
+func (p *testPlatform) Launchers(*config.Instance) []platforms.Launcher {
+	return p.launchers
+}
+
+func newTestPlatform(t *testing.T, launchers ...platforms.Launcher) *testPlatform {
+	t.Helper()
+	dir := t.TempDir()
+	return &testPlatform{
+		dataDir:   filepath.Join(dir, "data"),
+		roots:     []string{filepath.Join(dir, "media")},
+		launchers: launchers,
+	}
+}
+
+func newTestConfig(t *testing.T, vals config.Values) *config.Instance {
+	t.Helper()
+	t.Setenv(config.CfgEnv, "")
+	cfg, err := config.NewConfig(t.TempDir(), vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func writeFile(t *testing.T, path string, data string) {
+	t.Helper()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(path, []byte(data), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Return all media records of a system, keyed by path.
+func systemMedia(t *testing.T, pl platforms.Platform, systemId string) map[string]MediaRecord {
+	t.Helper()
+	db, err := open(pl, &bolt.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	rs := make(map[string]MediaRecord)
+	err = db.View(func(tx *bolt.Tx) error {
+		return eachSystemMedia(tx, systemId, func(r MediaRecord) (bool, error) {
+			rs[r.Path] = r
+			return true, nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return rs
+}
@@ -0,0 +1,324 @@
+package gamesdb
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	HashCRC32 = "crc32"
+	HashMD5   = "md5"
+	HashSHA1  = "sha1"
+)
+
+// MediaHashes are the content hashes of a media file, as lowercase hex.
+// Only the hashes enabled in the config are set.
+type MediaHashes struct {
+	CRC32 string `json:"crc32,omitempty"`
+	MD5   string `json:"md5,omitempty"`
+	SHA1  string `json:"sha1,omitempty"`
+}
+
+func (h *MediaHashes) get(algo string) string {
+	switch algo {
+	case HashCRC32:
+		return h.CRC32
+	case HashMD5:
+		return h.MD5
+	case HashSHA1:
+		return h.SHA1
+	default:
+		return ""
+	}
+}
+
+// Returns true if every one of the given hashes is set.
+func (h *MediaHashes) has(algos []string) bool {
+	if h == nil {
+		return len(algos) == 0
+	}
+	for _, a := range algos {
+		if h.get(a) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// HashAlgo guesses the hash algorithm from the length of a hex hash.
+func HashAlgo(hash string) (string, error) {
+	if _, err := hex.DecodeString(hash); err != nil {
+		return "", fmt.Errorf("invalid hash: %s", hash)
+	}
+
+	switch len(hash) {
+	case 8:
+		return HashCRC32, nil
+	case 32:
+		return HashMD5, nil
+	case 40:
+		return HashSHA1, nil
+	default:
+		return "", fmt.Errorf("unknown hash type: %s", hash)
+	}
+}
+
+// Key for a file in the hashes index. Keys start with the hash so all files
+// with the same content can be found with a prefix seek.
+func hashKey(algo string, hash string, systemId string, path string) []byte {
+	return []byte(algo + ":" + strings.ToLower(hash) + "\x00" + systemId + "\x00" + path)
+}
+
+func hashPrefix(algo string, hash string) []byte {
+	return []byte(algo + ":" + strings.ToLower(hash) + "\x00")
+}
+
+func putHashes(tx *bolt.Tx, r MediaRecord) error {
+	if r.Hashes == nil {
+		return nil
+	}
+
+	b := tx.Bucket([]byte(BucketHashes))
+	for _, algo := range []string{HashCRC32, HashMD5, HashSHA1} {
+		if h := r.Hashes.get(algo); h != "" {
+			err := b.Put(hashKey(algo, h, r.SystemId, r.Path), []byte{})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func deleteHashes(tx *bolt.Tx, r MediaRecord) error {
+	if r.Hashes == nil {
+		return nil
+	}
+
+	b := tx.Bucket([]byte(BucketHashes))
+	for _, algo := range []string{HashCRC32, HashMD5, HashSHA1} {
+		if h := r.Hashes.get(algo); h != "" {
+			err := b.Delete(hashKey(algo, h, r.SystemId, r.Path))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func newHashers(algos []string) map[string]hash.Hash {
+	hs := make(map[string]hash.Hash)
+	for _, a := range algos {
+		switch a {
+		case HashCRC32:
+			hs[a] = crc32.NewIEEE()
+		case HashMD5:
+			hs[a] = md5.New()
+		case HashSHA1:
+			hs[a] = sha1.New()
+		}
+	}
+	return hs
+}
+
+// Hash the contents of a reader with all the given algorithms in one pass.
+func hashReader(r io.Reader, algos []string) (*MediaHashes, error) {
+	hs := newHashers(algos)
+	ws := make([]io.Writer, 0, len(hs))
+	for _, h := range hs {
+		ws = append(ws, h)
+	}
+
+	_, err := io.Copy(io.MultiWriter(ws...), r)
+	if err != nil {
+		return nil, err
+	}
+
+	var mh MediaHashes
+	for a, h := range hs {
+		sum := hex.EncodeToString(h.Sum(nil))
+		switch a {
+		case HashCRC32:
+			mh.CRC32 = sum
+		case HashMD5:
+			mh.MD5 = sum
+		case HashSHA1:
+			mh.SHA1 = sum
+		}
+	}
+
+	return &mh, nil
+}
+
+// Hash a single file inside a zip. The CRC32 is read from the zip's central
+// directory, so the file is only decompressed if other hashes are needed.
+func hashZipFile(f *zip.File, algos []string) (*MediaHashes, error) {
+	var rest []string
+	for _, a := range algos {
+		if a != HashCRC32 {
+			rest = append(rest, a)
+		}
+	}
+
+	mh := &MediaHashes{}
+	if len(rest) > 0 {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		mh, err = hashReader(rc, rest)
+		closeErr := rc.Close()
+		if err != nil {
+			return nil, err
+		} else if closeErr != nil {
+			log.Warn().Err(closeErr).Msgf("error closing zip file: %s", f.Name)
+		}
+	}
+
+	if len(rest) != len(algos) {
+		mh.CRC32 = fmt.Sprintf("%08x", f.CRC32)
+	}
+
+	return mh, nil
+}
+
+// Split a path to a file inside a zip into the zip path and file name.
+func splitZipPath(path string) (string, string, bool) {
+	lower := strings.ToLower(path)
+	i := strings.Index(lower, ".zip"+string(filepath.Separator))
+	if i == -1 {
+		return "", "", false
+	}
+
+	return path[:i+4], path[i+5:], true
+}
+
+// Hash the contents of a media file. Files inside a zip are hashed without
+// extracting the zip, and a zip containing a single file is hashed as that
+// file, so hashes match those of the unzipped media.
+func hashMedia(pl platforms.Platform, path string, algos []string) (*MediaHashes, error) {
+	zipPath, name, inZip := splitZipPath(path)
+	if !inZip && utils.IsZip(path) && !pl.ZipsAsDirs() {
+		zipPath = path
+	}
+
+	if zipPath != "" {
+		zr, err := zip.OpenReader(zipPath)
+		if err != nil {
+			return nil, err
+		}
+		defer func(zr *zip.ReadCloser) {
+			err := zr.Close()
+			if err != nil {
+				log.Warn().Err(err).Msgf("error closing zip: %s", zipPath)
+			}
+		}(zr)
+
+		var files []*zip.File
+		for _, f := range zr.File {
+			if !f.FileInfo().IsDir() {
+				files = append(files, f)
+			}
+		}
+
+		if inZip {
+			for _, f := range files {
+				if filepath.FromSlash(f.Name) == name || f.Name == name {
+					return hashZipFile(f, algos)
+				}
+			}
+			return nil, fmt.Errorf("file not found in zip: %s", path)
+		} else if len(files) == 1 {
+			return hashZipFile(files[0], algos)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		err := f.Close()
+		if err != nil {
+			log.Warn().Err(err).Msgf("error closing file: %s", path)
+		}
+	}(f)
+
+	return hashReader(f, algos)
+}
+
+// Return all indexed media with the given content hash. The hash type is
+// detected from its length.
+func SearchHash(platform platforms.Platform, hash string) ([]SearchResult, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	algo, err := HashAlgo(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !Exists(platform) {
+		return nil, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(platform, &bolt.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer func(db *bolt.DB) {
+		err := db.Close()
+		if err != nil {
+			log.Warn().Err(err).Msg("closing database")
+		}
+	}(db)
+
+	var results []SearchResult
+
+	err = db.View(func(tx *bolt.Tx) error {
+		pre := hashPrefix(algo, hash)
+		c := tx.Bucket([]byte(BucketHashes)).Cursor()
+		for k, _ := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, _ = c.Next() {
+			systemId, path, ok := strings.Cut(string(k[len(pre):]), "\x00")
+			if !ok {
+				continue
+			}
+
+			r, err := getMedia(tx, systemId, path)
+			if err != nil {
+				return err
+			} else if r == nil {
+				continue
+			}
+
+			results = append(results, SearchResult{
+				SystemId: r.SystemId,
+				Name:     r.Name,
+				Path:     r.Path,
+				Tags:     r.Tags,
+				Title:    r.Title(),
+			})
+		}
+
+		return nil
+	})
+
+	return results, err
+}
@@ -0,0 +1,220 @@
+package gamesdb
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+)
+
+// Hashes of testdata/fox.nes, which contains "The quick brown fox jumps
+// over the lazy dog".
+var foxHashes = MediaHashes{
+	CRC32: "414fa339",
+	MD5:   "9e107d9d372bb6826bd81d3542a419d6",
+	SHA1:  "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12",
+}
+
+var allHashes = []string{HashCRC32, HashMD5, HashSHA1}
+
+func TestHashAlgo(t *testing.T) {
+	tests := []struct {
+		hash    string
+		want    string
+		wantErr bool
+	}{
+		{hash: foxHashes.CRC32, want: HashCRC32},
+		{hash: foxHashes.MD5, want: HashMD5},
+		{hash: foxHashes.SHA1, want: HashSHA1},
+		{hash: "414FA339", want: HashCRC32},
+		{hash: "", wantErr: true},
+		{hash: "414fa3", wantErr: true},
+		{hash: "414fa33z", wantErr: true},
+		{hash: "414fa339414fa339", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := HashAlgo(tc.hash)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("%q: expected error, got: %s", tc.hash, got)
+			}
+		} else if err != nil {
+			t.Fatalf("%q: %s", tc.hash, err)
+		} else if got != tc.want {
+			t.Fatalf("%q: expected: %s, got: %s", tc.hash, tc.want, got)
+		}
+	}
+}
+
+func TestHashMedia(t *testing.T) {
+	pl := &testPlatform{}
+	zipsPl := &testPlatform{zips: true}
+
+	tests := []struct {
+		name  string
+		zips  bool
+		path  string
+		algos []string
+		want  MediaHashes
+	}{
+		{
+			name:  "file",
+			path:  "fox.nes",
+			algos: allHashes,
+			want:  foxHashes,
+		},
+		{
+			name:  "only enabled hashes",
+			path:  "fox.nes",
+			algos: []string{HashMD5},
+			want:  MediaHashes{MD5: foxHashes.MD5},
+		},
+		{
+			name:  "single file zip is hashed as its file",
+			path:  "fox.zip",
+			algos: allHashes,
+			want:  foxHashes,
+		},
+		{
+			name:  "file inside zip",
+			zips:  true,
+			path:  filepath.Join("multi.zip", "USA", "fox.nes"),
+			algos: allHashes,
+			want:  foxHashes,
+		},
+		{
+			name:  "crc from central directory",
+			path:  "badcrc.zip",
+			algos: []string{HashCRC32},
+			want:  MediaHashes{CRC32: "deadbeef"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := pl
+			if tc.zips {
+				p = zipsPl
+			}
+
+			got, err := hashMedia(p, filepath.Join("testdata", tc.path), tc.algos)
+			if err != nil {
+				t.Fatal(err)
+			} else if *got != tc.want {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, *got)
+			}
+		})
+	}
+}
+
+func TestHashMediaZip(t *testing.T) {
+	// a zip of more than one file is hashed as the zip itself
+	path := filepath.Join("testdata", "multi.zip")
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+
+	want, err := hashReader(f, allHashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := hashMedia(&testPlatform{}, path, allHashes)
+	if err != nil {
+		t.Fatal(err)
+	} else if *got != *want || got.CRC32 == foxHashes.CRC32 {
+		t.Fatalf("expected zip hashes: %+v, got: %+v", *want, *got)
+	}
+
+	// files missing from a zip are an error
+	_, err = hashMedia(&testPlatform{zips: true}, filepath.Join(path, "missing.nes"), allHashes)
+	if err == nil {
+		t.Fatalf("expected error for missing file in zip")
+	}
+
+	// the stored crc is only used if the file doesn't need to be read
+	_, err = hashMedia(&testPlatform{}, filepath.Join("testdata", "badcrc.zip"), allHashes)
+	if !errors.Is(err, zip.ErrChecksum) {
+		t.Fatalf("expected checksum error, got: %v", err)
+	}
+}
+
+func TestSplitZipPath(t *testing.T) {
+	tests := []struct {
+		path string
+		zip  string
+		name string
+		ok   bool
+	}{
+		{path: filepath.Join("games", "nes.zip", "fox.nes"), zip: filepath.Join("games", "nes.zip"), name: "fox.nes", ok: true},
+		{path: filepath.Join("games", "NES.ZIP", "USA", "fox.nes"), zip: filepath.Join("games", "NES.ZIP"), name: filepath.Join("USA", "fox.nes"), ok: true},
+		{path: filepath.Join("games", "nes.zip")},
+		{path: filepath.Join("games.zipped", "fox.nes")},
+	}
+
+	for _, tc := range tests {
+		zip, name, ok := splitZipPath(tc.path)
+		if zip != tc.zip || name != tc.name || ok != tc.ok {
+			t.Fatalf("%q: expected: %q %q %v, got: %q %q %v", tc.path, tc.zip, tc.name, tc.ok, zip, name, ok)
+		}
+	}
+}
+
+func TestSearchHash(t *testing.T) {
+	pl := newTestPlatform(t, testNesLauncher)
+	cfg := newTestConfig(t, config.Values{
+		Media: config.Media{Hash: allHashes},
+	})
+
+	data, err := os.ReadFile(filepath.Join("testdata", "fox.nes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(pl.roots[0], "NES")
+	writeFile(t, filepath.Join(dir, "Fox (USA).nes"), string(data))
+	writeFile(t, filepath.Join(dir, "Fox (Japan).nes"), string(data))
+	writeFile(t, filepath.Join(dir, "Other (USA).nes"), "other")
+
+	_, err = SearchHash(pl, foxHashes.CRC32)
+	if err == nil {
+		t.Fatalf("expected error before the gamesdb exists")
+	}
+
+	_, err = indexSystem(pl, cfg, IndexOptions{}, "NES", []string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, h := range []string{foxHashes.CRC32, foxHashes.MD5, " " + foxHashes.SHA1 + " ", "414FA339"} {
+		results, err := SearchHash(pl, h)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(results) != 2 {
+			t.Fatalf("%q: expected 2 results, got: %v", h, resultNames(results))
+		}
+
+		for _, r := range results {
+			if r.SystemId != "NES" || r.Tags.Title != "Fox" {
+				t.Fatalf("%q: unexpected result: %+v", h, r)
+			}
+		}
+	}
+
+	results, err := SearchHash(pl, "00000000")
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != 0 {
+		t.Fatalf("expected no results, got: %v", resultNames(results))
+	}
+
+	if _, err := SearchHash(pl, "nothex"); err == nil {
+		t.Fatalf("expected error for invalid hash")
+	}
+}
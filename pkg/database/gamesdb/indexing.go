@@ -44,25 +44,73 @@ func FindPath(path string) (string, error) {
 	return "", fmt.Errorf("file match not found: %s", path)
 }
 
-func GetSystemPaths(pl platforms.Platform, rootFolders []string, systems []System) []PathResult {
-	var matches []PathResult
+// SystemFolders returns the media folder names for a system, relative to the
+// platform's root folders. These are the folders of all the platform's
+// launchers for the system, plus any extra folders added in the config's
+// system overrides.
+func SystemFolders(cfg *config.Instance, pl platforms.Platform, systemId string) []string {
+	var folders []string
+	hasLaunchers := false
+
+	for _, l := range pl.Launchers(cfg) {
+		if l.SystemId != systemId {
+			continue
+		}
+		hasLaunchers = true
 
-	for _, system := range systems {
-		var launchers []platforms.Launcher
-		for _, l := range pl.Launchers() {
-			if l.SystemId == system.Id {
-				launchers = append(launchers, l)
+		for _, folder := range l.Folders {
+			if !utils.Contains(folders, folder) {
+				folders = append(folders, folder)
 			}
 		}
+	}
 
-		var folders []string
-		for _, l := range launchers {
-			for _, folder := range l.Folders {
-				if !utils.Contains(folders, folder) {
-					folders = append(folders, folder)
-				}
+	// extra folders are no use if nothing can launch the system
+	if hasLaunchers && cfg != nil {
+		for _, folder := range cfg.SystemOverride(systemId).Folders {
+			if !utils.Contains(folders, folder) {
+				folders = append(folders, folder)
 			}
 		}
+	}
+
+	return folders
+}
+
+// SystemExtensions returns the file extensions matched for a system's media.
+// These are the extensions of all the platform's launchers for the system,
+// plus any extra extensions added in the config's system overrides.
+func SystemExtensions(cfg *config.Instance, pl platforms.Platform, systemId string) []string {
+	var exts []string
+	hasLaunchers := false
+
+	for _, l := range pl.Launchers(cfg) {
+		if l.SystemId != systemId {
+			continue
+		}
+		hasLaunchers = true
+		exts = appendUnique(exts, l.Extensions...)
+	}
+
+	if hasLaunchers && cfg != nil {
+		for _, ext := range cfg.SystemOverride(systemId).Extensions {
+			exts = appendUnique(exts, strings.ToLower(ext))
+		}
+	}
+
+	return exts
+}
+
+func GetSystemPaths(
+	cfg *config.Instance,
+	pl platforms.Platform,
+	rootFolders []string,
+	systems []System,
+) []PathResult {
+	var matches []PathResult
+
+	for _, system := range systems {
+		folders := SystemFolders(cfg, pl, system.Id)
 
 		for _, folder := range rootFolders {
 			gf, err := FindPath(folder)
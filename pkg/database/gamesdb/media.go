@@ -0,0 +1,168 @@
+package gamesdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// MediaRecord is a single indexed media file. Every file on disk has its own
+// record, keyed by system and path.
+type MediaRecord struct {
+	SystemId string `json:"systemId"`
+	Path     string `json:"path"`
+	Name     string `json:"name"`
+	// Metadata parsed from the tags in the name.
+	Tags MediaTags `json:"tags"`
+	// Content hashes, only set if hashing is enabled.
+	Hashes *MediaHashes `json:"hashes,omitempty"`
+	// Matching entry from an imported DAT file, if any.
+	Dat *DatMatch `json:"dat,omitempty"`
+}
+
+// Title returns the canonical title of the media from its DAT entry, or its
+// file name if it has none.
+func (r MediaRecord) Title() string {
+	if r.Dat != nil && r.Dat.Title != "" {
+		return r.Dat.Title
+	}
+	return r.Name
+}
+
+// MediaKey returns the key for a file in the media bucket.
+func MediaKey(systemId string, path string) []byte {
+	return []byte(systemId + ":" + path)
+}
+
+// NameKey returns the key for a file in the names index. Names are stored
+// lowercase and suffixed with the file path, so files sharing a name are
+// all kept in the index.
+func NameKey(systemId string, name string, path string) []byte {
+	return []byte(systemId + ":" + strings.ToLower(name) + "\x00" + path)
+}
+
+// Prefix matching all files in the names index with the given name.
+func namePrefix(systemId string, name string) []byte {
+	return []byte(systemId + ":" + strings.ToLower(name) + "\x00")
+}
+
+func systemPrefix(systemId string) []byte {
+	return []byte(systemId + ":")
+}
+
+// Returns the path stored in a names index key.
+func nameKeyPath(k []byte) string {
+	i := bytes.LastIndexByte(k, 0)
+	if i == -1 {
+		return ""
+	}
+	return string(k[i+1:])
+}
+
+func getMedia(tx *bolt.Tx, systemId string, path string) (*MediaRecord, error) {
+	v := tx.Bucket([]byte(BucketMedia)).Get(MediaKey(systemId, path))
+	if v == nil {
+		return nil, nil
+	}
+
+	var r MediaRecord
+	err := json.Unmarshal(v, &r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Write a media record and its names and hashes index entries, replacing any
+// existing record for the same file.
+func putMedia(tx *bolt.Tx, r MediaRecord) error {
+	bm := tx.Bucket([]byte(BucketMedia))
+	bn := tx.Bucket([]byte(BucketNames))
+
+	existing, err := getMedia(tx, r.SystemId, r.Path)
+	if err != nil {
+		return err
+	} else if existing != nil {
+		if !strings.EqualFold(existing.Name, r.Name) {
+			err := bn.Delete(NameKey(existing.SystemId, existing.Name, existing.Path))
+			if err != nil {
+				return err
+			}
+		}
+
+		err := deleteHashes(tx, *existing)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = putHashes(tx, r)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	err = bm.Put(MediaKey(r.SystemId, r.Path), data)
+	if err != nil {
+		return err
+	}
+
+	return bn.Put(NameKey(r.SystemId, r.Name, r.Path), []byte(r.Name))
+}
+
+// Remove a media record and its names and hashes index entries.
+func deleteMedia(tx *bolt.Tx, systemId string, path string) error {
+	existing, err := getMedia(tx, systemId, path)
+	if err != nil {
+		return err
+	} else if existing == nil {
+		return nil
+	}
+
+	err = deleteHashes(tx, *existing)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Bucket([]byte(BucketNames)).Delete(
+		NameKey(existing.SystemId, existing.Name, existing.Path),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket([]byte(BucketMedia)).Delete(MediaKey(systemId, path))
+}
+
+// Iterate all media records for a system. Stops if the callback returns
+// false.
+func eachSystemMedia(
+	tx *bolt.Tx,
+	systemId string,
+	fn func(MediaRecord) (bool, error),
+) error {
+	pre := systemPrefix(systemId)
+	c := tx.Bucket([]byte(BucketMedia)).Cursor()
+	for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+		var r MediaRecord
+		err := json.Unmarshal(v, &r)
+		if err != nil {
+			return err
+		}
+
+		ok, err := fn(r)
+		if err != nil {
+			return err
+		} else if !ok {
+			break
+		}
+	}
+	return nil
+}
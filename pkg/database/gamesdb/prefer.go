@@ -0,0 +1,110 @@
+package gamesdb
+
+import (
+	"sort"
+	"strings"
+)
+
+// Score penalty for media with a flag the user wants to avoid. Large enough
+// to rank it below any other version of the same media.
+const avoidPenalty = 50
+
+// MediaPreferences decide which version of a media is picked when a search
+// matches more than one, e.g. the USA and Japan releases of a game.
+type MediaPreferences struct {
+	// Preferred regions, most preferred first.
+	Regions []string
+	// Preferred language codes, most preferred first.
+	Languages []string
+	// Prefer verified good dumps.
+	Verified bool
+	// Flags to avoid unless nothing else matches, using the same names as
+	// search filter excludes.
+	Avoid []string
+}
+
+func (p MediaPreferences) avoided(t MediaTags) bool {
+	return len(p.Avoid) > 0 && !SearchFilter{Exclude: p.Avoid}.Match(t)
+}
+
+// Position of the media's best region in the preferred list. Media for the
+// World region rank after all exact matches and media for any other region
+// rank last.
+func (p MediaPreferences) regionRank(t MediaTags) int {
+	world := false
+	for i, want := range p.Regions {
+		want = NormalizeRegion(want)
+		for _, r := range t.Regions {
+			if strings.EqualFold(r, want) {
+				return i
+			} else if r == "World" {
+				world = true
+			}
+		}
+	}
+
+	if world {
+		return len(p.Regions)
+	}
+	return len(p.Regions) + 1
+}
+
+// Position of the media's best language in the preferred list. Media with
+// no language tags rank after all matches.
+func (p MediaPreferences) languageRank(t MediaTags) int {
+	for i, want := range p.Languages {
+		if t.HasLanguage(want) {
+			return i
+		}
+	}
+
+	if len(t.Languages) == 0 {
+		return len(p.Languages)
+	}
+	return len(p.Languages) + 1
+}
+
+// Returns true if media a should be picked over media b.
+func (p MediaPreferences) less(a SearchResult, b SearchResult) bool {
+	as, bs := a.Score, b.Score
+	if p.avoided(a.Tags) {
+		as -= avoidPenalty
+	}
+	if p.avoided(b.Tags) {
+		bs -= avoidPenalty
+	}
+	if as != bs {
+		return as > bs
+	}
+
+	if p.Verified {
+		av, bv := a.Tags.HasFlag(FlagVerified), b.Tags.HasFlag(FlagVerified)
+		if av != bv {
+			return av
+		}
+	}
+
+	if ar, br := p.regionRank(a.Tags), p.regionRank(b.Tags); ar != br {
+		return ar < br
+	}
+
+	if al, bl := p.languageRank(a.Tags), p.languageRank(b.Tags); al != bl {
+		return al < bl
+	}
+
+	return false
+}
+
+// PreferMedia sorts results so the preferred versions of equally relevant
+// media come first. The existing order is kept for results the preferences
+// don't separate.
+func PreferMedia(results []SearchResult, prefs MediaPreferences) []SearchResult {
+	sorted := make([]SearchResult, len(results))
+	copy(sorted, results)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return prefs.less(sorted[i], sorted[j])
+	})
+
+	return sorted
+}
@@ -0,0 +1,117 @@
+package gamesdb
+
+import (
+	"testing"
+)
+
+func preferResults(names ...string) []SearchResult {
+	results := make([]SearchResult, len(names))
+	for i, n := range names {
+		results[i] = SearchResult{Name: n, Tags: ParseTags(n), Score: 100}
+	}
+	return results
+}
+
+func TestPreferMedia(t *testing.T) {
+	tests := []struct {
+		name  string
+		prefs MediaPreferences
+		names []string
+		want  []string
+	}{
+		{
+			name:  "no preferences keeps order",
+			prefs: MediaPreferences{},
+			names: []string{"Tetris (Japan)", "Tetris (USA)", "Tetris (Europe)"},
+			want:  []string{"Tetris (Japan)", "Tetris (USA)", "Tetris (Europe)"},
+		},
+		{
+			name:  "region order",
+			prefs: MediaPreferences{Regions: []string{"eu", "USA"}},
+			names: []string{"Tetris (Japan)", "Tetris (USA)", "Tetris (Europe)"},
+			want:  []string{"Tetris (Europe)", "Tetris (USA)", "Tetris (Japan)"},
+		},
+		{
+			name:  "world after exact regions",
+			prefs: MediaPreferences{Regions: []string{"Europe"}},
+			names: []string{"Tetris (Japan)", "Tetris (World)", "Tetris (USA, Europe)"},
+			want:  []string{"Tetris (USA, Europe)", "Tetris (World)", "Tetris (Japan)"},
+		},
+		{
+			name:  "language order",
+			prefs: MediaPreferences{Languages: []string{"de", "fr"}},
+			names: []string{
+				"Asterix (Europe) (En,Es)",
+				"Asterix (Europe)",
+				"Asterix (Europe) (En,Fr)",
+				"Asterix (Europe) (En,Fr,De)",
+			},
+			want: []string{
+				"Asterix (Europe) (En,Fr,De)",
+				"Asterix (Europe) (En,Fr)",
+				"Asterix (Europe)",
+				"Asterix (Europe) (En,Es)",
+			},
+		},
+		{
+			name:  "region before language",
+			prefs: MediaPreferences{Regions: []string{"USA"}, Languages: []string{"fr"}},
+			names: []string{"Asterix (Europe) (En,Fr)", "Asterix (USA)"},
+			want:  []string{"Asterix (USA)", "Asterix (Europe) (En,Fr)"},
+		},
+		{
+			name:  "verified first",
+			prefs: MediaPreferences{Verified: true, Regions: []string{"USA"}},
+			names: []string{"Contra (U)", "Contra (J) [!]", "Contra (U) [!]"},
+			want:  []string{"Contra (U) [!]", "Contra (J) [!]", "Contra (U)"},
+		},
+		{
+			name:  "avoided flags last",
+			prefs: MediaPreferences{Regions: []string{"USA"}, Avoid: []string{"prerelease", "hacks"}},
+			names: []string{"Star Fox (USA) (Beta)", "Star Fox (USA) [h1]", "Star Fox (Japan)"},
+			want:  []string{"Star Fox (Japan)", "Star Fox (USA) (Beta)", "Star Fox (USA) [h1]"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := PreferMedia(preferResults(tc.names...), tc.prefs)
+			if !equalNames(resultNames(got), tc.want) {
+				t.Fatalf("expected: %q, got: %q", tc.want, resultNames(got))
+			}
+		})
+	}
+}
+
+func TestPreferMediaScore(t *testing.T) {
+	prefs := MediaPreferences{Regions: []string{"Japan"}, Avoid: []string{"prerelease"}}
+
+	results := preferResults("Mario 3 (USA)", "Mario 3 (Japan)", "Mario 3 (Japan) (Beta)")
+	// more relevant results aren't moved below preferred regions
+	results[0].Score = 120
+	// avoided flags only lose to versions within the penalty
+	results[2].Score = 200
+
+	got := PreferMedia(results, prefs)
+	want := []string{"Mario 3 (Japan) (Beta)", "Mario 3 (USA)", "Mario 3 (Japan)"}
+	if !equalNames(resultNames(got), want) {
+		t.Fatalf("expected: %q, got: %q", want, resultNames(got))
+	}
+
+	// the input isn't modified
+	if results[0].Name != "Mario 3 (USA)" || results[2].Name != "Mario 3 (Japan) (Beta)" {
+		t.Fatalf("expected input order to be kept, got: %q", resultNames(results))
+	}
+}
+
+func equalNames(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
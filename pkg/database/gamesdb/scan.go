@@ -0,0 +1,400 @@
+package gamesdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+// scanFile is the cached state of a file found during a media scan, used to
+// detect changes between scans.
+type scanFile struct {
+	// Folder on disk the file (or its zip) was found in.
+	Dir     string `json:"dir"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"modTime"`
+	// Path of the zip file, if the file was found inside one.
+	Zip string `json:"zip,omitempty"`
+}
+
+type scanDir struct {
+	ModTime int64 `json:"modTime"`
+}
+
+// systemScan incrementally scans the media folders of a single system. Folders
+// which haven't been modified since the last scan reuse their cached list of
+// files, which are only stat'd for changes, and zip files are only relisted
+// if their size or modified time has changed.
+type systemScan struct {
+	cfg      *config.Instance
+	platform platforms.Platform
+	systemId string
+	// ignore cached folder and zip state, all files are rescanned
+	full bool
+
+	prevFiles map[string]scanFile
+	prevDirs  map[string]scanDir
+	byDir     map[string][]string
+	byZip     map[string][]string
+
+	files   map[string]scanFile
+	dirs    map[string]scanDir
+	visited map[string]struct{}
+
+	// content hashes of the existing media records and of scanned files,
+	// only used if hashing is enabled
+	prevHashes map[string]*MediaHashes
+	hashes     map[string]*MediaHashes
+	// files which were hashed during this scan
+	rehashed []string
+}
+
+func loadSystemScan(
+	db *bolt.DB,
+	cfg *config.Instance,
+	platform platforms.Platform,
+	systemId string,
+	full bool,
+) (*systemScan, error) {
+	s := &systemScan{
+		cfg:        cfg,
+		platform:   platform,
+		systemId:   systemId,
+		full:       full,
+		prevFiles:  make(map[string]scanFile),
+		prevDirs:   make(map[string]scanDir),
+		byDir:      make(map[string][]string),
+		byZip:      make(map[string][]string),
+		files:      make(map[string]scanFile),
+		dirs:       make(map[string]scanDir),
+		visited:    make(map[string]struct{}),
+		prevHashes: make(map[string]*MediaHashes),
+		hashes:     make(map[string]*MediaHashes),
+	}
+
+	pre := systemPrefix(systemId)
+
+	err := db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(BucketScanFiles)).Cursor()
+		for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+			var f scanFile
+			err := json.Unmarshal(v, &f)
+			if err != nil {
+				return err
+			}
+
+			path := string(k[len(pre):])
+			s.prevFiles[path] = f
+			if f.Zip != "" {
+				s.byZip[f.Zip] = append(s.byZip[f.Zip], path)
+			} else {
+				s.byDir[f.Dir] = append(s.byDir[f.Dir], path)
+			}
+		}
+
+		c = tx.Bucket([]byte(BucketScanDirs)).Cursor()
+		for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+			var d scanDir
+			err := json.Unmarshal(v, &d)
+			if err != nil {
+				return err
+			}
+			s.prevDirs[string(k[len(pre):])] = d
+		}
+
+		if len(hashAlgos(cfg)) == 0 {
+			return nil
+		}
+
+		return eachSystemMedia(tx, systemId, func(r MediaRecord) (bool, error) {
+			if r.Hashes != nil {
+				s.prevHashes[r.Path] = r.Hashes
+			}
+			return true, nil
+		})
+	})
+
+	return s, err
+}
+
+// Keep all cached state outside the given folders, for scanning only part
+// of a system's media folders.
+func (s *systemScan) keepOutside(roots []string) {
+	within := func(path string) bool {
+		for _, root := range roots {
+			if utils.PathWithin(path, root) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for p, f := range s.prevFiles {
+		if !within(f.Dir) {
+			s.files[p] = f
+		}
+	}
+
+	for d, sd := range s.prevDirs {
+		if !within(d) {
+			s.dirs[d] = sd
+		}
+	}
+}
+
+// Walk a folder, path is the path reported in results and realPath is the
+// resolved location on disk, which differ when following symlinks.
+func (s *systemScan) walk(path string, realPath string) {
+	info, err := os.Stat(realPath)
+	if err != nil || !info.IsDir() {
+		return
+	}
+
+	// avoid recursive symlinks
+	if _, ok := s.visited[realPath]; ok {
+		return
+	}
+	s.visited[realPath] = struct{}{}
+
+	entries, err := os.ReadDir(realPath)
+	if err != nil {
+		log.Warn().Err(err).Msgf("error reading folder: %s", realPath)
+		return
+	}
+
+	modTime := info.ModTime().UnixNano()
+	prev, ok := s.prevDirs[path]
+	unchanged := ok && !s.full && prev.ModTime == modTime
+	s.dirs[path] = scanDir{ModTime: modTime}
+
+	// the list of files in a folder only changes if its modified time does,
+	// but files can still be overwritten in place so they're stat'd again
+	if unchanged {
+		for _, p := range s.byDir[path] {
+			fi, err := os.Stat(filepath.Join(realPath, filepath.Base(p)))
+			if err != nil {
+				continue
+			}
+
+			f := s.prevFiles[p]
+			f.Size = fi.Size()
+			f.ModTime = fi.ModTime().UnixNano()
+			s.files[p] = f
+		}
+	}
+
+	for _, e := range entries {
+		entryPath := filepath.Join(path, e.Name())
+		entryReal := filepath.Join(realPath, e.Name())
+
+		if e.Type()&os.ModeSymlink != 0 {
+			target, err := filepath.EvalSymlinks(entryReal)
+			if err != nil {
+				continue
+			}
+
+			ti, err := os.Stat(target)
+			if err != nil {
+				continue
+			}
+
+			if ti.IsDir() {
+				s.walk(entryPath, target)
+				continue
+			}
+
+			entryReal = target
+		} else if e.IsDir() {
+			s.walk(entryPath, entryReal)
+			continue
+		}
+
+		if unchanged && !utils.IsZip(entryPath) {
+			continue
+		}
+
+		s.scanFile(path, entryPath, entryReal)
+	}
+}
+
+func (s *systemScan) scanFile(dir string, path string, realPath string) {
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return
+	}
+
+	size := info.Size()
+	modTime := info.ModTime().UnixNano()
+
+	if utils.IsZip(path) && s.platform.ZipsAsDirs() {
+		// reuse the previous listing if the zip hasn't changed
+		if prev := s.byZip[path]; !s.full && len(prev) > 0 {
+			first := s.prevFiles[prev[0]]
+			if first.Size == size && first.ModTime == modTime {
+				for _, p := range prev {
+					f := s.prevFiles[p]
+					f.Dir = dir
+					s.files[p] = f
+				}
+				return
+			}
+		}
+
+		zipFiles, err := utils.ListZip(realPath)
+		if err != nil {
+			// skip invalid zip files
+			return
+		}
+
+		for _, zf := range zipFiles {
+			abs := filepath.Join(path, zf)
+			if utils.MatchSystemFile(s.cfg, s.platform, s.systemId, abs) {
+				s.files[abs] = scanFile{
+					Dir:     dir,
+					Size:    size,
+					ModTime: modTime,
+					Zip:     path,
+				}
+			}
+		}
+	} else if utils.MatchSystemFile(s.cfg, s.platform, s.systemId, path) {
+		s.files[path] = scanFile{
+			Dir:     dir,
+			Size:    size,
+			ModTime: modTime,
+		}
+	}
+}
+
+// Scan a system media folder, or a folder inside one.
+func (s *systemScan) scan(path string) {
+	realPath := path
+	if rp, err := filepath.EvalSymlinks(path); err == nil {
+		realPath = rp
+	}
+	s.walk(path, realPath)
+}
+
+// Valid hash algorithms enabled in the config.
+func hashAlgos(cfg *config.Instance) []string {
+	var algos []string
+	for _, a := range cfg.MediaHashes() {
+		a = strings.ToLower(strings.TrimSpace(a))
+		switch a {
+		case HashCRC32, HashMD5, HashSHA1:
+			if !utils.Contains(algos, a) {
+				algos = append(algos, a)
+			}
+		default:
+			log.Warn().Msgf("unknown media hash type: %s", a)
+		}
+	}
+	return algos
+}
+
+// Calculate content hashes of all scanned files. Files which haven't changed
+// since the last scan reuse their previous hashes.
+func (s *systemScan) hash(algos []string) {
+	for _, p := range utils.AlphaMapKeys(s.files) {
+		f := s.files[p]
+		prev, ok := s.prevFiles[p]
+		if h := s.prevHashes[p]; ok && !s.full &&
+			prev.Size == f.Size && prev.ModTime == f.ModTime && h.has(algos) {
+			s.hashes[p] = h
+			continue
+		}
+
+		h, err := hashMedia(s.platform, p, algos)
+		if err != nil {
+			log.Warn().Err(err).Msgf("error hashing file: %s", p)
+			continue
+		}
+
+		s.hashes[p] = h
+		s.rehashed = append(s.rehashed, p)
+	}
+}
+
+// Files which were removed since the last scan.
+func (s *systemScan) removed() []string {
+	var paths []string
+	for p := range s.prevFiles {
+		if _, ok := s.files[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// Files which were added or modified since the last scan.
+func (s *systemScan) changed() []string {
+	var paths []string
+	for p, f := range s.files {
+		prev, ok := s.prevFiles[p]
+		if s.full || !ok || prev.Size != f.Size || prev.ModTime != f.ModTime {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// Write the new scan state to the database.
+func (s *systemScan) save(tx *bolt.Tx) error {
+	pre := systemPrefix(s.systemId)
+	bf := tx.Bucket([]byte(BucketScanFiles))
+	bd := tx.Bucket([]byte(BucketScanDirs))
+
+	for _, p := range s.removed() {
+		err := bf.Delete(append(append([]byte{}, pre...), p...))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, p := range s.changed() {
+		data, err := json.Marshal(s.files[p])
+		if err != nil {
+			return err
+		}
+
+		err = bf.Put(append(append([]byte{}, pre...), p...), data)
+		if err != nil {
+			return err
+		}
+	}
+
+	for d := range s.prevDirs {
+		if _, ok := s.dirs[d]; !ok {
+			err := bd.Delete(append(append([]byte{}, pre...), d...))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for d, sd := range s.dirs {
+		if prev, ok := s.prevDirs[d]; ok && prev == sd {
+			continue
+		}
+
+		data, err := json.Marshal(sd)
+		if err != nil {
+			return err
+		}
+
+		err = bd.Put(append(append([]byte{}, pre...), d...), data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
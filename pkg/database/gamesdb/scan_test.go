@@ -0,0 +1,145 @@
+package gamesdb
+
+import (
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+)
+
+var testNesLauncher = platforms.Launcher{
+	Id:         "NES",
+	SystemId:   "NES",
+	Folders:    []string{"NES"},
+	Extensions: []string{".nes"},
+}
+
+func crc(data string) string {
+	return fmt.Sprintf("%08x", crc32.ChecksumIEEE([]byte(data)))
+}
+
+// Set the modified time of a path, to fake changes to folders and files.
+func touch(t *testing.T, path string, mt time.Time) {
+	t.Helper()
+	err := os.Chtimes(path, mt, mt)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIncrementalScan(t *testing.T) {
+	pl := newTestPlatform(t, testNesLauncher)
+	cfg := newTestConfig(t, config.Values{
+		Media: config.Media{Hash: []string{HashCRC32}},
+	})
+
+	dir := filepath.Join(pl.roots[0], "NES")
+	a := filepath.Join(dir, "a.nes")
+	b := filepath.Join(dir, "b.nes")
+	c := filepath.Join(dir, "c.nes")
+	writeFile(t, a, "aaaa")
+	writeFile(t, b, "bbbb")
+	writeFile(t, filepath.Join(dir, "notes.txt"), "ignored")
+
+	index := func(full bool) map[string]MediaRecord {
+		t.Helper()
+		_, err := indexSystem(pl, cfg, IndexOptions{Full: full}, "NES", []string{dir}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return systemMedia(t, pl, "NES")
+	}
+
+	rs := index(false)
+	if len(rs) != 2 {
+		t.Fatalf("expected 2 records, got: %v", rs)
+	} else if rs[a].Hashes == nil || rs[a].Hashes.CRC32 != crc("aaaa") {
+		t.Fatalf("expected hash %s, got: %v", crc("aaaa"), rs[a].Hashes)
+	}
+
+	// a file overwritten in place doesn't change its folder's modified time
+	dirTime := time.Now().Add(-time.Hour)
+	touch(t, dir, dirTime)
+	index(false)
+	writeFile(t, a, "changed")
+	touch(t, a, time.Now().Add(time.Minute))
+	touch(t, dir, dirTime)
+
+	rs = index(false)
+	if rs[a].Hashes == nil || rs[a].Hashes.CRC32 != crc("changed") {
+		t.Fatalf("expected updated hash %s, got: %v", crc("changed"), rs[a].Hashes)
+	} else if rs[b].Hashes == nil || rs[b].Hashes.CRC32 != crc("bbbb") {
+		t.Fatalf("expected unchanged hash %s, got: %v", crc("bbbb"), rs[b].Hashes)
+	}
+
+	// new files in a folder which looks unchanged are only found by a full
+	// scan
+	writeFile(t, c, "cccc")
+	touch(t, dir, dirTime)
+
+	rs = index(false)
+	if _, ok := rs[c]; ok || len(rs) != 2 {
+		t.Fatalf("expected incremental scan to reuse folder listing, got: %v", rs)
+	}
+
+	rs = index(true)
+	if len(rs) != 3 {
+		t.Fatalf("expected full scan to find new file, got: %v", rs)
+	} else if rs[c].Hashes == nil || rs[c].Hashes.CRC32 != crc("cccc") {
+		t.Fatalf("expected hash %s, got: %v", crc("cccc"), rs[c].Hashes)
+	}
+
+	// removing a file changes the folder's modified time
+	err := os.Remove(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	touch(t, dir, time.Now())
+
+	rs = index(false)
+	if _, ok := rs[b]; ok || len(rs) != 2 {
+		t.Fatalf("expected removed file to be removed, got: %v", rs)
+	}
+}
+
+func TestIncrementalScanSubfolders(t *testing.T) {
+	pl := newTestPlatform(t, testNesLauncher)
+	cfg := newTestConfig(t, config.Values{})
+
+	dir := filepath.Join(pl.roots[0], "NES")
+	writeFile(t, filepath.Join(dir, "a.nes"), "a")
+	writeFile(t, filepath.Join(dir, "USA", "b.nes"), "b")
+
+	_, err := indexSystem(pl, cfg, IndexOptions{}, "NES", []string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new file in a subfolder is found without the parent changing
+	parentTime := time.Now().Add(-time.Hour)
+	touch(t, dir, parentTime)
+	_, err = indexSystem(pl, cfg, IndexOptions{}, "NES", []string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, filepath.Join(dir, "USA", "c.nes"), "c")
+	touch(t, dir, parentTime)
+
+	n, err := indexSystem(pl, cfg, IndexOptions{}, "NES", []string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 3 {
+		t.Fatalf("expected 3 files, got: %d", n)
+	}
+
+	rs := systemMedia(t, pl, "NES")
+	if _, ok := rs[filepath.Join(dir, "USA", "c.nes")]; !ok {
+		t.Fatalf("expected new file in subfolder, got: %v", rs)
+	}
+}
@@ -0,0 +1,376 @@
+package gamesdb
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+var romanNumerals = map[string]int{
+	"i": 1, "ii": 2, "iii": 3, "iv": 4, "v": 5, "vi": 6, "vii": 7,
+	"viii": 8, "ix": 9, "x": 10, "xi": 11, "xii": 12, "xiii": 13,
+	"xiv": 14, "xv": 15, "xvi": 16, "xvii": 17, "xviii": 18, "xix": 19,
+	"xx": 20,
+}
+
+const (
+	hackPenalty       = 30
+	prereleasePenalty = 20
+	otherPenalty      = 5
+)
+
+// searchToken is a single normalised word in a name or query. Roman numerals
+// are also matched against their number.
+type searchToken struct {
+	text string
+	num  string
+}
+
+// Convert a name to a lowercase ASCII form with diacritics removed and all
+// punctuation replaced with spaces.
+func normalizeName(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if r, _, err := transform.String(t, s); err == nil {
+		s = r
+	}
+
+	s = strings.ToLower(s)
+	s = strings.ReplaceAll(s, "&", " and ")
+	// keep contractions as a single word
+	s = strings.ReplaceAll(s, "'", "")
+	s = strings.ReplaceAll(s, "’", "")
+
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return ' '
+	}, s)
+}
+
+func tokenize(s string) []searchToken {
+	fields := strings.Fields(normalizeName(s))
+	tokens := make([]searchToken, 0, len(fields))
+
+	for _, f := range fields {
+		t := searchToken{text: f}
+		if n, ok := romanNumerals[f]; ok {
+			t.num = strconv.Itoa(n)
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens
+}
+
+// Score penalty for hacks, prerelease versions and other undesirable dumps.
+func tagsPenalty(t MediaTags) int {
+	penalty := 0
+	for _, f := range t.Flags {
+		switch f {
+		case FlagHack, FlagTranslation, FlagTrainer, FlagCracked,
+			FlagFixed, FlagBadDump, FlagOverdump:
+			penalty += hackPenalty
+		case FlagBeta, FlagProto, FlagDemo, FlagSample:
+			penalty += prereleasePenalty
+		case FlagAlternate, FlagPirate, FlagUnlicensed:
+			penalty += otherPenalty
+		}
+	}
+	return penalty
+}
+
+// Levenshtein distance between two strings.
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
+
+// Number of typos allowed in a query word of the given length.
+func maxTypos(n int) int {
+	switch {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Score how well a single query word matches a word in a name, out of 100.
+func scoreToken(q searchToken, n searchToken) int {
+	switch {
+	case q.text == n.text:
+		return 100
+	case (q.num != "" && q.num == n.text) || (n.num != "" && n.num == q.text):
+		return 95
+	case strings.HasPrefix(n.text, q.text):
+		return 80
+	}
+
+	if d := editDistance(q.text, n.text); d <= maxTypos(len(q.text)) {
+		return 70 - d*10
+	}
+
+	if len(q.text) >= 3 && strings.Contains(n.text, q.text) {
+		return 40
+	}
+
+	return 0
+}
+
+// Join the words of a name, optionally with roman numerals replaced by their
+// numbers.
+func joinTokens(tokens []searchToken, numbers bool) string {
+	texts := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if numbers && t.num != "" {
+			texts = append(texts, t.num)
+		} else {
+			texts = append(texts, t.text)
+		}
+	}
+	return strings.Join(texts, " ")
+}
+
+type searchQuery struct {
+	text string
+	// text with roman numerals replaced by numbers
+	numText string
+	tokens  []searchToken
+}
+
+func newSearchQuery(query string) searchQuery {
+	tokens := tokenize(query)
+	return searchQuery{
+		text:    joinTokens(tokens, false),
+		numText: joinTokens(tokens, true),
+		tokens:  tokens,
+	}
+}
+
+// Score a name against the query. Returns false if any word in the query
+// doesn't match the name.
+func (q searchQuery) score(name string) (int, bool) {
+	tags := ParseTags(name)
+	penalty := tagsPenalty(tags)
+
+	if len(q.tokens) == 0 {
+		return -penalty, true
+	}
+
+	tokens := tokenize(tags.Title)
+	normTitle := joinTokens(tokens, false)
+	numTitle := joinTokens(tokens, true)
+
+	// also match adjacent words written as one, e.g. megaman
+	joined := make([]searchToken, 0, len(tokens))
+	for i := 1; i < len(tokens); i++ {
+		joined = append(joined, searchToken{text: tokens[i-1].text + tokens[i].text})
+	}
+
+	total := 0
+	lastPos := -1
+	inOrder := true
+	for _, qt := range q.tokens {
+		best, bestPos := 0, -1
+		for i, nt := range tokens {
+			if s := scoreToken(qt, nt); s > best {
+				best, bestPos = s, i
+			}
+		}
+		for i, nt := range joined {
+			if s := scoreToken(qt, nt) - 5; s > best {
+				best, bestPos = s, i
+			}
+		}
+
+		if best == 0 {
+			return 0, false
+		}
+
+		if bestPos < lastPos {
+			inOrder = false
+		}
+		lastPos = bestPos
+		total += best
+	}
+
+	score := total / len(q.tokens)
+
+	// whole phrase matches, with either roman numerals or numbers
+	switch {
+	case normTitle == q.text || numTitle == q.numText:
+		score += 50
+	case strings.HasPrefix(normTitle, q.text+" ") ||
+		strings.HasPrefix(numTitle, q.numText+" "):
+		score += 20
+	case strings.Contains(" "+normTitle+" ", " "+q.text+" ") ||
+		strings.Contains(" "+numTitle+" ", " "+q.numText+" "):
+		score += 10
+	}
+
+	if inOrder {
+		score += 5
+	}
+
+	// prefer names without extra words, e.g. the original over a sequel
+	if extra := len(tokens) - len(q.tokens); extra > 0 {
+		score -= min(extra*2, 20)
+	}
+
+	return score - penalty, true
+}
+
+// Sort search results by score, best first. Equal scores are sorted by
+// shortest name and then alphabetically.
+func sortResults(results []SearchResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Path < b.Path
+	})
+}
+
+// Return indexed names matching query, ranked by relevance. Each word in the
+// query must match a word in the name, allowing for small typos. Names are
+// compared ignoring case, punctuation and diacritics, roman numerals match
+// their numbers and hacks and prerelease versions are ranked lower. Results
+// are limited to media matching the filter's tags.
+func SearchMedia(
+	platform platforms.Platform,
+	systems []System,
+	query string,
+	filter SearchFilter,
+) ([]SearchResult, error) {
+	if !Exists(platform) {
+		return nil, fmt.Errorf("gamesdb does not exist")
+	}
+
+	db, err := open(platform, &bolt.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer func(db *bolt.DB) {
+		err := db.Close()
+		if err != nil {
+			log.Warn().Err(err).Msg("closing database")
+		}
+	}(db)
+
+	q := newSearchQuery(query)
+	scores := make(map[string]int)
+	var results []SearchResult
+
+	err = db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(BucketNames)).Cursor()
+
+		for _, system := range systems {
+			pre := systemPrefix(system.Id)
+			for k, v := c.Seek(pre); k != nil && bytes.HasPrefix(k, pre); k, v = c.Next() {
+				name := string(v)
+
+				if _, ok := scores[name]; !ok {
+					s, ok := q.score(name)
+					if !ok {
+						continue
+					}
+					scores[name] = s
+				}
+
+				r, err := getMedia(tx, system.Id, nameKeyPath(k))
+				if err != nil {
+					return err
+				} else if r == nil || !filter.Match(r.Tags) {
+					continue
+				}
+
+				results = append(results, SearchResult{
+					SystemId: r.SystemId,
+					Name:     r.Name,
+					Path:     r.Path,
+					Tags:     r.Tags,
+					Title:    r.Title(),
+					Score:    scores[name],
+				})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortResults(results)
+
+	return results, nil
+}
+
+// Rank existing search results against a query, most relevant first. Results
+// are never removed, unmatched names are ranked last.
+func RankResults(query string, results []SearchResult) []SearchResult {
+	q := newSearchQuery(query)
+	scores := make(map[string]int)
+	sorted := make([]SearchResult, 0, len(results))
+
+	for _, r := range results {
+		s, ok := scores[r.Name]
+		if !ok {
+			s, ok = q.score(r.Name)
+			if !ok {
+				s = -1000 - tagsPenalty(ParseTags(r.Name))
+			}
+			scores[r.Name] = s
+		}
+
+		if r.Tags.Title == "" {
+			r.Tags = ParseTags(r.Name)
+		}
+
+		r.Score = s
+		sorted = append(sorted, r)
+	}
+
+	sortResults(sorted)
+
+	return sorted
+}
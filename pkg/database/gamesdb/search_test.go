@@ -0,0 +1,265 @@
+package gamesdb
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "mario", b: "mario", want: 0},
+		{a: "", b: "zelda", want: 5},
+		{a: "zelda", b: "", want: 5},
+		{a: "zelda", b: "zleda", want: 2},
+		{a: "metroid", b: "metroyd", want: 1},
+		{a: "castlevania", b: "castlevana", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "pokémon", b: "pokemon", want: 1},
+	}
+
+	for _, tc := range tests {
+		if got := editDistance(tc.a, tc.b); got != tc.want {
+			t.Fatalf("%q, %q: expected: %d, got: %d", tc.a, tc.b, tc.want, got)
+		}
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Pokémon Snap", want: "pokemon snap"},
+		{name: "Sonic & Knuckles", want: "sonic  and  knuckles"},
+		{name: "Kirby's Adventure", want: "kirbys adventure"},
+		{name: "Legend of Zelda, The - Ocarina of Time", want: "legend of zelda  the   ocarina of time"},
+		{name: "F-Zero", want: "f zero"},
+	}
+
+	for _, tc := range tests {
+		if got := normalizeName(tc.name); got != tc.want {
+			t.Fatalf("%q: expected: %q, got: %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func resultNames(results []SearchResult) []string {
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.Name
+	}
+	return names
+}
+
+func TestRankResults(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		names []string
+		want  string
+	}{
+		{
+			name:  "number",
+			query: "mario 3",
+			names: []string{
+				"Dr. Mario (Japan, USA)",
+				"Super Mario Bros. (World)",
+				"Super Mario Bros. 2 (USA)",
+				"Super Mario Bros. 3 (USA) (Beta)",
+				"Super Mario Bros. 3 (USA)",
+				"Super Mario Bros. 3 (USA) (Rev 1) [h1]",
+			},
+			want: "Super Mario Bros. 3 (USA)",
+		},
+		{
+			name:  "roman numeral query",
+			query: "final fantasy iii",
+			names: []string{
+				"Final Fantasy (USA)",
+				"Final Fantasy II (Japan)",
+				"Final Fantasy 3 (USA)",
+				"Final Fantasy III (Japan)",
+			},
+			want: "Final Fantasy III (Japan)",
+		},
+		{
+			name:  "roman numeral name",
+			query: "final fantasy 3",
+			names: []string{
+				"Final Fantasy (USA)",
+				"Final Fantasy II (Japan)",
+				"Final Fantasy III (Japan)",
+			},
+			want: "Final Fantasy III (Japan)",
+		},
+		{
+			name:  "number matches roman numeral",
+			query: "street fighter 2",
+			names: []string{
+				"Street Fighter Alpha 2 (USA)",
+				"Street Fighter (USA)",
+				"Street Fighter II - The World Warrior (USA)",
+			},
+			want: "Street Fighter II - The World Warrior (USA)",
+		},
+		{
+			name:  "typo",
+			query: "zelda ocrina",
+			names: []string{
+				"Legend of Zelda, The - Majora's Mask (USA)",
+				"Legend of Zelda, The - Ocarina of Time (USA)",
+				"Legend of Zelda, The - A Link to the Past (USA)",
+			},
+			want: "Legend of Zelda, The - Ocarina of Time (USA)",
+		},
+		{
+			name:  "long word typos",
+			query: "castlevanai",
+			names: []string{
+				"Contra (USA)",
+				"Castlevania II - Simon's Quest (USA)",
+				"Castlevania (USA) (Rev 1)",
+			},
+			want: "Castlevania (USA) (Rev 1)",
+		},
+		{
+			name:  "joined words",
+			query: "megaman 2",
+			names: []string{
+				"Mega Man (USA)",
+				"Mega Man 2 (USA)",
+				"Mega Man 3 (USA)",
+			},
+			want: "Mega Man 2 (USA)",
+		},
+		{
+			name:  "exact title over sequel",
+			query: "sonic the hedgehog",
+			names: []string{
+				"Sonic the Hedgehog 2 (World)",
+				"Sonic the Hedgehog 3 (USA)",
+				"Sonic the Hedgehog (USA, Europe)",
+			},
+			want: "Sonic the Hedgehog (USA, Europe)",
+		},
+		{
+			name:  "hacks ranked lower",
+			query: "metroid",
+			names: []string{
+				"Metroid (USA) [h2]",
+				"Metroid (USA) (Proto)",
+				"Metroid (USA) [T+Fre]",
+				"Metroid (USA)",
+			},
+			want: "Metroid (USA)",
+		},
+		{
+			name:  "diacritics",
+			query: "pokemon snap",
+			names: []string{
+				"Pokémon Stadium (USA)",
+				"Pokémon Snap (USA)",
+			},
+			want: "Pokémon Snap (USA)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			results := make([]SearchResult, len(tc.names))
+			for i, n := range tc.names {
+				results[i] = SearchResult{Name: n}
+			}
+
+			ranked := RankResults(tc.query, results)
+			if len(ranked) != len(tc.names) {
+				t.Fatalf("expected %d results, got: %v", len(tc.names), resultNames(ranked))
+			} else if ranked[0].Name != tc.want {
+				t.Fatalf("expected: %q, got: %q", tc.want, resultNames(ranked))
+			}
+
+			for i := 1; i < len(ranked); i++ {
+				if ranked[i].Score > ranked[i-1].Score {
+					t.Fatalf("results not sorted by score: %v", ranked)
+				}
+			}
+		})
+	}
+}
+
+func TestRankResultsUnmatchedLast(t *testing.T) {
+	ranked := RankResults("tetris", []SearchResult{
+		{Name: "Dr. Mario (Japan, USA)"},
+		{Name: "Tetris (USA) (Beta)"},
+		{Name: "Tetris 2 (USA)"},
+	})
+
+	if len(ranked) != 3 || ranked[2].Name != "Dr. Mario (Japan, USA)" {
+		t.Fatalf("expected unmatched name last, got: %q", resultNames(ranked))
+	} else if ranked[2].Score >= 0 {
+		t.Fatalf("expected negative score for unmatched name, got: %d", ranked[2].Score)
+	} else if ranked[2].Tags.Title != "Dr. Mario" {
+		t.Fatalf("expected tags to be parsed, got: %v", ranked[2].Tags)
+	}
+}
+
+func TestSearchMedia(t *testing.T) {
+	pl := newTestPlatform(t, testNesLauncher)
+	cfg := newTestConfig(t, config.Values{})
+
+	dir := filepath.Join(pl.roots[0], "NES")
+	for _, name := range []string{
+		"Super Mario Bros. (World).nes",
+		"Super Mario Bros. 2 (USA).nes",
+		"Super Mario Bros. 3 (USA).nes",
+		"Super Mario Bros. 3 (Japan).nes",
+		"Super Mario Bros. 3 (USA) (Beta).nes",
+		"Zelda II - The Adventure of Link (USA).nes",
+	} {
+		writeFile(t, filepath.Join(dir, name), name)
+	}
+
+	_, err := indexSystem(pl, cfg, IndexOptions{}, "NES", []string{dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	systems := []System{{Id: "NES"}}
+
+	results, err := SearchMedia(pl, systems, "mario 3", SearchFilter{})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != 3 {
+		t.Fatalf("expected 3 results, got: %v", resultNames(results))
+	} else if results[2].Tags.Title != "Super Mario Bros. 3" || !results[2].Tags.HasFlag(FlagBeta) {
+		t.Fatalf("expected beta last, got: %v", resultNames(results))
+	}
+
+	results, err = SearchMedia(pl, systems, "mario 3", ParseSearchFilter("usa", "prerelease"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != 1 || results[0].Path != filepath.Join(dir, "Super Mario Bros. 3 (USA).nes") {
+		t.Fatalf("expected only the USA release, got: %v", resultNames(results))
+	}
+
+	results, err = SearchMedia(pl, systems, "zelda 2", SearchFilter{})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != 1 || results[0].Tags.Title != "Zelda II - The Adventure of Link" {
+		t.Fatalf("expected zelda ii, got: %v", resultNames(results))
+	}
+
+	results, err = SearchMedia(pl, systems, "metroid", SearchFilter{})
+	if err != nil {
+		t.Fatal(err)
+	} else if len(results) != 0 {
+		t.Fatalf("expected no results, got: %v", resultNames(results))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 )
 
@@ -15,9 +16,71 @@ import (
 // This list also contains some basic heuristics which, given a file path, can
 // be used to attempt to associate a file with a system.
 
+const (
+	MediaTypeGame  = "game"
+	MediaTypeAudio = "audio"
+	MediaTypeVideo = "video"
+)
+
 type System struct {
 	Id      string
 	Aliases []string
+	// ID of the system this is a variant of, e.g. a multiplayer core which
+	// plays the same media as its parent.
+	Parent string
+	// Type of media the system plays, defaults to game.
+	MediaType string
+	// Number of local players, only set for multiplayer variants.
+	Players int
+	// Default folder names for the system's media, following the MiSTer
+	// layout. This is reference data, platform launchers define the folders
+	// which are actually indexed, see SystemFolders.
+	Folders []string
+	// File extensions commonly used for the system's media. Like folders,
+	// launchers define the extensions which are actually indexed, see
+	// SystemExtensions.
+	Extensions []string
+}
+
+// GetMediaType returns the type of media the system plays.
+func (s System) GetMediaType() string {
+	if s.MediaType == "" {
+		return MediaTypeGame
+	}
+	return s.MediaType
+}
+
+// Append values to a list, skipping any already in it (case insensitive).
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, l := range list {
+			if strings.EqualFold(l, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// WithOverrides returns a copy of the system with the aliases, folders and
+// extensions from any matching [[systems.override]] entries in the config
+// added to it.
+func (s System) WithOverrides(cfg *config.Instance) System {
+	if cfg == nil {
+		return s
+	}
+
+	o := cfg.SystemOverride(s.Id)
+	s.Aliases = appendUnique(append([]string{}, s.Aliases...), o.Aliases...)
+	s.Folders = appendUnique(append([]string{}, s.Folders...), o.Folders...)
+	s.Extensions = appendUnique(append([]string{}, s.Extensions...), o.Extensions...)
+
+	return s
 }
 
 // GetSystem looks up an exact system definition by ID.
@@ -29,9 +92,12 @@ func GetSystem(id string) (*System, error) {
 	}
 }
 
-// LookupSystem case-insensitively looks up system ID definition including aliases.
-func LookupSystem(id string) (*System, error) {
+// LookupSystem case-insensitively looks up system ID definition including
+// aliases, and any aliases added in the config's system overrides.
+func LookupSystem(cfg *config.Instance, id string) (*System, error) {
 	for k, v := range Systems {
+		v = v.WithOverrides(cfg)
+
 		if strings.EqualFold(k, id) {
 			return &v, nil
 		}
@@ -170,349 +236,563 @@ const (
 var Systems = map[string]System{
 	// Consoles
 	SystemAdventureVision: {
-		Id:      SystemAdventureVision,
-		Aliases: []string{"AVision"},
+		Id:         SystemAdventureVision,
+		Aliases:    []string{"AVision"},
+		Folders:    []string{"AVision"},
+		Extensions: []string{".bin"},
 	},
 	SystemArcadia: {
-		Id: SystemArcadia,
+		Id:         SystemArcadia,
+		Folders:    []string{"Arcadia"},
+		Extensions: []string{".bin"},
 	},
 	SystemAstrocade: {
-		Id: SystemAstrocade,
+		Id:         SystemAstrocade,
+		Folders:    []string{"Astrocade"},
+		Extensions: []string{".bin"},
 	},
 	SystemAtari2600: {
-		Id: SystemAtari2600,
+		Id:         SystemAtari2600,
+		Folders:    []string{"ATARI7800", "Atari2600"},
+		Extensions: []string{".a26"},
 	},
 	SystemAtari5200: {
-		Id: SystemAtari5200,
+		Id:         SystemAtari5200,
+		Folders:    []string{"ATARI5200"},
+		Extensions: []string{".a52"},
 	},
 	SystemAtari7800: {
-		Id: SystemAtari7800,
+		Id:         SystemAtari7800,
+		Folders:    []string{"ATARI7800"},
+		Extensions: []string{".a78"},
 	},
 	SystemAtariLynx: {
-		Id: SystemAtariLynx,
+		Id:         SystemAtariLynx,
+		Folders:    []string{"AtariLynx"},
+		Extensions: []string{".lnx"},
 	},
 	SystemCasioPV1000: {
-		Id:      SystemCasioPV1000,
-		Aliases: []string{"Casio_PV-1000"},
+		Id:         SystemCasioPV1000,
+		Aliases:    []string{"Casio_PV-1000"},
+		Folders:    []string{"Casio_PV-1000"},
+		Extensions: []string{".bin"},
 	},
 	SystemChannelF: {
-		Id: SystemChannelF,
+		Id:         SystemChannelF,
+		Folders:    []string{"ChannelF"},
+		Extensions: []string{".rom", ".bin"},
 	},
 	SystemColecoVision: {
-		Id:      SystemColecoVision,
-		Aliases: []string{"Coleco"},
+		Id:         SystemColecoVision,
+		Aliases:    []string{"Coleco"},
+		Folders:    []string{"Coleco"},
+		Extensions: []string{".col", ".bin", ".rom"},
 	},
 	SystemCreatiVision: {
-		Id: SystemCreatiVision,
+		Id:         SystemCreatiVision,
+		Folders:    []string{"CreatiVision"},
+		Extensions: []string{".rom", ".bin", ".bas"},
 	},
 	SystemFDS: {
-		Id:      SystemFDS,
-		Aliases: []string{"FamicomDiskSystem"},
+		Id:         SystemFDS,
+		Aliases:    []string{"FamicomDiskSystem"},
+		Folders:    []string{"NES", "FDS"},
+		Extensions: []string{".fds"},
 	},
 	SystemGamate: {
-		Id: SystemGamate,
+		Id:         SystemGamate,
+		Folders:    []string{"Gamate"},
+		Extensions: []string{".bin"},
 	},
 	SystemGameboy: {
-		Id:      SystemGameboy,
-		Aliases: []string{"GB"},
+		Id:         SystemGameboy,
+		Aliases:    []string{"GB"},
+		Folders:    []string{"GAMEBOY"},
+		Extensions: []string{".gb"},
 	},
 	SystemGameboyColor: {
-		Id:      SystemGameboyColor,
-		Aliases: []string{"GBC"},
+		Id:         SystemGameboyColor,
+		Aliases:    []string{"GBC"},
+		Folders:    []string{"GAMEBOY", "GBC"},
+		Extensions: []string{".gbc"},
 	},
 	SystemGameboy2P: {
 		// TODO: Split 2P core into GB and GBC?
-		Id: SystemGameboy2P,
+		Id:         SystemGameboy2P,
+		Parent:     SystemGameboy,
+		Players:    2,
+		Folders:    []string{"GAMEBOY2P"},
+		Extensions: []string{".gb", ".gbc"},
 	},
 	SystemGameGear: {
-		Id:      SystemGameGear,
-		Aliases: []string{"GG"},
+		Id:         SystemGameGear,
+		Aliases:    []string{"GG"},
+		Folders:    []string{"SMS", "GameGear"},
+		Extensions: []string{".gg"},
 	},
 	SystemGameNWatch: {
-		Id: SystemGameNWatch,
+		Id:         SystemGameNWatch,
+		Folders:    []string{"GameNWatch"},
+		Extensions: []string{".bin"},
 	},
 	SystemGBA: {
-		Id:      SystemGBA,
-		Aliases: []string{"GameboyAdvance"},
+		Id:         SystemGBA,
+		Aliases:    []string{"GameboyAdvance"},
+		Folders:    []string{"GBA"},
+		Extensions: []string{".gba"},
 	},
 	SystemGBA2P: {
-		Id: SystemGBA2P,
+		Id:         SystemGBA2P,
+		Parent:     SystemGBA,
+		Players:    2,
+		Folders:    []string{"GBA2P"},
+		Extensions: []string{".gba"},
 	},
 	SystemGenesis: {
-		Id:      SystemGenesis,
-		Aliases: []string{"MegaDrive"},
+		Id:         SystemGenesis,
+		Aliases:    []string{"MegaDrive"},
+		Folders:    []string{"MegaDrive", "Genesis"},
+		Extensions: []string{".gen", ".bin", ".md"},
 	},
 	SystemIntellivision: {
-		Id: SystemIntellivision,
+		Id:         SystemIntellivision,
+		Folders:    []string{"Intellivision"},
+		Extensions: []string{".int", ".bin"},
 	},
 	// TODO: Jaguar
 	SystemMasterSystem: {
-		Id:      SystemMasterSystem,
-		Aliases: []string{"SMS"},
+		Id:         SystemMasterSystem,
+		Aliases:    []string{"SMS"},
+		Folders:    []string{"SMS"},
+		Extensions: []string{".sms"},
 	},
 	SystemMegaCD: {
-		Id:      SystemMegaCD,
-		Aliases: []string{"SegaCD"},
+		Id:         SystemMegaCD,
+		Aliases:    []string{"SegaCD"},
+		Folders:    []string{"MegaCD"},
+		Extensions: []string{".cue", ".chd"},
 	},
 	SystemMegaDuck: {
-		Id: SystemMegaDuck,
+		Id:         SystemMegaDuck,
+		Folders:    []string{"GAMEBOY", "MegaDuck"},
+		Extensions: []string{".bin"},
 	},
 	SystemNeoGeo: {
-		Id: SystemNeoGeo,
+		Id:         SystemNeoGeo,
+		Folders:    []string{"NEOGEO"},
+		Extensions: []string{".neo"},
 	},
 	SystemNeoGeoCD: {
-		Id: SystemNeoGeoCD,
+		Id:         SystemNeoGeoCD,
+		Folders:    []string{"NeoGeo-CD", "NEOGEO"},
+		Extensions: []string{".cue", ".chd"},
 	},
 	SystemNES: {
-		Id: SystemNES,
+		Id:         SystemNES,
+		Folders:    []string{"NES"},
+		Extensions: []string{".nes"},
 	},
 	SystemNESMusic: {
-		Id: SystemNESMusic,
+		Id:         SystemNESMusic,
+		MediaType:  MediaTypeAudio,
+		Folders:    []string{"NES"},
+		Extensions: []string{".nsf"},
 	},
 	SystemNintendo64: {
-		Id:      SystemNintendo64,
-		Aliases: []string{"N64"},
+		Id:         SystemNintendo64,
+		Aliases:    []string{"N64"},
+		Folders:    []string{"N64"},
+		Extensions: []string{".n64", ".z64"},
 	},
 	SystemOdyssey2: {
-		Id: SystemOdyssey2,
+		Id:         SystemOdyssey2,
+		Folders:    []string{"ODYSSEY2"},
+		Extensions: []string{".bin"},
 	},
 	SystemPocketChallengeV2: {
-		Id: SystemPocketChallengeV2,
+		Id:         SystemPocketChallengeV2,
+		Folders:    []string{"WonderSwan", "PocketChallengeV2"},
+		Extensions: []string{".pc2"},
 	},
 	SystemPokemonMini: {
-		Id: SystemPokemonMini,
+		Id:         SystemPokemonMini,
+		Folders:    []string{"PokemonMini"},
+		Extensions: []string{".min"},
 	},
 	SystemPSX: {
-		Id:      SystemPSX,
-		Aliases: []string{"Playstation", "PS1"},
+		Id:         SystemPSX,
+		Aliases:    []string{"Playstation", "PS1"},
+		Folders:    []string{"PSX"},
+		Extensions: []string{".cue", ".chd", ".exe"},
 	},
 	SystemSega32X: {
-		Id:      SystemSega32X,
-		Aliases: []string{"S32X", "32X"},
+		Id:         SystemSega32X,
+		Aliases:    []string{"S32X", "32X"},
+		Folders:    []string{"S32X"},
+		Extensions: []string{".32x"},
 	},
 	SystemSG1000: {
-		Id: SystemSG1000,
+		Id:         SystemSG1000,
+		Folders:    []string{"SG1000", "Coleco", "SMS"},
+		Extensions: []string{".sg"},
 	},
 	SystemSuperGameboy: {
-		Id:      SystemSuperGameboy,
-		Aliases: []string{"SGB"},
+		Id:         SystemSuperGameboy,
+		Aliases:    []string{"SGB"},
+		Parent:     SystemGameboy,
+		Folders:    []string{"SGB"},
+		Extensions: []string{".sgb", ".gb", ".gbc"},
 	},
 	SystemSuperVision: {
-		Id: SystemSuperVision,
+		Id:         SystemSuperVision,
+		Folders:    []string{"SuperVision"},
+		Extensions: []string{".bin", ".sv"},
 	},
 	SystemSaturn: {
-		Id: SystemSaturn,
+		Id:         SystemSaturn,
+		Folders:    []string{"Saturn"},
+		Extensions: []string{".cue", ".chd"},
 	},
 	SystemSNES: {
-		Id:      SystemSNES,
-		Aliases: []string{"SuperNintendo"},
+		Id:         SystemSNES,
+		Aliases:    []string{"SuperNintendo"},
+		Folders:    []string{"SNES"},
+		Extensions: []string{".sfc", ".smc", ".bin", ".bs"},
 	},
 	SystemSNESMusic: {
-		Id: SystemSNESMusic,
+		Id:         SystemSNESMusic,
+		MediaType:  MediaTypeAudio,
+		Folders:    []string{"SNES"},
+		Extensions: []string{".spc"},
 	},
 	SystemSuperGrafx: {
-		Id: SystemSuperGrafx,
+		Id:         SystemSuperGrafx,
+		Folders:    []string{"TGFX16"},
+		Extensions: []string{".sgx"},
 	},
 	SystemTurboGrafx16: {
-		Id:      SystemTurboGrafx16,
-		Aliases: []string{"TGFX16", "PCEngine"},
+		Id:         SystemTurboGrafx16,
+		Aliases:    []string{"TGFX16", "PCEngine"},
+		Folders:    []string{"TGFX16"},
+		Extensions: []string{".pce", ".bin"},
 	},
 	SystemTurboGrafx16CD: {
-		Id:      SystemTurboGrafx16CD,
-		Aliases: []string{"TGFX16-CD", "PCEngineCD"},
+		Id:         SystemTurboGrafx16CD,
+		Aliases:    []string{"TGFX16-CD", "PCEngineCD"},
+		Folders:    []string{"TGFX16-CD"},
+		Extensions: []string{".cue", ".chd"},
 	},
 	SystemVC4000: {
-		Id: SystemVC4000,
+		Id:         SystemVC4000,
+		Folders:    []string{"VC4000"},
+		Extensions: []string{".bin"},
 	},
 	SystemVectrex: {
-		Id: SystemVectrex,
+		Id:         SystemVectrex,
+		Folders:    []string{"VECTREX"},
+		Extensions: []string{".vec", ".bin", ".rom"},
 	},
 	SystemWonderSwan: {
-		Id: SystemWonderSwan,
+		Id:         SystemWonderSwan,
+		Folders:    []string{"WonderSwan"},
+		Extensions: []string{".ws"},
 	},
 	SystemWonderSwanColor: {
-		Id: SystemWonderSwanColor,
+		Id:         SystemWonderSwanColor,
+		Folders:    []string{"WonderSwan", "WonderSwanColor"},
+		Extensions: []string{".wsc"},
 	},
 	// Computers
 	SystemAcornAtom: {
-		Id: SystemAcornAtom,
+		Id:         SystemAcornAtom,
+		Folders:    []string{"AcornAtom"},
+		Extensions: []string{".vhd"},
 	},
 	SystemAcornElectron: {
-		Id: SystemAcornElectron,
+		Id:         SystemAcornElectron,
+		Folders:    []string{"AcornElectron"},
+		Extensions: []string{".vhd"},
 	},
 	SystemAliceMC10: {
-		Id: SystemAliceMC10,
+		Id:         SystemAliceMC10,
+		Folders:    []string{"AliceMC10"},
+		Extensions: []string{".c10"},
 	},
 	SystemAmiga: {
-		Id:      SystemAmiga,
-		Aliases: []string{"Minimig"},
+		Id:         SystemAmiga,
+		Aliases:    []string{"Minimig"},
+		Folders:    []string{"Amiga"},
+		Extensions: []string{".adf"},
 	},
 	SystemAmstrad: {
-		Id: SystemAmstrad,
+		Id:         SystemAmstrad,
+		Folders:    []string{"Amstrad"},
+		Extensions: []string{".dsk", ".cdt"},
 	},
 	SystemAmstradPCW: {
-		Id:      SystemAmstradPCW,
-		Aliases: []string{"Amstrad-PCW"},
+		Id:         SystemAmstradPCW,
+		Aliases:    []string{"Amstrad-PCW"},
+		Folders:    []string{"Amstrad PCW"},
+		Extensions: []string{".dsk"},
 	},
 	SystemDOS: {
-		Id:      SystemDOS,
-		Aliases: []string{"ao486", "MS-DOS"},
+		Id:         SystemDOS,
+		Aliases:    []string{"ao486", "MS-DOS"},
+		Folders:    []string{"AO486"},
+		Extensions: []string{".img", ".ima", ".vhd", ".vfd", ".iso", ".cue", ".chd"},
 	},
 	SystemApogee: {
-		Id: SystemApogee,
+		Id:         SystemApogee,
+		Folders:    []string{"APOGEE"},
+		Extensions: []string{".rka", ".rkr", ".gam"},
 	},
 	SystemAppleI: {
-		Id:      SystemAppleI,
-		Aliases: []string{"Apple-I"},
+		Id:         SystemAppleI,
+		Aliases:    []string{"Apple-I"},
+		Folders:    []string{"Apple-I"},
+		Extensions: []string{".txt"},
 	},
 	SystemAppleII: {
-		Id:      SystemAppleII,
-		Aliases: []string{"Apple-II"},
+		Id:         SystemAppleII,
+		Aliases:    []string{"Apple-II"},
+		Folders:    []string{"Apple-II"},
+		Extensions: []string{".dsk", ".do", ".po", ".nib", ".hdv"},
 	},
 	SystemAquarius: {
-		Id: SystemAquarius,
+		Id:         SystemAquarius,
+		Folders:    []string{"AQUARIUS"},
+		Extensions: []string{".bin", ".caq"},
 	},
 	SystemAtari800: {
-		Id: SystemAtari800,
+		Id:         SystemAtari800,
+		Folders:    []string{"ATARI800"},
+		Extensions: []string{".atr", ".xex", ".xfd", ".atx", ".car", ".rom", ".bin"},
 	},
 	SystemBBCMicro: {
-		Id: SystemBBCMicro,
+		Id:         SystemBBCMicro,
+		Folders:    []string{"BBCMicro"},
+		Extensions: []string{".ssd", ".dsd", ".vhd"},
 	},
 	SystemBK0011M: {
-		Id: SystemBK0011M,
+		Id:         SystemBK0011M,
+		Folders:    []string{"BK0011M"},
+		Extensions: []string{".bin", ".dsk", ".vhd"},
 	},
 	SystemC16: {
-		Id: SystemC16,
+		Id:         SystemC16,
+		Folders:    []string{"C16"},
+		Extensions: []string{".d64", ".g64", ".prg", ".tap", ".bin"},
 	},
 	SystemC64: {
-		Id: SystemC64,
+		Id:         SystemC64,
+		Folders:    []string{"C64"},
+		Extensions: []string{".d64", ".g64", ".t64", ".d81", ".prg", ".crt", ".reu", ".tap"},
 	},
 	SystemCasioPV2000: {
-		Id:      SystemCasioPV2000,
-		Aliases: []string{"Casio_PV-2000"},
+		Id:         SystemCasioPV2000,
+		Aliases:    []string{"Casio_PV-2000"},
+		Folders:    []string{"Casio_PV-2000"},
+		Extensions: []string{".bin"},
 	},
 	SystemCoCo2: {
-		Id: SystemCoCo2,
+		Id:         SystemCoCo2,
+		Folders:    []string{"CoCo2"},
+		Extensions: []string{".dsk", ".cas", ".ccc", ".rom"},
 	},
 	SystemEDSAC: {
-		Id: SystemEDSAC,
+		Id:         SystemEDSAC,
+		Folders:    []string{"EDSAC"},
+		Extensions: []string{".tap"},
 	},
 	SystemGalaksija: {
-		Id: SystemGalaksija,
+		Id:         SystemGalaksija,
+		Folders:    []string{"Galaksija"},
+		Extensions: []string{".tap"},
 	},
 	SystemInteract: {
-		Id: SystemInteract,
+		Id:         SystemInteract,
+		Folders:    []string{"Interact"},
+		Extensions: []string{".cin", ".k7"},
 	},
 	SystemJupiter: {
-		Id: SystemJupiter,
+		Id:         SystemJupiter,
+		Folders:    []string{"Jupiter"},
+		Extensions: []string{".ace"},
 	},
 	SystemLaser: {
-		Id:      SystemLaser,
-		Aliases: []string{"Laser310"},
+		Id:         SystemLaser,
+		Aliases:    []string{"Laser310"},
+		Folders:    []string{"Laser"},
+		Extensions: []string{".vz"},
 	},
 	SystemLynx48: {
-		Id: SystemLynx48,
+		Id:         SystemLynx48,
+		Folders:    []string{"Lynx48"},
+		Extensions: []string{".tap"},
 	},
 	SystemMacPlus: {
-		Id: SystemMacPlus,
+		Id:         SystemMacPlus,
+		Folders:    []string{"MACPLUS"},
+		Extensions: []string{".dsk", ".img", ".vhd"},
 	},
 	SystemMSX: {
-		Id: SystemMSX,
+		Id:         SystemMSX,
+		Folders:    []string{"MSX"},
+		Extensions: []string{".vhd"},
 	},
 	SystemMultiComp: {
-		Id: SystemMultiComp,
+		Id:         SystemMultiComp,
+		Folders:    []string{"MultiComp"},
+		Extensions: []string{".img"},
 	},
 	SystemOrao: {
-		Id: SystemOrao,
+		Id:         SystemOrao,
+		Folders:    []string{"ORAO"},
+		Extensions: []string{".tap"},
 	},
 	SystemOric: {
-		Id: SystemOric,
+		Id:         SystemOric,
+		Folders:    []string{"Oric"},
+		Extensions: []string{".dsk"},
 	},
 	SystemPC: {
 		Id: SystemPC,
 	},
 	SystemPCXT: {
-		Id: SystemPCXT,
+		Id:         SystemPCXT,
+		Folders:    []string{"PCXT"},
+		Extensions: []string{".img", ".vhd", ".ima", ".vfd"},
 	},
 	SystemPDP1: {
-		Id: SystemPDP1,
+		Id:         SystemPDP1,
+		Folders:    []string{"PDP1"},
+		Extensions: []string{".bin", ".rim", ".pdp"},
 	},
 	SystemPET2001: {
-		Id: SystemPET2001,
+		Id:         SystemPET2001,
+		Folders:    []string{"PET2001"},
+		Extensions: []string{".prg", ".tap"},
 	},
 	SystemPMD85: {
-		Id: SystemPMD85,
+		Id:         SystemPMD85,
+		Folders:    []string{"PMD85"},
+		Extensions: []string{".rmm"},
 	},
 	SystemQL: {
-		Id: SystemQL,
+		Id:         SystemQL,
+		Folders:    []string{"QL"},
+		Extensions: []string{".mdv", ".win"},
 	},
 	SystemRX78: {
-		Id: SystemRX78,
+		Id:         SystemRX78,
+		Folders:    []string{"RX78"},
+		Extensions: []string{".bin"},
 	},
 	SystemSAMCoupe: {
-		Id: SystemSAMCoupe,
+		Id:         SystemSAMCoupe,
+		Folders:    []string{"SAMCOUPE"},
+		Extensions: []string{".dsk", ".mgt", ".img"},
 	},
 	SystemSordM5: {
-		Id:      SystemSordM5,
-		Aliases: []string{"Sord M5"},
+		Id:         SystemSordM5,
+		Aliases:    []string{"Sord M5"},
+		Folders:    []string{"Sord M5"},
+		Extensions: []string{".bin", ".rom", ".cas"},
 	},
 	SystemSpecialist: {
-		Id:      SystemSpecialist,
-		Aliases: []string{"SPMX"},
+		Id:         SystemSpecialist,
+		Aliases:    []string{"SPMX"},
+		Folders:    []string{"SPMX"},
+		Extensions: []string{".rks", ".odi"},
 	},
 	SystemSVI328: {
-		Id: SystemSVI328,
+		Id:         SystemSVI328,
+		Folders:    []string{"SVI328"},
+		Extensions: []string{".cas", ".bin", ".rom"},
 	},
 	SystemTatungEinstein: {
-		Id: SystemTatungEinstein,
+		Id:         SystemTatungEinstein,
+		Folders:    []string{"TatungEinstein"},
+		Extensions: []string{".dsk"},
 	},
 	SystemTI994A: {
-		Id:      SystemTI994A,
-		Aliases: []string{"TI-99_4A"},
+		Id:         SystemTI994A,
+		Aliases:    []string{"TI-99_4A"},
+		Folders:    []string{"TI-99_4A"},
+		Extensions: []string{".bin", ".m99"},
 	},
 	SystemTomyTutor: {
-		Id: SystemTomyTutor,
+		Id:         SystemTomyTutor,
+		Folders:    []string{"TomyTutor"},
+		Extensions: []string{".bin", ".cas"},
 	},
 	SystemTRS80: {
-		Id: SystemTRS80,
+		Id:         SystemTRS80,
+		Folders:    []string{"TRS-80"},
+		Extensions: []string{".jvi", ".dsk", ".cas"},
 	},
 	SystemTSConf: {
-		Id: SystemTSConf,
+		Id:         SystemTSConf,
+		Folders:    []string{"TSConf"},
+		Extensions: []string{".vhf"},
 	},
 	SystemUK101: {
-		Id: SystemUK101,
+		Id:         SystemUK101,
+		Folders:    []string{"UK101"},
+		Extensions: []string{".txt", ".bas", ".lod"},
 	},
 	SystemVector06C: {
-		Id:      SystemVector06C,
-		Aliases: []string{"Vector06"},
+		Id:         SystemVector06C,
+		Aliases:    []string{"Vector06"},
+		Folders:    []string{"VECTOR06"},
+		Extensions: []string{".rom", ".com", ".c00", ".edd", ".fdd"},
 	},
 	SystemVIC20: {
-		Id: SystemVIC20,
+		Id:         SystemVIC20,
+		Folders:    []string{"VIC20"},
+		Extensions: []string{".d64", ".g64", ".prg", ".tap", ".crt"},
 	},
 	SystemX68000: {
-		Id: SystemX68000,
+		Id:         SystemX68000,
+		Folders:    []string{"X68000"},
+		Extensions: []string{".d88", ".hdf"},
 	},
 	SystemZX81: {
-		Id: SystemZX81,
+		Id:         SystemZX81,
+		Folders:    []string{"ZX81"},
+		Extensions: []string{".p", ".0"},
 	},
 	SystemZXSpectrum: {
-		Id:      SystemZXSpectrum,
-		Aliases: []string{"Spectrum"},
+		Id:         SystemZXSpectrum,
+		Aliases:    []string{"Spectrum"},
+		Folders:    []string{"Spectrum"},
+		Extensions: []string{".tap", ".csw", ".tzx", ".sna", ".z80", ".trd", ".img", ".dsk", ".mgt"},
 	},
 	SystemZXNext: {
-		Id: SystemZXNext,
+		Id:         SystemZXNext,
+		Folders:    []string{"ZXNext"},
+		Extensions: []string{".vhd"},
 	},
 	// Other
 	SystemArcade: {
-		Id: SystemArcade,
+		Id:         SystemArcade,
+		Folders:    []string{"_Arcade"},
+		Extensions: []string{".mra"},
 	},
 	SystemArduboy: {
-		Id: SystemArduboy,
+		Id:         SystemArduboy,
+		Folders:    []string{"Arduboy"},
+		Extensions: []string{".hex", ".bin"},
 	},
 	SystemChip8: {
-		Id: SystemChip8,
+		Id:         SystemChip8,
+		Folders:    []string{"Chip8"},
+		Extensions: []string{".ch8"},
 	},
 	SystemVideo: {
-		Id: SystemVideo,
+		Id:         SystemVideo,
+		MediaType:  MediaTypeVideo,
+		Folders:    []string{"Video", "Movies", "TV"},
+		Extensions: []string{".mp4", ".mkv", ".avi"},
 	},
 }
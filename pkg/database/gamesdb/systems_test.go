@@ -0,0 +1,129 @@
+package gamesdb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+)
+
+var testOverrides = config.Values{
+	Systems: config.Systems{
+		Override: []config.SystemsOverride{
+			{
+				System:     "NES",
+				Aliases:    []string{"Famicom", "nintendo"},
+				Folders:    []string{"Famicom"},
+				Extensions: []string{".UNF", ".nes"},
+			},
+			{
+				// entries for the same system are merged
+				System:     "nes",
+				Folders:    []string{"NES", "Homebrew"},
+				Extensions: []string{".unif"},
+			},
+			{
+				System:  "Genesis",
+				Aliases: []string{"Mega Drive"},
+			},
+		},
+	},
+}
+
+func TestSystemOverride(t *testing.T) {
+	cfg := newTestConfig(t, testOverrides)
+
+	o := cfg.SystemOverride("NES")
+	if !reflect.DeepEqual(o.Aliases, []string{"Famicom", "nintendo"}) {
+		t.Fatalf("unexpected aliases: %v", o.Aliases)
+	} else if !reflect.DeepEqual(o.Folders, []string{"Famicom", "NES", "Homebrew"}) {
+		t.Fatalf("unexpected folders: %v", o.Folders)
+	} else if !reflect.DeepEqual(o.Extensions, []string{".UNF", ".nes", ".unif"}) {
+		t.Fatalf("unexpected extensions: %v", o.Extensions)
+	}
+
+	if o := cfg.SystemOverride("SNES"); len(o.Folders) != 0 || o.System != "SNES" {
+		t.Fatalf("expected empty override, got: %+v", o)
+	}
+}
+
+func TestWithOverrides(t *testing.T) {
+	cfg := newTestConfig(t, testOverrides)
+
+	nes := Systems[SystemNES]
+	got := nes.WithOverrides(cfg)
+
+	// duplicates are skipped ignoring case
+	wantFolders := append(append([]string{}, nes.Folders...), "Famicom", "Homebrew")
+	wantExts := append(append([]string{}, nes.Extensions...), ".UNF", ".unif")
+	if !reflect.DeepEqual(got.Folders, wantFolders) {
+		t.Fatalf("expected folders: %v, got: %v", wantFolders, got.Folders)
+	} else if !reflect.DeepEqual(got.Extensions, wantExts) {
+		t.Fatalf("expected extensions: %v, got: %v", wantExts, got.Extensions)
+	} else if !reflect.DeepEqual(got.Aliases, appendUnique(append([]string{}, nes.Aliases...), "Famicom", "nintendo")) {
+		t.Fatalf("unexpected aliases: %v", got.Aliases)
+	}
+
+	// the built-in definition isn't modified
+	if !reflect.DeepEqual(Systems[SystemNES], nes) {
+		t.Fatalf("built-in system was modified: %+v", Systems[SystemNES])
+	}
+
+	if got := nes.WithOverrides(nil); !reflect.DeepEqual(got, nes) {
+		t.Fatalf("expected no change without config, got: %+v", got)
+	}
+}
+
+func TestLookupSystemOverrideAlias(t *testing.T) {
+	cfg := newTestConfig(t, testOverrides)
+
+	s, err := LookupSystem(cfg, "mega drive")
+	if err != nil {
+		t.Fatal(err)
+	} else if s.Id != SystemGenesis {
+		t.Fatalf("expected %s, got: %s", SystemGenesis, s.Id)
+	}
+
+	if _, err := LookupSystem(nil, "mega drive"); err == nil {
+		t.Fatalf("expected override alias to need a config")
+	}
+}
+
+func TestSystemFoldersExtensions(t *testing.T) {
+	cfg := newTestConfig(t, testOverrides)
+	pl := newTestPlatform(t,
+		testNesLauncher,
+		platforms.Launcher{
+			Id:         "NESAlt",
+			SystemId:   SystemNES,
+			Folders:    []string{"NES", "NESAlt"},
+			Extensions: []string{".nes", ".fds"},
+		},
+		platforms.Launcher{
+			Id:         "SNES",
+			SystemId:   SystemSNES,
+			Folders:    []string{"SNES"},
+			Extensions: []string{".sfc"},
+		},
+	)
+
+	folders := SystemFolders(cfg, pl, SystemNES)
+	want := []string{"NES", "NESAlt", "Famicom", "Homebrew"}
+	if !reflect.DeepEqual(folders, want) {
+		t.Fatalf("expected folders: %v, got: %v", want, folders)
+	}
+
+	exts := SystemExtensions(cfg, pl, SystemNES)
+	want = []string{".nes", ".fds", ".unf", ".unif"}
+	if !reflect.DeepEqual(exts, want) {
+		t.Fatalf("expected extensions: %v, got: %v", want, exts)
+	}
+
+	// overrides aren't used for systems without launchers
+	if f := SystemFolders(cfg, pl, SystemGenesis); len(f) != 0 {
+		t.Fatalf("expected no folders, got: %v", f)
+	} else if e := SystemExtensions(cfg, pl, SystemGenesis); len(e) != 0 {
+		t.Fatalf("expected no extensions, got: %v", e)
+	}
+}
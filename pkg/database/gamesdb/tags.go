@@ -0,0 +1,476 @@
+package gamesdb
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Flags set on media from their filename tags.
+const (
+	FlagVerified    = "verified"
+	FlagBeta        = "beta"
+	FlagProto       = "proto"
+	FlagDemo        = "demo"
+	FlagSample      = "sample"
+	FlagHack        = "hack"
+	FlagTranslation = "translation"
+	FlagTrainer     = "trainer"
+	FlagFixed       = "fixed"
+	FlagCracked     = "cracked"
+	FlagBadDump     = "bad"
+	FlagOverdump    = "overdump"
+	FlagAlternate   = "alt"
+	FlagPirate      = "pirate"
+	FlagUnlicensed  = "unlicensed"
+	FlagAftermarket = "aftermarket"
+	FlagHomebrew    = "homebrew"
+)
+
+// MediaTags is the metadata parsed from the No-Intro, TOSEC or GoodTools tags
+// in a media filename.
+type MediaTags struct {
+	// Name with all tags removed.
+	Title string `json:"title,omitempty"`
+	// Full region names, e.g. USA or Europe.
+	Regions []string `json:"regions,omitempty"`
+	// Lowercase two letter language codes.
+	Languages []string `json:"languages,omitempty"`
+	Revision  string   `json:"revision,omitempty"`
+	Version   string   `json:"version,omitempty"`
+	Year      string   `json:"year,omitempty"`
+	Flags     []string `json:"flags,omitempty"`
+	// Tags which weren't recognised.
+	Other []string `json:"other,omitempty"`
+}
+
+var (
+	filenameTagRe = regexp.MustCompile(`\(([^)]*)\)|\[([^]]*)]`)
+	revisionRe    = regexp.MustCompile(`(?i)^rev(?:ision)?\s*([0-9a-z.]+)$`)
+	versionRe     = regexp.MustCompile(`(?i)^v(?:ersion)?\s*([0-9][0-9a-z.]*)$`)
+	yearRe        = regexp.MustCompile(`^(19|20)[0-9x]{2}(-[0-9x]{2}){0,2}$`)
+	// dump flags, optionally numbered or followed by details, e.g. [h1C]
+	dumpFlagRe = regexp.MustCompile(`^(a|b|f|h|o|p|t|cr)([0-9]+[a-z]*)?(\s.*)?$`)
+)
+
+// Region names used by No-Intro.
+var regionNames = map[string]string{
+	"usa":           "USA",
+	"europe":        "Europe",
+	"japan":         "Japan",
+	"world":         "World",
+	"asia":          "Asia",
+	"australia":     "Australia",
+	"brazil":        "Brazil",
+	"canada":        "Canada",
+	"china":         "China",
+	"france":        "France",
+	"germany":       "Germany",
+	"hong kong":     "Hong Kong",
+	"italy":         "Italy",
+	"korea":         "Korea",
+	"netherlands":   "Netherlands",
+	"spain":         "Spain",
+	"sweden":        "Sweden",
+	"taiwan":        "Taiwan",
+	"uk":            "UK",
+	"russia":        "Russia",
+	"scandinavia":   "Scandinavia",
+	"latin america": "Latin America",
+	"mexico":        "Mexico",
+	"poland":        "Poland",
+	"portugal":      "Portugal",
+	"denmark":       "Denmark",
+	"finland":       "Finland",
+	"norway":        "Norway",
+	"greece":        "Greece",
+	"unknown":       "Unknown",
+}
+
+// Country codes used by TOSEC, which are always uppercase. Lowercase codes
+// are languages.
+var regionCodes = map[string]string{
+	"US": "USA",
+	"EU": "Europe",
+	"JP": "Japan",
+	"AS": "Asia",
+	"AU": "Australia",
+	"BR": "Brazil",
+	"CA": "Canada",
+	"CN": "China",
+	"FR": "France",
+	"DE": "Germany",
+	"HK": "Hong Kong",
+	"IT": "Italy",
+	"KR": "Korea",
+	"NL": "Netherlands",
+	"ES": "Spain",
+	"SE": "Sweden",
+	"TW": "Taiwan",
+	"GB": "UK",
+	"RU": "Russia",
+}
+
+// Single letter region codes used by GoodTools, e.g. (U) or (JUE).
+var goodRegionCodes = map[rune]string{
+	'U': "USA",
+	'E': "Europe",
+	'J': "Japan",
+	'W': "World",
+	'K': "Korea",
+	'A': "Australia",
+	'B': "Brazil",
+	'C': "China",
+	'F': "France",
+	'G': "Germany",
+	'S': "Spain",
+	'I': "Italy",
+}
+
+var languageCodes = map[string]bool{
+	"en": true, "ja": true, "fr": true, "de": true, "es": true, "it": true,
+	"nl": true, "pt": true, "sv": true, "no": true, "da": true, "fi": true,
+	"zh": true, "ko": true, "pl": true, "ru": true, "el": true, "tr": true,
+	"cs": true, "hu": true, "ca": true, "ar": true, "he": true, "hr": true,
+	"ro": true, "sk": true, "sl": true, "uk": true, "is": true, "th": true,
+}
+
+var wordFlags = map[string]string{
+	"beta":          FlagBeta,
+	"proto":         FlagProto,
+	"prototype":     FlagProto,
+	"alpha":         FlagBeta,
+	"pre-release":   FlagBeta,
+	"preview":       FlagBeta,
+	"debug":         FlagBeta,
+	"demo":          FlagDemo,
+	"kiosk":         FlagDemo,
+	"sample":        FlagSample,
+	"hack":          FlagHack,
+	"unl":           FlagUnlicensed,
+	"unlicensed":    FlagUnlicensed,
+	"pirate":        FlagPirate,
+	"aftermarket":   FlagAftermarket,
+	"homebrew":      FlagHomebrew,
+	"alt":           FlagAlternate,
+	"bad dump":      FlagBadDump,
+	"verified":      FlagVerified,
+	"translation":   FlagTranslation,
+	"translated":    FlagTranslation,
+	"trainer":       FlagTrainer,
+	"cracked":       FlagCracked,
+	"overdump":      FlagOverdump,
+	"fixed":         FlagFixed,
+	"pd":            FlagHomebrew,
+	"public domain": FlagHomebrew,
+}
+
+var dumpFlags = map[string]string{
+	"a":  FlagAlternate,
+	"b":  FlagBadDump,
+	"f":  FlagFixed,
+	"h":  FlagHack,
+	"o":  FlagOverdump,
+	"p":  FlagPirate,
+	"t":  FlagTrainer,
+	"cr": FlagCracked,
+}
+
+// HasFlag returns true if the media has the given flag.
+func (t MediaTags) HasFlag(flag string) bool {
+	for _, f := range t.Flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRegion returns true if the media is for the given region. Regions can
+// be given by name or code and World matches every region.
+func (t MediaTags) HasRegion(region string) bool {
+	want := NormalizeRegion(region)
+	for _, r := range t.Regions {
+		if strings.EqualFold(r, want) || r == "World" {
+			return true
+		}
+	}
+	return false
+}
+
+// HasLanguage returns true if the media includes the given language code.
+func (t MediaTags) HasLanguage(lang string) bool {
+	for _, l := range t.Languages {
+		if strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeRegion converts a region name or code to the name used in parsed
+// tags. Unknown regions are returned unchanged.
+func NormalizeRegion(region string) string {
+	region = strings.TrimSpace(region)
+	if r, ok := regionNames[strings.ToLower(region)]; ok {
+		return r
+	} else if r, ok := regionCodes[strings.ToUpper(region)]; ok {
+		return r
+	}
+	return region
+}
+
+func (t *MediaTags) addFlag(flag string) {
+	if !t.HasFlag(flag) {
+		t.Flags = append(t.Flags, flag)
+	}
+}
+
+func (t *MediaTags) addRegion(region string) {
+	for _, r := range t.Regions {
+		if r == region {
+			return
+		}
+	}
+	t.Regions = append(t.Regions, region)
+}
+
+// Parse a list of regions, e.g. "USA, Europe" or "US-EU". Returns nil if
+// any part isn't a region.
+func parseRegions(s string) []string {
+	var regions []string
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '-' }) {
+		p = strings.TrimSpace(p)
+		if r, ok := regionNames[strings.ToLower(p)]; ok {
+			regions = append(regions, r)
+		} else if r, ok := regionCodes[p]; ok {
+			regions = append(regions, r)
+		} else {
+			return nil
+		}
+	}
+	return regions
+}
+
+// Parse GoodTools region codes, which are all uppercase.
+func parseGoodRegions(s string) []string {
+	if len(s) == 0 || len(s) > 4 || strings.ToUpper(s) != s {
+		return nil
+	}
+
+	var regions []string
+	for _, c := range s {
+		r, ok := goodRegionCodes[c]
+		if !ok {
+			return nil
+		}
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+// Parse a list of languages, e.g. "En,Fr,De" or "en-fr". Returns nil if any
+// part isn't a language.
+func parseLanguages(s string) []string {
+	var langs []string
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '-' || r == '+' }) {
+		l := strings.ToLower(strings.TrimSpace(p))
+		if !languageCodes[l] {
+			return nil
+		}
+		langs = append(langs, l)
+	}
+	return langs
+}
+
+// Parse the contents of a single round bracket tag.
+func (t *MediaTags) parseRoundTag(tag string) {
+	lower := strings.ToLower(tag)
+
+	if f, ok := wordFlags[lower]; ok {
+		t.addFlag(f)
+		return
+	}
+
+	// numbered or described prerelease tags, e.g. (Beta 2) or (Proto 1)
+	if first, _, ok := strings.Cut(lower, " "); ok {
+		if f, ok := wordFlags[first]; ok && f != FlagVerified {
+			t.addFlag(f)
+			return
+		}
+	}
+
+	if regions := parseRegions(tag); len(regions) > 0 {
+		for _, r := range regions {
+			t.addRegion(r)
+		}
+		return
+	}
+
+	if regions := parseGoodRegions(tag); len(regions) > 0 {
+		for _, r := range regions {
+			t.addRegion(r)
+		}
+		return
+	}
+
+	if langs := parseLanguages(tag); len(langs) > 0 {
+		t.Languages = append(t.Languages, langs...)
+		return
+	}
+
+	if m := revisionRe.FindStringSubmatch(tag); m != nil {
+		t.Revision = m[1]
+		return
+	}
+
+	if m := versionRe.FindStringSubmatch(tag); m != nil {
+		t.Version = m[1]
+		return
+	}
+
+	if yearRe.MatchString(lower) {
+		t.Year = lower[:4]
+		return
+	}
+
+	t.Other = append(t.Other, tag)
+}
+
+// Parse the contents of a single square bracket tag.
+func (t *MediaTags) parseSquareTag(tag string) {
+	lower := strings.ToLower(tag)
+
+	switch {
+	case tag == "!":
+		t.addFlag(FlagVerified)
+		return
+	case strings.HasPrefix(lower, "t+") || strings.HasPrefix(lower, "t-"):
+		t.addFlag(FlagTranslation)
+		return
+	}
+
+	if f, ok := wordFlags[lower]; ok {
+		t.addFlag(f)
+		return
+	}
+
+	if m := dumpFlagRe.FindStringSubmatch(lower); m != nil {
+		t.addFlag(dumpFlags[m[1]])
+		return
+	}
+
+	t.Other = append(t.Other, tag)
+}
+
+// ParseTags extracts region, language, revision and dump information from
+// the tags in a media filename, e.g. "Sonic (USA, Europe) (Rev 1) [!]".
+func ParseTags(name string) MediaTags {
+	var t MediaTags
+
+	for _, m := range filenameTagRe.FindAllStringSubmatch(name, -1) {
+		if strings.HasPrefix(m[0], "(") {
+			tag := strings.TrimSpace(m[1])
+			if tag != "" {
+				t.parseRoundTag(tag)
+			}
+		} else {
+			tag := strings.TrimSpace(m[2])
+			if tag != "" {
+				t.parseSquareTag(tag)
+			}
+		}
+	}
+
+	title := filenameTagRe.ReplaceAllString(name, " ")
+	t.Title = strings.Join(strings.Fields(title), " ")
+
+	// TOSEC puts the version straight after the title, e.g. Game v1.1
+	if fields := strings.Fields(t.Title); t.Version == "" && len(fields) > 1 {
+		if m := versionRe.FindStringSubmatch(fields[len(fields)-1]); m != nil {
+			if _, err := strconv.ParseFloat(strings.Trim(m[1], "abcdefghijklmnopqrstuvwxyz"), 64); err == nil {
+				t.Version = m[1]
+				t.Title = strings.Join(fields[:len(fields)-1], " ")
+			}
+		}
+	}
+
+	return t
+}
+
+// SearchFilter limits search results by the parsed tags of each media.
+type SearchFilter struct {
+	// Only include media for one of these regions.
+	Regions []string
+	// Exclude media with any of these flags.
+	Exclude []string
+}
+
+// Empty returns true if the filter doesn't exclude anything.
+func (f SearchFilter) Empty() bool {
+	return len(f.Regions) == 0 && len(f.Exclude) == 0
+}
+
+// Names which can be used to exclude groups of flags.
+var excludeAliases = map[string][]string{
+	"prerelease": {FlagBeta, FlagProto, FlagDemo, FlagSample},
+	"unl":        {FlagUnlicensed},
+	"hacks":      {FlagHack, FlagTranslation, FlagTrainer, FlagCracked, FlagFixed},
+	"bad":        {FlagBadDump, FlagOverdump},
+}
+
+// Match returns true if the media tags pass the filter.
+func (f SearchFilter) Match(t MediaTags) bool {
+	if len(f.Regions) > 0 {
+		ok := false
+		for _, r := range f.Regions {
+			if t.HasRegion(r) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	for _, e := range f.Exclude {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "unverified" {
+			if !t.HasFlag(FlagVerified) {
+				return false
+			}
+			continue
+		}
+
+		flags, ok := excludeAliases[e]
+		if !ok {
+			flags = []string{e}
+		}
+
+		for _, flag := range flags {
+			if t.HasFlag(flag) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// ParseSearchFilter builds a filter from comma separated region and exclude
+// lists, as used in ZapScript named arguments.
+func ParseSearchFilter(regions string, exclude string) SearchFilter {
+	var f SearchFilter
+	for _, r := range strings.Split(regions, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			f.Regions = append(f.Regions, r)
+		}
+	}
+	for _, e := range strings.Split(exclude, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			f.Exclude = append(f.Exclude, e)
+		}
+	}
+	return f
+}
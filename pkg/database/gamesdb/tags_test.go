@@ -0,0 +1,298 @@
+package gamesdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		name string
+		want MediaTags
+	}{
+		// No-Intro
+		{
+			name: "Super Mario World (USA)",
+			want: MediaTags{Title: "Super Mario World", Regions: []string{"USA"}},
+		},
+		{
+			name: "Sonic The Hedgehog (USA, Europe)",
+			want: MediaTags{Title: "Sonic The Hedgehog", Regions: []string{"USA", "Europe"}},
+		},
+		{
+			name: "Legend of Zelda, The - A Link to the Past (Europe) (En,Fr,De)",
+			want: MediaTags{
+				Title:     "Legend of Zelda, The - A Link to the Past",
+				Regions:   []string{"Europe"},
+				Languages: []string{"en", "fr", "de"},
+			},
+		},
+		{
+			name: "Pokemon - Red Version (USA, Europe) (SGB Enhanced)",
+			want: MediaTags{
+				Title:   "Pokemon - Red Version",
+				Regions: []string{"USA", "Europe"},
+				Other:   []string{"SGB Enhanced"},
+			},
+		},
+		{
+			name: "Super Mario Bros. 3 (USA) (Rev 1)",
+			want: MediaTags{Title: "Super Mario Bros. 3", Regions: []string{"USA"}, Revision: "1"},
+		},
+		{
+			name: "Street Fighter II' - Special Champion Edition (Japan) (Rev A)",
+			want: MediaTags{
+				Title:    "Street Fighter II' - Special Champion Edition",
+				Regions:  []string{"Japan"},
+				Revision: "A",
+			},
+		},
+		{
+			name: "Star Fox (USA) (Beta) (1993-01-14)",
+			want: MediaTags{
+				Title:   "Star Fox",
+				Regions: []string{"USA"},
+				Year:    "1993",
+				Flags:   []string{FlagBeta},
+			},
+		},
+		{
+			name: "EarthBound (USA) (Proto 2)",
+			want: MediaTags{Title: "EarthBound", Regions: []string{"USA"}, Flags: []string{FlagProto}},
+		},
+		{
+			name: "Action 52 (USA) (Unl)",
+			want: MediaTags{Title: "Action 52", Regions: []string{"USA"}, Flags: []string{FlagUnlicensed}},
+		},
+		{
+			name: "Micro Mages (World) (Aftermarket) (Unl)",
+			want: MediaTags{
+				Title:   "Micro Mages",
+				Regions: []string{"World"},
+				Flags:   []string{FlagAftermarket, FlagUnlicensed},
+			},
+		},
+		{
+			name: "Donkey Kong Country (USA) (Kiosk)",
+			want: MediaTags{Title: "Donkey Kong Country", Regions: []string{"USA"}, Flags: []string{FlagDemo}},
+		},
+		{
+			name: "Tetris (Japan) (En) (v1.1)",
+			want: MediaTags{
+				Title:     "Tetris",
+				Regions:   []string{"Japan"},
+				Languages: []string{"en"},
+				Version:   "1.1",
+			},
+		},
+		// Redump
+		{
+			name: "Final Fantasy VII (USA) (Disc 1)",
+			want: MediaTags{
+				Title:   "Final Fantasy VII",
+				Regions: []string{"USA"},
+				Other:   []string{"Disc 1"},
+			},
+		},
+		{
+			name: "Gran Turismo 2 (Europe) (En,Fr,De,Es,It) (Disc 2) (Gran Turismo Mode)",
+			want: MediaTags{
+				Title:     "Gran Turismo 2",
+				Regions:   []string{"Europe"},
+				Languages: []string{"en", "fr", "de", "es", "it"},
+				Other:     []string{"Disc 2", "Gran Turismo Mode"},
+			},
+		},
+		{
+			name: "Metal Gear Solid (USA) (Disc 1) (Rev 1)",
+			want: MediaTags{
+				Title:    "Metal Gear Solid",
+				Regions:  []string{"USA"},
+				Revision: "1",
+				Other:    []string{"Disc 1"},
+			},
+		},
+		// TOSEC
+		{
+			name: "Elite (1984)(Acornsoft)",
+			want: MediaTags{Title: "Elite", Year: "1984", Other: []string{"Acornsoft"}},
+		},
+		{
+			name: "Lemmings v1.1 (1991)(Psygnosis)(GB)",
+			want: MediaTags{
+				Title:   "Lemmings",
+				Version: "1.1",
+				Year:    "1991",
+				Regions: []string{"UK"},
+				Other:   []string{"Psygnosis"},
+			},
+		},
+		{
+			name: "Turrican II (1991)(Rainbow Arts)(DE)(de)[cr Crackers]",
+			want: MediaTags{
+				Title:     "Turrican II",
+				Year:      "1991",
+				Regions:   []string{"Germany"},
+				Languages: []string{"de"},
+				Flags:     []string{FlagCracked},
+				Other:     []string{"Rainbow Arts"},
+			},
+		},
+		{
+			name: "Ghosts'n Goblins (1986)(Elite)(US-EU)[a2][t +3]",
+			want: MediaTags{
+				Title:   "Ghosts'n Goblins",
+				Year:    "1986",
+				Regions: []string{"USA", "Europe"},
+				Flags:   []string{FlagAlternate, FlagTrainer},
+				Other:   []string{"Elite"},
+			},
+		},
+		{
+			name: "Sabre Wulf (198x)(Ultimate)(PD)",
+			want: MediaTags{
+				Title: "Sabre Wulf",
+				Year:  "198x",
+				Flags: []string{FlagHomebrew},
+				Other: []string{"Ultimate"},
+			},
+		},
+		// GoodTools
+		{
+			name: "Super Mario Bros. 3 (U) (PRG1) [!]",
+			want: MediaTags{
+				Title:   "Super Mario Bros. 3",
+				Regions: []string{"USA"},
+				Flags:   []string{FlagVerified},
+				Other:   []string{"PRG1"},
+			},
+		},
+		{
+			name: "Sonic the Hedgehog (JUE) [h1C]",
+			want: MediaTags{
+				Title:   "Sonic the Hedgehog",
+				Regions: []string{"Japan", "USA", "Europe"},
+				Flags:   []string{FlagHack},
+			},
+		},
+		{
+			name: "Final Fantasy V (J) [T+Eng1.1_RPGe]",
+			want: MediaTags{
+				Title:   "Final Fantasy V",
+				Regions: []string{"Japan"},
+				Flags:   []string{FlagTranslation},
+			},
+		},
+		{
+			name: "Contra (U) [b1][o2]",
+			want: MediaTags{
+				Title:   "Contra",
+				Regions: []string{"USA"},
+				Flags:   []string{FlagBadDump, FlagOverdump},
+			},
+		},
+		{
+			name: "Zelda II - The Adventure of Link (U) [p1][f2]",
+			want: MediaTags{
+				Title:   "Zelda II - The Adventure of Link",
+				Regions: []string{"USA"},
+				Flags:   []string{FlagPirate, FlagFixed},
+			},
+		},
+		// no tags
+		{
+			name: "Homebrew Game",
+			want: MediaTags{Title: "Homebrew Game"},
+		},
+		{
+			name: "Game  ( )  [ ]  Extra",
+			want: MediaTags{Title: "Game Extra"},
+		},
+	}
+
+	for _, tc := range tests {
+		got := ParseTags(tc.name)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%q:\nexpected: %+v\ngot:      %+v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestMediaTagsHas(t *testing.T) {
+	tags := ParseTags("Sonic the Hedgehog (USA, Europe) (En,Ja) [!]")
+
+	if !tags.HasRegion("usa") || !tags.HasRegion("EU") || tags.HasRegion("Japan") {
+		t.Fatalf("unexpected regions: %v", tags.Regions)
+	} else if !tags.HasLanguage("JA") || tags.HasLanguage("fr") {
+		t.Fatalf("unexpected languages: %v", tags.Languages)
+	} else if !tags.HasFlag(FlagVerified) || tags.HasFlag(FlagHack) {
+		t.Fatalf("unexpected flags: %v", tags.Flags)
+	}
+
+	world := ParseTags("Tetris (World)")
+	if !world.HasRegion("Japan") || !world.HasRegion("US") {
+		t.Fatalf("expected world to match every region")
+	}
+}
+
+func TestParseSearchFilter(t *testing.T) {
+	tests := []struct {
+		regions string
+		exclude string
+		want    SearchFilter
+	}{
+		{want: SearchFilter{}},
+		{regions: "usa", want: SearchFilter{Regions: []string{"usa"}}},
+		{
+			regions: " USA, eu ,, Japan",
+			exclude: "prerelease , hacks,",
+			want: SearchFilter{
+				Regions: []string{"USA", "eu", "Japan"},
+				Exclude: []string{"prerelease", "hacks"},
+			},
+		},
+		{exclude: "unverified", want: SearchFilter{Exclude: []string{"unverified"}}},
+	}
+
+	for _, tc := range tests {
+		got := ParseSearchFilter(tc.regions, tc.exclude)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%q, %q: expected: %+v, got: %+v", tc.regions, tc.exclude, tc.want, got)
+		}
+	}
+
+	if !ParseSearchFilter(" , ", "").Empty() {
+		t.Fatalf("expected empty filter")
+	}
+}
+
+func TestSearchFilterMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		regions string
+		exclude string
+		want    bool
+	}{
+		{name: "Super Mario World (USA)", want: true},
+		{name: "Super Mario World (USA)", regions: "us", want: true},
+		{name: "Super Mario World (USA)", regions: "japan,europe", want: false},
+		{name: "Tetris (World)", regions: "japan", want: true},
+		{name: "Sonic the Hedgehog (JUE) [h1C]", regions: "jp", want: true},
+		{name: "Sonic the Hedgehog (JUE) [h1C]", exclude: "hacks", want: false},
+		{name: "Sonic the Hedgehog (JUE) [h1C]", exclude: "hack", want: false},
+		{name: "Star Fox (USA) (Beta)", exclude: "prerelease", want: false},
+		{name: "Star Fox (USA)", exclude: "prerelease,hacks,bad,unl", want: true},
+		{name: "Action 52 (USA) (Unl)", exclude: "unl", want: false},
+		{name: "Contra (U) [b1]", exclude: "bad", want: false},
+		{name: "Contra (U) [!]", exclude: "unverified", want: true},
+		{name: "Contra (USA)", exclude: "unverified", want: false},
+		{name: "Lemmings (1991)(Psygnosis)", regions: "usa", want: false},
+	}
+
+	for _, tc := range tests {
+		f := ParseSearchFilter(tc.regions, tc.exclude)
+		if got := f.Match(ParseTags(tc.name)); got != tc.want {
+			t.Fatalf("%q (%q, %q): expected: %v, got: %v", tc.name, tc.regions, tc.exclude, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package database
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	historyPrefix          = "history:"
+	HistoryFormatCsv       = "csv"
+	HistoryFormatJson      = "json"
+	HistoryOriginReader    = "reader"
+	HistoryOriginApi       = "api"
+	HistoryOriginPlaylist  = "playlist"
+	HistoryOriginScheduler = "scheduler"
+)
+
+type HistoryEntry struct {
+	Id     uint64    `json:"id"`
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	UID    string    `json:"uid"`
+	Text   string    `json:"text"`
+	Data   string    `json:"data"`
+	Source string    `json:"source"`
+	// Reader device string, if the token was read from a reader.
+	Device string `json:"device"`
+	// Where the token came from, empty if unknown.
+	Origin string `json:"origin"`
+	Remote bool   `json:"remote"`
+	// ID of the mapping applied to the token, if any.
+	Mapping string `json:"mapping"`
+	// Final ZapScript run after any mappings were applied.
+	ZapScript string `json:"zapscript"`
+	SystemId  string `json:"systemId"`
+	MediaPath string `json:"mediaPath"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error"`
+}
+
+// HistoryOrigin returns the origin and reader device of a token based on its
+// source. Tokens read from readers use the reader device as their source.
+func HistoryOrigin(source string, remote bool) (string, string) {
+	switch {
+	case source == tokens.SourcePlaylist:
+		return HistoryOriginPlaylist, ""
+	case source == tokens.SourceScheduler:
+		return HistoryOriginScheduler, ""
+	case source == tokens.SourceApi || (source == "" && remote):
+		return HistoryOriginApi, ""
+	case source != "":
+		return HistoryOriginReader, source
+	default:
+		return "", ""
+	}
+}
+
+// HistoryFilter limits which history entries are returned. Zero values
+// match all entries.
+type HistoryFilter struct {
+	From    time.Time
+	To      time.Time
+	UID     string
+	Success *bool
+	Source  string
+	Type    string
+}
+
+func (f HistoryFilter) match(he HistoryEntry) bool {
+	if !f.From.IsZero() && he.Time.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && he.Time.After(f.To) {
+		return false
+	}
+	if f.UID != "" && NormalizeUid(he.UID) != NormalizeUid(f.UID) {
+		return false
+	}
+	if f.Success != nil && he.Success != *f.Success {
+		return false
+	}
+	if f.Source != "" && he.Source != f.Source {
+		return false
+	}
+	if f.Type != "" && he.Type != f.Type {
+		return false
+	}
+	return true
+}
+
+// Zero padded so entries are stored in the order they were added.
+func historyKey(id uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", historyPrefix, id))
+}
+
+func putHistory(b *bolt.Bucket, entry HistoryEntry) (uint64, error) {
+	id, err := b.NextSequence()
+	if err != nil {
+		return 0, err
+	}
+	entry.Id = id
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, b.Put(historyKey(id), data)
+}
+
+func (d *Database) AddHistory(entry HistoryEntry) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		_, err := putHistory(txn.Bucket([]byte(BucketHistory)), entry)
+		return err
+	})
+}
+
+// Iterate history entries from newest to oldest, starting before the given
+// cursor ID. A cursor of 0 starts from the newest entry. Stops when the
+// callback returns false.
+func eachHistory(
+	txn *bolt.Tx,
+	cursor uint64,
+	fn func(HistoryEntry) (bool, error),
+) error {
+	c := txn.Bucket([]byte(BucketHistory)).Cursor()
+	prefix := []byte(historyPrefix)
+
+	var k, v []byte
+	if cursor == 0 {
+		k, v = c.Last()
+	} else if k, _ = c.Seek(historyKey(cursor)); k == nil {
+		// cursor is past the newest entry
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+		var he HistoryEntry
+		err := json.Unmarshal(v, &he)
+		if err != nil {
+			return err
+		}
+
+		ok, err := fn(he)
+		if err != nil {
+			return err
+		} else if !ok {
+			break
+		}
+	}
+
+	return nil
+}
+
+// GetHistory returns up to max entries matching the filter, newest first,
+// starting before the cursor ID. Also returns the cursor for the next page,
+// which is 0 if there are no more entries. A max of 0 or less returns no
+// entries.
+func (d *Database) GetHistory(
+	filter HistoryFilter,
+	cursor uint64,
+	max int,
+) ([]HistoryEntry, uint64, error) {
+	entries := make([]HistoryEntry, 0)
+	var next uint64
+
+	if max <= 0 {
+		return entries, next, nil
+	}
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		return eachHistory(txn, cursor, func(he HistoryEntry) (bool, error) {
+			if !filter.match(he) {
+				return true, nil
+			}
+
+			if len(entries) >= max {
+				next = entries[len(entries)-1].Id
+				return false, nil
+			}
+
+			entries = append(entries, he)
+			return true, nil
+		})
+	})
+
+	return entries, next, err
+}
+
+// PruneHistory removes entries older than maxAge and all but the newest
+// maxEntries entries. A zero value disables that limit. Returns the number
+// of entries removed.
+func (d *Database) PruneHistory(maxAge time.Duration, maxEntries int) (int, error) {
+	if maxAge <= 0 && maxEntries <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	var remove [][]byte
+
+	err := d.bdb.Update(func(txn *bolt.Tx) error {
+		count := 0
+		err := eachHistory(txn, 0, func(he HistoryEntry) (bool, error) {
+			count++
+			if (maxEntries > 0 && count > maxEntries) ||
+				(maxAge > 0 && he.Time.Before(cutoff)) {
+				remove = append(remove, historyKey(he.Id))
+			}
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		b := txn.Bucket([]byte(BucketHistory))
+		for _, k := range remove {
+			err := b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return len(remove), err
+}
+
+var historyCsvHeader = []string{
+	"id",
+	"time",
+	"type",
+	"uid",
+	"text",
+	"data",
+	"source",
+	"device",
+	"origin",
+	"remote",
+	"mapping",
+	"zapscript",
+	"systemId",
+	"mediaPath",
+	"success",
+	"error",
+}
+
+// ExportHistory writes all entries matching the filter to w, newest first,
+// in either CSV or JSON format.
+func (d *Database) ExportHistory(w io.Writer, format string, filter HistoryFilter) error {
+	if format != HistoryFormatCsv && format != HistoryFormatJson {
+		return fmt.Errorf("invalid export format: %s", format)
+	}
+
+	entries := make([]HistoryEntry, 0)
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		return eachHistory(txn, 0, func(he HistoryEntry) (bool, error) {
+			if filter.match(he) {
+				entries = append(entries, he)
+			}
+			return true, nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	if format == HistoryFormatJson {
+		return json.NewEncoder(w).Encode(entries)
+	}
+
+	cw := csv.NewWriter(w)
+	err = cw.Write(historyCsvHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, he := range entries {
+		err := cw.Write([]string{
+			strconv.FormatUint(he.Id, 10),
+			he.Time.Format(time.RFC3339),
+			he.Type,
+			he.UID,
+			he.Text,
+			he.Data,
+			he.Source,
+			he.Device,
+			he.Origin,
+			strconv.FormatBool(he.Remote),
+			he.Mapping,
+			he.ZapScript,
+			he.SystemId,
+			he.MediaPath,
+			strconv.FormatBool(he.Success),
+			he.Error,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
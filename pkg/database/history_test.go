@@ -0,0 +1,306 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	bolt "go.etcd.io/bbolt"
+)
+
+func testDb(t *testing.T) *Database {
+	t.Helper()
+	db, err := openFile(filepath.Join(t.TempDir(), "test.db"), &bolt.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return &Database{bdb: db}
+}
+
+func addHistory(t *testing.T, db *Database, entries ...HistoryEntry) {
+	t.Helper()
+	for _, he := range entries {
+		if err := db.AddHistory(he); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func historyUids(entries []HistoryEntry) []string {
+	uids := make([]string, len(entries))
+	for i, he := range entries {
+		uids[i] = he.UID
+	}
+	return uids
+}
+
+func equalUids(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestHistoryRoundTrip(t *testing.T) {
+	db := testDb(t)
+	want := HistoryEntry{
+		Time:      time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		Type:      "NTAG215",
+		UID:       "04aabbcc",
+		Text:      "mario",
+		Data:      "0102",
+		Source:    "pn532_uart:/dev/ttyUSB0",
+		Device:    "pn532_uart:/dev/ttyUSB0",
+		Origin:    HistoryOriginReader,
+		Mapping:   "3",
+		ZapScript: "**launch.system:snes",
+		SystemId:  "SNES",
+		MediaPath: "/media/snes/mario.sfc",
+		Success:   false,
+		Error:     "launch failed",
+	}
+	addHistory(t, db, want)
+	want.Id = 1
+
+	entries, _, err := db.GetHistory(HistoryFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 1 {
+		t.Fatalf("expected: %d entries, got: %d", 1, len(entries))
+	}
+
+	got := entries[0]
+	if !got.Time.Equal(want.Time) {
+		t.Fatalf("expected: %s, got: %s", want.Time, got.Time)
+	}
+	got.Time = want.Time
+	if got != want {
+		t.Fatalf("expected: %+v, got: %+v", want, got)
+	}
+
+	var buf bytes.Buffer
+	err = db.ExportHistory(&buf, HistoryFormatJson, HistoryFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exported []HistoryEntry
+	err = json.Unmarshal(buf.Bytes(), &exported)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(exported) != 1 {
+		t.Fatalf("expected: %d entries, got: %d", 1, len(exported))
+	}
+	exported[0].Time = want.Time
+	if exported[0] != want {
+		t.Fatalf("expected: %+v, got: %+v", want, exported[0])
+	}
+}
+
+func TestHistoryOrigin(t *testing.T) {
+	tests := []struct {
+		source     string
+		remote     bool
+		wantOrigin string
+		wantDevice string
+	}{
+		{tokens.SourcePlaylist, false, HistoryOriginPlaylist, ""},
+		{tokens.SourceScheduler, false, HistoryOriginScheduler, ""},
+		{tokens.SourceApi, true, HistoryOriginApi, ""},
+		{"", true, HistoryOriginApi, ""},
+		{"libnfc:pn532_uart:/dev/ttyUSB0", false, HistoryOriginReader, "libnfc:pn532_uart:/dev/ttyUSB0"},
+		{"", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		origin, device := HistoryOrigin(tt.source, tt.remote)
+		if origin != tt.wantOrigin || device != tt.wantDevice {
+			t.Fatalf("%q: expected: %q, %q, got: %q, %q", tt.source, tt.wantOrigin, tt.wantDevice, origin, device)
+		}
+	}
+}
+
+func TestGetHistoryPaging(t *testing.T) {
+	db := testDb(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, uid := range []string{"a", "b", "c", "d", "e"} {
+		addHistory(t, db, HistoryEntry{
+			Time:    start.Add(time.Duration(i) * time.Minute),
+			UID:     uid,
+			Success: uid != "c",
+		})
+	}
+
+	var got []string
+	var cursor uint64
+	pages := 0
+	for {
+		entries, next, err := db.GetHistory(HistoryFilter{}, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, historyUids(entries)...)
+		pages++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	want := []string{"e", "d", "c", "b", "a"}
+	if !equalUids(got, want) {
+		t.Fatalf("expected: %v, got: %v", want, got)
+	} else if pages != 3 {
+		t.Fatalf("expected 3 pages, got: %d", pages)
+	}
+
+	// filtered pages skip non-matching entries
+	failed := false
+	entries, next, err := db.GetHistory(HistoryFilter{Success: &failed}, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	} else if !equalUids(historyUids(entries), []string{"c"}) || next != 0 {
+		t.Fatalf("expected only c with no next page, got: %v, %d", historyUids(entries), next)
+	}
+
+	// a cursor past the newest entry starts from the newest
+	entries, _, err = db.GetHistory(HistoryFilter{}, 100, 1)
+	if err != nil {
+		t.Fatal(err)
+	} else if !equalUids(historyUids(entries), []string{"e"}) {
+		t.Fatalf("expected e, got: %v", historyUids(entries))
+	}
+}
+
+func TestGetHistoryNoMax(t *testing.T) {
+	db := testDb(t)
+	addHistory(t, db, HistoryEntry{Time: time.Now(), UID: "a"})
+
+	for _, max := range []int{0, -1} {
+		entries, next, err := db.GetHistory(HistoryFilter{}, 0, max)
+		if err != nil {
+			t.Fatal(err)
+		} else if len(entries) != 0 || next != 0 {
+			t.Fatalf("max %d: expected no entries, got: %v, %d", max, entries, next)
+		}
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	db := testDb(t)
+	now := time.Now()
+	addHistory(t, db,
+		HistoryEntry{Time: now.Add(-72 * time.Hour), UID: "old"},
+		HistoryEntry{Time: now.Add(-2 * time.Hour), UID: "a"},
+		HistoryEntry{Time: now.Add(-1 * time.Hour), UID: "b"},
+		HistoryEntry{Time: now, UID: "c"},
+	)
+
+	removed, err := db.PruneHistory(24*time.Hour, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if removed != 1 {
+		t.Fatalf("expected 1 removed by age, got: %d", removed)
+	}
+
+	removed, err = db.PruneHistory(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	} else if removed != 1 {
+		t.Fatalf("expected 1 removed by count, got: %d", removed)
+	}
+
+	entries, _, err := db.GetHistory(HistoryFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if !equalUids(historyUids(entries), []string{"c", "b"}) {
+		t.Fatalf("expected [c b], got: %v", historyUids(entries))
+	}
+}
+
+func TestMigrateHistoryKeys(t *testing.T) {
+	db := testDb(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	legacy := []HistoryEntry{
+		{Time: start.Add(2 * time.Minute), UID: "c"},
+		{Time: start, UID: "a"},
+		// same timestamp as c, previously would have collided without a UID
+		{Time: start.Add(2 * time.Minute), UID: ""},
+		{Time: start.Add(1 * time.Minute), UID: "b"},
+	}
+
+	err := db.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketHistory))
+		for _, he := range legacy {
+			data, err := json.Marshal(he)
+			if err != nil {
+				return err
+			}
+			key := he.Time.Format(time.RFC3339) + "-" + he.UID
+			if err := b.Put([]byte(key), data); err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte("invalid"), []byte("{"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.bdb.Update(migrateHistoryKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = db.bdb.View(func(txn *bolt.Tx) error {
+		return txn.Bucket([]byte(BucketHistory)).ForEach(func(k, _ []byte) error {
+			if len(k) < len(historyPrefix) || string(k[:len(historyPrefix)]) != historyPrefix {
+				t.Fatalf("legacy key not migrated: %s", k)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _, err := db.GetHistory(HistoryFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// entries with the same time keep their key order, so the empty UID
+	// entry is older than c
+	want := []string{"c", "", "b", "a"}
+	if !equalUids(historyUids(entries), want) {
+		t.Fatalf("expected: %q, got: %q", want, historyUids(entries))
+	}
+
+	for i, he := range entries {
+		if he.Id != uint64(len(entries)-i) {
+			t.Fatalf("expected sequential ids, got: %d at %d", he.Id, i)
+		}
+	}
+
+	// running it again doesn't change migrated entries
+	err = db.bdb.Update(migrateHistoryKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _, err := db.GetHistory(HistoryFilter{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	} else if !equalUids(historyUids(again), want) {
+		t.Fatalf("expected: %q, got: %q", want, historyUids(again))
+	}
+}
@@ -14,18 +14,30 @@ import (
 )
 
 const (
-	MappingTypeUID   = "uid"
-	MappingTypeText  = "text"
-	MappingTypeData  = "data"
-	MatchTypeExact   = "exact"
-	MatchTypePartial = "partial"
-	MatchTypeRegex   = "regex"
+	MappingTypeUID  = "uid"
+	MappingTypeText = "text"
+	MappingTypeData = "data"
+	// Match the character or series of a toys-to-life figure, by name or
+	// ID.
+	MappingTypeCharacter = "character"
+	MappingTypeSeries    = "series"
+	// Match a set of tags which are on a reader at the same time. The
+	// pattern is the UIDs or text of each tag separated by "+", in any
+	// order. Only libnfc readers can see more than one tag at a time and
+	// report combinations, all other readers report a single tag.
+	MappingTypeCombination = "combination"
+	MatchTypeExact         = "exact"
+	MatchTypePartial       = "partial"
+	MatchTypeRegex         = "regex"
 )
 
 var AllowedMappingTypes = []string{
 	MappingTypeUID,
 	MappingTypeText,
 	MappingTypeData,
+	MappingTypeCharacter,
+	MappingTypeSeries,
+	MappingTypeCombination,
 }
 
 var AllowedMatchTypes = []string{
@@ -43,6 +55,8 @@ type Mapping struct {
 	Match    string `json:"match"`
 	Pattern  string `json:"pattern"`
 	Override string `json:"override"`
+	// Only match tokens from verified secure tags.
+	VerifiedOnly bool `json:"verifiedOnly,omitempty"`
 }
 
 func mappingKey(id string) []byte {
@@ -0,0 +1,155 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const metaVersionKey = "meta:version"
+
+// Migrations are run in order on open, each one brings the database up to
+// the version of its index + 1. Never reorder or remove a migration, only
+// append new ones.
+var migrations = []func(*bolt.Tx) error{
+	migrateHistoryKeys,
+	migrateHistoryOrigin,
+}
+
+func dbVersion(txn *bolt.Tx) int {
+	v := txn.Bucket([]byte(BucketMeta)).Get([]byte(metaVersionKey))
+	if v == nil {
+		return 0
+	}
+
+	version, err := strconv.Atoi(string(v))
+	if err != nil {
+		return 0
+	}
+
+	return version
+}
+
+func migrate(txn *bolt.Tx) error {
+	version := dbVersion(txn)
+
+	for i := version; i < len(migrations); i++ {
+		log.Info().Msgf("migrating user database to version %d", i+1)
+		err := migrations[i](txn)
+		if err != nil {
+			return err
+		}
+	}
+
+	if version == len(migrations) {
+		return nil
+	}
+
+	return txn.Bucket([]byte(BucketMeta)).Put(
+		[]byte(metaVersionKey),
+		[]byte(strconv.Itoa(len(migrations))),
+	)
+}
+
+// History entries used to be keyed by their timestamp and UID, which could
+// collide. Move all of them to auto-increment keys, keeping their order.
+func migrateHistoryKeys(txn *bolt.Tx) error {
+	b := txn.Bucket([]byte(BucketHistory))
+
+	type legacyEntry struct {
+		key   []byte
+		entry HistoryEntry
+	}
+	var legacy []legacyEntry
+
+	err := b.ForEach(func(k, v []byte) error {
+		if bytes.HasPrefix(k, []byte(historyPrefix)) {
+			return nil
+		}
+
+		var he HistoryEntry
+		err := json.Unmarshal(v, &he)
+		if err != nil {
+			log.Warn().Err(err).Msgf("dropping invalid history entry: %s", k)
+		}
+
+		legacy = append(legacy, legacyEntry{
+			key:   append([]byte{}, k...),
+			entry: he,
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.SliceStable(legacy, func(i, j int) bool {
+		return legacy[i].entry.Time.Before(legacy[j].entry.Time)
+	})
+
+	for _, le := range legacy {
+		err := b.Delete(le.key)
+		if err != nil {
+			return err
+		}
+
+		if le.entry.Time.IsZero() {
+			continue
+		}
+
+		_, err = putHistory(b, le.entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fill in the origin and device fields of history entries added before they
+// existed, where it's possible to work them out from the token source.
+func migrateHistoryOrigin(txn *bolt.Tx) error {
+	b := txn.Bucket([]byte(BucketHistory))
+	updated := make(map[string][]byte)
+
+	err := b.ForEach(func(k, v []byte) error {
+		var he HistoryEntry
+		err := json.Unmarshal(v, &he)
+		if err != nil {
+			return err
+		}
+
+		if he.Origin != "" {
+			return nil
+		}
+
+		he.Origin, he.Device = HistoryOrigin(he.Source, he.Remote)
+		if he.Origin == "" {
+			return nil
+		}
+
+		data, err := json.Marshal(he)
+		if err != nil {
+			return err
+		}
+
+		updated[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for k, v := range updated {
+		err := b.Put([]byte(k), v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
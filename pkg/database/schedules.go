@@ -0,0 +1,96 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type Schedule struct {
+	Id        string `json:"id"`
+	Added     int64  `json:"added"`
+	Label     string `json:"label"`
+	Enabled   bool   `json:"enabled"`
+	Cron      string `json:"cron"`
+	Idle      int    `json:"idle"`
+	ZapScript string `json:"zapscript"`
+}
+
+func scheduleKey(id string) []byte {
+	return []byte(fmt.Sprintf("schedules:%s", id))
+}
+
+func (d *Database) AddSchedule(s Schedule) (string, error) {
+	if s.ZapScript == "" {
+		return "", fmt.Errorf("missing zapscript")
+	}
+
+	if (s.Cron == "") == (s.Idle <= 0) {
+		return "", fmt.Errorf("schedule must have either a cron or idle value")
+	}
+
+	s.Added = time.Now().Unix()
+
+	var id string
+	err := d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedules))
+		seq, _ := b.NextSequence()
+		id = strconv.Itoa(int(seq))
+		s.Id = id
+
+		sd, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(scheduleKey(id), sd)
+	})
+
+	return id, err
+}
+
+func (d *Database) DeleteSchedule(id string) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedules))
+		if b.Get(scheduleKey(id)) == nil {
+			return fmt.Errorf("schedule not found: %s", id)
+		}
+		return b.Delete(scheduleKey(id))
+	})
+}
+
+func (d *Database) GetAllSchedules() ([]Schedule, error) {
+	var ss = make([]Schedule, 0)
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSchedules))
+
+		c := b.Cursor()
+		prefix := []byte("schedules:")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var s Schedule
+			err := json.Unmarshal(v, &s)
+			if err != nil {
+				return err
+			}
+
+			ps := strings.Split(string(k), ":")
+			if len(ps) != 2 {
+				return fmt.Errorf("invalid schedule key: %s", k)
+			}
+
+			s.Id = ps[1]
+
+			ss = append(ss, s)
+		}
+
+		return nil
+	})
+
+	return ss, err
+}
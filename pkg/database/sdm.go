@@ -0,0 +1,34 @@
+package database
+
+import (
+	"encoding/binary"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// GetSdmCounter returns the last read counter seen from a secure tag, by its
+// UID as a hex string.
+func (d *Database) GetSdmCounter(uid string) (uint32, bool, error) {
+	var counter uint32
+	var ok bool
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		v := txn.Bucket([]byte(BucketSdm)).Get([]byte(uid))
+		if len(v) == 4 {
+			counter = binary.BigEndian.Uint32(v)
+			ok = true
+		}
+		return nil
+	})
+
+	return counter, ok, err
+}
+
+// SetSdmCounter stores the last read counter seen from a secure tag.
+func (d *Database) SetSdmCounter(uid string, counter uint32) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		v := make([]byte, 4)
+		binary.BigEndian.PutUint32(v, counter)
+		return txn.Bucket([]byte(BucketSdm)).Put([]byte(uid), v)
+	})
+}
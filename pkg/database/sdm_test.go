@@ -0,0 +1,28 @@
+package database
+
+import "testing"
+
+func TestSdmCounter(t *testing.T) {
+	db := testDb(t)
+
+	_, ok, err := db.GetSdmCounter("04de5f1eacc040")
+	if err != nil {
+		t.Fatal(err)
+	} else if ok {
+		t.Fatalf("expected no counter for new tag")
+	}
+
+	for _, ctr := range []uint32{6, 0xFFFFFF} {
+		err = db.SetSdmCounter("04de5f1eacc040", ctr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, ok, err := db.GetSdmCounter("04de5f1eacc040")
+		if err != nil {
+			t.Fatal(err)
+		} else if !ok || got != ctr {
+			t.Fatalf("expected: %d, got: %d, %v", ctr, got, ok)
+		}
+	}
+}
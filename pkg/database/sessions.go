@@ -0,0 +1,105 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Session is a single period of media being active on the platform, built
+// from the media started and stopped notifications.
+type Session struct {
+	Id         uint64    `json:"id"`
+	SystemId   string    `json:"systemId"`
+	SystemName string    `json:"systemName"`
+	MediaPath  string    `json:"mediaPath"`
+	MediaName  string    `json:"mediaName"`
+	Start      time.Time `json:"start"`
+	// Zero if the session is still active or wasn't closed cleanly.
+	End time.Time `json:"end"`
+	// UID of the token which launched the media, if known.
+	TokenUID string `json:"tokenUid"`
+}
+
+// Duration returns the length of the session. Sessions which are still
+// active, or were never closed, have no duration.
+func (s Session) Duration() time.Duration {
+	if s.End.IsZero() {
+		return 0
+	}
+	return s.End.Sub(s.Start)
+}
+
+// Zero padded so sessions are stored in the order they were started.
+func sessionKey(id uint64) []byte {
+	return []byte(fmt.Sprintf("sessions:%020d", id))
+}
+
+// AddSession stores a new session and returns it with its assigned ID.
+func (d *Database) AddSession(s Session) (Session, error) {
+	err := d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSessions))
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		s.Id = id
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(sessionKey(id), data)
+	})
+
+	return s, err
+}
+
+func (d *Database) UpdateSession(s Session) error {
+	return d.bdb.Update(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSessions))
+
+		data, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(sessionKey(s.Id), data)
+	})
+}
+
+// GetSessions returns all sessions matching the filter function, newest
+// first. A max of 0 returns all matching sessions.
+func (d *Database) GetSessions(filter func(Session) bool, max int) ([]Session, error) {
+	ss := make([]Session, 0)
+
+	err := d.bdb.View(func(txn *bolt.Tx) error {
+		b := txn.Bucket([]byte(BucketSessions))
+
+		c := b.Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if max > 0 && len(ss) >= max {
+				break
+			}
+
+			var s Session
+			err := json.Unmarshal(v, &s)
+			if err != nil {
+				return err
+			}
+
+			if filter != nil && !filter(s) {
+				continue
+			}
+
+			ss = append(ss, s)
+		}
+
+		return nil
+	})
+
+	return ss, err
+}
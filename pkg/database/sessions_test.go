@@ -0,0 +1,127 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	db := testDb(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := db.AddSession(Session{
+		SystemId:   "SNES",
+		SystemName: "Super Nintendo",
+		MediaPath:  "/media/snes/mario.sfc",
+		MediaName:  "Super Mario World",
+		Start:      start,
+		TokenUID:   "04aabbcc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if s.Id != 1 {
+		t.Fatalf("expected: %d, got: %d", 1, s.Id)
+	}
+
+	ss, err := db.GetSessions(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ss) != 1 {
+		t.Fatalf("expected: %d sessions, got: %d", 1, len(ss))
+	} else if !ss[0].End.IsZero() || ss[0].Duration() != 0 {
+		t.Fatalf("expected open session, got: %v", ss[0])
+	}
+
+	s.End = start.Add(90 * time.Second)
+	err = db.UpdateSession(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss, err = db.GetSessions(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ss) != 1 {
+		t.Fatalf("expected: %d sessions, got: %d", 1, len(ss))
+	}
+
+	got := ss[0]
+	if got.Id != s.Id ||
+		got.SystemId != s.SystemId ||
+		got.SystemName != s.SystemName ||
+		got.MediaPath != s.MediaPath ||
+		got.MediaName != s.MediaName ||
+		got.TokenUID != s.TokenUID ||
+		!got.Start.Equal(s.Start) ||
+		!got.End.Equal(s.End) {
+		t.Fatalf("expected: %v, got: %v", s, got)
+	}
+
+	if got.Duration() != 90*time.Second {
+		t.Fatalf("expected: %s, got: %s", 90*time.Second, got.Duration())
+	}
+}
+
+func TestGetSessions(t *testing.T) {
+	db := testDb(t)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, sys := range []string{"SNES", "Genesis", "SNES", "NES"} {
+		_, err := db.AddSession(Session{
+			SystemId: sys,
+			Start:    start.Add(time.Duration(i) * time.Minute),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter func(Session) bool
+		max    int
+		want   []uint64
+	}{
+		{name: "all newest first", want: []uint64{4, 3, 2, 1}},
+		{name: "max", max: 2, want: []uint64{4, 3}},
+		{
+			name:   "filter",
+			filter: func(s Session) bool { return s.SystemId == "SNES" },
+			want:   []uint64{3, 1},
+		},
+		{
+			name:   "filter and max",
+			filter: func(s Session) bool { return s.SystemId == "SNES" },
+			max:    1,
+			want:   []uint64{3},
+		},
+		{
+			name:   "no matches",
+			filter: func(s Session) bool { return s.SystemId == "N64" },
+			want:   []uint64{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ss, err := db.GetSessions(tt.filter, tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]uint64, len(ss))
+			for i, s := range ss {
+				got[i] = s.Id
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected: %v, got: %v", tt.want, got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("expected: %v, got: %v", tt.want, got)
+				}
+			}
+		})
+	}
+}
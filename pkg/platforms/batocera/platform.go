@@ -15,7 +15,9 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/file"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/hid_wedge"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/libnfc"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/qr"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/simple_serial"
 	"github.com/rs/zerolog/log"
 )
@@ -32,6 +34,8 @@ func (p *Platform) SupportedReaders(cfg *config.Instance) []readers.Reader {
 		libnfc.NewReader(cfg),
 		file.NewReader(cfg),
 		simple_serial.NewReader(cfg),
+		qr.NewReader(cfg),
+		hid_wedge.NewReader(cfg),
 	}
 }
 
@@ -140,7 +144,7 @@ func (p *Platform) LaunchFile(cfg *config.Instance, path string) error {
 	log.Info().Msgf("root: %s", root)
 
 	systemId := ""
-	for _, launcher := range p.Launchers() {
+	for _, launcher := range p.Launchers(cfg) {
 		for _, folder := range launcher.Folders {
 			if folder == root {
 				systemId = launcher.SystemId
@@ -150,10 +154,15 @@ func (p *Platform) LaunchFile(cfg *config.Instance, path string) error {
 	}
 
 	if systemId == "" {
+		// custom launchers may match the file by extension or scheme
+		if launchers := utils.PathToLaunchers(cfg, p, path); len(launchers) > 0 {
+			return launchers[0].Launch(cfg, path)
+		}
+
 		log.Error().Msgf("system not found for path: %s", path)
 	}
 
-	for _, launcher := range p.Launchers() {
+	for _, launcher := range p.Launchers(cfg) {
 		if launcher.SystemId == systemId {
 			return launcher.Launch(cfg, path)
 		}
@@ -182,8 +191,8 @@ func (p *Platform) LookupMapping(_ tokens.Token) (string, bool) {
 	return "", false
 }
 
-func (p *Platform) Launchers() []platforms.Launcher {
-	return []platforms.Launcher{
+func (p *Platform) Launchers(cfg *config.Instance) []platforms.Launcher {
+	return platforms.MergeLaunchers(cfg, p.RootDirs, []platforms.Launcher{
 		{
 			SystemId:   gamesdb.SystemGenesis,
 			Folders:    []string{"megadrive"},
@@ -195,5 +204,5 @@ func (p *Platform) Launchers() []platforms.Launcher {
 				return cmd.Start()
 			},
 		},
-	}
+	})
 }
@@ -0,0 +1,278 @@
+package platforms
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+// Processes started by custom launchers, by launcher ID. Launchers are
+// created fresh each time they're listed, so this is kept outside them.
+var customProcesses = struct {
+	mu    sync.Mutex
+	procs map[string]*os.Process
+}{procs: make(map[string]*os.Process)}
+
+// Split a command line into arguments. Arguments can be quoted with single
+// or double quotes, and a backslash escapes the next character outside of
+// single quotes.
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command: %s", s)
+	} else if escaped {
+		return nil, fmt.Errorf("trailing backslash in command: %s", s)
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+
+	return args, nil
+}
+
+// Replace the placeholders in each argument of a command template. Each
+// argument is replaced separately and no shell is involved, so paths don't
+// need quoting or escaping.
+func expandCommand(args []string, systemId string, path string) []string {
+	name := filepath.Base(path)
+	ext := filepath.Ext(path)
+
+	r := strings.NewReplacer(
+		"{path}", path,
+		"{dir}", filepath.Dir(path),
+		"{file}", name,
+		"{name}", strings.TrimSuffix(name, ext),
+		"{ext}", ext,
+		"{system}", systemId,
+	)
+
+	expanded := make([]string, 0, len(args))
+	for _, a := range args {
+		expanded = append(expanded, r.Replace(a))
+	}
+
+	return expanded
+}
+
+func normalizeExtensions(exts []string) []string {
+	normalized := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		} else if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized = append(normalized, ext)
+	}
+	return normalized
+}
+
+// Returns true if a path is inside one of a launcher's folders in any of the
+// root folders. The path is cleaned first so it can't use .. to leave them.
+func inLauncherFolders(roots []string, folders []string, path string) bool {
+	lp := strings.ToLower(filepath.Clean(path))
+	sep := string(filepath.Separator)
+
+	for _, root := range roots {
+		for _, folder := range folders {
+			dir := strings.ToLower(filepath.Clean(filepath.Join(root, folder)))
+			if strings.HasPrefix(lp, strings.TrimSuffix(dir, sep)+sep) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Create a launcher from a [[launchers.custom]] config entry. The roots
+// function returns the platform's root folders, it's only called when
+// launching.
+func customLauncher(
+	c config.LaunchersCustom,
+	roots func(*config.Instance) []string,
+) (Launcher, error) {
+	if c.Id == "" {
+		return Launcher{}, errors.New("custom launcher has no id")
+	} else if c.Launch == "" {
+		return Launcher{}, fmt.Errorf("custom launcher has no launch command: %s", c.Id)
+	}
+
+	launchArgs, err := splitCommand(c.Launch)
+	if err != nil {
+		return Launcher{}, err
+	} else if len(launchArgs) == 0 {
+		return Launcher{}, fmt.Errorf("custom launcher has no launch command: %s", c.Id)
+	}
+
+	var killArgs []string
+	if c.Kill != "" {
+		killArgs, err = splitCommand(c.Kill)
+		if err != nil {
+			return Launcher{}, err
+		}
+	}
+
+	schemes := make([]string, 0, len(c.Schemes))
+	for _, s := range c.Schemes {
+		schemes = append(schemes, strings.ToLower(strings.TrimSuffix(s, "://")))
+	}
+
+	// launchers without folders will launch any matching file from
+	// anywhere, so they must use the allow list like the built-in generic
+	// launchers. launchers with folders can also launch files outside them
+	// if they're in the allow list
+	allowListOnly := c.AllowListOnly || (len(c.Folders) == 0 && len(c.Extensions) > 0)
+
+	isScheme := func(path string) bool {
+		lp := strings.ToLower(path)
+		for _, s := range schemes {
+			if strings.HasPrefix(lp, s+":") {
+				return true
+			}
+		}
+		return false
+	}
+
+	return Launcher{
+		Id:            c.Id,
+		SystemId:      c.System,
+		Folders:       c.Folders,
+		Extensions:    normalizeExtensions(c.Extensions),
+		Schemes:       schemes,
+		AllowListOnly: allowListOnly,
+		Launch: func(cfg *config.Instance, path string) error {
+			switch {
+			case cfg.IsLauncherFileAllowed(path):
+			case allowListOnly:
+				return errors.New("file not in allow list: " + path)
+			case isScheme(path):
+			case inLauncherFolders(roots(cfg), c.Folders, path):
+			default:
+				return errors.New("file not in launcher folders or allow list: " + path)
+			}
+
+			args := expandCommand(launchArgs, c.System, path)
+			log.Info().Msgf("running custom launcher %s: %v", c.Id, args)
+
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Env = os.Environ()
+			err := cmd.Start()
+			if err != nil {
+				return err
+			}
+
+			customProcesses.mu.Lock()
+			customProcesses.procs[c.Id] = cmd.Process
+			customProcesses.mu.Unlock()
+
+			// reap the process when it exits
+			go func() {
+				err := cmd.Wait()
+				if err != nil {
+					log.Debug().Err(err).Msgf("custom launcher %s exited", c.Id)
+				}
+
+				customProcesses.mu.Lock()
+				if customProcesses.procs[c.Id] == cmd.Process {
+					delete(customProcesses.procs, c.Id)
+				}
+				customProcesses.mu.Unlock()
+			}()
+
+			return nil
+		},
+		Kill: func(cfg *config.Instance) error {
+			if len(killArgs) > 0 {
+				args := expandCommand(killArgs, c.System, "")
+				return exec.Command(args[0], args[1:]...).Run()
+			}
+
+			customProcesses.mu.Lock()
+			defer customProcesses.mu.Unlock()
+			p, ok := customProcesses.procs[c.Id]
+			if !ok {
+				return nil
+			}
+
+			return p.Kill()
+		},
+	}, nil
+}
+
+// CustomLaunchers returns the launchers defined in the [[launchers.custom]]
+// config table. Invalid entries are logged and skipped. Launchers with
+// folders can only launch files inside those folders in the given root
+// folders, or files in the allow list.
+func CustomLaunchers(cfg *config.Instance, roots func(*config.Instance) []string) []Launcher {
+	if cfg == nil {
+		return nil
+	}
+
+	var launchers []Launcher
+	for _, c := range cfg.CustomLaunchers() {
+		l, err := customLauncher(c, roots)
+		if err != nil {
+			log.Error().Err(err).Msg("invalid custom launcher")
+			continue
+		}
+		launchers = append(launchers, l)
+	}
+
+	return launchers
+}
+
+// MergeLaunchers adds the custom launchers from the config to a platform's
+// built-in launchers. Custom launchers come first so they're picked before
+// a built-in launcher for the same file. The roots function should be the
+// platform's RootDirs.
+func MergeLaunchers(
+	cfg *config.Instance,
+	roots func(*config.Instance) []string,
+	builtin []Launcher,
+) []Launcher {
+	return append(CustomLaunchers(cfg, roots), builtin...)
+}
@@ -0,0 +1,276 @@
+package platforms
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		cmd     string
+		want    []string
+		wantErr bool
+	}{
+		{cmd: "", want: nil},
+		{cmd: "   ", want: nil},
+		{cmd: "retroarch", want: []string{"retroarch"}},
+		{cmd: "retroarch -L core.so {path}", want: []string{"retroarch", "-L", "core.so", "{path}"}},
+		{cmd: "  a \t b\n c  ", want: []string{"a", "b", "c"}},
+		{cmd: `"/opt/My Emu/emu" "{path}"`, want: []string{"/opt/My Emu/emu", "{path}"}},
+		{cmd: `emu '--title={name} (x)'`, want: []string{"emu", "--title={name} (x)"}},
+		{cmd: `emu --opt="a b"c`, want: []string{"emu", "--opt=a bc"}},
+		{cmd: `emu a\ b`, want: []string{"emu", "a b"}},
+		{cmd: `emu "say \"hi\""`, want: []string{"emu", `say "hi"`}},
+		{cmd: `emu 'no \escape'`, want: []string{"emu", `no \escape`}},
+		{cmd: `emu "" ''`, want: []string{"emu", "", ""}},
+		{cmd: `emu "unterminated`, wantErr: true},
+		{cmd: `emu 'unterminated`, wantErr: true},
+		{cmd: `emu trailing\`, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		got, err := splitCommand(tc.cmd)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("%q: expected error, got: %q", tc.cmd, got)
+			}
+		} else if err != nil {
+			t.Fatalf("%q: %s", tc.cmd, err)
+		} else if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%q: expected: %q, got: %q", tc.cmd, tc.want, got)
+		}
+	}
+}
+
+func TestExpandCommand(t *testing.T) {
+	path := filepath.Join("/media", "fat", "games", "NES", "Super Mario Bros. (World).nes")
+	dir := filepath.Dir(path)
+
+	tests := []struct {
+		args []string
+		path string
+		want []string
+	}{
+		{
+			args: []string{"emu", "{path}"},
+			path: path,
+			want: []string{"emu", path},
+		},
+		{
+			args: []string{"emu", "--dir={dir}", "{file}", "{name}", "{ext}", "{system}"},
+			path: path,
+			want: []string{
+				"emu", "--dir=" + dir, "Super Mario Bros. (World).nes",
+				"Super Mario Bros. (World)", ".nes", "NES",
+			},
+		},
+		{
+			// values aren't expanded again
+			args: []string{"{name}"},
+			path: filepath.Join("/roms", "{path}.nes"),
+			want: []string{"{path}"},
+		},
+		{
+			args: []string{"emu", "{path}", "{unknown}"},
+			path: "steam://rungameid/123",
+			want: []string{"emu", "steam://rungameid/123", "{unknown}"},
+		},
+		{
+			args: []string{"pkill", "emu", "{path}"},
+			path: "",
+			want: []string{"pkill", "emu", ""},
+		},
+	}
+
+	for _, tc := range tests {
+		got := expandCommand(tc.args, "NES", tc.path)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%q: expected: %q, got: %q", tc.args, tc.want, got)
+		}
+	}
+}
+
+func TestCustomLauncher(t *testing.T) {
+	tests := []struct {
+		c       config.LaunchersCustom
+		want    Launcher
+		wantErr bool
+	}{
+		{c: config.LaunchersCustom{Launch: "emu"}, wantErr: true},
+		{c: config.LaunchersCustom{Id: "Emu"}, wantErr: true},
+		{c: config.LaunchersCustom{Id: "Emu", Launch: "  "}, wantErr: true},
+		{c: config.LaunchersCustom{Id: "Emu", Launch: `emu "{path}`}, wantErr: true},
+		{c: config.LaunchersCustom{Id: "Emu", Launch: "emu", Kill: `pkill 'emu`}, wantErr: true},
+		{
+			c: config.LaunchersCustom{
+				Id:         "Emu",
+				System:     "NES",
+				Folders:    []string{"NES"},
+				Extensions: []string{"NES", " .unf ", ""},
+				Schemes:    []string{"EMU://"},
+				Launch:     "emu {path}",
+			},
+			want: Launcher{
+				Id:         "Emu",
+				SystemId:   "NES",
+				Folders:    []string{"NES"},
+				Extensions: []string{".nes", ".unf"},
+				Schemes:    []string{"emu"},
+			},
+		},
+		{
+			c: config.LaunchersCustom{
+				Id:         "Generic",
+				Extensions: []string{".sh"},
+				Launch:     "sh {path}",
+			},
+			want: Launcher{
+				Id:            "Generic",
+				Extensions:    []string{".sh"},
+				Schemes:       []string{},
+				AllowListOnly: true,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		l, err := customLauncher(tc.c, nil)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("%+v: expected error", tc.c)
+			}
+			continue
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if l.Launch == nil || l.Kill == nil {
+			t.Fatalf("%s: expected launch and kill functions", tc.c.Id)
+		}
+		l.Launch, l.Kill = nil, nil
+		if !reflect.DeepEqual(l, tc.want) {
+			t.Fatalf("expected: %+v, got: %+v", tc.want, l)
+		}
+	}
+}
+
+func TestInLauncherFolders(t *testing.T) {
+	roots := []string{filepath.Join("/media", "fat", "games"), filepath.Join("/media", "usb0", "games") + "/"}
+	folders := []string{"NES", filepath.Join("Console", "Famicom")}
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/media/fat/games/NES/Mario.nes", want: true},
+		{path: "/media/fat/games/nes/USA/Mario.nes", want: true},
+		{path: "/media/usb0/games/Console/Famicom/Mario.nes", want: true},
+		{path: "/media/fat/games/SNES/Mario.sfc", want: false},
+		{path: "/media/fat/games/NESAlt/Mario.nes", want: false},
+		{path: "/media/fat/games/NES", want: false},
+		{path: "/media/fat/games/NES/../../../../etc/passwd", want: false},
+		{path: "/media/fat/games/NES/./USA/../Mario.nes", want: true},
+		{path: "/tmp/NES/Mario.nes", want: false},
+		{path: "NES/Mario.nes", want: false},
+	}
+
+	for _, tc := range tests {
+		if got := inLauncherFolders(roots, folders, filepath.FromSlash(tc.path)); got != tc.want {
+			t.Fatalf("%q: expected: %v, got: %v", tc.path, tc.want, got)
+		}
+	}
+
+	if inLauncherFolders(nil, folders, "/media/fat/games/NES/Mario.nes") {
+		t.Fatalf("expected no match without root folders")
+	}
+}
+
+func TestCustomLauncherAllowList(t *testing.T) {
+	if _, err := exec.LookPath("true"); err != nil {
+		t.Skip("true command not available")
+	}
+
+	cfg, err := config.NewConfig(t.TempDir(), config.Values{
+		Launchers: config.Launchers{
+			AllowFile: []string{`^/home/user/roms/.*\.nes$`, `^/tmp/ok\.sh$`},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := func(*config.Instance) []string {
+		return []string{"/media/fat/games"}
+	}
+
+	folders, err := customLauncher(config.LaunchersCustom{
+		Id:         "Folders",
+		Folders:    []string{"NES"},
+		Extensions: []string{".nes"},
+		Schemes:    []string{"emu"},
+		Launch:     "true {path}",
+	}, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generic, err := customLauncher(config.LaunchersCustom{
+		Id:         "Generic",
+		Extensions: []string{".sh"},
+		Launch:     "true {path}",
+	}, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schemes, err := customLauncher(config.LaunchersCustom{
+		Id:      "Schemes",
+		Schemes: []string{"steam"},
+		Launch:  "true {path}",
+	}, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bare, err := customLauncher(config.LaunchersCustom{
+		Id:     "Bare",
+		Launch: "true {path}",
+	}, roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		l       Launcher
+		path    string
+		allowed bool
+	}{
+		{l: folders, path: "/media/fat/games/NES/Mario.nes", allowed: true},
+		{l: folders, path: "/home/user/roms/Mario.nes", allowed: true},
+		{l: folders, path: "/media/fat/games/NES/../../../../tmp/evil.nes", allowed: false},
+		{l: folders, path: "/tmp/evil.nes", allowed: false},
+		{l: folders, path: "emu:game", allowed: true},
+		{l: generic, path: "/tmp/ok.sh", allowed: true},
+		{l: generic, path: "/tmp/evil.sh", allowed: false},
+		{l: generic, path: "/media/fat/games/NES/evil.sh", allowed: false},
+		{l: schemes, path: "steam://rungameid/123", allowed: true},
+		{l: schemes, path: "/tmp/evil.sh", allowed: false},
+		{l: schemes, path: "/media/fat/games/NES/Mario.nes", allowed: false},
+		{l: schemes, path: "/tmp/ok.sh", allowed: true},
+		{l: bare, path: "/tmp/evil.sh", allowed: false},
+		{l: bare, path: "/tmp/ok.sh", allowed: true},
+	}
+
+	for _, tc := range tests {
+		err := tc.l.Launch(cfg, tc.path)
+		if tc.allowed && err != nil {
+			t.Fatalf("%s %q: expected launch, got: %s", tc.l.Id, tc.path, err)
+		} else if !tc.allowed && err == nil {
+			t.Fatalf("%s %q: expected launch to be blocked", tc.l.Id, tc.path)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/file"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/pn532_uart"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/qr"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/simple_serial"
 	"github.com/rs/zerolog/log"
 )
@@ -28,6 +29,7 @@ func (p *Platform) SupportedReaders(cfg *config.Instance) []readers.Reader {
 	return []readers.Reader{
 		file.NewReader(cfg),
 		simple_serial.NewReader(cfg),
+		qr.NewReader(cfg),
 		pn532_uart.NewReader(cfg),
 	}
 }
@@ -140,6 +142,10 @@ func (p *Platform) LaunchFile(cfg *config.Instance, path string) error {
 		return exec.Command("cmd", "/c", "C:\\Program Files (x86)\\Steam\\steam.exe", "steam://rungameid/"+fn).Start()
 	}
 
+	if launchers := utils.PathToLaunchers(cfg, p, path); len(launchers) > 0 {
+		return launchers[0].Launch(cfg, path)
+	}
+
 	return nil
 }
 
@@ -163,6 +169,6 @@ func (p *Platform) LookupMapping(_ tokens.Token) (string, bool) {
 	return "", false
 }
 
-func (p *Platform) Launchers() []platforms.Launcher {
-	return nil
+func (p *Platform) Launchers(cfg *config.Instance) []platforms.Launcher {
+	return platforms.MergeLaunchers(cfg, p.RootDirs, nil)
 }
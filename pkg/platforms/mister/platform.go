@@ -23,7 +23,9 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/file"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/hid_wedge"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/libnfc"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/qr"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/simple_serial"
 	"github.com/bendahl/uinput"
 	"github.com/rs/zerolog/log"
@@ -79,6 +81,8 @@ func (p *Platform) SupportedReaders(cfg *config.Instance) []readers.Reader {
 		libnfc.NewReader(cfg),
 		file.NewReader(cfg),
 		simple_serial.NewReader(cfg),
+		qr.NewReader(cfg),
+		hid_wedge.NewReader(cfg),
 		optical_drive.NewReader(cfg),
 	}
 }
@@ -442,7 +446,7 @@ func readRomsets(filepath string) ([]Romset, error) {
 	return romsets.Romsets, nil
 }
 
-func (p *Platform) Launchers() []platforms.Launcher {
+func (p *Platform) Launchers(cfg *config.Instance) []platforms.Launcher {
 	amiga := platforms.Launcher{
 		Id:         gamesdb.SystemAmiga,
 		SystemId:   gamesdb.SystemAmiga,
@@ -464,7 +468,7 @@ func (p *Platform) Launchers() []platforms.Launcher {
 				return results, err
 			}
 
-			sfs := gamesdb.GetSystemPaths(p, p.RootDirs(cfg), []gamesdb.System{*s})
+			sfs := gamesdb.GetSystemPaths(cfg, p, p.RootDirs(cfg), []gamesdb.System{*s})
 			for _, sf := range sfs {
 				for _, txt := range []string{aGamesPath, aDemosPath} {
 					tp, err := gamesdb.FindPath(filepath.Join(sf.Path, txt))
@@ -520,7 +524,7 @@ func (p *Platform) Launchers() []platforms.Launcher {
 				return results, err
 			}
 
-			sfs := gamesdb.GetSystemPaths(p, p.RootDirs(cfg), []gamesdb.System{*s})
+			sfs := gamesdb.GetSystemPaths(cfg, p, p.RootDirs(cfg), []gamesdb.System{*s})
 			for _, sf := range sfs {
 				rsf, err := gamesdb.FindPath(filepath.Join(sf.Path, romsetsFilename))
 				if err == nil {
@@ -581,5 +585,5 @@ func (p *Platform) Launchers() []platforms.Launcher {
 	ls = append(ls, amiga)
 	ls = append(ls, neogeo)
 	ls = append(ls, mplayerVideo)
-	return ls
+	return platforms.MergeLaunchers(cfg, p.RootDirs, ls)
 }
@@ -16,8 +16,11 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms/mister"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/file"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/hid_wedge"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/libnfc"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/qr"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/simple_serial"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/bendahl/uinput"
 	mrextConfig "github.com/wizzomafizzo/mrext/pkg/config"
 	"github.com/wizzomafizzo/mrext/pkg/games"
@@ -41,6 +44,8 @@ func (p *Platform) SupportedReaders(cfg *config.Instance) []readers.Reader {
 		libnfc.NewReader(cfg),
 		file.NewReader(cfg),
 		simple_serial.NewReader(cfg),
+		qr.NewReader(cfg),
+		hid_wedge.NewReader(cfg),
 	}
 }
 
@@ -221,6 +226,12 @@ func (p *Platform) LaunchSystem(cfg *config.Instance, id string) error {
 }
 
 func (p *Platform) LaunchFile(cfg *config.Instance, path string) error {
+	for _, l := range platforms.CustomLaunchers(cfg, p.RootDirs) {
+		if utils.PathIsLauncher(cfg, p, l, path) {
+			return l.Launch(cfg, path)
+		}
+	}
+
 	return mm.LaunchGenericFile(mister.UserConfigToMrext(cfg), path)
 }
 
@@ -275,6 +286,6 @@ func (p *Platform) LookupMapping(_ tokens.Token) (string, bool) {
 	return "", false
 }
 
-func (p *Platform) Launchers() []platforms.Launcher {
-	return mister.Launchers
+func (p *Platform) Launchers(cfg *config.Instance) []platforms.Launcher {
+	return platforms.MergeLaunchers(cfg, p.RootDirs, mister.Launchers)
 }
@@ -11,6 +11,12 @@ import (
 const (
 	AssetsDir   = "assets"
 	MappingsDir = "mappings"
+	// DatsDir is the folder in the data folder which DAT files can be
+	// imported from by path.
+	DatsDir = "dats"
+	// AmiiboDbFile is an optional full Amiibo database in the data folder,
+	// used instead of the bundled one.
+	AmiiboDbFile = "amiibo.json"
 )
 
 type CmdEnv struct {
@@ -23,6 +29,10 @@ type CmdEnv struct {
 	Text          string
 	TotalCommands int
 	CurrentIndex  int
+	// Values set by earlier commands in the same chain, shared between all
+	// commands launched from a single token. Available to later commands
+	// using ${name} in their arguments.
+	Vars map[string]string
 }
 
 type ScanResult struct {
@@ -117,7 +127,9 @@ type Platform interface {
 	// Process a token command that has been resolved to a platform command.
 	ForwardCmd(CmdEnv) error
 	LookupMapping(tokens.Token) (string, bool)
-	Launchers() []Launcher
+	// Launchers returns all launchers available on the platform, including
+	// custom launchers defined in the config.
+	Launchers(*config.Instance) []Launcher
 }
 
 type LaunchToken struct {
@@ -39,6 +39,8 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/file"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/hid_wedge"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/qr"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/simple_serial"
 	"github.com/rs/zerolog/log"
 )
@@ -53,6 +55,8 @@ func (p *Platform) SupportedReaders(cfg *config.Instance) []readers.Reader {
 	return []readers.Reader{
 		file.NewReader(cfg),
 		simple_serial.NewReader(cfg),
+		qr.NewReader(cfg),
+		hid_wedge.NewReader(cfg),
 		libnfc.NewReader(cfg),
 		optical_drive.NewReader(cfg),
 	}
@@ -175,8 +179,8 @@ func (p *Platform) LookupMapping(_ tokens.Token) (string, bool) {
 	return "", false
 }
 
-func (p *Platform) Launchers() []platforms.Launcher {
-	return []platforms.Launcher{
+func (p *Platform) Launchers(cfg *config.Instance) []platforms.Launcher {
+	return platforms.MergeLaunchers(cfg, p.RootDirs, []platforms.Launcher{
 		{
 			Id:       "Steam",
 			SystemId: gamesdb.SystemPC,
@@ -210,5 +214,5 @@ func (p *Platform) Launchers() []platforms.Launcher {
 				return exec.Command("bash", "-c", path).Start()
 			},
 		},
-	}
+	})
 }
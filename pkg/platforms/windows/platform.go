@@ -21,6 +21,7 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/acr122_pcsc"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/file"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/pn532_uart"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/qr"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/simple_serial"
 	"github.com/rs/zerolog/log"
 )
@@ -36,6 +37,7 @@ func (p *Platform) SupportedReaders(cfg *config.Instance) []readers.Reader {
 	return []readers.Reader{
 		file.NewReader(cfg),
 		simple_serial.NewReader(cfg),
+		qr.NewReader(cfg),
 		acr122_pcsc.NewAcr122Pcsc(cfg),
 		pn532_uart.NewReader(cfg),
 	}
@@ -136,7 +138,7 @@ func (p *Platform) LaunchFile(cfg *config.Instance, path string) error {
 	lp := strings.ToLower(path)
 
 	// TODO: move to matchsystemfile
-	for _, l := range p.Launchers() {
+	for _, l := range p.Launchers(cfg) {
 		match := false
 
 		// check for global extensions
@@ -160,6 +162,11 @@ func (p *Platform) LaunchFile(cfg *config.Instance, path string) error {
 		}
 	}
 
+	if len(launchers) == 0 {
+		// custom launchers may be limited to folders
+		launchers = utils.PathToLaunchers(cfg, p, path)
+	}
+
 	if len(launchers) == 0 {
 		return errors.New("no launcher found for file")
 	}
@@ -422,8 +429,8 @@ func findLaunchBoxDir() (string, error) {
 	return "", fmt.Errorf("launchbox directory not found")
 }
 
-func (p *Platform) Launchers() []platforms.Launcher {
-	return []platforms.Launcher{
+func (p *Platform) Launchers(cfg *config.Instance) []platforms.Launcher {
+	return platforms.MergeLaunchers(cfg, p.RootDirs, []platforms.Launcher{
 		{
 			Id:       "Steam",
 			SystemId: gamesdb.SystemPC,
@@ -548,5 +555,5 @@ func (p *Platform) Launchers() []platforms.Launcher {
 				return exec.Command(cliLauncher, "launch_by_id", id).Start()
 			},
 		},
-	}
+	})
 }
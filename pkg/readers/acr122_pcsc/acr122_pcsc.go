@@ -1,31 +1,39 @@
 package acr122_pcsc
 
 import (
-	"bytes"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 	"strings"
 	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
 	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/ebfe/scard"
 	"github.com/rs/zerolog/log"
 )
 
+const (
+	periodBetweenPolls = 250 * time.Millisecond
+	writeTimeout       = 30 * time.Second
+)
+
 type Acr122Pcsc struct {
 	cfg     *config.Instance
 	device  string
 	name    string
 	polling bool
-	ctx     *scard.Context
+	ctx     pcscContext
+	write   chan readers.TagRequest
 }
 
 func NewAcr122Pcsc(cfg *config.Instance) *Acr122Pcsc {
 	return &Acr122Pcsc{
-		cfg: cfg,
+		cfg:   cfg,
+		write: make(chan readers.TagRequest),
 	}
 }
 
@@ -44,7 +52,7 @@ func (r *Acr122Pcsc) Open(device string, iq chan<- readers.Scan) error {
 	}
 
 	if r.ctx == nil {
-		ctx, err := scard.EstablishContext()
+		ctx, err := establishContext()
 		if err != nil {
 			return err
 		}
@@ -64,144 +72,337 @@ func (r *Acr122Pcsc) Open(device string, iq chan<- readers.Scan) error {
 	r.name = ps[1]
 	r.polling = true
 
-	go func() {
-		for r.polling {
-			ctx := r.ctx
-			if ctx == nil {
-				continue
-			}
+	go r.poll(iq)
 
-			rls, err := ctx.ListReaders()
-			if err != nil {
-				log.Debug().Msgf("error listing pcsc readers: %s", err)
-				continue
-			}
+	return nil
+}
 
-			if !utils.Contains(rls, r.name) {
-				log.Debug().Msgf("reader not found: %s", r.name)
-				r.polling = false
-				break
-			}
+// Watch the reader for cards being placed and removed, and handle write
+// requests in between. Only this goroutine uses the PC/SC context.
+func (r *Acr122Pcsc) poll(iq chan<- readers.Scan) {
+	var active *tokens.Token
+	state := scard.StateUnaware
 
-			rs := []scard.ReaderState{{
-				Reader:       r.name,
-				CurrentState: scard.StateUnaware,
-			}}
+	for r.polling {
+		ctx := r.ctx
+		if ctx == nil {
+			break
+		}
 
-			err = ctx.GetStatusChange(rs, 250*time.Millisecond)
-			if err != nil {
-				log.Debug().Msgf("error getting status change: %s", err)
-				continue
-			}
+		select {
+		case req := <-r.write:
+			req.Result <- r.handleRequest(ctx, req)
+		default:
+		}
 
-			if rs[0].EventState&scard.StatePresent == 0 {
-				continue
-			}
+		rls, err := ctx.ListReaders()
+		if err != nil {
+			log.Debug().Msgf("error listing pcsc readers: %s", err)
+			time.Sleep(periodBetweenPolls)
+			continue
+		}
 
-			tag, err := ctx.Connect(r.name, scard.ShareShared, scard.ProtocolAny)
-			if err != nil {
-				log.Debug().Msgf("error connecting to reader: %s", err)
-				continue
-			}
+		if !utils.Contains(rls, r.name) {
+			log.Debug().Msgf("reader not found: %s", r.name)
+			r.polling = false
+			break
+		}
 
-			status, err := tag.Status()
-			if err != nil {
-				log.Debug().Msgf("error getting status: %s", err)
-				_ = tag.Disconnect(scard.ResetCard)
-				continue
-			}
+		rs := []scard.ReaderState{{
+			Reader:       r.name,
+			CurrentState: state,
+		}}
+
+		err = ctx.GetStatusChange(rs, periodBetweenPolls)
+		if errors.Is(err, scard.ErrTimeout) {
+			// nothing changed
+			continue
+		} else if err != nil {
+			log.Debug().Msgf("error getting status change: %s", err)
+			time.Sleep(periodBetweenPolls)
+			continue
+		}
 
-			log.Debug().Msgf("status: %v", hex.EncodeToString(status.Atr))
+		state = rs[0].EventState &^ scard.StateChanged
+		present := state&scard.StatePresent != 0
 
-			res, err := tag.Transmit([]byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+		// PC/SC only gives access to one card per reader, so combinations
+		// of tags can't be read and only the first tag placed is reported
+		if present && active == nil {
+			token, err := r.readToken(ctx)
 			if err != nil {
-				log.Debug().Msgf("error transmitting: %s", err)
+				log.Debug().Msgf("error reading token: %s", err)
+				// try again on the next loop
+				state = scard.StateUnaware
+				time.Sleep(periodBetweenPolls)
 				continue
 			}
 
-			if len(res) < 2 {
-				log.Debug().Msgf("invalid response")
-				_ = tag.Disconnect(scard.ResetCard)
-				continue
+			log.Info().Msg("new token detected, sending to input queue")
+			active = token
+			iq <- readers.Scan{
+				Source: r.device,
+				Token:  token,
 			}
-
-			resCode := res[len(res)-2:]
-			if resCode[0] != 0x90 && resCode[1] != 0x00 {
-				log.Debug().Msgf("invalid response code: %x", resCode)
-				_ = tag.Disconnect(scard.ResetCard)
-				continue
+		} else if !present && active != nil {
+			log.Info().Msg("token removed, sending to input queue")
+			active = nil
+			iq <- readers.Scan{
+				Source: r.device,
+				Token:  nil,
 			}
+		}
+	}
+}
 
-			log.Debug().Msgf("response: %x", res)
-			uid := res[:len(res)-2]
-
-			i := 0
-			data := make([]byte, 0)
-			for {
-				res, err = tag.Transmit([]byte{0xFF, 0xB0, 0x00, byte(i), 0x04})
-				if err != nil {
-					log.Debug().Msgf("error transmitting: %s", err)
-					break
-				} else if bytes.Equal(res, []byte{0x00, 0x00, 0x00, 0x00, 0x90, 0x00}) {
-					break
-				} else if len(res) < 6 {
-					log.Debug().Msgf("invalid response")
-					break
-				} else if i >= 221 {
-					break
-				}
-
-				data = append(data, res[:len(res)-2]...)
-				i++
-			}
+// Read the UID and, if it's a supported tag, the NDEF data from a card.
+func (r *Acr122Pcsc) readCard(card pcscCard) (*tokens.Token, error) {
+	status, err := card.Status()
+	if err != nil {
+		return nil, err
+	}
 
-			log.Debug().Msgf("data: %x", data)
+	log.Debug().Msgf("status: %v", hex.EncodeToString(status.Atr))
 
-			text, err := ParseRecordText(data)
-			if err != nil {
-				log.Debug().Msgf("error parsing NDEF record: %s", err)
-				text = ""
-			}
+	uid, err := getUID(card)
+	if err != nil {
+		return nil, err
+	}
 
-			iq <- readers.Scan{
-				Source: r.device,
-				Token: &tokens.Token{
-					UID:      hex.EncodeToString(uid),
-					Text:     text,
-					ScanTime: time.Now(),
-					Source:   r.device,
-				},
-			}
+	token := &tokens.Token{
+		Type:     cardType(status.Atr),
+		UID:      hex.EncodeToString(uid),
+		ScanTime: time.Now(),
+		Source:   r.device,
+	}
 
-			_ = tag.Disconnect(scard.ResetCard)
+	var data []byte
+	switch token.Type {
+	case tokens.TypeNTAG:
+		toyType, toy, toyErr := readToy(card)
+		if toyErr == nil && toyType != "" {
+			log.Info().Msgf("found %s tag", toyType)
+			token.Type = toyType
+			token.Data = hex.EncodeToString(toy)
+			return token, nil
+		}
+		data, err = readNtag(card)
+	case tokens.TypeMifare:
+		data, err = readMifare(card, r.mifareKeys())
+	case tokens.TypeType4:
+		data, err = readType4(card)
+	default:
+		log.Debug().Msgf("unsupported card type, only reading uid: %x", status.Atr)
+		return token, nil
+	}
+	if err != nil {
+		// a token with just a UID can still be mapped
+		log.Warn().Err(err).Msgf("error reading %s data", token.Type)
+		return token, nil
+	}
 
-			for r.polling {
-				rs := []scard.ReaderState{{
-					Reader:       r.name,
-					CurrentState: scard.StatePresent,
-				}}
+	log.Debug().Msgf("data: %x", data)
+	token.Data = hex.EncodeToString(data)
 
-				err := ctx.GetStatusChange(rs, 250*time.Millisecond)
-				if err != nil {
-					log.Debug().Msgf("error getting status change: %s", err)
-					break
-				}
+	tr, err := ndef.ParseToken(data)
+	if err != nil {
+		log.Debug().Msgf("error parsing NDEF record: %s", err)
+	} else {
+		token.Text = tr.Text
+	}
 
-				if rs[0].EventState&scard.StatePresent == 0 {
-					break
-				}
-			}
+	return token, nil
+}
 
-			iq <- readers.Scan{
-				Source: r.device,
-				Token:  nil,
-			}
+func (r *Acr122Pcsc) readToken(ctx pcscContext) (*tokens.Token, error) {
+	card, err := ctx.Connect(r.name, scard.ShareShared, scard.ProtocolAny)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = card.Disconnect(scard.ResetCard)
+	}()
+
+	return r.readCard(card)
+}
+
+// Wait for a card to be placed on the reader and connect to it.
+func (r *Acr122Pcsc) waitForCard(ctx pcscContext) (pcscCard, error) {
+	deadline := time.Now().Add(writeTimeout)
+	for time.Now().Before(deadline) {
+		card, err := ctx.Connect(r.name, scard.ShareShared, scard.ProtocolAny)
+		if err == nil {
+			return card, nil
 		}
+		time.Sleep(periodBetweenPolls)
+	}
+
+	return nil, errors.New("could not detect a tag")
+}
+
+func (r *Acr122Pcsc) handleRequest(ctx pcscContext, req readers.TagRequest) readers.TagResult {
+	card, err := r.waitForCard(ctx)
+	if err != nil {
+		return readers.TagResult{Err: err}
+	}
+	defer func() {
+		_ = card.Disconnect(scard.ResetCard)
 	}()
 
+	current, err := r.readCard(card)
+	if err != nil {
+		return readers.TagResult{Err: err}
+	}
+
+	switch req.Op {
+	case readers.OpWrite:
+		token, err := r.writeCard(card, current, req.Message)
+		return readers.TagResult{Token: token, Err: err}
+	case readers.OpErase:
+		return readers.TagResult{Err: r.eraseCard(card, current)}
+	case readers.OpInspect:
+		info, err := r.inspectCard(card, current)
+		return readers.TagResult{Info: info, Err: err}
+	default:
+		return readers.TagResult{Err: fmt.Errorf("unknown request: %d", req.Op)}
+	}
+}
+
+// Extra MIFARE Classic keys from the config.
+func (r *Acr122Pcsc) mifareKeys() [][]byte {
+	if r.cfg == nil {
+		return nil
+	}
+	return r.cfg.MifareKeys()
+}
+
+func (r *Acr122Pcsc) writeData(card pcscCard, cardType string, data []byte) error {
+	switch cardType {
+	case tokens.TypeNTAG:
+		return writeNtag(card, data)
+	case tokens.TypeMifare:
+		return writeMifare(card, r.mifareKeys(), data)
+	default:
+		return fmt.Errorf("unsupported tag type: %s", cardType)
+	}
+}
+
+func (r *Acr122Pcsc) writeCard(
+	card pcscCard,
+	current *tokens.Token,
+	msg readers.WriteRequest,
+) (*tokens.Token, error) {
+	if msg.Lock && current.Type != tokens.TypeNTAG {
+		return nil, fmt.Errorf("locking is not supported for tag type: %s", current.Type)
+	}
+
+	data, err := ndef.BuildMessage(msg.Records...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.writeData(card, current.Type, data)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := r.readCard(card)
+	if err != nil {
+		return nil, fmt.Errorf("error reading written tag: %w", err)
+	}
+
+	if msg.Verify {
+		err := readers.VerifyWrite(token, data)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Msg("verified written tag")
+	}
+
+	if msg.Lock {
+		err := ndef.LockNtag(ntagAccess{card: card})
+		if err != nil {
+			return nil, fmt.Errorf("error locking tag: %w", err)
+		}
+		log.Info().Msgf("locked tag: %s", token.UID)
+	}
+
+	log.Info().Msgf("successfully wrote to card: %x", data)
+	return token, nil
+}
+
+func (r *Acr122Pcsc) capacity(card pcscCard, cardType string) (int, bool, error) {
+	switch cardType {
+	case tokens.TypeNTAG:
+		cc, err := readNtagCC(card)
+		if err != nil {
+			return 0, false, err
+		}
+		return int(cc[2]) * 8, cc[3] != 0x00, nil
+	case tokens.TypeMifare:
+		size, err := mifareCapacity(card, r.mifareKeys())
+		return size, false, err
+	case tokens.TypeType4:
+		cc, err := ndef.ReadType4CC(card.Transmit)
+		if err != nil {
+			return 0, false, err
+		}
+		// the NDEF file starts with its 2 byte length
+		return cc.MaxSize - 2, cc.WriteAccess != 0x00, nil
+	default:
+		return 0, false, fmt.Errorf("unsupported tag type: %s", cardType)
+	}
+}
+
+func (r *Acr122Pcsc) eraseCard(card pcscCard, current *tokens.Token) error {
+	size, _, err := r.capacity(card, current.Type)
+	if err != nil {
+		return err
+	}
+
+	read, err := hex.DecodeString(current.Data)
+	if err != nil {
+		return err
+	}
+
+	err = r.writeData(card, current.Type, ndef.ErasedMessage(min(len(read), size)))
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("erased tag: %s", current.UID)
 	return nil
 }
 
+func (r *Acr122Pcsc) inspectCard(card pcscCard, current *tokens.Token) (*readers.TagInfo, error) {
+	size, readOnly, err := r.capacity(card, current.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &readers.TagInfo{
+		UID:      current.UID,
+		Type:     current.Type,
+		Capacity: size,
+		ReadOnly: readOnly,
+	}
+
+	read, err := hex.DecodeString(current.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := ndef.FindMessage(read)
+	if err == nil {
+		header, err := ndef.CalculateNdefHeader(msg)
+		if err == nil {
+			info.Used = len(header) + len(msg) + 1
+		}
+	}
+
+	return info, nil
+}
+
 func (r *Acr122Pcsc) Close() error {
 	r.polling = false
 	if r.ctx != nil {
@@ -259,6 +460,35 @@ func (r *Acr122Pcsc) Info() string {
 	return r.name
 }
 
-func (r *Acr122Pcsc) Write(_ string) (*tokens.Token, error) {
-	return nil, errors.New("writing not supported on this reader")
+func (r *Acr122Pcsc) Writable() bool {
+	return true
+}
+
+func (r *Acr122Pcsc) MultiTag() bool {
+	return false
+}
+
+func (r *Acr122Pcsc) Write(text string) (*tokens.Token, error) {
+	return r.WriteMessage(readers.WriteRequest{
+		Records: []ndef.Record{ndef.NewTextRecord(text, "en")},
+		Verify:  true,
+	})
+}
+
+func (r *Acr122Pcsc) WriteMessage(msg readers.WriteRequest) (*tokens.Token, error) {
+	res := readers.SendTagRequest(r, r.write, readers.TagRequest{
+		Op:      readers.OpWrite,
+		Message: msg,
+	})
+	return res.Token, res.Err
+}
+
+func (r *Acr122Pcsc) Erase() error {
+	res := readers.SendTagRequest(r, r.write, readers.TagRequest{Op: readers.OpErase})
+	return res.Err
+}
+
+func (r *Acr122Pcsc) Inspect() (*readers.TagInfo, error) {
+	res := readers.SendTagRequest(r, r.write, readers.TagRequest{Op: readers.OpInspect})
+	return res.Info, res.Err
 }
@@ -0,0 +1,308 @@
+package acr122_pcsc
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+var (
+	testUid     = []byte{0x04, 0x5A, 0x2B, 0x1A, 0x7C, 0x5E, 0x80}
+	testNdefKey = []byte{0xD3, 0xF7, 0xD3, 0xF7, 0xD3, 0xF7}
+)
+
+func textMessage(t *testing.T, text string) []byte {
+	t.Helper()
+	data, err := ndef.BuildMessage(ndef.NewTextRecord(text, "en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCardType(t *testing.T) {
+	tests := []struct {
+		atr  []byte
+		want string
+	}{
+		{atr: atrNtag, want: tokens.TypeNTAG},
+		{atr: atrMifare, want: tokens.TypeMifare},
+		{atr: []byte{0x3B, 0x80, 0x80, 0x01, 0x01}, want: tokens.TypeType4},
+		{atr: []byte{0x3B, 0x02, 0x14, 0x50}, want: ""},
+		{atr: nil, want: ""},
+	}
+
+	for _, tc := range tests {
+		got := cardType(tc.atr)
+		if got != tc.want {
+			t.Fatalf("atr %x, expected: %q, got: %q", tc.atr, tc.want, got)
+		}
+	}
+}
+
+func TestReadCard(t *testing.T) {
+	lockControl := []byte{0x01, 0x03, 0xA0, 0x0C, 0x34}
+	long := strings.Repeat("A", 300)
+
+	tests := []struct {
+		name string
+		card *fakeCard
+		typ  string
+		want string
+	}{
+		{
+			name: "ntag",
+			card: newFakeNtag(testUid, textMessage(t, "**random:snes")),
+			typ:  tokens.TypeNTAG,
+			want: "**random:snes",
+		},
+		{
+			name: "ntag with lock control tlv",
+			card: newFakeNtag(testUid, append(lockControl, textMessage(t, "**random:snes")...)),
+			typ:  tokens.TypeNTAG,
+			want: "**random:snes",
+		},
+		{
+			name: "ntag long message",
+			card: newFakeNtag(testUid, textMessage(t, long)),
+			typ:  tokens.TypeNTAG,
+			want: long,
+		},
+		{
+			name: "blank ntag",
+			card: newFakeNtag(testUid, nil),
+			typ:  tokens.TypeNTAG,
+		},
+		{
+			name: "mifare with ndef key",
+			card: newFakeMifare(testUid, textMessage(t, long), testNdefKey),
+			typ:  tokens.TypeMifare,
+			want: long,
+		},
+		{
+			name: "mifare with default key",
+			card: newFakeMifare(testUid, textMessage(t, "**random:snes"), bytes.Repeat([]byte{0xFF}, 6)),
+			typ:  tokens.TypeMifare,
+			want: "**random:snes",
+		},
+		{
+			name: "mifare with unknown key",
+			card: newFakeMifare(testUid, textMessage(t, "**random:snes"), bytes.Repeat([]byte{0x01}, 6)),
+			typ:  tokens.TypeMifare,
+		},
+	}
+
+	r := &Acr122Pcsc{device: "acr122_pcsc:test"}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			token, err := r.readCard(tc.card)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.UID != "045a2b1a7c5e80" {
+				t.Fatalf("unexpected uid: %s", token.UID)
+			}
+			if token.Type != tc.typ {
+				t.Fatalf("expected type: %q, got: %q", tc.typ, token.Type)
+			}
+			if token.Text != tc.want {
+				t.Fatalf("expected text: %q, got: %q", tc.want, token.Text)
+			}
+		})
+	}
+}
+
+func TestWriteCard(t *testing.T) {
+	msg := readers.WriteRequest{
+		Records: []ndef.Record{
+			ndef.NewURIRecord("https://zaparoo.link/abc"),
+			ndef.NewTextRecord("**launch.random:snes", "en"),
+		},
+		Verify: true,
+	}
+
+	cards := map[string]*fakeCard{
+		"ntag":   newFakeNtag(testUid, textMessage(t, strings.Repeat("B", 200))),
+		"mifare": newFakeMifare(testUid, textMessage(t, strings.Repeat("B", 200)), testNdefKey),
+	}
+
+	r := &Acr122Pcsc{device: "acr122_pcsc:test"}
+
+	for name, card := range cards {
+		t.Run(name, func(t *testing.T) {
+			current, err := r.readCard(card)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := r.writeCard(card, current, msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.Text != "**launch.random:snes" {
+				t.Fatalf("unexpected text after write: %q", token.Text)
+			}
+
+			info, err := r.inspectCard(card, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := ndef.BuildMessage(msg.Records...)
+			if info.Used != len(data) || info.Capacity < info.Used || info.ReadOnly {
+				t.Fatalf("unexpected tag info: %+v", info)
+			}
+
+			err = r.eraseCard(card, token)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			erased, err := r.readCard(card)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if erased.Text != "" {
+				t.Fatalf("expected erased tag, got: %q", erased.Text)
+			}
+		})
+	}
+}
+
+func TestWriteCardTooBig(t *testing.T) {
+	card := newFakeNtag(testUid, nil)
+	card.mem[14] = 0x12 // NTAG213
+
+	r := &Acr122Pcsc{}
+	current, err := r.readCard(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.writeCard(card, current, readers.WriteRequest{
+		Records: []ndef.Record{ndef.NewTextRecord(strings.Repeat("A", 200), "en")},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestLockNtag(t *testing.T) {
+	card := newFakeNtag(testUid, nil)
+	r := &Acr122Pcsc{}
+
+	current, err := r.readCard(card)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.writeCard(card, current, readers.WriteRequest{
+		Records: []ndef.Record{ndef.NewTextRecord("**random:snes", "en")},
+		Verify:  true,
+		Lock:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem := card.data()
+	if !bytes.Equal(card.mem[8:16], []byte{0x00, 0x00, 0xFF, 0xFF, 0xE1, 0x10, 0x3E, 0x0F}) {
+		t.Fatalf("unexpected lock and cc pages: %x", card.mem[8:16])
+	}
+	if !bytes.Equal(card.mem[0x82*4:0x82*4+4], []byte{0xFF, 0xFF, 0xFF, 0x00}) {
+		t.Fatalf("unexpected dynamic lock bytes: %x", card.mem[0x82*4:0x82*4+4])
+	}
+
+	info, err := r.inspectCard(card, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ReadOnly {
+		t.Fatal("expected read-only tag")
+	}
+
+	err = writeNtag(card, textMessage(t, "A"))
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected error %v, got: %v", ErrReadOnly, err)
+	}
+	if !bytes.Equal(card.data(), mem) {
+		t.Fatal("locked tag was written")
+	}
+}
+
+func expectScan(t *testing.T, iq <-chan readers.Scan) readers.Scan {
+	t.Helper()
+	select {
+	case scan := <-iq:
+		return scan
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for scan")
+	}
+	return readers.Scan{}
+}
+
+func expectNoScan(t *testing.T, iq <-chan readers.Scan) {
+	t.Helper()
+	select {
+	case scan := <-iq:
+		t.Fatalf("unexpected scan: %+v", scan.Token)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPoll(t *testing.T) {
+	ctx := &fakeContext{reader: "ACS ACR122U PICC Interface 00 00"}
+	r := NewAcr122Pcsc(nil)
+	r.ctx = ctx
+
+	iq := make(chan readers.Scan)
+	err := r.Open("acr122_pcsc:"+ctx.reader, iq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = r.Close()
+	}()
+
+	expectNoScan(t, iq)
+
+	card := newFakeNtag(testUid, textMessage(t, "**random:snes"))
+	ctx.setCard(card)
+
+	scan := expectScan(t, iq)
+	if scan.Token == nil || scan.Token.Text != "**random:snes" || scan.Source != r.device {
+		t.Fatalf("unexpected scan: %+v", scan.Token)
+	}
+
+	// still on the reader
+	expectNoScan(t, iq)
+
+	token, err := r.Write("**launch.random:nes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Text != "**launch.random:nes" {
+		t.Fatalf("unexpected text after write: %q", token.Text)
+	}
+
+	// writing doesn't count as a new scan
+	expectNoScan(t, iq)
+
+	ctx.setCard(nil)
+	scan = expectScan(t, iq)
+	if scan.Token != nil {
+		t.Fatalf("expected removal, got: %+v", scan.Token)
+	}
+
+	ctx.setCard(card)
+	scan = expectScan(t, iq)
+	if scan.Token == nil || scan.Token.Text != "**launch.random:nes" {
+		t.Fatalf("unexpected scan: %+v", scan.Token)
+	}
+}
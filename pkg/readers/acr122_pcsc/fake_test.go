@@ -0,0 +1,188 @@
+package acr122_pcsc
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/ebfe/scard"
+)
+
+var (
+	atrNtag   = []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00, 0x00, 0x68}
+	atrMifare = []byte{0x3B, 0x8F, 0x80, 0x01, 0x80, 0x4F, 0x0C, 0xA0, 0x00, 0x00, 0x03, 0x06, 0x03, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x6A}
+
+	swOk    = []byte{0x90, 0x00}
+	swError = []byte{0x63, 0x00}
+)
+
+// fakeCard emulates a tag on an ACR122U, responding to the reader's
+// pseudo-APDUs.
+type fakeCard struct {
+	mu    sync.Mutex
+	atr   []byte
+	uid   []byte
+	mem   []byte
+	block int
+	// MIFARE Classic key A for each sector, authentication is skipped if
+	// nil
+	keys       [][]byte
+	loadedKey  []byte
+	authSector int
+	// NTAG lock state, set when the static lock bytes are written
+	locked bool
+}
+
+func newFakeNtag(uid []byte, data []byte) *fakeCard {
+	// NTAG215: 135 pages
+	mem := make([]byte, 135*4)
+	copy(mem, uid)
+	copy(mem[12:], []byte{0xE1, 0x10, 0x3E, 0x00})
+	copy(mem[16:], data)
+	return &fakeCard{atr: atrNtag, uid: uid, mem: mem, block: 4, authSector: -1}
+}
+
+func newFakeMifare(uid []byte, data []byte, key []byte) *fakeCard {
+	mem := make([]byte, 64*16)
+	copy(mem, uid)
+	keys := make([][]byte, 16)
+	for i := range keys {
+		keys[i] = key
+	}
+
+	// data goes in the data blocks from sector 1, skipping trailers
+	offset := 0
+	for block := 4; block < 64 && offset < len(data); block++ {
+		if block%4 == 3 {
+			continue
+		}
+		offset += copy(mem[block*16:block*16+16], data[offset:])
+	}
+
+	return &fakeCard{atr: atrMifare, uid: uid, mem: mem, block: 16, keys: keys, authSector: -1}
+}
+
+func (c *fakeCard) Status() (*scard.CardStatus, error) {
+	return &scard.CardStatus{Atr: c.atr}, nil
+}
+
+func (c *fakeCard) Disconnect(scard.Disposition) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.authSector = -1
+	return nil
+}
+
+func (c *fakeCard) canAccess(block int) bool {
+	if c.keys == nil {
+		return true
+	}
+	return c.authSector == block/4
+}
+
+func (c *fakeCard) Transmit(cmd []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(cmd) < 5 || cmd[0] != 0xFF {
+		return swError, nil
+	}
+
+	switch cmd[1] {
+	case 0xCA:
+		return append(append([]byte{}, c.uid...), swOk...), nil
+	case 0xB0:
+		start := int(cmd[3]) * c.block
+		end := start + int(cmd[4])
+		if !c.canAccess(int(cmd[3])) || end > len(c.mem) {
+			return swError, nil
+		}
+		return append(append([]byte{}, c.mem[start:end]...), swOk...), nil
+	case 0xD6:
+		block := int(cmd[3])
+		data := cmd[5:]
+		if !c.canAccess(block) || len(data) != c.block || (block+1)*c.block > len(c.mem) {
+			return swError, nil
+		}
+		if c.locked && c.keys == nil && block >= 2 {
+			return swError, nil
+		}
+		start := block * c.block
+		if c.keys == nil && block == 2 {
+			// only the lock bytes of the static lock page are writable
+			copy(c.mem[start+2:start+4], data[2:4])
+			c.locked = data[2] == 0xFF && data[3] == 0xFF
+		} else {
+			copy(c.mem[start:start+c.block], data)
+		}
+		return swOk, nil
+	case 0x82:
+		c.loadedKey = append([]byte{}, cmd[5:]...)
+		return swOk, nil
+	case 0x86:
+		sector := int(cmd[7]) / 4
+		if c.keys == nil || !bytes.Equal(c.keys[sector], c.loadedKey) {
+			c.authSector = -1
+			return swError, nil
+		}
+		c.authSector = sector
+		return swOk, nil
+	}
+
+	return swError, nil
+}
+
+// NTAG data area from block 4.
+func (c *fakeCard) data() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte{}, c.mem[16:]...)
+}
+
+// fakeContext emulates a PC/SC context with a single reader.
+type fakeContext struct {
+	mu     sync.Mutex
+	reader string
+	card   *fakeCard
+}
+
+func (f *fakeContext) setCard(card *fakeCard) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.card = card
+}
+
+func (f *fakeContext) ListReaders() ([]string, error) {
+	return []string{f.reader}, nil
+}
+
+func (f *fakeContext) GetStatusChange(rs []scard.ReaderState, timeout time.Duration) error {
+	f.mu.Lock()
+	state := scard.StateEmpty
+	if f.card != nil {
+		state = scard.StatePresent
+	}
+	f.mu.Unlock()
+
+	if rs[0].CurrentState == state {
+		// keep tests quick, a real reader would block until the timeout
+		time.Sleep(time.Millisecond)
+		return scard.ErrTimeout
+	}
+
+	rs[0].EventState = state | scard.StateChanged
+	return nil
+}
+
+func (f *fakeContext) Connect(_ string, _ scard.ShareMode, _ scard.Protocol) (pcscCard, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.card == nil {
+		return nil, scard.ErrNoSmartcard
+	}
+	return f.card, nil
+}
+
+func (f *fakeContext) Release() error {
+	return nil
+}
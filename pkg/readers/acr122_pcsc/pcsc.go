@@ -0,0 +1,88 @@
+package acr122_pcsc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
+	"github.com/ebfe/scard"
+)
+
+// pcscContext is the part of a PC/SC context used by the reader, so it can
+// be replaced with a fake in tests.
+type pcscContext interface {
+	ListReaders() ([]string, error)
+	GetStatusChange([]scard.ReaderState, time.Duration) error
+	Connect(string, scard.ShareMode, scard.Protocol) (pcscCard, error)
+	Release() error
+}
+
+// pcscCard is a connection to a card on the reader.
+type pcscCard interface {
+	Status() (*scard.CardStatus, error)
+	Transmit([]byte) ([]byte, error)
+	Disconnect(scard.Disposition) error
+}
+
+type scardContext struct {
+	*scard.Context
+}
+
+func (c scardContext) Connect(reader string, mode scard.ShareMode, proto scard.Protocol) (pcscCard, error) {
+	return c.Context.Connect(reader, mode, proto)
+}
+
+func establishContext() (pcscContext, error) {
+	ctx, err := scard.EstablishContext()
+	if err != nil {
+		return nil, err
+	}
+	return scardContext{ctx}, nil
+}
+
+// Send an APDU and check the status word. Returns the response data without
+// the status word.
+func transmit(card pcscCard, cmd []byte) ([]byte, error) {
+	return ndef.TransceiveAPDU(card.Transmit, cmd)
+}
+
+// ACR122U pseudo-APDUs for contactless storage cards.
+// https://www.acs.com.hk/download-manual/419/API-ACR122U-2.04.pdf
+
+func getUID(card pcscCard) ([]byte, error) {
+	return transmit(card, []byte{0xFF, 0xCA, 0x00, 0x00, 0x00})
+}
+
+// Read a number of bytes from a page or block. The reader allows up to 16
+// bytes per read.
+func readBinary(card pcscCard, block byte, length byte) ([]byte, error) {
+	res, err := transmit(card, []byte{0xFF, 0xB0, 0x00, block, length})
+	if err != nil {
+		return nil, err
+	} else if len(res) != int(length) {
+		return nil, fmt.Errorf("short read from block %d: %x", block, res)
+	}
+	return res, nil
+}
+
+func updateBinary(card pcscCard, block byte, data []byte) error {
+	cmd := append([]byte{0xFF, 0xD6, 0x00, block, byte(len(data))}, data...)
+	_, err := transmit(card, cmd)
+	return err
+}
+
+// Load a MIFARE key into the reader's volatile key slot 0.
+func loadKey(card pcscCard, key []byte) error {
+	cmd := append([]byte{0xFF, 0x82, 0x00, 0x00, byte(len(key))}, key...)
+	_, err := transmit(card, cmd)
+	return err
+}
+
+// Authenticate a MIFARE Classic block with the key in slot 0.
+func authenticate(card pcscCard, block byte, keyType byte) error {
+	_, err := transmit(card, []byte{
+		0xFF, 0x86, 0x00, 0x00, 0x05,
+		0x01, 0x00, block, keyType, 0x00,
+	})
+	return err
+}
@@ -0,0 +1,237 @@
+package acr122_pcsc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/toys"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+const keyTypeA = 0x60
+
+var ErrReadOnly = errors.New("tag is read-only")
+
+// Returns true if the ATR is for a contactless storage card, which has a
+// card name in its historical bytes.
+// PC/SC part 3 supplemental document, section 3.1.3.2.3
+func storageCard(atr []byte) bool {
+	return len(atr) >= 15 && atr[0] == 0x3B && atr[4] == 0x80 && atr[5] == 0x4F
+}
+
+// Get the token type from the card name in a PC/SC contactless storage card
+// ATR. Other cards using ISO 14443-4 are treated as Type 4 tags.
+// PC/SC part 3 supplemental document, section 3.1.3.2.3
+func cardType(atr []byte) string {
+	if !storageCard(atr) {
+		if len(atr) >= 4 && atr[0] == 0x3B && atr[1]&0xF0 == 0x80 &&
+			atr[2] == 0x80 && atr[3] == 0x01 {
+			return tokens.TypeType4
+		}
+		return ""
+	}
+
+	switch uint16(atr[13])<<8 | uint16(atr[14]) {
+	case 0x0001, 0x0002, 0x0026:
+		// MIFARE Classic 1K, 4K and Mini
+		return tokens.TypeMifare
+	case 0x0003:
+		// MIFARE Ultralight family, which includes NTAG
+		return tokens.TypeNTAG
+	default:
+		return ""
+	}
+}
+
+// Get the size of a MIFARE Classic card from the card name in its ATR,
+// assuming a 1K card if it's not known.
+func mifareLayout(atr []byte) ndef.MifareClassic {
+	if storageCard(atr) {
+		switch uint16(atr[13])<<8 | uint16(atr[14]) {
+		case 0x0002:
+			return ndef.Mifare4K
+		case 0x0026:
+			return ndef.MifareMini
+		}
+	}
+	return ndef.Mifare1K
+}
+
+func padBlock(data []byte, size int) []byte {
+	if len(data)%size == 0 {
+		return data
+	}
+	return append(data, make([]byte, size-len(data)%size)...)
+}
+
+// Read the capability container of an NTAG. Byte 2 is the size of the data
+// area divided by 8 and byte 3 is the write access, 0x00 if writable.
+func readNtagCC(card pcscCard) ([]byte, error) {
+	cc, err := readBinary(card, 0x03, 4)
+	if err != nil {
+		return nil, err
+	} else if cc[0] != 0xE1 {
+		return cc, fmt.Errorf("tag is not NDEF formatted: %x", cc)
+	}
+	return cc, nil
+}
+
+// Read the identifying data of a toys-to-life figure on an NTAG. Returns an
+// empty type if the tag isn't a known toy.
+func readToy(card pcscCard) (string, []byte, error) {
+	header, err := readBinary(card, 0x00, 16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if toys.IsAmiibo(header) {
+		id, err := readBinary(card, toys.AmiiboIDPage, 8)
+		return tokens.TypeAmiibo, id, err
+	}
+
+	data, err := readBinary(card, 0x04, 16)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if toys.IsLegoDimensions(data) {
+		data, err := readBinary(card, toys.LegoDataPage, 16)
+		return tokens.TypeLegoDimensions, data, err
+	}
+
+	return "", nil, nil
+}
+
+// Read the data area of an NTAG, stopping once a whole NDEF message has been
+// read.
+func readNtag(card pcscCard) ([]byte, error) {
+	cc, err := readNtagCC(card)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(cc[2]) * 8
+	data := make([]byte, 0, size)
+
+	for page := 4; len(data) < size; page += 4 {
+		block, err := readBinary(card, byte(page), 16)
+		if err != nil {
+			return data, err
+		}
+
+		data = append(data, block...)
+		if ndef.MessageComplete(data) {
+			break
+		}
+	}
+
+	return data[:min(len(data), size)], nil
+}
+
+func writeNtag(card pcscCard, data []byte) error {
+	cc, err := readNtagCC(card)
+	if err != nil {
+		return err
+	} else if cc[3] != 0x00 {
+		return ErrReadOnly
+	}
+
+	size := int(cc[2]) * 8
+	if len(data) > size {
+		return fmt.Errorf("payload too big for card: [%d/%d] bytes used", len(data), size)
+	}
+
+	data = padBlock(data, 4)
+	for i := 0; i < len(data); i += 4 {
+		err := updateBinary(card, byte(4+i/4), data[i:i+4])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ntagAccess reads and writes NTAG pages with the reader's pseudo-APDUs.
+type ntagAccess struct {
+	card pcscCard
+}
+
+func (n ntagAccess) ReadPages(page int) ([]byte, error) {
+	return readBinary(n.card, byte(page), 16)
+}
+
+func (n ntagAccess) WritePage(page int, data []byte) error {
+	return updateBinary(n.card, byte(page), data)
+}
+
+// mifareAccess reads and writes MIFARE Classic blocks with the reader's
+// pseudo-APDUs.
+type mifareAccess struct {
+	card pcscCard
+}
+
+func (m mifareAccess) Auth(block int, key []byte) error {
+	err := loadKey(m.card, key)
+	if err != nil {
+		return err
+	}
+	return authenticate(m.card, byte(block), keyTypeA)
+}
+
+func (m mifareAccess) ReadBlock(block int) ([]byte, error) {
+	return readBinary(m.card, byte(block), ndef.MifareBlockSize)
+}
+
+func (m mifareAccess) WriteBlock(block int, data []byte) error {
+	return updateBinary(m.card, byte(block), data)
+}
+
+func cardLayout(card pcscCard) (ndef.MifareClassic, error) {
+	status, err := card.Status()
+	if err != nil {
+		return 0, err
+	}
+	return mifareLayout(status.Atr), nil
+}
+
+// Read the NDEF data area of a MIFARE Classic card, using the MAD to find
+// its sectors if it has one.
+func readMifare(card pcscCard, keys [][]byte) ([]byte, error) {
+	layout, err := cardLayout(card)
+	if err != nil {
+		return nil, err
+	}
+	return ndef.ReadMifareClassic(mifareAccess{card: card}, layout, keys)
+}
+
+func writeMifare(card pcscCard, keys [][]byte, data []byte) error {
+	layout, err := cardLayout(card)
+	if err != nil {
+		return err
+	}
+	_, err = ndef.WriteMifareClassic(mifareAccess{card: card}, layout, keys, data)
+	return err
+}
+
+func mifareCapacity(card pcscCard, keys [][]byte) (int, error) {
+	layout, err := cardLayout(card)
+	if err != nil {
+		return 0, err
+	}
+	return ndef.MifareCapacity(mifareAccess{card: card}, layout, keys), nil
+}
+
+// Read the NDEF message from a Type 4 tag. APDUs are passed straight through
+// to the card. The message is wrapped in an NDEF TLV so it can be parsed the
+// same as the data area of other tags.
+func readType4(card pcscCard) ([]byte, error) {
+	msg, err := ndef.ReadType4(card.Transmit)
+	if errors.Is(err, ndef.ErrNoRecords) {
+		return ndef.EmptyMessage(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return ndef.WrapTLV(msg)
+}
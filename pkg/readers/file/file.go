@@ -149,6 +149,26 @@ func (r *Reader) Info() string {
 	return r.path
 }
 
+func (r *Reader) Writable() bool {
+	return false
+}
+
+func (r *Reader) MultiTag() bool {
+	return false
+}
+
 func (r *Reader) Write(text string) (*tokens.Token, error) {
 	return nil, errors.New("writing not supported on this reader")
 }
+
+func (r *Reader) WriteMessage(_ readers.WriteRequest) (*tokens.Token, error) {
+	return nil, readers.ErrWriteNotSupported
+}
+
+func (r *Reader) Erase() error {
+	return readers.ErrWriteNotSupported
+}
+
+func (r *Reader) Inspect() (*readers.TagInfo, error) {
+	return nil, readers.ErrWriteNotSupported
+}
@@ -0,0 +1,109 @@
+//go:build linux
+
+package hid_wedge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// Input device ioctls.
+//
+// Reference: linux/input.h
+const (
+	eviocgrab     = 0x40044590 // _IOW('E', 0x90, int)
+	eviocgnameLen = 256
+	eviocgname    = 0x80004506 | eviocgnameLen<<16 // _IOC(_IOC_READ, 'E', 0x06, len)
+)
+
+// Size of a struct input_event, which starts with a struct timeval whose
+// size depends on the architecture.
+var inputEventSize = int(unsafe.Sizeof(syscall.Timeval{})) + 8
+
+// evdevDevice is a Linux input device opened from /dev/input.
+type evdevDevice struct {
+	f    *os.File
+	name string
+}
+
+// Run an ioctl on a file without using Fd, which would put the file in
+// blocking mode and stop Close from interrupting a pending read.
+func ioctl(f *os.File, req uintptr, arg uintptr) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	err = rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	})
+	if err != nil {
+		return err
+	} else if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// Open an input device and grab it, so its key presses only go to this
+// reader.
+func openDevice(path string) (inputDevice, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	name := make([]byte, eviocgnameLen)
+	err = ioctl(f, eviocgname, uintptr(unsafe.Pointer(&name[0])))
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("not an input device: %s: %w", path, err)
+	}
+
+	err = ioctl(f, eviocgrab, 1)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("could not grab input device: %s: %w", path, err)
+	}
+
+	return &evdevDevice{
+		f:    f,
+		name: string(bytes.TrimRight(name, "\x00")),
+	}, nil
+}
+
+func (d *evdevDevice) Name() string {
+	return d.name
+}
+
+func (d *evdevDevice) ReadKey() (uint16, int32, error) {
+	buf := make([]byte, inputEventSize)
+	for {
+		_, err := io.ReadFull(d.f, buf)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		ev := buf[inputEventSize-8:]
+		evType := binary.NativeEndian.Uint16(ev[0:2])
+		if evType != evKey {
+			continue
+		}
+
+		code := binary.NativeEndian.Uint16(ev[2:4])
+		value := int32(binary.NativeEndian.Uint32(ev[4:8]))
+		return code, value, nil
+	}
+}
+
+// Close releases the grab along with the device.
+func (d *evdevDevice) Close() error {
+	return d.f.Close()
+}
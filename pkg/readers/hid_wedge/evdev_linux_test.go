@@ -0,0 +1,71 @@
+//go:build linux
+
+package hid_wedge
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/bendahl/uinput"
+)
+
+// Find the event device created for a uinput device by its name.
+func findEventDevice(t *testing.T, name string) string {
+	t.Helper()
+	for tries := 0; tries < 20; tries++ {
+		paths, _ := filepath.Glob("/sys/class/input/event*/device/name")
+		for _, p := range paths {
+			b, err := os.ReadFile(p)
+			if err == nil && strings.TrimSpace(string(b)) == name {
+				event := filepath.Base(filepath.Dir(filepath.Dir(p)))
+				return filepath.Join("/dev/input", event)
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("no event device found for: %s", name)
+	return ""
+}
+
+func TestUinputScanner(t *testing.T) {
+	if _, err := os.Stat("/dev/uinput"); err != nil {
+		t.Skip("uinput not available")
+	}
+
+	name := "zaparoo test scanner"
+	kb, err := uinput.CreateKeyboard("/dev/uinput", []byte(name))
+	if err != nil {
+		t.Skipf("could not create virtual keyboard: %s", err)
+	}
+	defer func() { _ = kb.Close() }()
+
+	path := findEventDevice(t, name)
+
+	r := NewReader(nil)
+	iq := make(chan readers.Scan, 10)
+	err = r.Open("hid_wedge:"+path, iq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	if r.Info() != name {
+		t.Fatalf("expected device name %q, got: %q", name, r.Info())
+	}
+
+	for _, k := range []int{uinput.Key4, uinput.Key2, uinput.KeyEnter} {
+		err := kb.KeyPress(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := waitScan(t, iq, 2*time.Second)
+	if s.Token == nil || s.Token.UID != "42" {
+		t.Fatalf("expected uid token, got: %v", s.Token)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package hid_wedge
+
+import "errors"
+
+func openDevice(_ string) (inputDevice, error) {
+	return nil, errors.New("hid_wedge reader is only supported on linux")
+}
@@ -0,0 +1,232 @@
+// Package hid_wedge reads tokens from USB RFID and barcode scanners which
+// present themselves as keyboards. The scanner's input device is grabbed
+// exclusively so scans aren't also typed into other software.
+package hid_wedge
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// Scanners only send a line when a tag is presented, so a token is treated
+// as removed when it hasn't been scanned again for this long.
+const removalTimeout = 1 * time.Second
+
+// inputDevice is a keyboard input device, so it can be replaced with a fake
+// in tests.
+type inputDevice interface {
+	// Name returns the name reported by the device.
+	Name() string
+	// ReadKey blocks until the next key event and returns its key code and
+	// value.
+	ReadKey() (uint16, int32, error)
+	Close() error
+}
+
+type HidWedgeReader struct {
+	cfg       *config.Instance
+	device    string
+	path      string
+	polling   bool
+	dev       inputDevice
+	lastToken *tokens.Token
+}
+
+func NewReader(cfg *config.Instance) *HidWedgeReader {
+	return &HidWedgeReader{
+		cfg: cfg,
+	}
+}
+
+func (r *HidWedgeReader) Ids() []string {
+	return []string{"hid_wedge"}
+}
+
+// Create a token from a line typed by the scanner. RFID scanners type the
+// tag's ID as a decimal number, which becomes the UID. Anything else is a
+// barcode and becomes the text.
+func (r *HidWedgeReader) parseLine(line string) *tokens.Token {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 {
+		return nil
+	}
+
+	t := tokens.Token{
+		Data:     line,
+		ScanTime: time.Now(),
+		Source:   r.device,
+	}
+
+	numeric := true
+	for _, c := range line {
+		if c < '0' || c > '9' {
+			numeric = false
+			break
+		}
+	}
+
+	if numeric {
+		t.UID = line
+	} else {
+		t.Text = line
+	}
+
+	return &t
+}
+
+func (r *HidWedgeReader) Open(device string, iq chan<- readers.Scan) error {
+	ps := strings.SplitN(device, ":", 2)
+	if len(ps) != 2 {
+		return errors.New("invalid device string: " + device)
+	}
+
+	if !utils.Contains(r.Ids(), ps[0]) {
+		return errors.New("invalid reader id: " + ps[0])
+	}
+
+	path := ps[1]
+
+	if r.dev == nil {
+		dev, err := openDevice(path)
+		if err != nil {
+			return err
+		}
+		r.dev = dev
+	}
+
+	r.device = device
+	r.path = path
+	r.polling = true
+
+	lines := make(chan string)
+	go r.readLines(r.dev, lines)
+	go r.poll(lines, iq)
+
+	return nil
+}
+
+// Decode key events from the device into lines until the device is closed.
+func (r *HidWedgeReader) readLines(dev inputDevice, lines chan<- string) {
+	defer close(lines)
+
+	var d keyDecoder
+	for {
+		code, value, err := dev.ReadKey()
+		if err != nil {
+			if r.polling {
+				log.Error().Err(err).Msg("failed to read from input device")
+			}
+			return
+		}
+
+		if line, ok := d.key(code, value); ok {
+			lines <- line
+		}
+	}
+}
+
+// Send tokens for scanned lines and emulate removal when the scanner stops
+// sending them.
+func (r *HidWedgeReader) poll(lines <-chan string, iq chan<- readers.Scan) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for r.polling {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				err := r.Close()
+				if err != nil {
+					log.Error().Err(err).Msg("failed to close input device")
+				}
+				return
+			}
+
+			t := r.parseLine(line)
+			if t == nil {
+				continue
+			}
+
+			if !utils.TokensEqual(t, r.lastToken) {
+				iq <- readers.Scan{
+					Source: r.device,
+					Token:  t,
+				}
+			}
+
+			r.lastToken = t
+		case <-ticker.C:
+		}
+
+		if r.lastToken != nil && time.Since(r.lastToken.ScanTime) > removalTimeout {
+			iq <- readers.Scan{
+				Source: r.device,
+				Token:  nil,
+			}
+			r.lastToken = nil
+		}
+	}
+}
+
+func (r *HidWedgeReader) Close() error {
+	r.polling = false
+	if r.dev != nil {
+		dev := r.dev
+		r.dev = nil
+		err := dev.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *HidWedgeReader) Detect(_ []string) string {
+	return ""
+}
+
+func (r *HidWedgeReader) Device() string {
+	return r.device
+}
+
+func (r *HidWedgeReader) Connected() bool {
+	return r.polling && r.dev != nil
+}
+
+func (r *HidWedgeReader) Info() string {
+	if r.dev != nil && r.dev.Name() != "" {
+		return r.dev.Name()
+	}
+	return r.path
+}
+
+func (r *HidWedgeReader) Writable() bool {
+	return false
+}
+
+func (r *HidWedgeReader) MultiTag() bool {
+	return false
+}
+
+func (r *HidWedgeReader) Write(_ string) (*tokens.Token, error) {
+	return nil, readers.ErrWriteNotSupported
+}
+
+func (r *HidWedgeReader) WriteMessage(_ readers.WriteRequest) (*tokens.Token, error) {
+	return nil, readers.ErrWriteNotSupported
+}
+
+func (r *HidWedgeReader) Erase() error {
+	return readers.ErrWriteNotSupported
+}
+
+func (r *HidWedgeReader) Inspect() (*readers.TagInfo, error) {
+	return nil, readers.ErrWriteNotSupported
+}
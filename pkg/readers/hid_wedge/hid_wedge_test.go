@@ -0,0 +1,208 @@
+package hid_wedge
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+)
+
+// fakeDevice replays key events as if they were typed on a scanner.
+type fakeDevice struct {
+	keys   chan [2]int32
+	closed chan struct{}
+}
+
+func newFakeDevice() *fakeDevice {
+	return &fakeDevice{
+		keys:   make(chan [2]int32, 256),
+		closed: make(chan struct{}),
+	}
+}
+
+func (d *fakeDevice) Name() string {
+	return "fake scanner"
+}
+
+func (d *fakeDevice) ReadKey() (uint16, int32, error) {
+	select {
+	case k := <-d.keys:
+		return uint16(k[0]), k[1], nil
+	case <-d.closed:
+		return 0, 0, errors.New("device closed")
+	}
+}
+
+func (d *fakeDevice) Close() error {
+	close(d.closed)
+	return nil
+}
+
+func (d *fakeDevice) press(code uint16) {
+	d.keys <- [2]int32{int32(code), keyPressed}
+	d.keys <- [2]int32{int32(code), keyReleased}
+}
+
+func (d *fakeDevice) typeLine(s string) {
+	for _, c := range s {
+		code, shifted := findKey(c)
+		if shifted {
+			d.keys <- [2]int32{keyLeftShift, keyPressed}
+		}
+		d.press(code)
+		if shifted {
+			d.keys <- [2]int32{keyLeftShift, keyReleased}
+		}
+	}
+	d.press(keyEnter)
+}
+
+func findKey(c rune) (uint16, bool) {
+	for code, chars := range keyChars {
+		// prefer the main keys over the keypad
+		if code > 70 {
+			continue
+		}
+		if chars[0] == c {
+			return code, false
+		} else if chars[1] == c {
+			return code, true
+		}
+	}
+	panic("no key for character: " + string(c))
+}
+
+func TestKeyDecoder(t *testing.T) {
+	tests := []struct {
+		name string
+		keys [][2]int32
+		want string
+	}{
+		{
+			name: "digits",
+			keys: [][2]int32{{2, 1}, {2, 0}, {11, 1}, {11, 0}, {keyEnter, 1}},
+			want: "10",
+		},
+		{
+			name: "shifted",
+			keys: [][2]int32{{keyLeftShift, 1}, {30, 1}, {30, 0}, {keyLeftShift, 0}, {30, 1}, {keyEnter, 1}},
+			want: "Aa",
+		},
+		{
+			name: "caps lock",
+			keys: [][2]int32{{keyCapsLock, 1}, {keyCapsLock, 0}, {30, 1}, {2, 1}, {keyKPEnter, 1}},
+			want: "A1",
+		},
+		{
+			name: "keypad",
+			keys: [][2]int32{{79, 1}, {82, 1}, {keyKPEnter, 1}},
+			want: "10",
+		},
+		{
+			name: "unknown keys ignored",
+			keys: [][2]int32{{29, 1}, {30, 1}, {125, 1}, {keyEnter, 1}},
+			want: "a",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var d keyDecoder
+			var got string
+			done := false
+			for _, k := range tc.keys {
+				got, done = d.key(uint16(k[0]), k[1])
+			}
+			if !done {
+				t.Fatalf("expected a line")
+			} else if got != tc.want {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	r := NewReader(nil)
+
+	tok := r.parseLine("0004215678\n")
+	if tok == nil || tok.UID != "0004215678" || tok.Text != "" {
+		t.Fatalf("expected numeric uid, got: %v", tok)
+	}
+
+	tok = r.parseLine("**launch.random:snes")
+	if tok == nil || tok.Text != "**launch.random:snes" || tok.UID != "" {
+		t.Fatalf("expected text, got: %v", tok)
+	}
+
+	if tok := r.parseLine("  "); tok != nil {
+		t.Fatalf("expected nil for empty line, got: %v", tok)
+	}
+}
+
+func waitScan(t *testing.T, iq <-chan readers.Scan, timeout time.Duration) readers.Scan {
+	t.Helper()
+	select {
+	case s := <-iq:
+		return s
+	case <-time.After(timeout):
+		t.Fatalf("timed out waiting for scan")
+	}
+	return readers.Scan{}
+}
+
+func TestReaderScan(t *testing.T) {
+	dev := newFakeDevice()
+	r := NewReader(nil)
+	r.dev = dev
+
+	iq := make(chan readers.Scan, 10)
+	err := r.Open("hid_wedge:/dev/input/event99", iq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	dev.typeLine("0012345678")
+	s := waitScan(t, iq, time.Second)
+	if s.Token == nil || s.Token.UID != "0012345678" {
+		t.Fatalf("expected uid token, got: %v", s.Token)
+	} else if s.Source != "hid_wedge:/dev/input/event99" {
+		t.Fatalf("unexpected source: %s", s.Source)
+	}
+
+	// repeated scans of the same tag keep it on the reader
+	dev.typeLine("0012345678")
+
+	s = waitScan(t, iq, 3*removalTimeout)
+	if s.Token != nil {
+		t.Fatalf("expected removal, got: %v", s.Token)
+	}
+
+	dev.typeLine("Zap-Card_1")
+	s = waitScan(t, iq, time.Second)
+	if s.Token == nil || s.Token.Text != "Zap-Card_1" {
+		t.Fatalf("expected text token, got: %v", s.Token)
+	}
+}
+
+func TestReaderInvalidDevice(t *testing.T) {
+	r := NewReader(nil)
+	iq := make(chan readers.Scan)
+
+	if err := r.Open("simple_serial:/dev/input/event0", iq); err == nil {
+		t.Fatalf("expected error for wrong reader id")
+	}
+
+	if err := r.Open("hid_wedge", iq); err == nil {
+		t.Fatalf("expected error for missing path")
+	}
+}
+
+func TestWriteNotSupported(t *testing.T) {
+	r := NewReader(nil)
+	if _, err := r.Write("text"); !errors.Is(err, readers.ErrWriteNotSupported) {
+		t.Fatalf("expected write not supported, got: %v", err)
+	}
+}
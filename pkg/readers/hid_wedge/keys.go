@@ -0,0 +1,136 @@
+package hid_wedge
+
+// Linux input event types and key codes used by keyboard wedge scanners.
+//
+// Reference: linux/input-event-codes.h
+const (
+	evKey = 0x01
+
+	keyReleased = 0
+	keyPressed  = 1
+	keyRepeated = 2
+
+	keyEnter      = 28
+	keyLeftShift  = 42
+	keyRightShift = 54
+	keyCapsLock   = 58
+	keyKPEnter    = 96
+)
+
+// Characters typed by each key, unshifted and shifted, on a US layout which
+// is what almost all scanners emulate.
+var keyChars = map[uint16][2]rune{
+	2:  {'1', '!'},
+	3:  {'2', '@'},
+	4:  {'3', '#'},
+	5:  {'4', '$'},
+	6:  {'5', '%'},
+	7:  {'6', '^'},
+	8:  {'7', '&'},
+	9:  {'8', '*'},
+	10: {'9', '('},
+	11: {'0', ')'},
+	12: {'-', '_'},
+	13: {'=', '+'},
+	15: {'\t', '\t'},
+	16: {'q', 'Q'},
+	17: {'w', 'W'},
+	18: {'e', 'E'},
+	19: {'r', 'R'},
+	20: {'t', 'T'},
+	21: {'y', 'Y'},
+	22: {'u', 'U'},
+	23: {'i', 'I'},
+	24: {'o', 'O'},
+	25: {'p', 'P'},
+	26: {'[', '{'},
+	27: {']', '}'},
+	30: {'a', 'A'},
+	31: {'s', 'S'},
+	32: {'d', 'D'},
+	33: {'f', 'F'},
+	34: {'g', 'G'},
+	35: {'h', 'H'},
+	36: {'j', 'J'},
+	37: {'k', 'K'},
+	38: {'l', 'L'},
+	39: {';', ':'},
+	40: {'\'', '"'},
+	41: {'`', '~'},
+	43: {'\\', '|'},
+	44: {'z', 'Z'},
+	45: {'x', 'X'},
+	46: {'c', 'C'},
+	47: {'v', 'V'},
+	48: {'b', 'B'},
+	49: {'n', 'N'},
+	50: {'m', 'M'},
+	51: {',', '<'},
+	52: {'.', '>'},
+	53: {'/', '?'},
+	55: {'*', '*'},
+	57: {' ', ' '},
+	71: {'7', '7'},
+	72: {'8', '8'},
+	73: {'9', '9'},
+	74: {'-', '-'},
+	75: {'4', '4'},
+	76: {'5', '5'},
+	77: {'6', '6'},
+	78: {'+', '+'},
+	79: {'1', '1'},
+	80: {'2', '2'},
+	81: {'3', '3'},
+	82: {'0', '0'},
+	83: {'.', '.'},
+	98: {'/', '/'},
+}
+
+// keyDecoder turns key events from a keyboard wedge into lines of text.
+type keyDecoder struct {
+	shift bool
+	caps  bool
+	line  []rune
+}
+
+// Handle a single key event. Returns the typed line and true when enter is
+// pressed.
+func (d *keyDecoder) key(code uint16, value int32) (string, bool) {
+	switch code {
+	case keyLeftShift, keyRightShift:
+		d.shift = value != keyReleased
+		return "", false
+	case keyCapsLock:
+		if value == keyPressed {
+			d.caps = !d.caps
+		}
+		return "", false
+	}
+
+	if value == keyReleased {
+		return "", false
+	}
+
+	if code == keyEnter || code == keyKPEnter {
+		line := string(d.line)
+		d.line = nil
+		return line, true
+	}
+
+	chars, ok := keyChars[code]
+	if !ok {
+		return "", false
+	}
+
+	c := chars[0]
+	shifted := d.shift
+	if d.caps && c >= 'a' && c <= 'z' {
+		shifted = !shifted
+	}
+	if shifted {
+		c = chars[1]
+	}
+
+	d.line = append(d.line, c)
+	return "", false
+}
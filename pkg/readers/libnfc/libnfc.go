@@ -10,12 +10,14 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers/libnfc/tags"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
 	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/clausecker/nfc/v2"
 	"github.com/rs/zerolog/log"
@@ -30,29 +32,19 @@ const (
 	autoConnStr        = "libnfc_auto:"
 )
 
-type WriteRequestResult struct {
-	Token *tokens.Token
-	Err   error
-}
-
-type WriteRequest struct {
-	Text   string
-	Result chan WriteRequestResult
-}
-
 type Reader struct {
 	cfg       *config.Instance
 	conn      string
 	pnd       *nfc.Device
 	polling   bool
 	prevToken *tokens.Token
-	write     chan WriteRequest
+	write     chan readers.TagRequest
 }
 
 func NewReader(cfg *config.Instance) *Reader {
 	return &Reader{
 		cfg:   cfg,
-		write: make(chan WriteRequest),
+		write: make(chan readers.TagRequest),
 	}
 }
 
@@ -82,7 +74,7 @@ func (r *Reader) Open(device string, iq chan<- readers.Scan) error {
 		for r.polling {
 			select {
 			case req := <-r.write:
-				r.writeTag(req)
+				r.handleRequest(req)
 			case <-time.After(periodBetweenLoop):
 				// continue with reading
 			}
@@ -181,25 +173,37 @@ func (r *Reader) Info() string {
 	return r.pnd.String()
 }
 
-func (r *Reader) Write(text string) (*tokens.Token, error) {
-	if !r.Connected() {
-		return nil, errors.New("not connected")
-	}
+func (r *Reader) Writable() bool {
+	return true
+}
 
-	req := WriteRequest{
-		Text:   text,
-		Result: make(chan WriteRequestResult),
-	}
+func (r *Reader) MultiTag() bool {
+	return true
+}
 
-	r.write <- req
+func (r *Reader) Write(text string) (*tokens.Token, error) {
+	return r.WriteMessage(readers.WriteRequest{
+		Records: []ndef.Record{ndef.NewTextRecord(text, "en")},
+		Verify:  true,
+	})
+}
 
-	res := <-req.Result
-	if res.Err != nil {
-		log.Error().Msgf("error writing to tag: %s", res.Err)
-		return nil, res.Err
-	}
+func (r *Reader) WriteMessage(msg readers.WriteRequest) (*tokens.Token, error) {
+	res := readers.SendTagRequest(r, r.write, readers.TagRequest{
+		Op:      readers.OpWrite,
+		Message: msg,
+	})
+	return res.Token, res.Err
+}
+
+func (r *Reader) Erase() error {
+	res := readers.SendTagRequest(r, r.write, readers.TagRequest{Op: readers.OpErase})
+	return res.Err
+}
 
-	return res.Token, nil
+func (r *Reader) Inspect() (*readers.TagInfo, error) {
+	res := readers.SendTagRequest(r, r.write, readers.TagRequest{Op: readers.OpInspect})
+	return res.Info, res.Err
 }
 
 // keep track of serial devices that had failed opens
@@ -323,10 +327,6 @@ func (r *Reader) pollDevice(
 		return nil, false, err
 	}
 
-	if count > 1 {
-		log.Info().Msg("more than one card on the reader")
-	}
-
 	if count <= 0 {
 		if activeToken != nil && time.Since(activeToken.ScanTime) > timeToForgetCard {
 			log.Info().Msg("card removed")
@@ -337,6 +337,10 @@ func (r *Reader) pollDevice(
 		return activeToken, removed, nil
 	}
 
+	if count > 1 {
+		return r.pollTargets(pnd, activeToken, removed)
+	}
+
 	tagUid := tags.GetTagUID(target)
 	if tagUid == "" {
 		log.Warn().Msgf("unable to detect token UID: %s", target.String())
@@ -349,53 +353,149 @@ func (r *Reader) pollDevice(
 
 	log.Info().Msgf("found token UID: %s", tagUid)
 
+	card, err := r.readTarget(pnd, target)
+	if err != nil {
+		return activeToken, removed, err
+	}
+
+	return card, removed, nil
+}
+
+// Read every tag in the field when more than one is on the reader and
+// combine them into a single token. Each tag is selected in turn, read and
+// then deselected so the next one can be selected.
+func (r *Reader) pollTargets(
+	pnd *nfc.Device,
+	activeToken *tokens.Token,
+	removed bool,
+) (*tokens.Token, bool, error) {
+	targets, err := pnd.InitiatorListPassiveTargets(tags.SupportedCardTypes[0])
+	if err != nil {
+		return activeToken, removed, fmt.Errorf("error listing tags: %w", err)
+	} else if len(targets) == 0 {
+		return activeToken, removed, nil
+	}
+
+	uids := make([]string, 0, len(targets))
+	for _, t := range targets {
+		uids = append(uids, tags.GetTagUID(t))
+	}
+	sort.Strings(uids)
+
+	// no change in tags
+	if activeToken != nil && strings.Join(uids, tokens.CombinationSep) == activeToken.UID {
+		return activeToken, removed, nil
+	}
+
+	log.Info().Msgf("found %d tokens: %v", len(targets), uids)
+
+	members := make([]tokens.Token, 0, len(targets))
+	for _, t := range targets {
+		tagUid := tags.GetTagUID(t)
+		uid, err := hex.DecodeString(tagUid)
+		if err != nil {
+			log.Warn().Err(err).Msgf("invalid token UID: %s", tagUid)
+			continue
+		}
+
+		selected, err := pnd.InitiatorSelectPassiveTarget(tags.SupportedCardTypes[0], uid)
+		if err != nil {
+			log.Warn().Err(err).Msgf("error selecting token: %s", tagUid)
+			continue
+		}
+
+		card, err := r.readTarget(pnd, selected)
+		if err != nil {
+			log.Warn().Err(err).Msgf("error reading token: %s", tagUid)
+		} else {
+			members = append(members, *card)
+		}
+
+		err = pnd.InitiatorDeselectTarget()
+		if err != nil {
+			log.Warn().Err(err).Msgf("error deselecting token: %s", tagUid)
+		}
+	}
+
+	switch len(members) {
+	case 0:
+		return activeToken, removed, errors.New("could not read any tokens")
+	case 1:
+		return &members[0], removed, nil
+	}
+
+	// tags already on the reader come first, so the fallback text of the
+	// combination runs in the order the tags were placed
+	if activeToken != nil {
+		sort.SliceStable(members, func(i, j int) bool {
+			return inToken(activeToken, members[i].UID) && !inToken(activeToken, members[j].UID)
+		})
+	}
+
+	combo := tokens.NewCombination(members)
+	combo.Source = r.conn
+	log.Info().Msgf("combined tokens: %s", combo.UID)
+
+	return &combo, removed, nil
+}
+
+func inToken(t *tokens.Token, uid string) bool {
+	return t.UID == uid || t.HasMember(uid)
+}
+
+// Read the data from the currently selected tag and decode it into a token.
+func (r *Reader) readTarget(pnd *nfc.Device, target nfc.Target) (*tokens.Token, error) {
 	var record tags.TagData
+	var err error
 	cardType := tags.GetTagType(target)
 
 	if cardType == tokens.TypeNTAG {
 		log.Info().Msg("NTAG detected")
 		record, err = tags.ReadNtag(*pnd)
 		if err != nil {
-			return activeToken, removed, fmt.Errorf("error reading ntag: %s", err)
+			return nil, fmt.Errorf("error reading ntag: %s", err)
 		}
-		cardType = tokens.TypeNTAG
 	} else if cardType == tokens.TypeMifare {
 		log.Info().Msg("MIFARE detected")
-		record, err = tags.ReadMifare(*pnd, tagUid)
+		record, err = tags.ReadMifare(*pnd, target, r.cfg.MifareKeys())
 		if err != nil {
 			log.Error().Msgf("error reading mifare: %s", err)
 		}
-		cardType = tokens.TypeMifare
+	} else if cardType == tokens.TypeType4 {
+		log.Info().Msg("Type 4 tag detected")
+		record, err = tags.ReadType4(*pnd)
+		if err != nil {
+			log.Error().Msgf("error reading type 4 tag: %s", err)
+		}
 	}
 
 	log.Debug().Msgf("record bytes: %s", hex.EncodeToString(record.Bytes))
-	tagText, err := tags.ParseRecordText(record.Bytes)
+	tagText := ""
+	tr, err := ndef.ParseToken(record.Bytes)
 	if err != nil {
 		log.Error().Err(err).Msgf("error parsing NDEF record")
-		tagText = ""
+	} else {
+		tagText = tr.Text
 	}
 
 	if tagText == "" {
-		log.Warn().Msg("no text NDEF found")
+		log.Warn().Msg("no token NDEF found")
 	} else {
-		log.Info().Msgf("decoded text NDEF: %s", tagText)
+		log.Info().Msgf("decoded %s NDEF: %s", tr.Kind, tagText)
 	}
 
-	card := &tokens.Token{
+	return &tokens.Token{
 		Type:     record.Type,
-		UID:      tagUid,
+		UID:      tags.GetTagUID(target),
 		Text:     tagText,
 		Data:     hex.EncodeToString(record.Bytes),
 		ScanTime: time.Now(),
 		Source:   r.conn,
-	}
-
-	return card, removed, nil
+	}, nil
 }
 
-func (r *Reader) writeTag(req WriteRequest) {
-	log.Info().Msgf("libnfc write request: %s", req.Text)
-
+// Wait for a tag to be placed on the reader.
+func (r *Reader) waitForTag() (nfc.Target, error) {
 	var count int
 	var target nfc.Target
 	var err error
@@ -413,80 +513,208 @@ func (r *Reader) writeTag(req WriteRequest) {
 		}
 
 		if count > 0 {
-			break
+			return target, nil
 		}
 
 		tries--
 	}
 
-	if count == 0 {
-		log.Error().Msgf("could not detect a tag")
-		req.Result <- WriteRequestResult{
-			Err: errors.New("could not detect a tag"),
+	log.Error().Msgf("could not detect a tag")
+	return nil, errors.New("could not detect a tag")
+}
+
+func (r *Reader) handleRequest(req readers.TagRequest) {
+	target, err := r.waitForTag()
+	if err != nil {
+		req.Result <- readers.TagResult{
+			Err: err,
 		}
 		return
 	}
 
-	cardUid := tags.GetTagUID(target)
-	log.Info().Msgf("found tag with UID: %s", cardUid)
+	log.Info().Msgf("found tag with UID: %s", tags.GetTagUID(target))
+
+	switch req.Op {
+	case readers.OpWrite:
+		t, err := r.writeTag(target, req.Message)
+		req.Result <- readers.TagResult{
+			Token: t,
+			Err:   err,
+		}
+	case readers.OpErase:
+		req.Result <- readers.TagResult{
+			Err: r.eraseTag(target),
+		}
+	case readers.OpInspect:
+		info, err := r.inspectTag(target)
+		req.Result <- readers.TagResult{
+			Info: info,
+			Err:  err,
+		}
+	default:
+		req.Result <- readers.TagResult{
+			Err: fmt.Errorf("unknown request: %d", req.Op),
+		}
+	}
+}
 
+// Write raw data to the data area of a tag, refusing if the tag is
+// read-only.
+func (r *Reader) writeData(target nfc.Target, data []byte) ([]byte, error) {
 	cardType := tags.GetTagType(target)
-	var bytesWritten []byte
 
 	switch cardType {
 	case tokens.TypeMifare:
-		bytesWritten, err = tags.WriteMifare(*r.pnd, req.Text, cardUid)
-		if err != nil {
-			log.Error().Msgf("error writing to mifare: %s", err)
-			req.Result <- WriteRequestResult{
-				Err: err,
-			}
-			return
-		}
+		return tags.WriteMifareData(*r.pnd, data, target, r.cfg.MifareKeys())
 	case tokens.TypeNTAG:
-		bytesWritten, err = tags.WriteNtag(*r.pnd, req.Text)
+		readOnly, err := tags.NtagReadOnly(*r.pnd)
 		if err != nil {
-			log.Error().Msgf("error writing to ntag: %s", err)
-			req.Result <- WriteRequestResult{
-				Err: err,
-			}
-			return
+			return nil, err
+		} else if readOnly {
+			return nil, errors.New("tag is read-only")
 		}
+		return tags.WriteNtagData(*r.pnd, data)
 	default:
-		log.Error().Msgf("unsupported tag type: %s", cardType)
-		req.Result <- WriteRequestResult{
-			Err: err,
-		}
-		return
+		return nil, fmt.Errorf("unsupported tag type: %s", cardType)
+	}
+}
+
+func (r *Reader) writeTag(target nfc.Target, msg readers.WriteRequest) (*tokens.Token, error) {
+	cardUid := tags.GetTagUID(target)
+	cardType := tags.GetTagType(target)
+
+	if msg.Lock && cardType != tokens.TypeNTAG {
+		return nil, fmt.Errorf("locking is not supported for tag type: %s", cardType)
+	}
+
+	data, err := ndef.BuildMessage(msg.Records...)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().Msgf("libnfc write request: %x", data)
+
+	bytesWritten, err := r.writeData(target, data)
+	if err != nil {
+		log.Error().Msgf("error writing to %s: %s", cardType, err)
+		return nil, err
 	}
 
 	t, _, err := r.pollDevice(r.pnd, nil, timesToPoll, periodBetweenPolls)
 	if err != nil || t == nil {
 		log.Error().Msgf("error reading written tag: %s", err)
-		req.Result <- WriteRequestResult{
-			Err: err,
-		}
-		return
+		return nil, fmt.Errorf("error reading written tag: %w", err)
 	}
 
 	if t.UID != cardUid {
 		log.Error().Msgf("UID mismatch after write: %s != %s", t.UID, cardUid)
-		req.Result <- WriteRequestResult{
-			Err: errors.New("UID mismatch after write"),
+		return nil, errors.New("UID mismatch after write")
+	}
+
+	if msg.Verify {
+		err := readers.VerifyWrite(t, data)
+		if err != nil {
+			return nil, err
 		}
-		return
+		log.Info().Msg("verified written tag")
 	}
 
-	if t.Text != req.Text {
-		log.Error().Msgf("text mismatch after write: %s != %s", t.Text, req.Text)
-		req.Result <- WriteRequestResult{
-			Err: errors.New("text mismatch after write"),
+	if msg.Lock {
+		err := tags.LockNtag(*r.pnd)
+		if err != nil {
+			return nil, fmt.Errorf("error locking tag: %w", err)
 		}
-		return
+		log.Info().Msgf("locked tag: %s", cardUid)
 	}
 
 	log.Info().Msgf("successfully wrote to card: %s", hex.EncodeToString(bytesWritten))
-	req.Result <- WriteRequestResult{
-		Token: t,
+	return t, nil
+}
+
+// Read the current data area of a tag, so its size is known.
+func (r *Reader) readData(target nfc.Target) (tags.TagData, int, error) {
+	cardType := tags.GetTagType(target)
+
+	switch cardType {
+	case tokens.TypeMifare:
+		capacity, err := tags.GetMifareCapacity(*r.pnd, target, r.cfg.MifareKeys())
+		if err != nil {
+			return tags.TagData{}, 0, err
+		}
+		record, err := tags.ReadMifare(*r.pnd, target, r.cfg.MifareKeys())
+		return record, capacity, err
+	case tokens.TypeType4:
+		cc, err := tags.ReadType4CC(*r.pnd)
+		if err != nil {
+			return tags.TagData{}, 0, err
+		}
+		record, err := tags.ReadType4(*r.pnd)
+		// the NDEF file starts with its 2 byte length
+		return record, cc.MaxSize - 2, err
+	case tokens.TypeNTAG:
+		capacity, err := tags.GetNtagCapacity(*r.pnd)
+		if err != nil {
+			return tags.TagData{}, 0, err
+		}
+		record, err := tags.ReadNtag(*r.pnd)
+		return record, capacity, err
+	default:
+		return tags.TagData{}, 0, fmt.Errorf("unsupported tag type: %s", cardType)
+	}
+}
+
+func (r *Reader) eraseTag(target nfc.Target) error {
+	record, capacity, err := r.readData(target)
+	if err != nil {
+		return err
+	}
+
+	if record.Type == tokens.TypeAmiibo || record.Type == tokens.TypeLegoDimensions {
+		return fmt.Errorf("refusing to erase %s tag", record.Type)
+	}
+
+	_, err = r.writeData(target, ndef.ErasedMessage(min(len(record.Bytes), capacity)))
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("erased tag: %s", tags.GetTagUID(target))
+	return nil
+}
+
+func (r *Reader) inspectTag(target nfc.Target) (*readers.TagInfo, error) {
+	record, capacity, err := r.readData(target)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &readers.TagInfo{
+		UID:      tags.GetTagUID(target),
+		Type:     record.Type,
+		Capacity: capacity,
+	}
+
+	switch tags.GetTagType(target) {
+	case tokens.TypeNTAG:
+		info.ReadOnly, err = tags.NtagReadOnly(*r.pnd)
+		if err != nil {
+			return nil, err
+		}
+	case tokens.TypeType4:
+		cc, err := tags.ReadType4CC(*r.pnd)
+		if err != nil {
+			return nil, err
+		}
+		info.ReadOnly = cc.WriteAccess != 0x00
 	}
+
+	msg, err := ndef.FindMessage(record.Bytes)
+	if err == nil {
+		header, err := ndef.CalculateNdefHeader(msg)
+		if err == nil {
+			info.Used = len(header) + len(msg) + 1
+		}
+	}
+
+	return info, nil
 }
@@ -23,134 +23,132 @@ along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
 package tags
 
 import (
-	"bytes"
-	"encoding/hex"
-	"errors"
 	"fmt"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 	"github.com/rs/zerolog/log"
 
 	"github.com/clausecker/nfc/v2"
-	"golang.org/x/exp/slices"
 )
 
-const (
-	MifareWritableSectorCount     = 15
-	MifareWritableBlocksPerSector = 3
-	MifareBlockSizeBytes          = 16
-)
+// mifareAccess reads and writes MIFARE Classic blocks through a libnfc
+// device.
+type mifareAccess struct {
+	pnd    nfc.Device
+	target *nfc.ISO14443aTarget
+	// a failed auth halts the card, so it must be selected again before
+	// the next command
+	halted bool
+}
 
-// buildMifareAuthCommand returns a command to authenticate against a block
-func buildMifareAuthCommand(block byte, cardUid string) []byte {
-	command := []byte{
-		// Auth using key A
-		0x60, block,
-		// Using the NDEF well known private key
-		0xd3, 0xf7, 0xd3, 0xf7, 0xd3, 0xf7,
+func newMifareAccess(pnd nfc.Device, target nfc.Target) (*mifareAccess, error) {
+	card, ok := target.(*nfc.ISO14443aTarget)
+	if !ok {
+		return nil, fmt.Errorf("not an ISO14443a target: %s", target.String())
 	}
-	// And finally append the card UID to the end
-	uidBytes, _ := hex.DecodeString(cardUid)
-	return append(command, uidBytes...)
+	return &mifareAccess{pnd: pnd, target: card}, nil
 }
 
-// ReadMifare reads data from all blocks in sectors 1-15
-func ReadMifare(pnd nfc.Device, cardUid string) (TagData, error) {
-	permissionSectors := []int{4, 8, 12, 16, 20, 24, 28, 32, 36, 40, 44, 48, 52, 56, 60}
-	var allBlocks []byte
-	for block := 0; block < 64; block++ {
-		if block <= 3 {
-			// The first sector contains infomation we don't care about and
-			// also has a different key (0xA0A1A2A3A4A5) YAGNI, so skip over
-			continue
-		}
+func (m *mifareAccess) Auth(block int, key []byte) error {
+	uid := m.target.UID[:m.target.UIDLen]
 
-		// The last block of a sector contains KeyA + Permissions + KeyB
-		// We don't care about that info so skip if present.
-		if slices.Contains(permissionSectors, block+1) {
-			continue
+	if m.halted {
+		_, err := m.pnd.InitiatorSelectPassiveTarget(m.target.Modulation(), uid)
+		if err != nil {
+			return fmt.Errorf("error reselecting card: %w", err)
 		}
+		m.halted = false
+	}
 
-		// Mifare is split up into 16 sectors each containing 4 blocks.
-		// We need to authenticate before any read/ write operations can be performed
-		// Only need to authenticate once per sector
-		if block%4 == 0 {
-			_, err := comm(pnd, buildMifareAuthCommand(byte(block), cardUid), 2)
-			if err != nil {
-				log.Warn().Err(err).Msg("authenticating sector error")
-			}
-		}
+	// auth using key A, followed by the last 4 bytes of the UID
+	cmd := append([]byte{0x60, byte(block)}, key...)
+	cmd = append(cmd, uid[max(0, len(uid)-4):]...)
 
-		blockData, err := comm(pnd, []byte{0x30, byte(block)}, 16)
-		if err != nil {
-			return TagData{}, err
-		}
+	_, err := comm(m.pnd, cmd, 2)
+	if err != nil {
+		m.halted = true
+		return err
+	}
+
+	return nil
+}
 
-		allBlocks = append(allBlocks, blockData...)
+func (m *mifareAccess) ReadBlock(block int) ([]byte, error) {
+	return comm(m.pnd, []byte{ReadCommand, byte(block)}, ndef.MifareBlockSize)
+}
+
+func (m *mifareAccess) WriteBlock(block int, data []byte) error {
+	_, err := comm(m.pnd, append([]byte{0xA0, byte(block)}, data...), 2)
+	return err
+}
 
-		if bytes.Contains(blockData, NdefEnd) {
-			// Once we find the end of the NDEF text record there is no need to
-			// continue reading the rest of the card.
-			// This should make things "load" quicker
-			break
+// GetMifareLayout returns the size of a MIFARE Classic card from its SAK,
+// assuming a 1K card if it's not known.
+func GetMifareLayout(target nfc.Target) ndef.MifareClassic {
+	card, ok := target.(*nfc.ISO14443aTarget)
+	if ok {
+		if m, ok := ndef.MifareClassicFromSAK(card.Sak); ok {
+			return m
 		}
+	}
+	return ndef.Mifare1K
+}
+
+// ReadMifare reads the NDEF data area of a MIFARE Classic card, using the
+// MAD to find its sectors if it has one. The given keys are tried after the
+// well-known keys.
+func ReadMifare(pnd nfc.Device, target nfc.Target, keys [][]byte) (TagData, error) {
+	a, err := newMifareAccess(pnd, target)
+	if err != nil {
+		return TagData{}, err
+	}
 
+	layout := GetMifareLayout(target)
+	log.Debug().Msgf("reading mifare classic with %d sectors", layout)
+
+	data, err := ndef.ReadMifareClassic(a, layout, keys)
+	if err != nil {
+		return TagData{}, err
 	}
 
 	return TagData{
 		Type:  tokens.TypeMifare,
-		Bytes: allBlocks,
+		Bytes: data,
 	}, nil
 }
 
-// getMifareCapacityInBytes returns the Mifare card capacity
-func getMifareCapacityInBytes() int {
-	return (MifareWritableBlocksPerSector * MifareWritableSectorCount) * MifareBlockSizeBytes
+// GetMifareCapacity returns the number of bytes available for NDEF data on
+// a MIFARE Classic card.
+func GetMifareCapacity(pnd nfc.Device, target nfc.Target, keys [][]byte) (int, error) {
+	a, err := newMifareAccess(pnd, target)
+	if err != nil {
+		return 0, err
+	}
+	return ndef.MifareCapacity(a, GetMifareLayout(target), keys), nil
 }
 
-// WriteMifare writes the given text string to a Mifare card starting from sector, skipping any trailer blocks
-func WriteMifare(pnd nfc.Device, text string, cardUid string) ([]byte, error) {
+// WriteMifare writes the given text string to a MIFARE Classic card.
+func WriteMifare(pnd nfc.Device, text string, target nfc.Target, keys [][]byte) ([]byte, error) {
 	var payload, err = BuildMessage(text)
 	if err != nil {
 		return nil, err
 	}
 
-	var cardCapacity = getMifareCapacityInBytes()
-	if len(payload) > cardCapacity {
-		return nil, errors.New(fmt.Sprintf("Payload too big for card: [%d/%d] bytes used\n", len(payload), cardCapacity))
-	}
+	return WriteMifareData(pnd, payload, target, keys)
+}
 
-	var chunks [][]byte
-	for _, chunk := range chunkBy(payload, 16) {
-		for len(chunk) < 16 {
-			chunk = append(chunk, []byte{0x00}...)
-		}
-		chunks = append(chunks, chunk)
+// WriteMifareData writes raw data to the NDEF sectors of a MIFARE Classic
+// card, skipping any trailer blocks. The data is padded to a whole block.
+func WriteMifareData(pnd nfc.Device, payload []byte, target nfc.Target, keys [][]byte) ([]byte, error) {
+	a, err := newMifareAccess(pnd, target)
+	if err != nil {
+		return nil, err
 	}
 
-	var chunkIndex = 0
-	for sector := 1; sector <= 15; sector++ {
-		// Iterate over blocks in sector (0-2) skipping trailer block (3)
-		for sectorIndex := 0; sectorIndex < 3; sectorIndex++ {
-			blockToWrite := (sector * 4) + sectorIndex
-			if sectorIndex == 0 {
-				// We changed sectors, time to authenticate
-				_, err := comm(pnd, buildMifareAuthCommand(byte(blockToWrite), cardUid), 2)
-				if err != nil {
-					return nil, err
-				}
-			}
-
-			writeBlockCommand := append([]byte{0xA0, byte(blockToWrite)}, chunks[chunkIndex]...)
-			_, err := comm(pnd, writeBlockCommand, 2)
-			if err != nil {
-				return nil, err
-			}
-			chunkIndex++
-			if chunkIndex >= len(chunks) {
-				// All data has been written, we are done
-				return payload, nil
-			}
-		}
+	_, err = ndef.WriteMifareClassic(a, GetMifareLayout(target), keys, payload)
+	if err != nil {
+		return nil, err
 	}
 
 	return payload, nil
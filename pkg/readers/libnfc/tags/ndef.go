@@ -24,70 +24,13 @@ along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
 package tags
 
 import (
-	"bytes"
-	"encoding/binary"
-	"fmt"
-
-	"github.com/hsanjuan/go-ndef"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
 )
 
-var NdefEnd = []byte{0xFE}
-var NdefStart = []byte{0x54, 0x02, 0x65, 0x6E}
-
-func ParseRecordText(blocks []byte) (string, error) {
-	startIndex := bytes.Index(blocks, NdefStart)
-	if startIndex == -1 {
-		return "", fmt.Errorf("NDEF start not found: %x", blocks)
-	}
-
-	endIndex := bytes.Index(blocks, NdefEnd)
-	if endIndex == -1 {
-		return "", fmt.Errorf("NDEF end not found: %x", blocks)
-	}
-
-	if startIndex >= endIndex || startIndex+4 >= len(blocks) {
-		return "", fmt.Errorf("start index out of bounds: %d, %x", startIndex, blocks)
-	}
-
-	if endIndex <= startIndex || endIndex >= len(blocks) {
-		return "", fmt.Errorf("end index out of bounds: %d, %x", endIndex, blocks)
-	}
-
-	tagText := string(blocks[startIndex+4 : endIndex])
-
-	return tagText, nil
-}
-
 func BuildMessage(text string) ([]byte, error) {
-	msg := ndef.NewTextMessage(text, "en")
-	payload, err := msg.Marshal()
-	if err != nil {
-		return nil, err
-	}
-
-	header, err := CalculateNdefHeader(payload)
-	if err != nil {
-		return nil, err
-	}
-	payload = append(header, payload...)
-	payload = append(payload, []byte{0xFE}...)
-	return payload, nil
+	return ndef.BuildMessage(ndef.NewTextRecord(text, "en"))
 }
 
 func CalculateNdefHeader(ndefRecord []byte) ([]byte, error) {
-	var recordLength = len(ndefRecord)
-	if recordLength < 255 {
-		return []byte{0x03, byte(len(ndefRecord))}, nil
-	}
-
-	// NFCForum-TS-Type-2-Tag_1.1.pdf Page 9
-	// > 255 Use three consecutive bytes format
-	buf := new(bytes.Buffer)
-	err := binary.Write(buf, binary.BigEndian, uint16(recordLength))
-	if err != nil {
-		return nil, err
-	}
-
-	var header = []byte{0x03, 0xFF}
-	return append(header, buf.Bytes()...), nil
+	return ndef.CalculateNdefHeader(ndefRecord)
 }
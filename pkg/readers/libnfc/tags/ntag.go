@@ -23,10 +23,11 @@ along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
 package tags
 
 import (
-	"bytes"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/toys"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 
 	"github.com/clausecker/nfc/v2"
@@ -44,20 +45,6 @@ const (
 	Ntag216Identifier    = 0x6D
 )
 
-// Can be identified by matching blocks 0x03-0x07
-// https://github.com/RfidResearchGroup/proxmark3/blob/master/client/src/cmdhfmfu.c
-var LegoDimensionsMatcher = []byte{
-	//0xE1, 0x10, 0x12, 0x00, // Skip as we never read 0x03
-	0x01, 0x03, 0xA0, 0x0C,
-	0x34, 0x03, 0x13, 0xD1,
-	0x01, 0x0F, 0x54, 0x02,
-	0x65, 0x6E}
-
-// Can be identified by matching address 0x09-0x0F
-var AmiiboMatcher = []byte{
-	0x48, 0x0F, 0xE0,
-	0xF1, 0x10, 0xFF, 0xEE}
-
 func ReadNtag(pnd nfc.Device) (TagData, error) {
 	blockCount, err := getNtagBlockCount(pnd)
 	if err != nil {
@@ -67,9 +54,12 @@ func ReadNtag(pnd nfc.Device) (TagData, error) {
 	log.Debug().Msgf("NTAG has %d blocks", blockCount)
 
 	header, _ := comm(pnd, []byte{ReadCommand, byte(0)}, 16)
-	if len(header) > 9 && bytes.Equal(header[9:], AmiiboMatcher) {
+	if toys.IsAmiibo(header) {
 		log.Info().Msg("found Amiibo")
-		amiibo, _ := comm(pnd, []byte{ReadCommand, byte(21)}, 16)
+		amiibo, err := comm(pnd, []byte{ReadCommand, toys.AmiiboIDPage}, 16)
+		if err != nil {
+			return TagData{}, err
+		}
 		amiibo = amiibo[:8]
 		log.Info().Msg("Amiibo identifier:" + hex.EncodeToString(amiibo))
 		return TagData{
@@ -87,18 +77,23 @@ func ReadNtag(pnd nfc.Device) (TagData, error) {
 			return TagData{}, err
 		}
 
-		if byte(currentBlock) == 0x04 && len(blocks) >= 13 && bytes.Equal(blocks[0:14], LegoDimensionsMatcher) {
+		if byte(currentBlock) == 0x04 && toys.IsLegoDimensions(blocks) {
 			log.Info().Msg("found Lego Dimensions tag")
+			toy, err := comm(pnd, []byte{ReadCommand, toys.LegoDataPage}, 16)
+			if err != nil {
+				log.Warn().Err(err).Msg("error reading Lego Dimensions data")
+				toy = []byte{}
+			}
 			return TagData{
 				Type:  tokens.TypeLegoDimensions,
-				Bytes: []byte{},
+				Bytes: toy,
 			}, nil
 		}
 
 		allBlocks = append(allBlocks, blocks...)
 		currentBlock = currentBlock + 4
 
-		if bytes.Contains(allBlocks, NdefEnd) {
+		if ndef.MessageComplete(allBlocks) {
 			// Once we find the end of the NDEF text record there is no need to
 			// continue reading the rest of the card.
 			// This should make things "load" quicker
@@ -119,7 +114,13 @@ func WriteNtag(pnd nfc.Device, text string) ([]byte, error) {
 		return nil, err
 	}
 
-	cardCapacity, err := getNtagCapacity(pnd)
+	return WriteNtagData(pnd, payload)
+}
+
+// WriteNtagData writes raw data to the data area of an NTAG, starting at
+// block 4. The data is padded to a whole block.
+func WriteNtagData(pnd nfc.Device, payload []byte) ([]byte, error) {
+	cardCapacity, err := GetNtagCapacity(pnd)
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +172,8 @@ func getNtagBlockCount(pnd nfc.Device) (int, error) {
 	}
 }
 
-func getNtagCapacity(pnd nfc.Device) (int, error) {
+// GetNtagCapacity returns the size of the data area of an NTAG.
+func GetNtagCapacity(pnd nfc.Device) (int, error) {
 	// Find tag capacity by looking in block 3 (capability container)
 	tx := []byte{ReadCommand, 0x03}
 	rx := make([]byte, 16)
@@ -202,3 +204,36 @@ func chunkBy[T any](items []T, chunkSize int) (chunks [][]T) {
 	}
 	return append(chunks, items)
 }
+
+// NtagReadOnly returns true if the capability container marks the NTAG as
+// read-only.
+func NtagReadOnly(pnd nfc.Device) (bool, error) {
+	rx, err := comm(pnd, []byte{ReadCommand, 0x03}, 16)
+	if err != nil {
+		return false, err
+	}
+
+	// byte 3 is the write access condition, 0x00 is writable
+	return rx[3] != 0x00, nil
+}
+
+// ntagAccess reads and writes NTAG pages with the READ and WRITE commands.
+type ntagAccess struct {
+	pnd nfc.Device
+}
+
+func (n ntagAccess) ReadPages(page int) ([]byte, error) {
+	return comm(n.pnd, []byte{ReadCommand, byte(page)}, 16)
+}
+
+func (n ntagAccess) WritePage(page int, data []byte) error {
+	_, err := comm(n.pnd, append([]byte{WriteCommand, byte(page)}, data...), 1)
+	return err
+}
+
+// LockNtag makes an NTAG permanently read-only by marking the capability
+// container as read-only and setting all the static and dynamic lock bits.
+// This can't be undone.
+func LockNtag(pnd nfc.Device) error {
+	return ndef.LockNtag(ntagAccess{pnd: pnd})
+}
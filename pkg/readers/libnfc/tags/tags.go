@@ -26,6 +26,7 @@ package tags
 import (
 	"encoding/hex"
 	"fmt"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 
 	"github.com/clausecker/nfc/v2"
@@ -75,10 +76,14 @@ func GetTagType(target nfc.Target) string {
 	switch target.Modulation() {
 	case nfc.Modulation{Type: nfc.ISO14443a, BaudRate: nfc.Nbr106}:
 		var card = target.(*nfc.ISO14443aTarget)
-		if card.Atqa == [2]byte{0x00, 0x04} && card.Sak == 0x08 {
+		if _, ok := ndef.MifareClassicFromSAK(card.Sak); ok {
 			// https://www.nxp.com/docs/en/application-note/AN10833.pdf page 9
 			return tokens.TypeMifare
 		}
+		if card.Sak&0x20 != 0 {
+			// ISO 14443-4 compliant, e.g. DESFire and NTAG 424 DNA
+			return tokens.TypeType4
+		}
 		if card.Atqa == [2]byte{0x00, 0x44} && card.Sak == 0x00 {
 			// https://www.nxp.com/docs/en/data-sheet/NTAG213_215_216.pdf page 33
 			return tokens.TypeNTAG
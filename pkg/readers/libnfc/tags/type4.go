@@ -0,0 +1,75 @@
+//go:build (linux || darwin) && cgo
+
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package tags
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+
+	"github.com/clausecker/nfc/v2"
+)
+
+// Send an APDU to an ISO 14443-4 tag. libnfc handles the ISO-DEP framing.
+func type4Transceive(pnd nfc.Device) ndef.Transceive {
+	return func(apdu []byte) ([]byte, error) {
+		rx := make([]byte, 264)
+		n, err := pnd.InitiatorTransceiveBytes(apdu, rx, 0)
+		if err != nil {
+			return nil, fmt.Errorf("comm error: %s", err)
+		}
+		return rx[:n], nil
+	}
+}
+
+// ReadType4CC reads the capability container of a Type 4 tag.
+func ReadType4CC(pnd nfc.Device) (ndef.Type4CC, error) {
+	return ndef.ReadType4CC(type4Transceive(pnd))
+}
+
+// ReadType4 reads the NDEF message from a Type 4 tag, like a DESFire or
+// NTAG 424 DNA card. The message is wrapped in an NDEF TLV so it can be
+// parsed the same as the data area of other tags.
+func ReadType4(pnd nfc.Device) (TagData, error) {
+	msg, err := ndef.ReadType4(type4Transceive(pnd))
+	if errors.Is(err, ndef.ErrNoRecords) {
+		return TagData{
+			Type:  tokens.TypeType4,
+			Bytes: ndef.EmptyMessage(),
+		}, nil
+	} else if err != nil {
+		return TagData{}, err
+	}
+
+	data, err := ndef.WrapTLV(msg)
+	if err != nil {
+		return TagData{}, err
+	}
+
+	return TagData{
+		Type:  tokens.TypeType4,
+		Bytes: data,
+	}, nil
+}
@@ -0,0 +1,328 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// MifareClassic is the size of a MIFARE Classic card, in sectors.
+type MifareClassic int
+
+const (
+	MifareMini MifareClassic = 5
+	Mifare1K   MifareClassic = 16
+	Mifare4K   MifareClassic = 40
+)
+
+const (
+	MifareBlockSize = 16
+	// MadAIDNdef is the MAD application ID of sectors holding NDEF data.
+	MadAIDNdef = 0xE103
+	// sector holding the MAD2 on 4K cards
+	mad2Sector = 16
+)
+
+// Well-known MIFARE Classic keys.
+var (
+	MifareKeyMAD     = []byte{0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5}
+	MifareKeyNDEF    = []byte{0xD3, 0xF7, 0xD3, 0xF7, 0xD3, 0xF7}
+	MifareKeyDefault = []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+)
+
+var ErrMifareAuth = errors.New("could not authenticate sector")
+
+// MifareClassicFromSAK returns the card size from the SAK byte of its
+// ISO 14443-3 select response.
+//
+// Reference: NXP AN10833 section 3.2.
+func MifareClassicFromSAK(sak byte) (MifareClassic, bool) {
+	switch sak {
+	case 0x08, 0x88:
+		return Mifare1K, true
+	case 0x18:
+		return Mifare4K, true
+	case 0x09:
+		return MifareMini, true
+	default:
+		return 0, false
+	}
+}
+
+// Blocks returns the number of blocks in a sector. The last 8 sectors of a
+// 4K card have 16 blocks, all the others have 4.
+func (m MifareClassic) Blocks(sector int) int {
+	if sector >= 32 {
+		return 16
+	}
+	return 4
+}
+
+// FirstBlock returns the block number of the first block in a sector.
+func (m MifareClassic) FirstBlock(sector int) int {
+	if sector >= 32 {
+		return 32*4 + (sector-32)*16
+	}
+	return sector * 4
+}
+
+// TrailerBlock returns the block number of a sector's trailer, which holds
+// its keys and access bits.
+func (m MifareClassic) TrailerBlock(sector int) int {
+	return m.FirstBlock(sector) + m.Blocks(sector) - 1
+}
+
+// DataSectors returns the sectors which can hold NDEF data when there's no
+// MAD to say which do, every sector except the MAD sectors.
+func (m MifareClassic) DataSectors() []int {
+	var sectors []int
+	for s := 1; s < int(m); s++ {
+		if s != mad2Sector {
+			sectors = append(sectors, s)
+		}
+	}
+	return sectors
+}
+
+// Capacity returns the number of bytes available in the data blocks of the
+// given sectors.
+func (m MifareClassic) Capacity(sectors []int) int {
+	size := 0
+	for _, s := range sectors {
+		size += (m.Blocks(s) - 1) * MifareBlockSize
+	}
+	return size
+}
+
+// CRC-8 of a MAD, polynomial 0x1D with a preset of 0xC7.
+//
+// Reference: NXP AN10787 section 3.7.
+func madCRC(data []byte) byte {
+	crc := byte(0xC7)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x1D
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// Return the sectors of a MAD with the NDEF application ID. The first byte
+// of the MAD is the CRC, the second the info byte and then 2 byte AIDs
+// starting at firstSector.
+func madNdefSectors(mad []byte, firstSector int) ([]int, error) {
+	if madCRC(mad[1:]) != mad[0] {
+		return nil, fmt.Errorf("invalid MAD CRC: %02x", mad[0])
+	}
+
+	var sectors []int
+	for i := 2; i+1 < len(mad); i += 2 {
+		if binary.LittleEndian.Uint16(mad[i:]) == MadAIDNdef {
+			sectors = append(sectors, firstSector+(i-2)/2)
+		}
+	}
+
+	return sectors, nil
+}
+
+// NdefSectors returns the sectors holding NDEF data from a card's MAD, in
+// order. mad1 is blocks 1 and 2 of sector 0, and mad2 is the first 3 blocks
+// of sector 16 on 4K cards, or nil.
+//
+// Reference: NXP AN10787 section 3.
+func NdefSectors(mad1 []byte, mad2 []byte) ([]int, error) {
+	if len(mad1) != 2*MifareBlockSize {
+		return nil, fmt.Errorf("invalid MAD1 length: %d", len(mad1))
+	}
+
+	sectors, err := madNdefSectors(mad1, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(mad2) == 3*MifareBlockSize {
+		more, err := madNdefSectors(mad2, mad2Sector+1)
+		if err != nil {
+			return nil, err
+		}
+		sectors = append(sectors, more...)
+	}
+
+	return sectors, nil
+}
+
+// MifareAccess reads and writes the blocks of a MIFARE Classic card through
+// a reader.
+type MifareAccess interface {
+	// Authenticate a block's sector with key A.
+	Auth(block int, key []byte) error
+	ReadBlock(block int) ([]byte, error)
+	WriteBlock(block int, data []byte) error
+}
+
+// Keys to try for a sector, the well-known key for the sector first.
+func mifareKeysFor(sector int, keys [][]byte) [][]byte {
+	first := MifareKeyNDEF
+	if sector == 0 || sector == mad2Sector {
+		first = MifareKeyMAD
+	}
+
+	all := [][]byte{first}
+	for _, k := range keys {
+		if !bytes.Equal(k, first) && !bytes.Equal(k, MifareKeyDefault) {
+			all = append(all, k)
+		}
+	}
+
+	return append(all, MifareKeyDefault)
+}
+
+func mifareAuth(a MifareAccess, m MifareClassic, sector int, keys [][]byte) error {
+	block := m.FirstBlock(sector)
+	for _, key := range mifareKeysFor(sector, keys) {
+		if a.Auth(block, key) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d", ErrMifareAuth, sector)
+}
+
+// Read consecutive blocks in a sector.
+func readBlocks(a MifareAccess, first int, count int) ([]byte, error) {
+	data := make([]byte, 0, count*MifareBlockSize)
+	for b := first; b < first+count; b++ {
+		block, err := a.ReadBlock(b)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, block...)
+	}
+	return data, nil
+}
+
+// MifareNdefSectors returns the sectors which hold NDEF data on a card,
+// using the MAD if it has one. Cards without a MAD, like ones written by
+// older versions of Core, use all the data sectors in order.
+func MifareNdefSectors(a MifareAccess, m MifareClassic, keys [][]byte) []int {
+	err := mifareAuth(a, m, 0, keys)
+	if err != nil {
+		return m.DataSectors()
+	}
+
+	mad1, err := readBlocks(a, 1, 2)
+	if err != nil {
+		return m.DataSectors()
+	}
+
+	var mad2 []byte
+	if m == Mifare4K && mifareAuth(a, m, mad2Sector, keys) == nil {
+		mad2, _ = readBlocks(a, m.FirstBlock(mad2Sector), 3)
+	}
+
+	sectors, err := NdefSectors(mad1, mad2)
+	if err != nil || len(sectors) == 0 {
+		return m.DataSectors()
+	}
+
+	return sectors
+}
+
+// ReadMifareClassic reads the NDEF data area of a MIFARE Classic card,
+// stopping once a whole NDEF message has been read. Sector trailers are
+// skipped. The given keys are tried after the well-known keys.
+func ReadMifareClassic(a MifareAccess, m MifareClassic, keys [][]byte) ([]byte, error) {
+	var data []byte
+
+	for _, sector := range MifareNdefSectors(a, m, keys) {
+		err := mifareAuth(a, m, sector, keys)
+		if err != nil {
+			return data, err
+		}
+
+		block, err := readBlocks(a, m.FirstBlock(sector), m.Blocks(sector)-1)
+		if err != nil {
+			return data, err
+		}
+
+		// check block by block so no more is returned than was needed
+		for i := 0; i < len(block); i += MifareBlockSize {
+			data = append(data, block[i:i+MifareBlockSize]...)
+			if MessageComplete(data) {
+				return data, nil
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// WriteMifareClassic writes data to the NDEF data area of a MIFARE Classic
+// card. The data is padded to a whole block. Returns the number of bytes
+// available.
+func WriteMifareClassic(a MifareAccess, m MifareClassic, keys [][]byte, data []byte) (int, error) {
+	sectors := MifareNdefSectors(a, m, keys)
+	capacity := m.Capacity(sectors)
+	if len(data) > capacity {
+		return capacity, fmt.Errorf("payload too big for card: [%d/%d] bytes used", len(data), capacity)
+	}
+
+	if rem := len(data) % MifareBlockSize; rem != 0 {
+		data = append(data, make([]byte, MifareBlockSize-rem)...)
+	}
+
+	offset := 0
+	for _, sector := range sectors {
+		if offset >= len(data) {
+			break
+		}
+
+		err := mifareAuth(a, m, sector, keys)
+		if err != nil {
+			return capacity, err
+		}
+
+		first := m.FirstBlock(sector)
+		for b := first; b < first+m.Blocks(sector)-1 && offset < len(data); b++ {
+			err := a.WriteBlock(b, data[offset:offset+MifareBlockSize])
+			if err != nil {
+				return capacity, err
+			}
+			offset += MifareBlockSize
+		}
+	}
+
+	return capacity, nil
+}
+
+// MifareCapacity returns the number of bytes available for NDEF data on a
+// card.
+func MifareCapacity(a MifareAccess, m MifareClassic, keys [][]byte) int {
+	return m.Capacity(MifareNdefSectors(a, m, keys))
+}
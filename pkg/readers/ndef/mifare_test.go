@@ -0,0 +1,244 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// fakeMifare emulates the blocks of a MIFARE Classic card.
+type fakeMifare struct {
+	layout MifareClassic
+	blocks [][]byte
+	// key A of each sector
+	keys   [][]byte
+	authed int
+}
+
+func newFakeMifare(layout MifareClassic, key []byte) *fakeMifare {
+	m := &fakeMifare{layout: layout, authed: -1}
+	for s := 0; s < int(layout); s++ {
+		m.keys = append(m.keys, key)
+		for b := 0; b < layout.Blocks(s); b++ {
+			m.blocks = append(m.blocks, make([]byte, MifareBlockSize))
+		}
+	}
+	return m
+}
+
+func (m *fakeMifare) sector(block int) int {
+	if block >= 128 {
+		return 32 + (block-128)/16
+	}
+	return block / 4
+}
+
+func (m *fakeMifare) Auth(block int, key []byte) error {
+	s := m.sector(block)
+	if !bytes.Equal(m.keys[s], key) {
+		m.authed = -1
+		return errors.New("auth failed")
+	}
+	m.authed = s
+	return nil
+}
+
+func (m *fakeMifare) ReadBlock(block int) ([]byte, error) {
+	if m.sector(block) != m.authed {
+		return nil, fmt.Errorf("block %d not authenticated", block)
+	}
+	return append([]byte{}, m.blocks[block]...), nil
+}
+
+func (m *fakeMifare) WriteBlock(block int, data []byte) error {
+	if m.sector(block) != m.authed {
+		return fmt.Errorf("block %d not authenticated", block)
+	}
+	copy(m.blocks[block], data)
+	return nil
+}
+
+// Format sector 0 with a MAD giving the NDEF application to the given
+// sectors.
+func (m *fakeMifare) setMAD(t *testing.T, mad string, sectors ...int) {
+	t.Helper()
+	m.keys[0] = MifareKeyMAD
+	data := mustHex(t, mad)
+	copy(m.blocks[1], data[:16])
+	copy(m.blocks[2], data[16:])
+	for _, s := range sectors {
+		m.keys[s] = MifareKeyNDEF
+	}
+}
+
+// MAD1 from a card formatted for NDEF by NXP TagWriter, every sector
+// assigned to NDEF.
+const madAllNdef = "1401 03e103e103e103e103e103e103e103e1 03e103e103e103e103e103e103e1"
+
+func TestNdefSectors(t *testing.T) {
+	tests := []struct {
+		name string
+		mad1 string
+		want []int
+		err  bool
+	}{
+		{
+			name: "all sectors",
+			mad1: madAllNdef,
+			want: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+		},
+		{
+			name: "first two sectors",
+			mad1: "f301 03e103e1" + strings.Repeat("0000", 13),
+			want: []int{1, 2},
+		},
+		{
+			name: "bad crc",
+			mad1: "1501 03e103e103e103e103e103e103e103e1 03e103e103e103e103e103e103e1",
+			err:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NdefSectors(mustHex(t, tc.mad1), nil)
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Fatalf("expected sectors: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestMifareClassicLayout(t *testing.T) {
+	tests := []struct {
+		layout   MifareClassic
+		blocks   int
+		capacity int
+	}{
+		{layout: MifareMini, blocks: 20, capacity: 4 * 48},
+		{layout: Mifare1K, blocks: 64, capacity: 15 * 48},
+		{layout: Mifare4K, blocks: 256, capacity: 30*48 + 8*240},
+	}
+
+	for _, tc := range tests {
+		last := int(tc.layout) - 1
+		if got := tc.layout.TrailerBlock(last) + 1; got != tc.blocks {
+			t.Errorf("%d sectors, expected %d blocks, got: %d", tc.layout, tc.blocks, got)
+		}
+		if got := tc.layout.Capacity(tc.layout.DataSectors()); got != tc.capacity {
+			t.Errorf("%d sectors, expected capacity %d, got: %d", tc.layout, tc.capacity, got)
+		}
+	}
+
+	if m, ok := MifareClassicFromSAK(0x18); !ok || m != Mifare4K {
+		t.Errorf("expected 4K card from SAK 0x18, got: %d", m)
+	}
+	if _, ok := MifareClassicFromSAK(0x20); ok {
+		t.Error("expected no MIFARE Classic card from SAK 0x20")
+	}
+}
+
+func TestReadWriteMifareClassic(t *testing.T) {
+	custom := mustHex(t, "a1b2c3d4e5f6")
+	data, err := BuildMessage(NewTextRecord(strings.Repeat("A", 60), "en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("mad", func(t *testing.T) {
+		card := newFakeMifare(Mifare1K, MifareKeyDefault)
+		card.setMAD(t, "f301 03e103e1"+strings.Repeat("0000", 13), 1, 2)
+
+		if got := MifareCapacity(card, Mifare1K, nil); got != 96 {
+			t.Fatalf("expected capacity 96, got: %d", got)
+		}
+
+		_, err := WriteMifareClassic(card, Mifare1K, nil, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := ReadMifareClassic(card, Mifare1K, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := ParseRecordText(read)
+		if err != nil || text != strings.Repeat("A", 60) {
+			t.Fatalf("unexpected text: %q, %v", text, err)
+		}
+
+		// sectors outside the MAD are left alone
+		if !bytes.Equal(card.blocks[12], make([]byte, MifareBlockSize)) {
+			t.Fatalf("sector 3 was written: %x", card.blocks[12])
+		}
+
+		_, err = WriteMifareClassic(card, Mifare1K, nil, make([]byte, 97))
+		if err == nil {
+			t.Fatal("expected payload too big error")
+		}
+	})
+
+	t.Run("configured key", func(t *testing.T) {
+		card := newFakeMifare(Mifare1K, custom)
+
+		_, err := ReadMifareClassic(card, Mifare1K, nil)
+		if !errors.Is(err, ErrMifareAuth) {
+			t.Fatalf("expected error %v, got: %v", ErrMifareAuth, err)
+		}
+
+		// no MAD, so all the data sectors are used
+		keys := [][]byte{custom}
+		if got := MifareCapacity(card, Mifare1K, keys); got != 720 {
+			t.Fatalf("expected capacity 720, got: %d", got)
+		}
+
+		_, err = WriteMifareClassic(card, Mifare1K, keys, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := ReadMifareClassic(card, Mifare1K, keys)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := FindMessage(read)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := FindMessage(data)
+		if !bytes.Equal(msg, want) {
+			t.Fatalf("expected message: %x, got: %x", want, msg)
+		}
+	})
+}
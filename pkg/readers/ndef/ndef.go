@@ -0,0 +1,341 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package ndef parses and builds NDEF messages as stored on NFC tags. It is
+// shared by all the reader drivers.
+//
+// Reference: NFC Forum NDEF 1.0, RTD Text 1.0 and RTD URI 1.0 specifications.
+package ndef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Type Name Format values, the lower 3 bits of a record header.
+const (
+	TNFEmpty       = 0x00
+	TNFWellKnown   = 0x01
+	TNFMedia       = 0x02
+	TNFAbsoluteURI = 0x03
+	TNFExternal    = 0x04
+	TNFUnknown     = 0x05
+	TNFUnchanged   = 0x06
+	TNFReserved    = 0x07
+)
+
+// Record header flags.
+const (
+	flagMB  = 0x80 // message begin
+	flagME  = 0x40 // message end
+	flagCF  = 0x20 // chunk flag
+	flagSR  = 0x10 // short record
+	flagIL  = 0x08 // ID length present
+	maskTNF = 0x07
+)
+
+var (
+	ErrNoRecords  = errors.New("no NDEF records found")
+	ErrNoText     = errors.New("no text record found")
+	ErrTruncated  = errors.New("NDEF message is truncated")
+	ErrNotTextRTD = errors.New("record is not a text record")
+)
+
+// Record is a single NDEF record. Chunked records are joined into one record
+// when a message is parsed.
+type Record struct {
+	TNF     byte
+	Type    []byte
+	ID      []byte
+	Payload []byte
+}
+
+// IsWellKnown returns true if the record is an NFC Forum well-known type
+// with the given type name, e.g. "T" for text.
+func (r Record) IsWellKnown(t string) bool {
+	return r.TNF == TNFWellKnown && string(r.Type) == t
+}
+
+// TextRecord is the decoded payload of a well-known text record.
+type TextRecord struct {
+	// IANA language code, e.g. "en" or "en-US".
+	Lang string
+	Text string
+}
+
+// Text decodes the payload of a well-known text record. Both UTF-8 and
+// UTF-16 text are supported.
+func (r Record) Text() (TextRecord, error) {
+	if !r.IsWellKnown("T") {
+		return TextRecord{}, ErrNotTextRTD
+	}
+
+	if len(r.Payload) < 1 {
+		return TextRecord{}, fmt.Errorf("text record is empty")
+	}
+
+	status := r.Payload[0]
+	utf16Enc := status&0x80 != 0
+	langLen := int(status & 0x3F)
+	if 1+langLen > len(r.Payload) {
+		return TextRecord{}, fmt.Errorf("text record language length out of bounds: %d", langLen)
+	}
+
+	lang := string(r.Payload[1 : 1+langLen])
+	body := r.Payload[1+langLen:]
+
+	if !utf16Enc {
+		return TextRecord{Lang: lang, Text: string(body)}, nil
+	}
+
+	if len(body)%2 != 0 {
+		return TextRecord{}, fmt.Errorf("invalid UTF-16 text length: %d", len(body))
+	}
+
+	// big endian unless there's a byte order mark saying otherwise
+	order := binary.ByteOrder(binary.BigEndian)
+	if len(body) >= 2 {
+		if body[0] == 0xFF && body[1] == 0xFE {
+			order = binary.LittleEndian
+			body = body[2:]
+		} else if body[0] == 0xFE && body[1] == 0xFF {
+			body = body[2:]
+		}
+	}
+
+	units := make([]uint16, 0, len(body)/2)
+	for i := 0; i+1 < len(body); i += 2 {
+		units = append(units, order.Uint16(body[i:]))
+	}
+
+	return TextRecord{Lang: lang, Text: string(utf16.Decode(units))}, nil
+}
+
+// ParseMessage parses a raw NDEF message into its records. Chunked records
+// are reassembled into a single record.
+func ParseMessage(msg []byte) ([]Record, error) {
+	var records []Record
+	var chunk *Record
+	i := 0
+
+	for i < len(msg) {
+		header := msg[i]
+		i++
+
+		tnf := header & maskTNF
+		if len(records) == 0 && chunk == nil && header&flagMB == 0 {
+			return nil, fmt.Errorf("first record is missing message begin flag")
+		}
+
+		if i >= len(msg) {
+			return nil, ErrTruncated
+		}
+		typeLen := int(msg[i])
+		i++
+
+		// long payload lengths can overflow an int on 32-bit platforms, so
+		// they're checked as a uint64
+		var payloadLen uint64
+		if header&flagSR != 0 {
+			if i >= len(msg) {
+				return nil, ErrTruncated
+			}
+			payloadLen = uint64(msg[i])
+			i++
+		} else {
+			if i+4 > len(msg) {
+				return nil, ErrTruncated
+			}
+			payloadLen = uint64(binary.BigEndian.Uint32(msg[i : i+4]))
+			i += 4
+		}
+
+		idLen := 0
+		if header&flagIL != 0 {
+			if i >= len(msg) {
+				return nil, ErrTruncated
+			}
+			idLen = int(msg[i])
+			i++
+		}
+
+		if i+typeLen+idLen > len(msg) ||
+			payloadLen > uint64(len(msg)-i-typeLen-idLen) {
+			return nil, ErrTruncated
+		}
+
+		rType := msg[i : i+typeLen]
+		i += typeLen
+		id := msg[i : i+idLen]
+		i += idLen
+		payload := msg[i : i+int(payloadLen)]
+		i += int(payloadLen)
+
+		switch {
+		case chunk == nil && header&flagCF != 0:
+			// first chunk carries the type and ID of the whole record
+			chunk = &Record{
+				TNF:     tnf,
+				Type:    append([]byte{}, rType...),
+				ID:      append([]byte{}, id...),
+				Payload: append([]byte{}, payload...),
+			}
+		case chunk != nil:
+			if tnf != TNFUnchanged || typeLen != 0 {
+				return nil, fmt.Errorf("invalid middle or terminating record chunk")
+			}
+			chunk.Payload = append(chunk.Payload, payload...)
+			if header&flagCF == 0 {
+				records = append(records, *chunk)
+				chunk = nil
+			}
+		default:
+			if tnf == TNFUnchanged {
+				return nil, fmt.Errorf("unchanged type outside of a chunked record")
+			}
+			records = append(records, Record{
+				TNF:     tnf,
+				Type:    rType,
+				ID:      id,
+				Payload: payload,
+			})
+		}
+
+		if header&flagME != 0 {
+			if chunk != nil {
+				return nil, fmt.Errorf("message ended inside a chunked record")
+			}
+			break
+		}
+	}
+
+	if chunk != nil {
+		return nil, ErrTruncated
+	}
+
+	if len(records) == 0 {
+		return nil, ErrNoRecords
+	}
+
+	return records, nil
+}
+
+// MarshalRecords encodes records as a raw NDEF message. Records with small
+// payloads use the short record format.
+func MarshalRecords(records []Record) ([]byte, error) {
+	if len(records) == 0 {
+		return nil, ErrNoRecords
+	}
+
+	var buf bytes.Buffer
+	for i, r := range records {
+		if len(r.Type) > 255 || len(r.ID) > 255 {
+			return nil, fmt.Errorf("record type or id too long")
+		}
+
+		header := r.TNF & maskTNF
+		if i == 0 {
+			header |= flagMB
+		}
+		if i == len(records)-1 {
+			header |= flagME
+		}
+		if len(r.Payload) < 256 {
+			header |= flagSR
+		}
+		if len(r.ID) > 0 {
+			header |= flagIL
+		}
+
+		buf.WriteByte(header)
+		buf.WriteByte(byte(len(r.Type)))
+		if header&flagSR != 0 {
+			buf.WriteByte(byte(len(r.Payload)))
+		} else {
+			_ = binary.Write(&buf, binary.BigEndian, uint32(len(r.Payload)))
+		}
+		if len(r.ID) > 0 {
+			buf.WriteByte(byte(len(r.ID)))
+		}
+		buf.Write(r.Type)
+		buf.Write(r.ID)
+		buf.Write(r.Payload)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// NewTextRecord creates a well-known UTF-8 text record.
+func NewTextRecord(text string, lang string) Record {
+	payload := make([]byte, 0, 1+len(lang)+len(text))
+	payload = append(payload, byte(len(lang)&0x3F))
+	payload = append(payload, lang...)
+	payload = append(payload, text...)
+
+	return Record{
+		TNF:     TNFWellKnown,
+		Type:    []byte("T"),
+		Payload: payload,
+	}
+}
+
+// ParseRecordText finds the first text record in the NDEF message stored in
+// the data area of a tag and returns its text.
+func ParseRecordText(data []byte) (string, error) {
+	msg, err := FindMessage(data)
+	if err != nil {
+		return "", err
+	}
+
+	records, err := ParseMessage(msg)
+	if err != nil {
+		return "", err
+	}
+
+	for _, r := range records {
+		if !r.IsWellKnown("T") {
+			continue
+		}
+
+		t, err := r.Text()
+		if err != nil {
+			return "", err
+		}
+
+		return t.Text, nil
+	}
+
+	return "", ErrNoText
+}
+
+// BuildMessage builds the data to write to a tag for a message of one or
+// more records, wrapped in an NDEF TLV and ending with a terminator TLV.
+func BuildMessage(records ...Record) ([]byte, error) {
+	msg, err := MarshalRecords(records)
+	if err != nil {
+		return nil, err
+	}
+
+	return WrapTLV(msg)
+}
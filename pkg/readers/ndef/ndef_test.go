@@ -0,0 +1,380 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Data areas below are dumps from real tags, starting at the first byte
+// after the capability container (page 4 on Type 2 tags). Trailing empty
+// pages have been cut down.
+func TestParseRecordText(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+		err  error
+	}{
+		{
+			name: "ntag215 written by core",
+			data: "0314d101105402656e2a2a72616e646f6d3a736e6573fe 0000000000000000",
+			want: "**random:snes",
+		},
+		{
+			name: "ntag216 with lock control tlv",
+			data: "0103a00c34 0314d101105402656e2a2a72616e646f6d3a736e6573fe 000000",
+			want: "**random:snes",
+		},
+		{
+			name: "lock and memory control tlvs with null padding",
+			data: "0103a01044 0203b40202 0000 0308d101045402656e41fe 0000",
+			want: "A",
+		},
+		{
+			name: "three byte tlv length",
+			data: "03ff000bd101075402656e41414141fe",
+			want: "AAAA",
+		},
+		{
+			name: "long text with 0xfe payload length",
+			data: "03ff0102d101fe5402656e" + strings.Repeat("41", 251) + "fe",
+			want: strings.Repeat("A", 251),
+		},
+		{
+			name: "no terminator tlv",
+			data: "0308d101045402656e41",
+			want: "A",
+		},
+		{
+			name: "german language code",
+			data: "030cd101085402646548616c6c6ffe",
+			want: "Hallo",
+		},
+		{
+			name: "region language code",
+			data: "031ed1011a5405656e2d55532a2a6c61756e63682e72616e646f6d3a736e6573fe",
+			want: "**launch.random:snes",
+		},
+		{
+			name: "utf-16 big endian bom",
+			data: "0311d1010d5482656efeff0053004e00450053fe",
+			want: "SNES",
+		},
+		{
+			name: "utf-16 little endian bom",
+			data: "0311d1010d5482656efffe53004e0045005300fe",
+			want: "SNES",
+		},
+		{
+			name: "utf-16 without bom containing 0xfe",
+			data: "0309d101055482656e01fefe",
+			want: "Ǿ",
+		},
+		{
+			name: "uri record before text record",
+			data: "032491010c55047a617061726f6f2e6f72675101105402656e2a2a72616e646f6d3a736e6573fe",
+			want: "**random:snes",
+		},
+		{
+			name: "chunked text record",
+			data: "031ab101085402656e2a2a72616e360004646f6d3a560004736e6573fe",
+			want: "**random:snes",
+		},
+		{
+			name: "record with id",
+			data: "0316d9011001543102656e2a2a72616e646f6d3a736e6573fe",
+			want: "**random:snes",
+		},
+		{
+			name: "uri record only",
+			data: "0310d1010c55047a617061726f6f2e6f7267fe",
+			err:  ErrNoText,
+		},
+		{
+			name: "blank ntag",
+			data: "0000000000000000000000000000000000000000",
+			err:  ErrNoNDEF,
+		},
+		{
+			name: "terminator only",
+			data: "fe0000000000000000000000",
+			err:  ErrNoNDEF,
+		},
+		{
+			name: "empty ndef tlv",
+			data: "0300fe",
+			err:  ErrNoRecords,
+		},
+		{
+			name: "tlv cut off",
+			data: "0314d101105402656e2a2a72616e",
+			err:  ErrTruncated,
+		},
+		{
+			name: "record cut off",
+			data: "0308d101105402656e41fe",
+			err:  ErrTruncated,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseRecordText(mustHex(t, tc.data))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected error %v, got: %v", tc.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	msg := mustHex(t, "91010c55047a617061726f6f2e6f7267 59011001543102656e2a2a72616e646f6d3a736e6573")
+	records, err := ParseMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got: %d", len(records))
+	}
+
+	if !records[0].IsWellKnown("U") || string(records[0].Payload) != "\x04zaparoo.org" {
+		t.Fatalf("unexpected first record: %+v", records[0])
+	}
+
+	text, err := records[1].Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(records[1].ID) != "1" || text.Lang != "en" || text.Text != "**random:snes" {
+		t.Fatalf("unexpected second record: %+v %+v", records[1], text)
+	}
+
+	_, err = records[0].Text()
+	if !errors.Is(err, ErrNotTextRTD) {
+		t.Fatalf("expected error %v, got: %v", ErrNotTextRTD, err)
+	}
+}
+
+func TestParseMessageInvalid(t *testing.T) {
+	tests := map[string]string{
+		"missing message begin":   "51010454",
+		"unchanged outside chunk": "d6000141",
+		"ends inside chunk":       "f101025402",
+		"chunk changes type":      "b101035402656e5101014141",
+		"long payload cut off":    "c1010000",
+		// overflows an int on 32-bit platforms
+		"long payload too large": "c1017fffffff5402656e",
+	}
+
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseMessage(mustHex(t, data))
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestMessageComplete(t *testing.T) {
+	tests := map[string]struct {
+		data string
+		want bool
+	}{
+		"whole message":        {data: "0103a00c34 0308d101045402656e41", want: true},
+		"partial message":      {data: "0103a00c34 0314d101105402656e2a2a", want: false},
+		"partial lock control": {data: "0103a0", want: false},
+		"blank":                {data: "00000000", want: false},
+		"terminator":           {data: "0000fe00", want: true},
+		// 0xfe inside a payload isn't a terminator
+		"fe in payload": {data: "0311d1010d5482656efeff00", want: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := MessageComplete(mustHex(t, tc.data))
+			if got != tc.want {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseTLVs(t *testing.T) {
+	tlvs, err := ParseTLVs(mustHex(t, "0103a00c34 00 0203b40202 0308d101045402656e41 fe 0301ff"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TLV{
+		{Type: TLVLockControl, Value: mustHex(t, "a00c34")},
+		{Type: TLVMemoryControl, Value: mustHex(t, "b40202")},
+		{Type: TLVNDEF, Value: mustHex(t, "d101045402656e41")},
+	}
+
+	if len(tlvs) != len(want) {
+		t.Fatalf("expected %d tlvs, got: %d", len(want), len(tlvs))
+	}
+	for i := range want {
+		if tlvs[i].Type != want[i].Type || !bytes.Equal(tlvs[i].Value, want[i].Value) {
+			t.Fatalf("tlv %d, expected: %x %x, got: %x %x", i, want[i].Type, want[i].Value, tlvs[i].Type, tlvs[i].Value)
+		}
+	}
+}
+
+func TestMarshalRecords(t *testing.T) {
+	records := []Record{
+		{TNF: TNFWellKnown, Type: []byte("U"), Payload: []byte("\x04zaparoo.org")},
+		{TNF: TNFWellKnown, Type: []byte("T"), ID: []byte("1"), Payload: []byte("\x02en**random:snes")},
+		NewTextRecord(strings.Repeat("A", 300), "en"),
+	}
+
+	msg, err := MarshalRecords(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "91010c55047a617061726f6f2e6f7267" +
+		"19011001543102656e2a2a72616e646f6d3a736e6573" +
+		"410100000" + "12f5402656e" + strings.Repeat("41", 300)
+	if hex.EncodeToString(msg) != want {
+		t.Fatalf("expected: %s, got: %x", want, msg)
+	}
+
+	parsed, err := ParseMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != len(records) {
+		t.Fatalf("expected %d records, got: %d", len(records), len(parsed))
+	}
+	for i := range records {
+		if parsed[i].TNF != records[i].TNF ||
+			!bytes.Equal(parsed[i].Type, records[i].Type) ||
+			!bytes.Equal(parsed[i].ID, records[i].ID) ||
+			!bytes.Equal(parsed[i].Payload, records[i].Payload) {
+			t.Fatalf("record %d, expected: %+v, got: %+v", i, records[i], parsed[i])
+		}
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	tests := []string{"A", "**random:snes", strings.Repeat("A", 251), strings.Repeat("A", 512)}
+
+	for _, text := range tests {
+		data, err := BuildMessage(NewTextRecord(text, "en"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ParseRecordText(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != text {
+			t.Fatalf("expected: %q, got: %q", text, got)
+		}
+		if !MessageComplete(data) {
+			t.Fatalf("message not complete: %x", data)
+		}
+	}
+}
+
+func TestCalculateNdefHeaderTooLong(t *testing.T) {
+	_, err := CalculateNdefHeader(make([]byte, 0xFFFF))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	written, err := BuildMessage(NewTextRecord("**launch.random:snes", "en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := BuildMessage(NewTextRecord("**launch.random:nes", "en"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tags are read back in whole pages, so there's usually data left over
+	// from before the write after the terminator
+	readBack := append(append([]byte{}, written...), 0x03, 0x05, 0xde, 0xad)
+
+	tests := map[string]struct {
+		read []byte
+		err  error
+	}{
+		"same message":      {read: written},
+		"trailing data":     {read: readBack},
+		"different message": {read: other, err: ErrMismatch},
+		"no message":        {read: []byte{0x00, 0x00, 0x00, 0x00}, err: ErrNoNDEF},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := VerifyMessage(written, tc.read)
+			if tc.err == nil && err != nil {
+				t.Fatal(err)
+			} else if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Fatalf("expected error %v, got: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestErasedMessage(t *testing.T) {
+	tests := map[int]string{
+		0: "0300fe",
+		3: "0300fe",
+		8: "0300fe0000000000",
+	}
+
+	for size, want := range tests {
+		if got := ErasedMessage(size); !bytes.Equal(got, mustHex(t, want)) {
+			t.Fatalf("%d: expected: %s, got: %x", size, want, got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import "fmt"
+
+// NtagAccess reads and writes the pages of an NTAG.
+type NtagAccess interface {
+	// Read 4 pages (16 bytes) starting at a page.
+	ReadPages(page int) ([]byte, error)
+	// Write a single page of 4 bytes.
+	WritePage(page int, data []byte) error
+}
+
+// Page holding the dynamic lock bytes, by the data area size in the
+// capability container.
+// https://www.nxp.com/docs/en/data-sheet/NTAG213_215_216.pdf section 8.5.2
+var ntagDynamicLockPages = map[byte]int{
+	0x12: 0x28, // NTAG213
+	0x3E: 0x82, // NTAG215
+	0x6D: 0xE2, // NTAG216
+}
+
+// LockNtag makes an NTAG permanently read-only by marking the capability
+// container as read-only and setting all the static and dynamic lock bits.
+// Dynamic lock bits are only set for known NTAG sizes. This can't be undone.
+func LockNtag(a NtagAccess) error {
+	// static lock bytes are in page 2, then the capability container
+	rx, err := a.ReadPages(0x02)
+	if err != nil {
+		return err
+	} else if len(rx) < 8 {
+		return fmt.Errorf("short read of lock pages: %x", rx)
+	}
+	static, cc := rx[0:4], rx[4:8]
+
+	err = a.WritePage(0x03, []byte{cc[0], cc[1], cc[2], 0x0F})
+	if err != nil {
+		return fmt.Errorf("error writing capability container: %w", err)
+	}
+
+	if page, ok := ntagDynamicLockPages[cc[2]]; ok {
+		// 4th byte is reserved and must be left as 0
+		err = a.WritePage(page, []byte{0xFF, 0xFF, 0xFF, 0x00})
+		if err != nil {
+			return fmt.Errorf("error writing dynamic lock bytes: %w", err)
+		}
+	}
+
+	// static lock bits go last because they also lock the capability
+	// container
+	err = a.WritePage(0x02, []byte{static[0], static[1], 0xFF, 0xFF})
+	if err != nil {
+		return fmt.Errorf("error writing static lock bytes: %w", err)
+	}
+
+	return nil
+}
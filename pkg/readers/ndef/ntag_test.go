@@ -0,0 +1,84 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"bytes"
+	"testing"
+)
+
+// fakeNtag is an NTAG's memory, with writes to locked pages ignored.
+type fakeNtag struct {
+	mem []byte
+}
+
+func newFakeNtag(size byte, pages int) *fakeNtag {
+	mem := make([]byte, pages*4)
+	copy(mem[12:16], []byte{0xE1, 0x10, size, 0x00})
+	return &fakeNtag{mem: mem}
+}
+
+func (f *fakeNtag) ReadPages(page int) ([]byte, error) {
+	return append([]byte{}, f.mem[page*4:page*4+16]...), nil
+}
+
+func (f *fakeNtag) WritePage(page int, data []byte) error {
+	copy(f.mem[page*4:page*4+4], data)
+	return nil
+}
+
+func (f *fakeNtag) page(page int) []byte {
+	return f.mem[page*4 : page*4+4]
+}
+
+func TestLockNtag(t *testing.T) {
+	tag := newFakeNtag(0x3E, 135)
+
+	err := LockNtag(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(tag.page(0x02), []byte{0x00, 0x00, 0xFF, 0xFF}) {
+		t.Fatalf("unexpected static lock bytes: %x", tag.page(0x02))
+	} else if !bytes.Equal(tag.page(0x03), []byte{0xE1, 0x10, 0x3E, 0x0F}) {
+		t.Fatalf("unexpected capability container: %x", tag.page(0x03))
+	} else if !bytes.Equal(tag.page(0x82), []byte{0xFF, 0xFF, 0xFF, 0x00}) {
+		t.Fatalf("unexpected dynamic lock bytes: %x", tag.page(0x82))
+	}
+}
+
+func TestLockNtagUnknownSize(t *testing.T) {
+	tag := newFakeNtag(0x06, 16)
+
+	err := LockNtag(tag)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the static lock bytes and capability container are set
+	want := newFakeNtag(0x06, 16)
+	copy(want.mem[8:16], []byte{0x00, 0x00, 0xFF, 0xFF, 0xE1, 0x10, 0x06, 0x0F})
+	if !bytes.Equal(tag.mem, want.mem) {
+		t.Fatalf("expected: %x, got: %x", want.mem, tag.mem)
+	}
+}
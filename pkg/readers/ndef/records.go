@@ -0,0 +1,372 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// MimeJSON is the MIME type of records holding a JSON ZapScript token.
+	MimeJSON = "application/json"
+	// ExternalZapScript is the external type reserved for records holding
+	// native ZapScript payloads.
+	ExternalZapScript = "zaparoo.com:zs"
+)
+
+// Kinds of record a token can be read from.
+const (
+	KindText      = "text"
+	KindURI       = "uri"
+	KindJSON      = "json"
+	KindZapScript = "zapscript"
+)
+
+var ErrNoToken = errors.New("no supported token record found")
+
+// URI identifier codes, the first byte of a URI record's payload. Index is
+// the code.
+//
+// Reference: NFC Forum URI RTD 1.0 section 3.2.2.
+var uriPrefixes = []string{
+	"",
+	"http://www.",
+	"https://www.",
+	"http://",
+	"https://",
+	"tel:",
+	"mailto:",
+	"ftp://anonymous:anonymous@",
+	"ftp://ftp.",
+	"ftps://",
+	"sftp://",
+	"smb://",
+	"nfs://",
+	"ftp://",
+	"dav://",
+	"news:",
+	"telnet://",
+	"imap:",
+	"rtsp://",
+	"urn:",
+	"pop:",
+	"sip:",
+	"sips:",
+	"tftp:",
+	"btspp://",
+	"btl2cap://",
+	"btgoep://",
+	"tcpobex://",
+	"irdaobex://",
+	"file://",
+	"urn:epc:id:",
+	"urn:epc:tag:",
+	"urn:epc:pat:",
+	"urn:epc:raw:",
+	"urn:epc:",
+	"urn:nfc:",
+}
+
+// IsMime returns true if the record is a MIME type record of the given type.
+// MIME types are case insensitive.
+func (r Record) IsMime(t string) bool {
+	if r.TNF != TNFMedia {
+		return false
+	}
+	// ignore parameters like charset
+	mt, _, _ := strings.Cut(string(r.Type), ";")
+	return strings.EqualFold(strings.TrimSpace(mt), t)
+}
+
+// IsExternal returns true if the record is an NFC Forum external type record
+// of the given type. External types are case insensitive.
+func (r Record) IsExternal(t string) bool {
+	return r.TNF == TNFExternal && strings.EqualFold(string(r.Type), t)
+}
+
+// URI decodes the payload of a well-known URI record, expanding the prefix
+// code.
+func (r Record) URI() (string, error) {
+	if !r.IsWellKnown("U") {
+		return "", fmt.Errorf("record is not a uri record")
+	}
+
+	if len(r.Payload) < 1 {
+		return "", fmt.Errorf("uri record is empty")
+	}
+
+	code := int(r.Payload[0])
+	if code >= len(uriPrefixes) {
+		// reserved codes must be treated as no prefix
+		code = 0
+	}
+
+	return uriPrefixes[code] + string(r.Payload[1:]), nil
+}
+
+// NewURIRecord creates a well-known URI record, using the longest matching
+// prefix code to shorten it.
+func NewURIRecord(uri string) Record {
+	code := 0
+	for i, p := range uriPrefixes {
+		if p != "" && strings.HasPrefix(uri, p) && len(p) > len(uriPrefixes[code]) {
+			code = i
+		}
+	}
+
+	payload := make([]byte, 0, 1+len(uri))
+	payload = append(payload, byte(code))
+	payload = append(payload, uri[len(uriPrefixes[code]):]...)
+
+	return Record{
+		TNF:     TNFWellKnown,
+		Type:    []byte("U"),
+		Payload: payload,
+	}
+}
+
+// NewMimeRecord creates a MIME type record.
+func NewMimeRecord(mimeType string, payload []byte) Record {
+	return Record{
+		TNF:     TNFMedia,
+		Type:    []byte(mimeType),
+		Payload: payload,
+	}
+}
+
+// NewExternalRecord creates an NFC Forum external type record. The type is
+// a domain name and type joined by a colon, e.g. "zaparoo.com:zs".
+func NewExternalRecord(externalType string, payload []byte) Record {
+	return Record{
+		TNF:     TNFExternal,
+		Type:    []byte(strings.ToLower(externalType)),
+		Payload: payload,
+	}
+}
+
+// NewZapScriptRecord creates a native ZapScript record.
+func NewZapScriptRecord(text string) Record {
+	return NewExternalRecord(ExternalZapScript, []byte(text))
+}
+
+// ZapScriptCmd is a single command in a JSON ZapScript token.
+type ZapScriptCmd struct {
+	Cmd string `json:"cmd"`
+	// Either a string or, for commands which take JSON arguments, an
+	// object.
+	Args json.RawMessage `json:"args,omitempty"`
+	// Named arguments, added as a query string.
+	Named map[string]string `json:"named,omitempty"`
+}
+
+// ZapScript is a token stored as JSON in an application/json MIME record.
+// Either the ZapScript text is given as-is, or it's built from a list of
+// commands.
+type ZapScript struct {
+	ZapScript string         `json:"zapscript,omitempty"`
+	Cmds      []ZapScriptCmd `json:"cmds,omitempty"`
+}
+
+func (c ZapScriptCmd) text() (string, error) {
+	name := strings.TrimSpace(c.Cmd)
+	if name == "" {
+		return "", fmt.Errorf("zapscript command has no name")
+	}
+
+	args := ""
+	jsonArgs := false
+	raw := bytes.TrimSpace(c.Args)
+	if len(raw) > 0 && raw[0] == '"' {
+		err := json.Unmarshal(raw, &args)
+		if err != nil {
+			return "", fmt.Errorf("invalid args for %s: %w", name, err)
+		}
+	} else if len(raw) > 0 && string(raw) != "null" {
+		var buf bytes.Buffer
+		err := json.Compact(&buf, raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid args for %s: %w", name, err)
+		}
+		args = buf.String()
+		jsonArgs = true
+	}
+
+	text := "**" + name + ":" + args
+
+	// named args can't be used with json args, the query string would be
+	// passed through as part of the json
+	if len(c.Named) > 0 && !jsonArgs {
+		keys := make([]string, 0, len(c.Named))
+		for k := range c.Named {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		qs := make([]string, 0, len(keys))
+		for _, k := range keys {
+			qs = append(qs, url.QueryEscape(k)+"="+url.QueryEscape(c.Named[k]))
+		}
+		text += "?" + strings.Join(qs, "&")
+	}
+
+	return text, nil
+}
+
+// Text returns the ZapScript text for the token.
+func (z ZapScript) Text() (string, error) {
+	if z.ZapScript != "" {
+		return z.ZapScript, nil
+	}
+
+	cmds := make([]string, 0, len(z.Cmds))
+	for _, c := range z.Cmds {
+		text, err := c.text()
+		if err != nil {
+			return "", err
+		}
+		cmds = append(cmds, text)
+	}
+
+	if len(cmds) == 0 {
+		return "", fmt.Errorf("zapscript token is empty")
+	}
+
+	return strings.Join(cmds, "||"), nil
+}
+
+// ParseZapScript parses the payload of a JSON ZapScript record.
+func ParseZapScript(payload []byte) (ZapScript, error) {
+	var z ZapScript
+	err := json.Unmarshal(payload, &z)
+	if err != nil {
+		return z, fmt.Errorf("invalid zapscript json: %w", err)
+	}
+	return z, nil
+}
+
+// NewJSONRecord creates an application/json MIME record for a ZapScript
+// token.
+func NewJSONRecord(z ZapScript) (Record, error) {
+	payload, err := json.Marshal(z)
+	if err != nil {
+		return Record{}, err
+	}
+	return NewMimeRecord(MimeJSON, payload), nil
+}
+
+// TokenRecord is the token found in an NDEF message.
+type TokenRecord struct {
+	// Kind of record the token was read from.
+	Kind string
+	// ZapScript text of the token. JSON tokens are converted to text.
+	Text string
+}
+
+// Order records are picked in when a message has more than one kind. Phone
+// apps often add a URI record linking to the app, so it comes last.
+var tokenKinds = []string{KindZapScript, KindJSON, KindText, KindURI}
+
+func recordKind(r Record) string {
+	switch {
+	case r.IsExternal(ExternalZapScript):
+		return KindZapScript
+	case r.IsMime(MimeJSON):
+		return KindJSON
+	case r.IsWellKnown("T"):
+		return KindText
+	case r.IsWellKnown("U"):
+		return KindURI
+	default:
+		return ""
+	}
+}
+
+func tokenFromRecord(kind string, r Record) (TokenRecord, error) {
+	tr := TokenRecord{Kind: kind}
+
+	switch kind {
+	case KindZapScript:
+		tr.Text = string(r.Payload)
+	case KindJSON:
+		z, err := ParseZapScript(r.Payload)
+		if err != nil {
+			return tr, err
+		}
+		tr.Text, err = z.Text()
+		if err != nil {
+			return tr, err
+		}
+	case KindText:
+		t, err := r.Text()
+		if err != nil {
+			return tr, err
+		}
+		tr.Text = t.Text
+	case KindURI:
+		uri, err := r.URI()
+		if err != nil {
+			return tr, err
+		}
+		tr.Text = uri
+	default:
+		return tr, ErrNoToken
+	}
+
+	return tr, nil
+}
+
+// TokenFromRecords returns the token from the first supported record in a
+// list of records. Native ZapScript records are preferred, then JSON, text
+// and URI records.
+func TokenFromRecords(records []Record) (TokenRecord, error) {
+	for _, kind := range tokenKinds {
+		for _, r := range records {
+			if recordKind(r) == kind {
+				return tokenFromRecord(kind, r)
+			}
+		}
+	}
+
+	return TokenRecord{}, ErrNoToken
+}
+
+// ParseToken finds the token in the NDEF message stored in the data area of
+// a tag.
+func ParseToken(data []byte) (TokenRecord, error) {
+	msg, err := FindMessage(data)
+	if err != nil {
+		return TokenRecord{}, err
+	}
+
+	records, err := ParseMessage(msg)
+	if err != nil {
+		return TokenRecord{}, err
+	}
+
+	return TokenFromRecords(records)
+}
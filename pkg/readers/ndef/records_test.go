@@ -0,0 +1,226 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseToken(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		kind string
+		want string
+		err  error
+	}{
+		{
+			name: "https uri",
+			data: "0315d1011155047a617061726f6f2e6c696e6b2f616263fe",
+			kind: KindURI,
+			want: "https://zaparoo.link/abc",
+		},
+		{
+			name: "uri without prefix code",
+			data: "031ad101165500737465616d3a2f2f72756e67616d6569642f363230fe",
+			kind: KindURI,
+			want: "steam://rungameid/620",
+		},
+		{
+			name: "reserved uri prefix code",
+			data: "0308d101045530616263fe",
+			kind: KindURI,
+			want: "abc",
+		},
+		{
+			name: "json zapscript text",
+			data: "0337d210246170706c69636174696f6e2f6a736f6e7b227a6170736372697074223a222a2a6c61756e63682e72616e646f6d3a736e6573227dfe",
+			kind: KindJSON,
+			want: "**launch.random:snes",
+		},
+		{
+			name: "json zapscript commands",
+			data: "0360d2104d6170706c69636174696f6e2f6a736f6e7b22636d6473223a5b7b22636d64223a226c61756e63682e73797374656d222c2261726773223a22736e6573227d2c7b22636d64223a2264656c6179222c2261726773223a22353030227d5d7dfe",
+			kind: KindJSON,
+			want: "**launch.system:snes||**delay:500",
+		},
+		{
+			name: "native zapscript",
+			data: "0325d40e147a617061726f6f2e636f6d3a7a732a2a6c61756e63682e72616e646f6d3a736e6573fe",
+			kind: KindZapScript,
+			want: "**launch.random:snes",
+		},
+		{
+			name: "text preferred over app uri",
+			data: "032991011155047a617061726f6f2e6c696e6b2f6162635101105402656e2a2a72616e646f6d3a736e6573fe",
+			kind: KindText,
+			want: "**random:snes",
+		},
+		{
+			name: "native preferred over text",
+			data: "031a9101045402656e41540e017a617061726f6f2e636f6d3a7a7342fe",
+			kind: KindZapScript,
+			want: "B",
+		},
+		{
+			name: "unsupported mime type",
+			data: "0318d20a0b746578742f7663617264424547494e3a5643415244fe",
+			err:  ErrNoToken,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseToken(mustHex(t, tc.data))
+			if tc.err != nil {
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected error %v, got: %v", tc.err, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got.Kind != tc.kind || got.Text != tc.want {
+				t.Fatalf("expected: %s %q, got: %s %q", tc.kind, tc.want, got.Kind, got.Text)
+			}
+		})
+	}
+}
+
+func TestZapScriptText(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{
+			name: "named args",
+			json: `{"cmds":[{"cmd":"launch.random","args":"snes","named":{"b":"x y","a":"1"}}]}`,
+			want: "**launch.random:snes?a=1&b=x+y",
+		},
+		{
+			name: "json args",
+			json: `{"cmds":[{"cmd":"http.request","args":{"url": "http://x/?a=1", "method": "POST"},"named":{"a":"1"}}]}`,
+			want: `**http.request:{"url":"http://x/?a=1","method":"POST"}`,
+		},
+		{
+			name: "no args",
+			json: `{"cmds":[{"cmd":"playlist.next"}]}`,
+			want: "**playlist.next:",
+		},
+		{
+			name: "text wins over commands",
+			json: `{"zapscript":"SNES/game.sfc","cmds":[{"cmd":"delay","args":"1"}]}`,
+			want: "SNES/game.sfc",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			z, err := ParseZapScript([]byte(tc.json))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := z.Text()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+
+	for _, bad := range []string{`{}`, `{"cmds":[{"args":"x"}]}`, `[1]`} {
+		z, err := ParseZapScript([]byte(bad))
+		if err == nil {
+			_, err = z.Text()
+		}
+		if err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestNewURIRecord(t *testing.T) {
+	tests := map[string]byte{
+		"https://www.zaparoo.org":  0x02,
+		"https://zaparoo.link/abc": 0x04,
+		"http://example.com":       0x03,
+		"urn:epc:id:sgtin:1":       0x1E,
+		"urn:nfc:wkt:T":            0x23,
+		"steam://rungameid/620":    0x00,
+	}
+
+	for uri, code := range tests {
+		t.Run(uri, func(t *testing.T) {
+			r := NewURIRecord(uri)
+			if r.Payload[0] != code {
+				t.Fatalf("expected code %02x, got: %02x", code, r.Payload[0])
+			}
+			got, err := r.URI()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != uri {
+				t.Fatalf("expected: %q, got: %q", uri, got)
+			}
+		})
+	}
+}
+
+func TestBuildMessageKinds(t *testing.T) {
+	jsonRecord, err := NewJSONRecord(ZapScript{
+		Cmds: []ZapScriptCmd{{Cmd: "launch.system", Args: []byte(`"snes"`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		record Record
+		kind   string
+		want   string
+	}{
+		{record: NewTextRecord("**random:snes", "en"), kind: KindText, want: "**random:snes"},
+		{record: NewURIRecord("https://zaparoo.link/abc"), kind: KindURI, want: "https://zaparoo.link/abc"},
+		{record: jsonRecord, kind: KindJSON, want: "**launch.system:snes"},
+		{record: NewZapScriptRecord("**launch.random:snes"), kind: KindZapScript, want: "**launch.random:snes"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.kind, func(t *testing.T) {
+			data, err := BuildMessage(tc.record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseToken(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Kind != tc.kind || got.Text != tc.want {
+				t.Fatalf("expected: %s %q, got: %s %q", tc.kind, tc.want, got.Kind, got.Text)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// TLV block types used in the data area of Type 2 tags and MIFARE Classic
+// NDEF sectors.
+//
+// Reference: NFCForum-TS-Type-2-Tag_1.1 section 2.3.
+const (
+	TLVNull          = 0x00
+	TLVLockControl   = 0x01
+	TLVMemoryControl = 0x02
+	TLVNDEF          = 0x03
+	TLVProprietary   = 0xFD
+	TLVTerminator    = 0xFE
+)
+
+var (
+	ErrNoNDEF   = errors.New("no NDEF TLV found")
+	ErrMismatch = errors.New("message mismatch after write")
+)
+
+// TLV is a single TLV block from a tag's data area.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// Read the length field of a TLV at the given offset. Returns the length
+// and the offset of the value.
+func tlvLength(data []byte, i int) (int, int, error) {
+	if i >= len(data) {
+		return 0, 0, ErrTruncated
+	}
+
+	if data[i] != 0xFF {
+		return int(data[i]), i + 1, nil
+	}
+
+	// three byte format for lengths of 255 and over
+	if i+3 > len(data) {
+		return 0, 0, ErrTruncated
+	}
+
+	return int(binary.BigEndian.Uint16(data[i+1 : i+3])), i + 3, nil
+}
+
+// Walk the TLV blocks in a tag's data area until the terminator TLV or the
+// end of the data. Null TLVs are skipped. Returns true if the terminator was
+// found.
+func walkTLVs(data []byte) ([]TLV, bool, error) {
+	var tlvs []TLV
+	i := 0
+
+	for i < len(data) {
+		t := data[i]
+		i++
+
+		switch t {
+		case TLVNull:
+			continue
+		case TLVTerminator:
+			return tlvs, true, nil
+		}
+
+		length, start, err := tlvLength(data, i)
+		if err != nil {
+			return tlvs, false, err
+		}
+
+		if start+length > len(data) {
+			return tlvs, false, fmt.Errorf("%w: TLV %02x needs %d bytes", ErrTruncated, t, length)
+		}
+
+		tlvs = append(tlvs, TLV{
+			Type:  t,
+			Value: data[start : start+length],
+		})
+		i = start + length
+	}
+
+	return tlvs, false, nil
+}
+
+// ParseTLVs returns the TLV blocks in a tag's data area, up to the
+// terminator TLV or the end of the data. Null TLVs are skipped.
+func ParseTLVs(data []byte) ([]TLV, error) {
+	tlvs, _, err := walkTLVs(data)
+	return tlvs, err
+}
+
+// FindMessage returns the raw NDEF message from the first NDEF TLV in a
+// tag's data area.
+func FindMessage(data []byte) ([]byte, error) {
+	tlvs, _, err := walkTLVs(data)
+	for _, tlv := range tlvs {
+		if tlv.Type == TLVNDEF {
+			return tlv.Value, nil
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, ErrNoNDEF
+}
+
+// VerifyMessage checks the NDEF message read back from a tag's data area
+// matches the one in the data that was written to it.
+func VerifyMessage(written []byte, read []byte) error {
+	want, err := FindMessage(written)
+	if err != nil {
+		return err
+	}
+
+	got, err := FindMessage(read)
+	if err != nil {
+		return fmt.Errorf("error reading back message: %w", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: %x != %x", ErrMismatch, got, want)
+	}
+
+	return nil
+}
+
+// MessageComplete returns true if the data read from a tag so far contains
+// a whole NDEF message or the terminator TLV, so there's no need to read the
+// rest of the tag.
+func MessageComplete(data []byte) bool {
+	tlvs, terminated, _ := walkTLVs(data)
+	for _, tlv := range tlvs {
+		if tlv.Type == TLVNDEF {
+			return true
+		}
+	}
+	return terminated
+}
+
+// WrapTLV wraps a raw NDEF message in an NDEF TLV followed by a terminator
+// TLV, ready to write to a tag's data area.
+func WrapTLV(msg []byte) ([]byte, error) {
+	header, err := CalculateNdefHeader(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, 0, len(header)+len(msg)+1)
+	data = append(data, header...)
+	data = append(data, msg...)
+	data = append(data, TLVTerminator)
+
+	return data, nil
+}
+
+// EmptyMessage returns the data for a formatted tag with an empty NDEF
+// message.
+func EmptyMessage() []byte {
+	return []byte{TLVNDEF, 0x00, TLVTerminator}
+}
+
+// ErasedMessage returns an empty NDEF message padded with zeros to a size,
+// so writing it clears the old message too, not just the TLV in front of it.
+func ErasedMessage(size int) []byte {
+	data := EmptyMessage()
+	if size > len(data) {
+		data = append(data, make([]byte, size-len(data))...)
+	}
+	return data
+}
+
+// CalculateNdefHeader returns the NDEF TLV type and length bytes for a
+// message of the given length.
+func CalculateNdefHeader(ndefRecord []byte) ([]byte, error) {
+	var recordLength = len(ndefRecord)
+	if recordLength < 255 {
+		return []byte{TLVNDEF, byte(len(ndefRecord))}, nil
+	} else if recordLength > 0xFFFE {
+		return nil, fmt.Errorf("NDEF message too long: %d", recordLength)
+	}
+
+	// NFCForum-TS-Type-2-Tag_1.1.pdf Page 9
+	// > 255 Use three consecutive bytes format
+	buf := new(bytes.Buffer)
+	err := binary.Write(buf, binary.BigEndian, uint16(recordLength))
+	if err != nil {
+		return nil, err
+	}
+
+	var header = []byte{TLVNDEF, 0xFF}
+	return append(header, buf.Bytes()...), nil
+}
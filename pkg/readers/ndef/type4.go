@@ -0,0 +1,185 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Transceive sends an APDU to an ISO 14443-4 tag and returns the response,
+// including the status word.
+type Transceive func([]byte) ([]byte, error)
+
+// Application ID of the NDEF tag application.
+var type4AID = []byte{0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01}
+
+const (
+	type4CCFile = 0xE103
+	// largest read to ask for, to stay within the frame size of all the
+	// supported readers
+	type4MaxRead = 0xF0
+)
+
+// Type4CC is the capability container file of a Type 4 tag.
+//
+// Reference: NFCForum-TS-Type-4-Tag_2.0 section 5.1.
+type Type4CC struct {
+	Version byte
+	// Maximum data which can be read or written in one command.
+	MLe int
+	MLc int
+	// File ID and maximum size of the NDEF file.
+	FileID      uint16
+	MaxSize     int
+	ReadAccess  byte
+	WriteAccess byte
+}
+
+// ParseType4CC parses a Type 4 tag capability container file.
+func ParseType4CC(cc []byte) (Type4CC, error) {
+	if len(cc) < 15 {
+		return Type4CC{}, fmt.Errorf("capability container too short: %d", len(cc))
+	}
+
+	// only the NDEF file control TLV is supported, not the extended one
+	// from mapping version 3.0
+	if cc[7] != 0x04 || cc[8] != 0x06 {
+		return Type4CC{}, fmt.Errorf("unsupported NDEF file control TLV: %02x", cc[7])
+	}
+
+	return Type4CC{
+		Version:     cc[2],
+		MLe:         int(binary.BigEndian.Uint16(cc[3:5])),
+		MLc:         int(binary.BigEndian.Uint16(cc[5:7])),
+		FileID:      binary.BigEndian.Uint16(cc[9:11]),
+		MaxSize:     int(binary.BigEndian.Uint16(cc[11:13])),
+		ReadAccess:  cc[13],
+		WriteAccess: cc[14],
+	}, nil
+}
+
+// TransceiveAPDU sends an APDU and checks the status word. Returns the
+// response data without the status word.
+func TransceiveAPDU(tx Transceive, cmd []byte) ([]byte, error) {
+	res, err := tx(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res) < 2 {
+		return nil, fmt.Errorf("invalid response to %x: %x", cmd[:2], res)
+	}
+
+	sw := res[len(res)-2:]
+	if sw[0] != 0x90 || sw[1] != 0x00 {
+		return nil, fmt.Errorf("command %x failed with status: %x", cmd[:2], sw)
+	}
+
+	return res[:len(res)-2], nil
+}
+
+func type4SelectFile(tx Transceive, id uint16) error {
+	_, err := TransceiveAPDU(tx, []byte{0x00, 0xA4, 0x00, 0x0C, 0x02, byte(id >> 8), byte(id)})
+	return err
+}
+
+func type4ReadBinary(tx Transceive, offset int, length int) ([]byte, error) {
+	res, err := TransceiveAPDU(tx, []byte{0x00, 0xB0, byte(offset >> 8), byte(offset), byte(length)})
+	if err != nil {
+		return nil, err
+	} else if len(res) != length {
+		return nil, fmt.Errorf("short read at offset %d: %d bytes", offset, len(res))
+	}
+	return res, nil
+}
+
+// ReadType4CC selects the NDEF tag application and reads its capability
+// container.
+func ReadType4CC(tx Transceive) (Type4CC, error) {
+	cmd := append([]byte{0x00, 0xA4, 0x04, 0x00, byte(len(type4AID))}, type4AID...)
+	_, err := TransceiveAPDU(tx, append(cmd, 0x00))
+	if err != nil {
+		return Type4CC{}, fmt.Errorf("error selecting NDEF application: %w", err)
+	}
+
+	err = type4SelectFile(tx, type4CCFile)
+	if err != nil {
+		return Type4CC{}, fmt.Errorf("error selecting capability container: %w", err)
+	}
+
+	cc, err := type4ReadBinary(tx, 0, 15)
+	if err != nil {
+		return Type4CC{}, err
+	}
+
+	return ParseType4CC(cc)
+}
+
+// ReadType4 reads the raw NDEF message from a Type 4 tag, such as a
+// DESFire or NTAG 424 DNA card.
+//
+// Reference: NFCForum-TS-Type-4-Tag_2.0 section 5.4.
+func ReadType4(tx Transceive) ([]byte, error) {
+	cc, err := ReadType4CC(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if cc.ReadAccess != 0x00 {
+		return nil, fmt.Errorf("NDEF file is not readable: %02x", cc.ReadAccess)
+	}
+
+	err = type4SelectFile(tx, cc.FileID)
+	if err != nil {
+		return nil, fmt.Errorf("error selecting NDEF file: %w", err)
+	}
+
+	nlen, err := type4ReadBinary(tx, 0, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	size := int(binary.BigEndian.Uint16(nlen))
+	if size == 0 {
+		return nil, ErrNoRecords
+	} else if size > cc.MaxSize-2 {
+		return nil, fmt.Errorf("%w: NDEF file length %d over maximum %d", ErrTruncated, size, cc.MaxSize-2)
+	}
+
+	chunk := type4MaxRead
+	if cc.MLe > 0 && cc.MLe < chunk {
+		chunk = cc.MLe
+	}
+
+	msg := make([]byte, 0, size)
+	for len(msg) < size {
+		n := min(chunk, size-len(msg))
+		data, err := type4ReadBinary(tx, 2+len(msg), n)
+		if err != nil {
+			return nil, err
+		}
+		msg = append(msg, data...)
+	}
+
+	return msg, nil
+}
@@ -0,0 +1,172 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package ndef
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var selectApp = []byte{0x00, 0xA4, 0x04, 0x00, 0x07, 0xD2, 0x76, 0x00, 0x00, 0x85, 0x01, 0x01, 0x00}
+
+// fakeType4 emulates the NDEF tag application of a Type 4 tag.
+type fakeType4 struct {
+	cc       []byte
+	ndef     []byte
+	selected []byte
+	app      bool
+	reads    int
+}
+
+func newFakeType4(t *testing.T, msg []byte) *fakeType4 {
+	// NTAG 424 DNA capability container, MLe 0x3B and a 256 byte NDEF
+	// file E104
+	file := make([]byte, 256)
+	binary.BigEndian.PutUint16(file, uint16(len(msg)))
+	copy(file[2:], msg)
+	return &fakeType4{
+		cc:   mustHex(t, "0017 20 003b 0034 04 06 e104 0100 00 00"),
+		ndef: file,
+	}
+}
+
+func (f *fakeType4) transceive(apdu []byte) ([]byte, error) {
+	ok := []byte{0x90, 0x00}
+	notFound := []byte{0x6A, 0x82}
+
+	switch {
+	case bytes.Equal(apdu, selectApp):
+		f.app = true
+		return ok, nil
+	case bytes.HasPrefix(apdu, []byte{0x00, 0xA4, 0x00, 0x0C, 0x02}):
+		if !f.app {
+			return notFound, nil
+		}
+		switch binary.BigEndian.Uint16(apdu[5:7]) {
+		case 0xE103:
+			f.selected = f.cc
+		case 0xE104:
+			f.selected = f.ndef
+		default:
+			return notFound, nil
+		}
+		return ok, nil
+	case bytes.HasPrefix(apdu, []byte{0x00, 0xB0}):
+		f.reads++
+		offset := int(binary.BigEndian.Uint16(apdu[2:4]))
+		length := int(apdu[4])
+		if f.selected == nil || offset+length > len(f.selected) {
+			return []byte{0x6B, 0x00}, nil
+		}
+		if len(f.selected) == len(f.ndef) && length > 0x3B {
+			return []byte{0x67, 0x00}, nil
+		}
+		return append(append([]byte{}, f.selected[offset:offset+length]...), ok...), nil
+	}
+
+	return []byte{0x6D, 0x00}, nil
+}
+
+func TestReadType4(t *testing.T) {
+	msg, err := MarshalRecords([]Record{NewTextRecord(strings.Repeat("B", 200), "en")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tag := newFakeType4(t, msg)
+	got, err := ReadType4(tag.transceive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Fatalf("expected message: %x, got: %x", msg, got)
+	}
+	// CC, NLEN and the message in chunks of MLe
+	if tag.reads != 2+(len(msg)+0x3A)/0x3B {
+		t.Fatalf("unexpected number of reads: %d", tag.reads)
+	}
+}
+
+func TestReadType4Errors(t *testing.T) {
+	empty := newFakeType4(t, nil)
+	_, err := ReadType4(empty.transceive)
+	if !errors.Is(err, ErrNoRecords) {
+		t.Fatalf("expected error %v, got: %v", ErrNoRecords, err)
+	}
+
+	protected := newFakeType4(t, []byte{0xD0, 0x00, 0x00})
+	protected.cc[13] = 0x80
+	_, err = ReadType4(protected.transceive)
+	if err == nil {
+		t.Fatal("expected error reading protected NDEF file")
+	}
+
+	bad := newFakeType4(t, nil)
+	binary.BigEndian.PutUint16(bad.ndef, 0x1000)
+	_, err = ReadType4(bad.transceive)
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("expected error %v, got: %v", ErrTruncated, err)
+	}
+
+	_, err = ReadType4(func([]byte) ([]byte, error) {
+		return []byte{0x6A, 0x82}, nil
+	})
+	if err == nil {
+		t.Fatal("expected error from tag without NDEF application")
+	}
+}
+
+func TestTransceiveAPDU(t *testing.T) {
+	tests := map[string]struct {
+		res     string
+		want    string
+		wantErr bool
+	}{
+		"data":         {res: "01029000", want: "0102"},
+		"no data":      {res: "9000", want: ""},
+		"error status": {res: "6a82", wantErr: true},
+		"warning":      {res: "01026282", wantErr: true},
+		"too short":    {res: "90", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := TransceiveAPDU(func([]byte) ([]byte, error) {
+				return mustHex(t, tc.res), nil
+			}, []byte{0x00, 0xB0, 0x00, 0x00, 0x02})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got: %x", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, mustHex(t, tc.want)) {
+				t.Fatalf("expected: %s, got: %x", tc.want, got)
+			}
+		})
+	}
+}
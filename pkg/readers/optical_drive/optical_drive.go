@@ -140,6 +140,26 @@ func (r *FileReader) Info() string {
 	return r.path
 }
 
+func (r *FileReader) Writable() bool {
+	return false
+}
+
+func (r *FileReader) MultiTag() bool {
+	return false
+}
+
 func (r *FileReader) Write(text string) (*tokens.Token, error) {
 	return nil, nil
 }
+
+func (r *FileReader) WriteMessage(_ readers.WriteRequest) (*tokens.Token, error) {
+	return nil, readers.ErrWriteNotSupported
+}
+
+func (r *FileReader) Erase() error {
+	return readers.ErrWriteNotSupported
+}
+
+func (r *FileReader) Inspect() (*readers.TagInfo, error) {
+	return nil, readers.ErrWriteNotSupported
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
 	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/rs/zerolog/log"
 
@@ -189,18 +190,26 @@ func (r *Pn532UartReader) Open(device string, iq chan<- readers.Scan) error {
 
 			log.Debug().Msgf("record bytes: %s", hex.EncodeToString(data))
 
-			tagText, err := ParseRecordText(data)
+			// reading started at the CC page, data area starts after it
+			tagText := ""
+			var tr ndef.TokenRecord
+			if len(data) > 4 {
+				tr, err = ndef.ParseToken(data[4:])
+			} else {
+				err = ndef.ErrNoNDEF
+			}
 			if err != nil {
-				log.Error().Err(err).Msgf("error parsing NDEF record")
 				// TODO: there should be some distinction between a data
 				// transfer error and a legitimate empty/missing NDEF record
-				tagText = ""
+				log.Error().Err(err).Msgf("error parsing NDEF record")
+			} else {
+				tagText = tr.Text
 			}
 
 			if tagText == "" {
-				log.Warn().Msg("no text NDEF found")
+				log.Warn().Msg("no token NDEF found")
 			} else {
-				log.Info().Msgf("decoded text NDEF: %s", tagText)
+				log.Info().Msgf("decoded %s NDEF: %s", tr.Kind, tagText)
 			}
 
 			token := &tokens.Token{
@@ -332,6 +341,26 @@ func (r *Pn532UartReader) Info() string {
 	return "PN532 UART (" + r.name + ")"
 }
 
+func (r *Pn532UartReader) Writable() bool {
+	return false
+}
+
+func (r *Pn532UartReader) MultiTag() bool {
+	return false
+}
+
 func (r *Pn532UartReader) Write(text string) (*tokens.Token, error) {
 	return nil, errors.New("writing not supported on this reader")
 }
+
+func (r *Pn532UartReader) WriteMessage(_ readers.WriteRequest) (*tokens.Token, error) {
+	return nil, readers.ErrWriteNotSupported
+}
+
+func (r *Pn532UartReader) Erase() error {
+	return readers.ErrWriteNotSupported
+}
+
+func (r *Pn532UartReader) Inspect() (*readers.TagInfo, error) {
+	return nil, readers.ErrWriteNotSupported
+}
@@ -0,0 +1,88 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+	qrenc "rsc.io/qr"
+)
+
+// Size in pixels of each module of a generated QR code.
+const (
+	DefaultScale = 8
+	MaxScale     = 32
+)
+
+var ErrNoCode = errors.New("no QR code found")
+
+// Decode finds a QR code in an image and returns its text.
+func Decode(img image.Image) (string, error) {
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", err
+	}
+
+	hints := map[gozxing.DecodeHintType]interface{}{
+		gozxing.DecodeHintType_TRY_HARDER:    true,
+		gozxing.DecodeHintType_CHARACTER_SET: "UTF-8",
+	}
+
+	res, err := qrcode.NewQRCodeReader().Decode(bmp, hints)
+	if _, ok := err.(gozxing.NotFoundException); ok {
+		return "", ErrNoCode
+	} else if err != nil {
+		return "", fmt.Errorf("error decoding QR code: %w", err)
+	}
+
+	return res.GetText(), nil
+}
+
+// DecodeFile finds a QR code in a PNG, JPEG or GIF image file and returns its
+// text.
+func DecodeFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return "", err
+	}
+
+	return Decode(img)
+}
+
+// Generate creates a PNG image of a QR code containing the given text. The
+// scale is the size in pixels of each module of the code, DefaultScale is
+// used if it's 0 or less.
+func Generate(text string, scale int) ([]byte, error) {
+	if text == "" {
+		return nil, errors.New("no text to encode")
+	} else if scale > MaxScale {
+		return nil, fmt.Errorf("scale must be %d or less", MaxScale)
+	}
+
+	c, err := qrenc.Encode(text, qrenc.M)
+	if err != nil {
+		return nil, err
+	}
+
+	if scale > 0 {
+		c.Scale = scale
+	} else {
+		c.Scale = DefaultScale
+	}
+
+	return c.PNG(), nil
+}
@@ -0,0 +1,249 @@
+// Package qr reads tokens from QR codes, either from image files dropped
+// into a folder or from a video device such as a webcam. QR codes can be
+// printed as cards instead of using NFC tags. Video devices are read with
+// ffmpeg, which must be installed and in the PATH.
+package qr
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	TokenType = "QR"
+	// Read QR codes from the newest image in a folder.
+	IdFolder = "qr_folder"
+	// Read QR codes from frames of a V4L2 video device. Requires ffmpeg.
+	IdVideo = "qr_video"
+)
+
+const (
+	folderPollDelay = 250 * time.Millisecond
+	// Longest delay between polls while the folder can't be read.
+	folderMaxPollDelay = 5 * time.Second
+)
+
+var imageExts = []string{".png", ".jpg", ".jpeg", ".gif"}
+
+type Reader struct {
+	cfg     *config.Instance
+	device  string
+	path    string
+	polling bool
+	cmd     *exec.Cmd
+}
+
+func NewReader(cfg *config.Instance) *Reader {
+	return &Reader{
+		cfg: cfg,
+	}
+}
+
+func (r *Reader) Ids() []string {
+	return []string{IdFolder, IdVideo}
+}
+
+func (r *Reader) newToken(text string) *tokens.Token {
+	return &tokens.Token{
+		Type:     TokenType,
+		Text:     text,
+		Data:     hex.EncodeToString([]byte(text)),
+		ScanTime: time.Now(),
+		Source:   r.device,
+	}
+}
+
+func (r *Reader) Open(device string, iq chan<- readers.Scan) error {
+	ps := strings.SplitN(device, ":", 2)
+	if len(ps) != 2 {
+		return errors.New("invalid device string: " + device)
+	}
+
+	if !utils.Contains(r.Ids(), ps[0]) {
+		return errors.New("invalid reader id: " + ps[0])
+	}
+
+	path := ps[1]
+
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	r.device = device
+	r.path = path
+
+	if ps[0] == IdVideo {
+		return r.openVideo(iq)
+	}
+
+	if !filepath.IsAbs(path) {
+		return errors.New("invalid device path, must be absolute")
+	}
+
+	r.polling = true
+	go r.pollFolder(iq)
+
+	return nil
+}
+
+// Find the most recently modified image in a folder. Returns an empty path
+// if there are no images.
+func newestImage(dir string) (string, time.Time, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	var newest string
+	var newestMod time.Time
+	for _, e := range entries {
+		ext := strings.ToLower(filepath.Ext(e.Name()))
+		if e.IsDir() || !utils.Contains(imageExts, ext) {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		if newest == "" || info.ModTime().After(newestMod) {
+			newest = filepath.Join(dir, e.Name())
+			newestMod = info.ModTime()
+		}
+	}
+
+	return newest, newestMod, nil
+}
+
+// Watch a folder for images containing QR codes. The newest image in the
+// folder is treated as the token on the reader, and the token is removed
+// when there are no images left. If the folder can't be read, it's treated
+// as empty and polled less often until it's back.
+func (r *Reader) pollFolder(iq chan<- readers.Scan) {
+	var token *tokens.Token
+	var lastPath string
+	var lastMod time.Time
+	var folderErr error
+	delay := folderPollDelay
+
+	for r.polling {
+		time.Sleep(delay)
+
+		path, mod, err := newestImage(r.path)
+		if err != nil {
+			if folderErr == nil {
+				log.Warn().Err(err).Msgf("error reading QR code folder: %s", r.path)
+			}
+			folderErr = err
+			delay = min(delay*2, folderMaxPollDelay)
+		} else if folderErr != nil {
+			log.Info().Msgf("QR code folder readable again: %s", r.path)
+			folderErr = nil
+			delay = folderPollDelay
+		}
+
+		if path == "" {
+			lastPath = ""
+			if token != nil {
+				log.Debug().Msg("no images in folder, removing token")
+				token = nil
+				iq <- readers.Scan{
+					Source: r.device,
+					Token:  nil,
+				}
+			}
+			continue
+		}
+
+		if path == lastPath && mod.Equal(lastMod) {
+			continue
+		}
+		lastPath = path
+		lastMod = mod
+
+		// images may still be being written, so failures are retried the
+		// next time the file changes
+		text, err := DecodeFile(path)
+		if err != nil {
+			log.Warn().Err(err).Msgf("error reading QR code from: %s", path)
+			continue
+		}
+
+		if token != nil && token.Text == text {
+			continue
+		}
+
+		token = r.newToken(text)
+		log.Debug().Msgf("new token: %s", token.Text)
+		iq <- readers.Scan{
+			Source: r.device,
+			Token:  token,
+		}
+	}
+}
+
+func (r *Reader) Close() error {
+	r.polling = false
+	if r.cmd != nil && r.cmd.Process != nil {
+		cmd := r.cmd
+		r.cmd = nil
+		err := cmd.Process.Kill()
+		if err != nil && !errors.Is(err, os.ErrProcessDone) {
+			return err
+		}
+		_ = cmd.Wait()
+	}
+	return nil
+}
+
+func (r *Reader) Detect(_ []string) string {
+	return ""
+}
+
+func (r *Reader) Device() string {
+	return r.device
+}
+
+func (r *Reader) Connected() bool {
+	return r.polling
+}
+
+func (r *Reader) Info() string {
+	return r.path
+}
+
+func (r *Reader) Writable() bool {
+	return false
+}
+
+func (r *Reader) MultiTag() bool {
+	return false
+}
+
+func (r *Reader) Write(_ string) (*tokens.Token, error) {
+	return nil, readers.ErrWriteNotSupported
+}
+
+func (r *Reader) WriteMessage(_ readers.WriteRequest) (*tokens.Token, error) {
+	return nil, readers.ErrWriteNotSupported
+}
+
+func (r *Reader) Erase() error {
+	return readers.ErrWriteNotSupported
+}
+
+func (r *Reader) Inspect() (*readers.TagInfo, error) {
+	return nil, readers.ErrWriteNotSupported
+}
@@ -0,0 +1,238 @@
+package qr
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+)
+
+func generate(t *testing.T, text string) []byte {
+	t.Helper()
+	data, err := Generate(text, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func waitScan(t *testing.T, iq <-chan readers.Scan) readers.Scan {
+	t.Helper()
+	select {
+	case s := <-iq:
+		return s
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for scan")
+	}
+	return readers.Scan{}
+}
+
+func TestGenerateDecode(t *testing.T) {
+	tests := []string{
+		"**launch.random:snes",
+		"SNES/Super Mario World.sfc",
+		"12345",
+		"**input.keyboard:{f12}||**delay:500",
+		"Pokémon ✨",
+	}
+
+	for _, text := range tests {
+		img, err := png.Decode(bytes.NewReader(generate(t, text)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := Decode(img)
+		if err != nil {
+			t.Fatalf("%q: %s", text, err)
+		} else if got != text {
+			t.Fatalf("expected: %q, got: %q", text, got)
+		}
+	}
+}
+
+func TestGenerateEmpty(t *testing.T) {
+	if _, err := Generate("", 0); err == nil {
+		t.Fatalf("expected error for empty text")
+	}
+
+	if _, err := Generate("text", MaxScale+1); err == nil {
+		t.Fatalf("expected error for scale too large")
+	}
+}
+
+func TestDecodeNoCode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blank.png")
+	img, _ := png.Decode(bytes.NewReader(generate(t, "x")))
+	blank := bytes.Buffer{}
+	// a crop of the quiet zone has no code in it
+	sub := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}).SubImage(image.Rect(0, 0, 16, 16))
+	if err := png.Encode(&blank, sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, blank.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecodeFile(path); err != ErrNoCode {
+		t.Fatalf("expected no code error, got: %v", err)
+	}
+}
+
+func TestFolderReader(t *testing.T) {
+	dir := t.TempDir()
+
+	r := NewReader(nil)
+	iq := make(chan readers.Scan, 10)
+	err := r.Open(IdFolder+":"+dir, iq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	// non-images are ignored
+	err = os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("hello"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := filepath.Join(dir, "first.png")
+	err = os.WriteFile(first, generate(t, "**launch.random:snes"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := waitScan(t, iq)
+	if s.Token == nil || s.Token.Text != "**launch.random:snes" {
+		t.Fatalf("expected first token, got: %v", s.Token)
+	} else if s.Token.Type != TokenType || s.Source != IdFolder+":"+dir {
+		t.Fatalf("unexpected token type or source: %v", s)
+	}
+
+	// the newest image replaces the current token
+	second := filepath.Join(dir, "second.png")
+	err = os.WriteFile(second, generate(t, "**launch.random:genesis"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	err = os.Chtimes(second, future, future)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s = waitScan(t, iq)
+	if s.Token == nil || s.Token.Text != "**launch.random:genesis" {
+		t.Fatalf("expected second token, got: %v", s.Token)
+	}
+
+	// removing the images removes the token
+	_ = os.Remove(first)
+	_ = os.Remove(second)
+
+	s = waitScan(t, iq)
+	if s.Token != nil {
+		t.Fatalf("expected removal, got: %v", s.Token)
+	}
+}
+
+func TestFolderReaderMissing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "codes")
+	err := os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewReader(nil)
+	iq := make(chan readers.Scan, 10)
+	err = r.Open(IdFolder+":"+dir, iq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+
+	err = os.WriteFile(filepath.Join(dir, "code.png"), generate(t, "**launch.random:snes"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := waitScan(t, iq); s.Token == nil {
+		t.Fatalf("expected token, got: %v", s)
+	}
+
+	// a missing folder removes the token once, without reporting errors
+	err = os.RemoveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := waitScan(t, iq); s.Token != nil || s.Error != nil {
+		t.Fatalf("expected removal, got: %v", s)
+	}
+
+	select {
+	case s := <-iq:
+		t.Fatalf("unexpected scan while folder is missing: %v", s)
+	case <-time.After(time.Second):
+	}
+
+	// polling continues when the folder is back
+	err = os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "code.png"), generate(t, "**launch.random:nes"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := waitScan(t, iq); s.Token == nil || s.Token.Text != "**launch.random:nes" {
+		t.Fatalf("expected token after folder is back, got: %v", s)
+	}
+}
+
+func TestFolderReaderInvalid(t *testing.T) {
+	r := NewReader(nil)
+	iq := make(chan readers.Scan)
+
+	if err := r.Open(IdFolder+":"+filepath.Join(t.TempDir(), "missing"), iq); err == nil {
+		t.Fatalf("expected error for missing folder")
+	}
+
+	if err := r.Open("file:/tmp", iq); err == nil {
+		t.Fatalf("expected error for wrong reader id")
+	}
+}
+
+func TestReadFrames(t *testing.T) {
+	var frames bytes.Buffer
+	frames.Write(generate(t, "**launch.random:nes"))
+	frames.Write(generate(t, "**launch.random:nes"))
+	frames.Write(generate(t, "**launch.random:gba"))
+
+	r := NewReader(nil)
+	r.device = IdVideo + ":/dev/video0"
+	r.polling = true
+	iq := make(chan readers.Scan, 10)
+
+	r.readFrames(&frames, iq)
+
+	s := waitScan(t, iq)
+	if s.Token == nil || s.Token.Text != "**launch.random:nes" {
+		t.Fatalf("expected first token, got: %v", s.Token)
+	}
+
+	// repeated frames of the same code don't send it again
+	s = waitScan(t, iq)
+	if s.Token == nil || s.Token.Text != "**launch.random:gba" {
+		t.Fatalf("expected second token, got: %v", s.Token)
+	}
+
+	if r.Connected() {
+		t.Fatalf("expected reader to stop at end of stream")
+	}
+}
@@ -0,0 +1,109 @@
+package qr
+
+import (
+	"bufio"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Frames per second grabbed from the video device.
+	videoFps = "4"
+	// Codes can be missed in some frames, so a token is only removed when
+	// it hasn't been seen for this long.
+	videoRemovalTimeout = 2 * time.Second
+)
+
+// Grab frames from a video device with ffmpeg, which handles the V4L2
+// formats of different cameras, and read QR codes from them.
+func (r *Reader) openVideo(iq chan<- readers.Scan) error {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return errors.New("ffmpeg is required to read QR codes from video devices")
+	}
+
+	cmd := exec.Command(
+		ffmpeg,
+		"-loglevel", "error",
+		"-f", "v4l2",
+		"-i", r.path,
+		"-vf", "fps="+videoFps,
+		"-f", "image2pipe",
+		"-vcodec", "png",
+		"-",
+	)
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	r.cmd = cmd
+	r.polling = true
+	go r.readFrames(out, iq)
+
+	return nil
+}
+
+// Read a stream of PNG frames and send tokens for the QR codes in them.
+func (r *Reader) readFrames(frames io.Reader, iq chan<- readers.Scan) {
+	br := bufio.NewReader(frames)
+	var token *tokens.Token
+	var lastSeen time.Time
+
+	for r.polling {
+		// png reports the end of the stream as an unexpected EOF
+		_, err := br.Peek(1)
+		var img image.Image
+		if err == nil {
+			img, err = png.Decode(br)
+		}
+		if err != nil {
+			if r.polling && !errors.Is(err, io.EOF) {
+				log.Error().Err(err).Msg("failed to read video frame")
+			}
+			err = r.Close()
+			if err != nil {
+				log.Error().Err(err).Msg("failed to stop video capture")
+			}
+			break
+		}
+
+		text, err := Decode(img)
+		if err == nil {
+			lastSeen = time.Now()
+			if token == nil || token.Text != text {
+				token = r.newToken(text)
+				log.Debug().Msgf("new token: %s", token.Text)
+				iq <- readers.Scan{
+					Source: r.device,
+					Token:  token,
+				}
+			}
+		} else if !errors.Is(err, ErrNoCode) {
+			log.Debug().Err(err).Msg("error reading QR code from frame")
+		}
+
+		if token != nil && time.Since(lastSeen) > videoRemovalTimeout {
+			log.Debug().Msg("QR code out of view, removing token")
+			token = nil
+			iq <- readers.Scan{
+				Source: r.device,
+				Token:  nil,
+			}
+		}
+	}
+}
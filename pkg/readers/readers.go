@@ -1,15 +1,91 @@
 package readers
 
 import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/ndef"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/rs/zerolog/log"
 )
 
+var ErrWriteNotSupported = errors.New("writing not supported on this reader")
+
 type Scan struct {
 	Source string
 	Token  *tokens.Token
 	Error  error
 }
 
+// WriteRequest is a full NDEF message to write to a token.
+type WriteRequest struct {
+	Records []ndef.Record
+	// Read the token back after writing and check it matches.
+	Verify bool
+	// Make the token permanently read-only after writing. This can't be
+	// undone.
+	Lock bool
+}
+
+// TagInfo describes the token currently on a reader.
+type TagInfo struct {
+	UID  string
+	Type string
+	// Bytes available for an NDEF message, including TLV overhead.
+	Capacity int
+	// Bytes used by the current NDEF message, including TLV overhead.
+	Used     int
+	ReadOnly bool
+}
+
+// Operations a reader's polling loop can be asked to run on the next tag.
+const (
+	OpWrite = iota
+	OpErase
+	OpInspect
+)
+
+// TagRequest is an operation sent to a reader's polling loop.
+type TagRequest struct {
+	Op      int
+	Message WriteRequest
+	Result  chan TagResult
+}
+
+type TagResult struct {
+	Token *tokens.Token
+	Info  *TagInfo
+	Err   error
+}
+
+// SendTagRequest sends a request to a reader's polling loop and waits for
+// the result.
+func SendTagRequest(r Reader, requests chan<- TagRequest, req TagRequest) TagResult {
+	if !r.Connected() {
+		return TagResult{Err: errors.New("not connected")}
+	}
+
+	req.Result = make(chan TagResult)
+	requests <- req
+
+	res := <-req.Result
+	if res.Err != nil {
+		log.Error().Msgf("error writing to tag: %s", res.Err)
+	}
+
+	return res
+}
+
+// VerifyWrite checks the token read back from a tag after a write has the
+// NDEF message which was written to it.
+func VerifyWrite(t *tokens.Token, written []byte) error {
+	read, err := hex.DecodeString(t.Data)
+	if err != nil {
+		return err
+	}
+	return ndef.VerifyMessage(written, read)
+}
+
 type Reader interface {
 	// TODO: type? file, libnfc, etc.
 	// Ids returns the device string prefixes supported by this reader.
@@ -29,7 +105,22 @@ type Reader interface {
 	Connected() bool
 	// Info returns a string with information about the connected device.
 	Info() string
+	// Writable returns true if the reader supports writing, erasing and
+	// inspecting tokens.
+	Writable() bool
+	// MultiTag returns true if the reader can see more than one tag at a
+	// time and reports them as combination tokens.
+	MultiTag() bool
 	// Write sends a string to the device to be written to a token, if
 	// that device supports writing. Blocks until completion or timeout.
 	Write(string) (*tokens.Token, error)
+	// WriteMessage writes a full NDEF message to a token, optionally
+	// verifying and locking it. Blocks until completion or timeout.
+	WriteMessage(WriteRequest) (*tokens.Token, error)
+	// Erase formats a token with an empty NDEF message. Blocks until
+	// completion or timeout.
+	Erase() error
+	// Inspect waits for a token and reports its capacity and lock state
+	// without writing to it.
+	Inspect() (*TagInfo, error)
 }
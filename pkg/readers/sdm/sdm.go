@@ -0,0 +1,405 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package sdm verifies the Secure Unique NFC (SUN) messages generated by
+// NTAG 424 DNA tags using Secure Dynamic Messaging (SDM). Each tap of a tag
+// produces a URL with a new read counter and a MAC made with a key only the
+// tag and the deployment know, so a copied tag can be told apart from the
+// original.
+//
+// Reference: NXP AN12196 NTAG 424 DNA and NTAG 424 DNA TagTamper features
+// and hints.
+package sdm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	keySize = 16
+	uidSize = 7
+	ctrSize = 3
+	macSize = 8
+)
+
+var (
+	ErrNoMessage   = errors.New("no SUN message found")
+	ErrInvalidMAC  = errors.New("SUN message MAC is invalid")
+	ErrReplayed    = errors.New("SUN message read counter has already been used")
+	ErrUIDMismatch = errors.New("SUN message UID does not match tag")
+	ErrNoCounter   = errors.New("SUN message has no read counter")
+	ErrNoUID       = errors.New("tag UID unknown, SUN message UID not checked")
+)
+
+// Keys are the SDM application keys shared by a deployment of tags.
+type Keys struct {
+	// SDMMetaReadKey, used to decrypt the PICC data. Not needed when the
+	// UID and read counter are mirrored in plain text.
+	MetaRead []byte
+	// SDMFileReadKey, used to derive the session key for the MAC.
+	FileRead []byte
+}
+
+// ParseKeys decodes a pair of hex encoded AES-128 keys. The meta read key
+// may be empty.
+func ParseKeys(metaRead string, fileRead string) (Keys, error) {
+	var keys Keys
+
+	if metaRead != "" {
+		k, err := hex.DecodeString(metaRead)
+		if err != nil || len(k) != keySize {
+			return keys, fmt.Errorf("invalid meta read key: %s", metaRead)
+		}
+		keys.MetaRead = k
+	}
+
+	k, err := hex.DecodeString(fileRead)
+	if err != nil || len(k) != keySize {
+		return keys, fmt.Errorf("invalid file read key: %s", fileRead)
+	}
+	keys.FileRead = k
+
+	return keys, nil
+}
+
+// PICCData is the identity of the tag which generated a SUN message.
+type PICCData struct {
+	UID     []byte
+	Counter uint32
+	// False if the tag isn't configured to mirror its read counter, in
+	// which case replays can't be detected.
+	HasCounter bool
+}
+
+// Message is the SUN parameters from a tag's URL.
+type Message struct {
+	// Encrypted PICC data, or nil if the UID and read counter are mirrored
+	// in plain text.
+	EncPICCData []byte
+	UID         []byte
+	Counter     []byte
+	MAC         []byte
+	// Text from the first SDM parameter up to the MAC, which the MAC
+	// covers if the tag's SDMMACInputOffset is set before the MAC.
+	MACInput string
+}
+
+// Return the query value of the first parameter found and its offset in
+// the raw URL.
+func param(raw string, q url.Values, names ...string) (string, int) {
+	for _, name := range names {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		for _, sep := range []string{"?", "&"} {
+			if i := strings.Index(raw, sep+name+"="+v); i >= 0 {
+				return v, i + len(sep+name+"=")
+			}
+		}
+		return v, -1
+	}
+	return "", -1
+}
+
+func decodeParam(v string, size int) ([]byte, error) {
+	b, err := hex.DecodeString(v)
+	if err != nil || len(b) != size {
+		return nil, fmt.Errorf("invalid SUN parameter: %s", v)
+	}
+	return b, nil
+}
+
+// ParseMessage finds the SUN parameters in the text of a tag. Both the
+// parameter names used by AN12196 (picc_data, uid, ctr and cmac) and their
+// short forms (e and c) are supported.
+func ParseMessage(text string) (Message, error) {
+	var msg Message
+
+	raw := strings.TrimSpace(text)
+	u, err := url.Parse(raw)
+	if err != nil || u.RawQuery == "" {
+		return msg, ErrNoMessage
+	}
+	q := u.Query()
+
+	macHex, macAt := param(raw, q, "cmac", "c")
+	if macHex == "" {
+		return msg, ErrNoMessage
+	}
+	msg.MAC, err = decodeParam(macHex, macSize)
+	if err != nil {
+		return msg, err
+	}
+
+	start := -1
+	if v, at := param(raw, q, "picc_data", "e"); v != "" {
+		msg.EncPICCData, err = decodeParam(v, aes.BlockSize)
+		if err != nil {
+			return msg, err
+		}
+		start = at
+	} else {
+		v, at := param(raw, q, "uid")
+		if v == "" {
+			return msg, ErrNoMessage
+		}
+		msg.UID, err = decodeParam(v, uidSize)
+		if err != nil {
+			return msg, err
+		}
+		start = at
+
+		if v, _ := param(raw, q, "ctr"); v != "" {
+			msg.Counter, err = decodeParam(v, ctrSize)
+			if err != nil {
+				return msg, err
+			}
+		}
+	}
+
+	if start >= 0 && macAt > start {
+		msg.MACInput = raw[start:macAt]
+	}
+
+	return msg, nil
+}
+
+// DecryptPICCData decrypts the encrypted PICC data of a SUN message with the
+// SDMMetaReadKey.
+func DecryptPICCData(key []byte, enc []byte) (PICCData, error) {
+	var p PICCData
+
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return p, err
+	} else if len(enc) != aes.BlockSize {
+		return p, fmt.Errorf("invalid PICC data length: %d", len(enc))
+	}
+
+	data := make([]byte, aes.BlockSize)
+	cipher.NewCBCDecrypter(c, make([]byte, aes.BlockSize)).CryptBlocks(data, enc)
+
+	// PICCDataTag: bit 7 UID mirrored, bit 6 read counter mirrored and the
+	// lower nibble the UID length
+	tag := data[0]
+	if tag&0x80 == 0 || int(tag&0x0F) != uidSize {
+		return p, fmt.Errorf("invalid PICC data tag: %02x", tag)
+	}
+	p.UID = data[1 : 1+uidSize]
+
+	if tag&0x40 != 0 {
+		ctr := data[1+uidSize : 1+uidSize+ctrSize]
+		p.Counter = uint32(ctr[0]) | uint32(ctr[1])<<8 | uint32(ctr[2])<<16
+		p.HasCounter = true
+	}
+
+	return p, nil
+}
+
+// SessionMACKey derives the SesSDMFileReadMACKey for a tap from the
+// SDMFileReadKey.
+func SessionMACKey(fileRead []byte, p PICCData) ([]byte, error) {
+	sv2 := []byte{0x3C, 0xC3, 0x00, 0x01, 0x00, 0x80}
+	sv2 = append(sv2, p.UID...)
+	if p.HasCounter {
+		sv2 = append(sv2, byte(p.Counter), byte(p.Counter>>8), byte(p.Counter>>16))
+	}
+	if rem := len(sv2) % aes.BlockSize; rem != 0 {
+		sv2 = append(sv2, make([]byte, aes.BlockSize-rem)...)
+	}
+
+	return cmacAES(fileRead, sv2)
+}
+
+// TruncatedMAC returns the SDMMAC of some input, the even numbered bytes of
+// its CMAC counting from 1.
+func TruncatedMAC(sessionKey []byte, input []byte) ([]byte, error) {
+	full, err := cmacAES(sessionKey, input)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := make([]byte, 0, macSize)
+	for i := 1; i < len(full); i += 2 {
+		mac = append(mac, full[i])
+	}
+
+	return mac, nil
+}
+
+// Check a message against a deployment's keys.
+func verifyKeys(msg Message, keys Keys) (PICCData, bool) {
+	var p PICCData
+
+	if msg.EncPICCData != nil {
+		if keys.MetaRead == nil {
+			return p, false
+		}
+		var err error
+		p, err = DecryptPICCData(keys.MetaRead, msg.EncPICCData)
+		if err != nil {
+			return p, false
+		}
+	} else {
+		p.UID = msg.UID
+		if msg.Counter != nil {
+			// mirrored as big endian hex in the URL
+			p.Counter = uint32(msg.Counter[0])<<16 | uint32(msg.Counter[1])<<8 | uint32(msg.Counter[2])
+			p.HasCounter = true
+		}
+	}
+
+	sessionKey, err := SessionMACKey(keys.FileRead, p)
+	if err != nil {
+		return p, false
+	}
+
+	inputs := []string{""}
+	if msg.MACInput != "" {
+		inputs = append(inputs, msg.MACInput)
+	}
+
+	for _, input := range inputs {
+		mac, err := TruncatedMAC(sessionKey, []byte(input))
+		if err == nil && subtle.ConstantTimeCompare(mac, msg.MAC) == 1 {
+			return p, true
+		}
+	}
+
+	return p, false
+}
+
+// VerifyMessage checks the MAC of a SUN message against each set of keys
+// and returns the tag's PICC data if one matches.
+func VerifyMessage(msg Message, keys []Keys) (PICCData, error) {
+	for _, k := range keys {
+		p, ok := verifyKeys(msg, k)
+		if ok {
+			return p, nil
+		}
+	}
+	return PICCData{}, ErrInvalidMAC
+}
+
+// CounterStore persists the last read counter of each tag, so replayed
+// messages are still rejected after a restart.
+type CounterStore interface {
+	GetSdmCounter(uid string) (uint32, bool, error)
+	SetSdmCounter(uid string, counter uint32) error
+}
+
+// Verifier verifies SUN messages and rejects ones which have been seen
+// before, by keeping the last read counter of each tag. Counters are kept
+// in memory and in the store, if one is given.
+type Verifier struct {
+	mu       sync.Mutex
+	store    CounterStore
+	counters map[string]uint32
+}
+
+func NewVerifier(store CounterStore) *Verifier {
+	return &Verifier{
+		store:    store,
+		counters: make(map[string]uint32),
+	}
+}
+
+func (v *Verifier) lastCounter(id string) (uint32, bool, error) {
+	if last, ok := v.counters[id]; ok {
+		return last, true, nil
+	} else if v.store == nil {
+		return 0, false, nil
+	}
+	return v.store.GetSdmCounter(id)
+}
+
+func (v *Verifier) checkCounter(p PICCData) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	id := hex.EncodeToString(p.UID)
+	last, ok, err := v.lastCounter(id)
+	if err != nil {
+		return fmt.Errorf("error reading last read counter: %w", err)
+	} else if ok && p.Counter <= last {
+		return fmt.Errorf("%w: %d <= %d", ErrReplayed, p.Counter, last)
+	}
+
+	v.counters[id] = p.Counter
+	if v.store != nil {
+		err := v.store.SetSdmCounter(id, p.Counter)
+		if err != nil {
+			return fmt.Errorf("error saving read counter: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Verify checks the SUN message in the text of a tag. The UID in the
+// message must match the tag's uid, so a message copied to another tag is
+// rejected, and its read counter must be higher than the last one seen, so
+// a message can't be replayed.
+//
+// A message from a tag which doesn't mirror its read counter, or read
+// without the tag's UID, has a valid MAC but could be a copy. The PICC data
+// is returned with ErrNoCounter or ErrNoUID, so the caller can decide
+// whether to trust it.
+func (v *Verifier) Verify(keys []Keys, text string, uid []byte) (PICCData, error) {
+	msg, err := ParseMessage(text)
+	if err != nil {
+		return PICCData{}, err
+	}
+
+	p, err := VerifyMessage(msg, keys)
+	if err != nil {
+		return p, err
+	}
+
+	if len(uid) > 0 && !bytes.Equal(uid, p.UID) {
+		return p, fmt.Errorf("%w: %x != %x", ErrUIDMismatch, uid, p.UID)
+	}
+
+	if !p.HasCounter {
+		return p, ErrNoCounter
+	}
+
+	err = v.checkCounter(p)
+	if err != nil {
+		return p, err
+	}
+
+	if len(uid) == 0 {
+		return p, ErrNoUID
+	}
+
+	return p, nil
+}
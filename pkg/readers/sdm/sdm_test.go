@@ -0,0 +1,243 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package sdm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// RFC 4493 section 4 test vectors.
+func TestCMAC(t *testing.T) {
+	key := "2b7e151628aed2a6abf7158809cf4f3c"
+	msg := "6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710"
+
+	tests := []struct {
+		len  int
+		want string
+	}{
+		{len: 0, want: "bb1d6929e95937287fa37d129b756746"},
+		{len: 16, want: "070a16b46b4d4144f79bdd9dd04a287c"},
+		{len: 40, want: "dfa66747de9ae63030ca32611497c827"},
+		{len: 64, want: "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+
+	for _, tc := range tests {
+		got, err := cmacAES(mustHex(t, key), mustHex(t, msg)[:tc.len])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(got) != tc.want {
+			t.Errorf("length %d, expected: %s, got: %x", tc.len, tc.want, got)
+		}
+	}
+}
+
+var zeroKeys = Keys{
+	MetaRead: make([]byte, keySize),
+	FileRead: make([]byte, keySize),
+}
+
+// SUN messages from tags with all keys set to zero. The encrypted one is the
+// example from AN12196.
+const (
+	sunEncrypted = "https://choose.url.com/ntag424?e=EF963FF7828658A599F3041510671E88&c=94EED9EE65337086"
+	sunPlain     = "https://sdm.example.com/tag?uid=041E3C8A2D6B80&ctr=000006&cmac=4B00064004B0B3D3"
+)
+
+func TestVerifyMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		uid  string
+		ctr  uint32
+	}{
+		{name: "encrypted picc data", text: sunEncrypted, uid: "04de5f1eacc040", ctr: 61},
+		{name: "plain uid and counter", text: sunPlain, uid: "041e3c8a2d6b80", ctr: 6},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msg, err := ParseMessage(tc.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := VerifyMessage(msg, []Keys{zeroKeys})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(p.UID) != tc.uid || p.Counter != tc.ctr || !p.HasCounter {
+				t.Fatalf("unexpected picc data: %x %d", p.UID, p.Counter)
+			}
+
+			other := Keys{MetaRead: zeroKeys.MetaRead, FileRead: mustHex(t, "00000000000000000000000000000001")}
+			_, err = VerifyMessage(msg, []Keys{other})
+			if !errors.Is(err, ErrInvalidMAC) {
+				t.Fatalf("expected error %v, got: %v", ErrInvalidMAC, err)
+			}
+		})
+	}
+}
+
+func TestDecryptPICCData(t *testing.T) {
+	p, err := DecryptPICCData(make([]byte, keySize), mustHex(t, "EF963FF7828658A599F3041510671E88"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(p.UID) != "04de5f1eacc040" || p.Counter != 61 {
+		t.Fatalf("unexpected picc data: %x %d", p.UID, p.Counter)
+	}
+
+	_, err = DecryptPICCData(mustHex(t, "00000000000000000000000000000001"), mustHex(t, "EF963FF7828658A599F3041510671E88"))
+	if err == nil {
+		t.Fatal("expected error decrypting with wrong key")
+	}
+}
+
+func TestParseMessage(t *testing.T) {
+	invalid := []string{
+		"**launch.random:snes",
+		"https://zaparoo.com/",
+		"https://choose.url.com/ntag424?e=EF963FF7828658A599F3041510671E88",
+		"https://choose.url.com/ntag424?e=EF96&c=94EED9EE65337086",
+		"https://choose.url.com/ntag424?c=94EED9EE65337086",
+	}
+
+	for _, text := range invalid {
+		_, err := ParseMessage(text)
+		if err == nil {
+			t.Errorf("expected error parsing: %s", text)
+		}
+	}
+
+	msg, err := ParseMessage(sunPlain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.MACInput != "041E3C8A2D6B80&ctr=000006&cmac=" {
+		t.Fatalf("unexpected mac input: %q", msg.MACInput)
+	}
+}
+
+// counterStore is an in-memory CounterStore.
+type counterStore map[string]uint32
+
+func (c counterStore) GetSdmCounter(uid string) (uint32, bool, error) {
+	ctr, ok := c[uid]
+	return ctr, ok, nil
+}
+
+func (c counterStore) SetSdmCounter(uid string, counter uint32) error {
+	c[uid] = counter
+	return nil
+}
+
+func TestVerifier(t *testing.T) {
+	store := counterStore{}
+	v := NewVerifier(store)
+	keys := []Keys{zeroKeys}
+	uid := mustHex(t, "04de5f1eacc040")
+
+	_, err := v.Verify(keys, sunEncrypted, uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = v.Verify(keys, sunEncrypted, uid)
+	if !errors.Is(err, ErrReplayed) {
+		t.Fatalf("expected error %v, got: %v", ErrReplayed, err)
+	}
+
+	// counters are kept in the store between verifiers
+	if store["04de5f1eacc040"] != 0x3d {
+		t.Fatalf("unexpected stored counter: %v", store)
+	}
+	_, err = NewVerifier(store).Verify(keys, sunEncrypted, uid)
+	if !errors.Is(err, ErrReplayed) {
+		t.Fatalf("expected error %v after restart, got: %v", ErrReplayed, err)
+	}
+
+	_, err = v.Verify(keys, sunPlain, uid)
+	if !errors.Is(err, ErrUIDMismatch) {
+		t.Fatalf("expected error %v, got: %v", ErrUIDMismatch, err)
+	}
+
+	_, err = v.Verify(nil, sunPlain, nil)
+	if !errors.Is(err, ErrInvalidMAC) {
+		t.Fatalf("expected error %v, got: %v", ErrInvalidMAC, err)
+	}
+}
+
+func TestVerifierUnknownUID(t *testing.T) {
+	v := NewVerifier(nil)
+	keys := []Keys{zeroKeys}
+
+	// the MAC is valid but the message could have been copied to another
+	// tag, and the counter is still used up
+	p, err := v.Verify(keys, sunPlain, nil)
+	if !errors.Is(err, ErrNoUID) {
+		t.Fatalf("expected error %v, got: %v", ErrNoUID, err)
+	} else if p.Counter != 6 {
+		t.Fatalf("expected PICC data with the counter, got: %+v", p)
+	}
+
+	_, err = v.Verify(keys, sunPlain, mustHex(t, "041e3c8a2d6b80"))
+	if !errors.Is(err, ErrReplayed) {
+		t.Fatalf("expected error %v, got: %v", ErrReplayed, err)
+	}
+}
+
+func TestVerifierNoCounter(t *testing.T) {
+	uid := mustHex(t, "041e3c8a2d6b80")
+	key, err := SessionMACKey(zeroKeys.FileRead, PICCData{UID: uid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac, err := TruncatedMAC(key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := fmt.Sprintf("https://sdm.example.com/tag?uid=%X&cmac=%X", uid, mac)
+
+	v := NewVerifier(nil)
+	for i := 0; i < 2; i++ {
+		p, err := v.Verify([]Keys{zeroKeys}, text, uid)
+		if !errors.Is(err, ErrNoCounter) {
+			t.Fatalf("expected error %v, got: %v", ErrNoCounter, err)
+		} else if !bytes.Equal(p.UID, uid) || p.HasCounter {
+			t.Fatalf("unexpected PICC data: %+v", p)
+		}
+	}
+}
@@ -200,6 +200,26 @@ func (r *SimpleSerialReader) Info() string {
 	return r.path
 }
 
+func (r *SimpleSerialReader) Writable() bool {
+	return false
+}
+
+func (r *SimpleSerialReader) MultiTag() bool {
+	return false
+}
+
 func (r *SimpleSerialReader) Write(text string) (*tokens.Token, error) {
 	return nil, errors.New("writing not supported on this reader")
 }
+
+func (r *SimpleSerialReader) WriteMessage(_ readers.WriteRequest) (*tokens.Token, error) {
+	return nil, readers.ErrWriteNotSupported
+}
+
+func (r *SimpleSerialReader) Erase() error {
+	return readers.ErrWriteNotSupported
+}
+
+func (r *SimpleSerialReader) Inspect() (*readers.TagInfo, error) {
+	return nil, readers.ErrWriteNotSupported
+}
@@ -0,0 +1,199 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package toys
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/assets"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+// AmiiboDatabase is the names of Amiibo figures and their fields, keyed by
+// lowercase hex strings of the fields, e.g. "0x0000".
+type AmiiboDatabase struct {
+	AmiiboSeries map[string]string `json:"amiibo_series"`
+	Amiibos      map[string]struct {
+		Name string `json:"name"`
+	} `json:"amiibos"`
+	Characters map[string]string `json:"characters"`
+	GameSeries map[string]string `json:"game_series"`
+	Types      map[string]string `json:"types"`
+}
+
+var amiiboDb struct {
+	mu sync.RWMutex
+	db *AmiiboDatabase
+}
+
+func parseAmiiboDatabase(data []byte) (*AmiiboDatabase, error) {
+	var db AmiiboDatabase
+	err := json.Unmarshal(data, &db)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing amiibo database: %w", err)
+	}
+
+	// keys are compared as lowercase hex
+	for _, m := range []map[string]string{db.AmiiboSeries, db.Characters, db.GameSeries, db.Types} {
+		for k, v := range m {
+			if lk := strings.ToLower(k); lk != k {
+				delete(m, k)
+				m[lk] = v
+			}
+		}
+	}
+	for k, v := range db.Amiibos {
+		if lk := strings.ToLower(k); lk != k {
+			delete(db.Amiibos, k)
+			db.Amiibos[lk] = v
+		}
+	}
+
+	return &db, nil
+}
+
+func getAmiiboDatabase() (*AmiiboDatabase, error) {
+	amiiboDb.mu.RLock()
+	db := amiiboDb.db
+	amiiboDb.mu.RUnlock()
+	if db != nil {
+		return db, nil
+	}
+
+	data, err := assets.Toys.ReadFile("toys/amiibo.json")
+	if err != nil {
+		return nil, err
+	}
+
+	db, err = parseAmiiboDatabase(data)
+	if err != nil {
+		return nil, err
+	}
+
+	amiiboDb.mu.Lock()
+	amiiboDb.db = db
+	amiiboDb.mu.Unlock()
+
+	return db, nil
+}
+
+// LoadAmiiboDatabase replaces the bundled Amiibo database with a file in
+// the AmiiboAPI format, such as a full copy of its amiibo.json.
+func LoadAmiiboDatabase(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	db, err := parseAmiiboDatabase(data)
+	if err != nil {
+		return err
+	}
+
+	amiiboDb.mu.Lock()
+	amiiboDb.db = db
+	amiiboDb.mu.Unlock()
+
+	return nil
+}
+
+// Amiibo is the fields of an 8 byte Amiibo identifier.
+//
+// Reference: https://www.3dbrew.org/wiki/Amiibo
+type Amiibo struct {
+	// Game series and character, the first 3 hex digits are the game
+	// series.
+	Character uint16
+	Variant   byte
+	Type      byte
+	Model     uint16
+	Series    byte
+}
+
+// ParseAmiiboID splits an Amiibo identifier, read from page 0x15, into its
+// fields.
+func ParseAmiiboID(id []byte) (Amiibo, error) {
+	if len(id) < 8 {
+		return Amiibo{}, fmt.Errorf("invalid amiibo id length: %d", len(id))
+	}
+
+	return Amiibo{
+		Character: binary.BigEndian.Uint16(id[0:2]),
+		Variant:   id[2],
+		Type:      id[3],
+		Model:     binary.BigEndian.Uint16(id[4:6]),
+		Series:    id[6],
+	}, nil
+}
+
+// DecodeAmiibo returns the toy for an Amiibo identifier, named using the
+// Amiibo database. Unknown fields are left empty.
+func DecodeAmiibo(id []byte) (Toy, error) {
+	a, err := ParseAmiiboID(id)
+	if err != nil {
+		return Toy{}, err
+	}
+
+	db, err := getAmiiboDatabase()
+	if err != nil {
+		return Toy{}, err
+	}
+
+	charId := fmt.Sprintf("0x%04x", a.Character)
+	toy := Toy{
+		Type:        tokens.TypeAmiibo,
+		ID:          fmt.Sprintf("0x%x", id[:8]),
+		Character:   db.Characters[charId],
+		CharacterID: charId,
+		Kind:        db.Types[fmt.Sprintf("0x%02x", a.Type)],
+	}
+
+	toy.Name = toy.Character
+	if v, ok := db.Amiibos[toy.ID]; ok && v.Name != "" {
+		toy.Name = v.Name
+	}
+
+	for _, s := range []string{
+		db.GameSeries[charId[:5]],
+		db.AmiiboSeries[fmt.Sprintf("0x%02x", a.Series)],
+	} {
+		if s != "" && !containsFold(toy.Series, s) {
+			toy.Series = append(toy.Series, s)
+		}
+	}
+
+	return toy, nil
+}
+
+func containsFold(ss []string, s string) bool {
+	for _, v := range ss {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
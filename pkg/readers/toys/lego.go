@@ -0,0 +1,148 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package toys
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"sync"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/assets"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+const teaDelta = 0x9E3779B9
+
+// LegoDatabase is the names of LEGO Dimensions characters and vehicles,
+// keyed by their decimal ID.
+type LegoDatabase struct {
+	Characters map[string]string `json:"characters"`
+	Vehicles   map[string]string `json:"vehicles"`
+}
+
+var legoDb = sync.OnceValues(func() (*LegoDatabase, error) {
+	data, err := assets.Toys.ReadFile("toys/lego_dimensions.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var db LegoDatabase
+	err = json.Unmarshal(data, &db)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing lego dimensions database: %w", err)
+	}
+
+	return &db, nil
+})
+
+// Hash the tag's UID with the start of a copyright string, giving one word
+// of a key. The last byte used is replaced with 0xAA.
+func legoScramble(uid []byte, count int) uint32 {
+	base := make([]byte, 32)
+	copy(base, uid[:7])
+	copy(base[7:], "(c) Copyright LEGO 2014")
+	base[30] = 0xAA
+	base[31] = 0xAA
+	base[count*4-1] = 0xAA
+
+	var v uint32
+	for i := 0; i < count; i++ {
+		b := binary.LittleEndian.Uint32(base[i*4:])
+		v = b + bits.RotateLeft32(v, -25) + bits.RotateLeft32(v, -10) - v
+	}
+
+	return v
+}
+
+// Generate the TEA key which encrypts a character tag's ID from its UID.
+func legoKey(uid []byte) [4]uint32 {
+	return [4]uint32{
+		legoScramble(uid, 3),
+		legoScramble(uid, 4),
+		legoScramble(uid, 5),
+		legoScramble(uid, 6),
+	}
+}
+
+func teaDecrypt(data []byte, key [4]uint32) []byte {
+	v0 := binary.LittleEndian.Uint32(data[0:4])
+	v1 := binary.LittleEndian.Uint32(data[4:8])
+	sum := uint32(0xC6EF3720) // delta * 32
+
+	for i := 0; i < 32; i++ {
+		v1 -= ((v0 << 4) + key[2]) ^ (v0 + sum) ^ ((v0 >> 5) + key[3])
+		v0 -= ((v1 << 4) + key[0]) ^ (v1 + sum) ^ ((v1 >> 5) + key[1])
+		sum -= teaDelta
+	}
+
+	out := make([]byte, 8)
+	binary.LittleEndian.PutUint32(out[0:4], v0)
+	binary.LittleEndian.PutUint32(out[4:8], v1)
+	return out
+}
+
+// DecodeLegoDimensions returns the toy for a LEGO Dimensions tag from its
+// UID and at least 3 pages of data from page 0x24. Vehicle tags store their
+// ID in plain text, and character tags store it encrypted with a key made
+// from the UID.
+func DecodeLegoDimensions(uid []byte, data []byte) (Toy, error) {
+	if len(uid) != 7 {
+		return Toy{}, fmt.Errorf("invalid lego dimensions uid length: %d", len(uid))
+	} else if len(data) < 12 {
+		return Toy{}, fmt.Errorf("invalid lego dimensions data length: %d", len(data))
+	}
+
+	db, err := legoDb()
+	if err != nil {
+		return Toy{}, err
+	}
+
+	toy := Toy{Type: tokens.TypeLegoDimensions}
+
+	// page 0x26 marks vehicles and gadgets
+	if data[8] == 0x00 && data[9] == 0x01 {
+		id := binary.LittleEndian.Uint16(data[0:2])
+		toy.ID = strconv.Itoa(int(id))
+		toy.Kind = "Vehicle"
+		toy.Name = db.Vehicles[toy.ID]
+		return toy, nil
+	}
+
+	dec := teaDecrypt(data[0:8], legoKey(uid))
+	id := binary.LittleEndian.Uint32(dec[0:4])
+	// the ID is stored twice, so a mismatch means the tag isn't a character
+	// or the data is corrupt
+	if id != binary.LittleEndian.Uint32(dec[4:8]) {
+		return Toy{}, fmt.Errorf("invalid lego dimensions character data: %x", data[0:8])
+	}
+
+	toy.ID = strconv.Itoa(int(id))
+	toy.Kind = "Character"
+	toy.Character = db.Characters[toy.ID]
+	toy.CharacterID = toy.ID
+	toy.Name = toy.Character
+
+	return toy, nil
+}
@@ -0,0 +1,108 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+// Package toys decodes the figures of toys-to-life games read from NFC tags,
+// so they can be mapped by character or series instead of their raw data.
+package toys
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+var ErrNotToy = errors.New("token is not a toys-to-life figure")
+
+// Can be identified by matching address 0x09-0x0F
+var amiiboMatcher = []byte{
+	0x48, 0x0F, 0xE0,
+	0xF1, 0x10, 0xFF, 0xEE}
+
+// Can be identified by matching blocks 0x03-0x07
+// https://github.com/RfidResearchGroup/proxmark3/blob/master/client/src/cmdhfmfu.c
+var legoDimensionsMatcher = []byte{
+	//0xE1, 0x10, 0x12, 0x00, // Skip as we never read 0x03
+	0x01, 0x03, 0xA0, 0x0C,
+	0x34, 0x03, 0x13, 0xD1,
+	0x01, 0x0F, 0x54, 0x02,
+	0x65, 0x6E}
+
+const (
+	// AmiiboIDPage is the first page of the 8 byte Amiibo identifier.
+	AmiiboIDPage = 0x15
+	// LegoDataPage is the first page of the LEGO Dimensions character or
+	// vehicle data.
+	LegoDataPage = 0x24
+)
+
+// IsAmiibo returns true if the first 4 pages of an NTAG are from an Amiibo.
+func IsAmiibo(header []byte) bool {
+	return len(header) >= 16 && bytes.Equal(header[9:16], amiiboMatcher)
+}
+
+// IsLegoDimensions returns true if the data area of an NTAG, from page 4, is
+// from a LEGO Dimensions toy tag.
+func IsLegoDimensions(data []byte) bool {
+	return len(data) >= len(legoDimensionsMatcher) &&
+		bytes.Equal(data[:len(legoDimensionsMatcher)], legoDimensionsMatcher)
+}
+
+// Toy is a decoded toys-to-life figure.
+type Toy struct {
+	// Token type the toy was read from.
+	Type string
+	ID   string
+	// Name of the figure, or the character name if the figure isn't known.
+	Name        string
+	Character   string
+	CharacterID string
+	// Kind of figure, e.g. "Figure" or "Card" for Amiibo and "Character" or
+	// "Vehicle" for LEGO Dimensions.
+	Kind string
+	// Names of the game series and product lines the figure is part of.
+	Series []string
+}
+
+// Decode returns the toy read from a token. Amiibo tokens hold the Amiibo
+// identifier in their data, and LEGO Dimensions tokens hold the data pages
+// which are decrypted with the tag's UID.
+func Decode(t tokens.Token) (Toy, error) {
+	data, err := hex.DecodeString(t.Data)
+	if err != nil {
+		return Toy{}, fmt.Errorf("invalid token data: %w", err)
+	}
+
+	switch t.Type {
+	case tokens.TypeAmiibo:
+		return DecodeAmiibo(data)
+	case tokens.TypeLegoDimensions:
+		uid, err := hex.DecodeString(t.UID)
+		if err != nil {
+			return Toy{}, fmt.Errorf("invalid token uid: %w", err)
+		}
+		return DecodeLegoDimensions(uid, data)
+	default:
+		return Toy{}, ErrNotToy
+	}
+}
@@ -0,0 +1,264 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package toys
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/assets"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+func TestDecodeAmiibo(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want Toy
+	}{
+		{
+			name: "known amiibo",
+			data: "0000000000000002",
+			want: Toy{
+				Type:        tokens.TypeAmiibo,
+				ID:          "0x0000000000000002",
+				Name:        "Mario",
+				Character:   "Mario",
+				CharacterID: "0x0000",
+				Kind:        "Figure",
+				Series:      []string{"Super Mario", "Super Smash Bros."},
+			},
+		},
+		{
+			name: "known character",
+			data: "0101000100350502",
+			want: Toy{
+				Type:        tokens.TypeAmiibo,
+				ID:          "0x0101000100350502",
+				Name:        "Zelda",
+				Character:   "Zelda",
+				CharacterID: "0x0101",
+				Kind:        "Card",
+				Series:      []string{"The Legend of Zelda", "Animal Crossing"},
+			},
+		},
+		{
+			name: "unknown",
+			data: "ffff00ff00000f02",
+			want: Toy{
+				Type:        tokens.TypeAmiibo,
+				ID:          "0xffff00ff00000f02",
+				CharacterID: "0xffff",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Decode(tokens.Token{Type: tokens.TypeAmiibo, Data: tc.data})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBundledDatabases(t *testing.T) {
+	data, err := assets.Toys.ReadFile("toys/amiibo.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adb, err := parseAmiiboDatabase(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	amiibos := map[string]string{
+		"0x0000000000000002": "Mario",
+	}
+	for id, want := range amiibos {
+		if got := adb.Amiibos[id].Name; got != want {
+			t.Fatalf("amiibo %s: expected: %q, got: %q", id, want, got)
+		}
+	}
+
+	characters := map[string]string{
+		"0x0000": "Mario",
+		"0x0001": "Luigi",
+		"0x0100": "Link",
+		"0x0101": "Zelda",
+	}
+	for id, want := range characters {
+		if got := adb.Characters[id]; got != want {
+			t.Fatalf("amiibo character %s: expected: %q, got: %q", id, want, got)
+		}
+	}
+
+	if got := adb.AmiiboSeries["0x00"]; got != "Super Smash Bros." {
+		t.Fatalf("amiibo series 0x00: expected: %q, got: %q", "Super Smash Bros.", got)
+	}
+
+	ldb, err := legoDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lego := map[string]string{
+		"1": "Batman",
+		"2": "Gandalf",
+		"3": "Wyldstyle",
+	}
+	for id, want := range lego {
+		if got := ldb.Characters[id]; got != want {
+			t.Fatalf("lego character %s: expected: %q, got: %q", id, want, got)
+		}
+	}
+}
+
+func TestLoadAmiiboDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "amiibo.json")
+	err := os.WriteFile(path, []byte(`{
+		"amiibos": {"0x0000000000000002": {"name": "Mario (Smash)"}},
+		"characters": {"0x0000": "Mario"},
+		"game_series": {"0x000": "Super Mario"},
+		"amiibo_series": {"0x00": "Super Smash Bros."},
+		"types": {"0x00": "Figure"}
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = LoadAmiiboDatabase(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		amiiboDb.mu.Lock()
+		amiiboDb.db = nil
+		amiiboDb.mu.Unlock()
+	}()
+
+	toy, err := DecodeAmiibo([]byte{0, 0, 0, 0, 0, 0, 0, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toy.Name != "Mario (Smash)" || toy.Character != "Mario" {
+		t.Fatalf("unexpected toy: %+v", toy)
+	}
+}
+
+func teaEncrypt(data []byte, key [4]uint32) []byte {
+	v0 := binary.LittleEndian.Uint32(data[0:4])
+	v1 := binary.LittleEndian.Uint32(data[4:8])
+	var sum uint32
+
+	for i := 0; i < 32; i++ {
+		sum += teaDelta
+		v0 += ((v1 << 4) + key[0]) ^ (v1 + sum) ^ ((v1 >> 5) + key[1])
+		v1 += ((v0 << 4) + key[2]) ^ (v0 + sum) ^ ((v0 >> 5) + key[3])
+	}
+
+	out := make([]byte, 8)
+	binary.LittleEndian.PutUint32(out[0:4], v0)
+	binary.LittleEndian.PutUint32(out[4:8], v1)
+	return out
+}
+
+func TestDecodeLegoDimensions(t *testing.T) {
+	uid, _ := hex.DecodeString("04a1b2c3d4e580")
+
+	plain := make([]byte, 8)
+	binary.LittleEndian.PutUint32(plain[0:4], 1)
+	binary.LittleEndian.PutUint32(plain[4:8], 1)
+	character := append(teaEncrypt(plain, legoKey(uid)), make([]byte, 8)...)
+
+	toy, err := Decode(tokens.Token{
+		Type: tokens.TypeLegoDimensions,
+		UID:  hex.EncodeToString(uid),
+		Data: hex.EncodeToString(character),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toy.ID != "1" || toy.Character != "Batman" || toy.Kind != "Character" {
+		t.Fatalf("unexpected toy: %+v", toy)
+	}
+
+	// same data on another tag doesn't decrypt
+	_, err = DecodeLegoDimensions([]byte{0x04, 1, 2, 3, 4, 5, 6}, character)
+	if err == nil {
+		t.Fatal("expected error decoding with wrong uid")
+	}
+
+	vehicle := []byte{0xE8, 0x03, 0, 0, 0, 0, 0, 0, 0x00, 0x01, 0x00, 0x00}
+	toy, err = DecodeLegoDimensions(uid, vehicle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toy.ID != "1000" || toy.Kind != "Vehicle" {
+		t.Fatalf("unexpected toy: %+v", toy)
+	}
+}
+
+func TestLegoScramble(t *testing.T) {
+	uid := []byte{0x04, 0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0x80}
+	// the UID and copyright string, with the last byte used replaced
+	base := append(append([]byte{}, uid...), "(c) Copyright LEGO 2014"...)
+	base[11] = 0xAA
+
+	var want uint32
+	for i := 0; i < 3; i++ {
+		b := binary.LittleEndian.Uint32(base[i*4:])
+		want = b + (want>>25 | want<<7) + (want>>10 | want<<22) - want
+	}
+
+	if got := legoScramble(uid, 3); got != want {
+		t.Fatalf("expected: %08x, got: %08x", want, got)
+	}
+}
+
+func TestMatchers(t *testing.T) {
+	amiibo, _ := hex.DecodeString("04d2a8fc8a5a4d80b7480fe0f110ffee")
+	if !IsAmiibo(amiibo) {
+		t.Error("expected amiibo header to match")
+	}
+	if IsAmiibo(amiibo[:12]) {
+		t.Error("expected short header not to match")
+	}
+
+	lego, _ := hex.DecodeString("0103a00c340313d1010f5402656e0000")
+	if !IsLegoDimensions(lego) {
+		t.Error("expected lego dimensions data to match")
+	}
+
+	_, err := Decode(tokens.Token{Type: tokens.TypeNTAG, Data: "00"})
+	if err != ErrNotToy {
+		t.Errorf("expected error %v, got: %v", ErrNotToy, err)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/rs/zerolog/log"
+)
+
+const historyPruneInterval = 1 * time.Hour
+
+// Periodically remove history entries outside the configured retention
+// limits, starting immediately on service start.
+func pruneHistory(
+	cfg *config.Instance,
+	st *state.State,
+	db *database.Database,
+) {
+	lastPrune := time.Time{}
+
+	for !st.ShouldStopService() {
+		if time.Since(lastPrune) >= historyPruneInterval {
+			lastPrune = time.Now()
+
+			r := cfg.HistoryRetention()
+			removed, err := db.PruneHistory(
+				time.Duration(r.MaxAge)*24*time.Hour,
+				r.MaxEntries,
+			)
+			if err != nil {
+				log.Error().Err(err).Msg("error pruning history")
+			} else if removed > 0 {
+				log.Info().Msgf("pruned %d history entries", removed)
+			}
+		}
+
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func historyEntry(t tokens.Token, res launchResult, err error) database.HistoryEntry {
+	origin, device := database.HistoryOrigin(t.Source, t.Remote)
+
+	he := database.HistoryEntry{
+		Time:      t.ScanTime,
+		Type:      t.Type,
+		UID:       t.UID,
+		Text:      t.Text,
+		Data:      t.Data,
+		Source:    t.Source,
+		Device:    device,
+		Origin:    origin,
+		Remote:    t.Remote,
+		Mapping:   res.mapping,
+		ZapScript: res.zapScript,
+		SystemId:  res.systemId,
+		MediaPath: res.mediaPath,
+		Success:   err == nil,
+	}
+
+	if err != nil {
+		he.Error = err.Error()
+	}
+
+	return he
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+func TestHistoryEntry(t *testing.T) {
+	pl := &testPlatform{dataDir: t.TempDir()}
+	db := testDb(t, pl)
+	scanTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		token tokens.Token
+		res   launchResult
+		err   error
+		want  database.HistoryEntry
+	}{
+		{
+			name: "reader mapping launch",
+			token: tokens.Token{
+				Type:     "NTAG215",
+				UID:      "04aabbcc",
+				Text:     "mario",
+				ScanTime: scanTime,
+				Source:   "libnfc:pn532_uart:/dev/ttyUSB0",
+			},
+			res: launchResult{
+				mapping:   "7",
+				zapScript: "**launch:snes/mario.sfc",
+				systemId:  "SNES",
+				mediaPath: "/media/snes/mario.sfc",
+			},
+			want: database.HistoryEntry{
+				Id:        1,
+				Time:      scanTime,
+				Type:      "NTAG215",
+				UID:       "04aabbcc",
+				Text:      "mario",
+				Source:    "libnfc:pn532_uart:/dev/ttyUSB0",
+				Device:    "libnfc:pn532_uart:/dev/ttyUSB0",
+				Origin:    database.HistoryOriginReader,
+				Mapping:   "7",
+				ZapScript: "**launch:snes/mario.sfc",
+				SystemId:  "SNES",
+				MediaPath: "/media/snes/mario.sfc",
+				Success:   true,
+			},
+		},
+		{
+			name: "api launch error",
+			token: tokens.Token{
+				Text:     "**launch.random:n64",
+				ScanTime: scanTime,
+				Source:   tokens.SourceApi,
+				Remote:   true,
+			},
+			res: launchResult{zapScript: "**launch.random:n64"},
+			err: errors.New("no media found"),
+			want: database.HistoryEntry{
+				Id:        2,
+				Time:      scanTime,
+				Text:      "**launch.random:n64",
+				Source:    tokens.SourceApi,
+				Origin:    database.HistoryOriginApi,
+				Remote:    true,
+				ZapScript: "**launch.random:n64",
+				Success:   false,
+				Error:     "no media found",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.AddHistory(historyEntry(tt.token, tt.res, tt.err))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries, _, err := db.GetHistory(database.HistoryFilter{}, 0, 1)
+			if err != nil {
+				t.Fatal(err)
+			} else if len(entries) != 1 {
+				t.Fatalf("expected: %d entries, got: %d", 1, len(entries))
+			}
+
+			got := entries[0]
+			if !got.Time.Equal(tt.want.Time) {
+				t.Fatalf("expected: %s, got: %s", tt.want.Time, got.Time)
+			}
+			got.Time = tt.want.Time
+			if got != tt.want {
+				t.Fatalf("expected: %+v, got: %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -22,7 +22,9 @@ along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
 package service
 
 import (
+	"fmt"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/toys"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 	"regexp"
 	"strings"
@@ -88,6 +90,124 @@ func checkMappingData(m database.Mapping, t tokens.Token) bool {
 	return false
 }
 
+// Match a toys-to-life figure's character or series names and IDs. Exact
+// matches ignore case, so mappings can use names as they're written in the
+// database.
+func checkMappingToy(m database.Mapping, t tokens.Token) bool {
+	toy, err := toys.Decode(t)
+	if err != nil {
+		return false
+	}
+
+	var values []string
+	if m.Type == database.MappingTypeCharacter {
+		values = []string{toy.Character, toy.CharacterID}
+	} else {
+		values = toy.Series
+	}
+
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+
+		switch {
+		case m.Match == database.MatchTypeExact:
+			if strings.EqualFold(v, m.Pattern) {
+				return true
+			}
+		case m.Match == database.MatchTypePartial:
+			if strings.Contains(strings.ToLower(v), strings.ToLower(m.Pattern)) {
+				return true
+			}
+		case m.Match == database.MatchTypeRegex:
+			re, err := regexp.Compile(m.Pattern)
+			if err != nil {
+				log.Error().Err(err).Msgf("error compiling regex")
+				return false
+			}
+			if re.MatchString(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Match the tags in a combination token. Each element of the pattern must
+// match the UID or text of a different tag in the combination. Exact matches
+// need every tag in the combination to be matched, partial matches allow
+// other tags to be on the reader too. Regex patterns are matched against
+// the combination's UID.
+func checkMappingCombination(m database.Mapping, t tokens.Token) bool {
+	if len(t.Tokens) == 0 {
+		return false
+	}
+
+	if m.Match == database.MatchTypeRegex {
+		re, err := regexp.Compile(m.Pattern)
+		if err != nil {
+			log.Error().Err(err).Msgf("error compiling regex")
+			return false
+		}
+		return re.MatchString(database.NormalizeUid(t.UID))
+	}
+
+	var elements []string
+	for _, e := range strings.Split(m.Pattern, tokens.CombinationSep) {
+		if e = strings.TrimSpace(e); e != "" {
+			elements = append(elements, e)
+		}
+	}
+
+	if len(elements) == 0 {
+		return false
+	} else if m.Match == database.MatchTypeExact && len(elements) != len(t.Tokens) {
+		return false
+	} else if len(elements) > len(t.Tokens) {
+		return false
+	}
+
+	used := make([]bool, len(t.Tokens))
+	for _, e := range elements {
+		found := false
+		for i, member := range t.Tokens {
+			if used[i] {
+				continue
+			}
+			if database.NormalizeUid(member.UID) == database.NormalizeUid(e) || member.Text == e {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns true if any enabled db or config mapping matches combinations of
+// tags.
+func hasCombinationMappings(cfg *config.Instance, db *database.Database) bool {
+	ms, err := db.GetEnabledMappings()
+	if err != nil {
+		log.Error().Err(err).Msgf("error getting db mappings")
+	}
+	ms = append(ms, mappingsFromConfig(cfg)...)
+
+	for _, m := range ms {
+		if m.Type == database.MappingTypeCombination {
+			return true
+		}
+	}
+
+	return false
+}
+
 func isCfgRegex(s string) bool {
 	return len(s) > 2 && s[0] == '/' && s[len(s)-1] == '/'
 }
@@ -96,15 +216,21 @@ func mappingsFromConfig(cfg *config.Instance) []database.Mapping {
 	var mappings []database.Mapping
 	cfgMappings := cfg.Mappings()
 
-	for _, m := range cfgMappings {
+	for i, m := range cfgMappings {
 		var dbm database.Mapping
+		dbm.Id = fmt.Sprintf("config:%d", i)
 		dbm.Enabled = true
 		dbm.Override = m.ZapScript
+		dbm.VerifiedOnly = m.VerifiedOnly
 
 		if m.TokenKey == "data" {
 			dbm.Type = database.MappingTypeData
 		} else if m.TokenKey == "value" {
 			dbm.Type = database.MappingTypeText
+		} else if m.TokenKey == database.MappingTypeCharacter ||
+			m.TokenKey == database.MappingTypeSeries ||
+			m.TokenKey == database.MappingTypeCombination {
+			dbm.Type = m.TokenKey
 		} else {
 			dbm.Type = database.MappingTypeUID
 		}
@@ -128,39 +254,66 @@ func mappingsFromConfig(cfg *config.Instance) []database.Mapping {
 	return mappings
 }
 
-func getMapping(cfg *config.Instance, db *database.Database, pl platforms.Platform, token tokens.Token) (string, bool) {
-	// TODO: need a way to identify the source of a match so it can be
-	// reported and debugged by the user if there's issues
-
+// Returns the ZapScript override of the first matching mapping and an ID
+// identifying where the mapping came from, so it can be reported back to
+// the user.
+func getMapping(
+	cfg *config.Instance,
+	db *database.Database,
+	pl platforms.Platform,
+	token tokens.Token,
+) (string, string, bool) {
 	// check db mappings
 	ms, err := db.GetEnabledMappings()
 	if err != nil {
 		log.Error().Err(err).Msgf("error getting db mappings")
 	}
+	for i := range ms {
+		ms[i].Id = "db:" + ms[i].Id
+	}
 
 	// load config mappings after
 	ms = append(ms, mappingsFromConfig(cfg)...)
 
 	for _, m := range ms {
+		if m.VerifiedOnly && !token.Verified {
+			continue
+		}
+
 		switch {
 		case m.Type == database.MappingTypeUID:
 			if checkMappingUid(m, token) {
 				log.Info().Msg("launching with db/cfg uid match override")
-				return m.Override, true
+				return m.Override, m.Id, true
 			}
 		case m.Type == database.MappingTypeText:
 			if checkMappingText(m, token) {
 				log.Info().Msg("launching with db/cfg text match override")
-				return m.Override, true
+				return m.Override, m.Id, true
 			}
 		case m.Type == database.MappingTypeData:
 			if checkMappingData(m, token) {
 				log.Info().Msg("launching with db/cfg data match override")
-				return m.Override, true
+				return m.Override, m.Id, true
+			}
+		case m.Type == database.MappingTypeCharacter || m.Type == database.MappingTypeSeries:
+			if checkMappingToy(m, token) {
+				log.Info().Msgf("launching with db/cfg %s match override", m.Type)
+				return m.Override, m.Id, true
+			}
+		case m.Type == database.MappingTypeCombination:
+			if checkMappingCombination(m, token) {
+				log.Info().Msg("launching with db/cfg combination match override")
+				return m.Override, m.Id, true
 			}
 		}
 	}
 
 	// check platform mappings
-	return pl.LookupMapping(token)
+	text, ok := pl.LookupMapping(token)
+	if ok {
+		return text, "platform", true
+	}
+
+	return "", "", false
 }
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+func TestCheckMappingCombination(t *testing.T) {
+	combo := tokens.NewCombination([]tokens.Token{
+		{UID: "04:AA:BB:CC", Text: "**launch.system:snes"},
+		{UID: "04ddeeff", Text: "mario"},
+		{UID: "04112233", Text: ""},
+	})
+
+	tests := []struct {
+		name    string
+		match   string
+		pattern string
+		token   tokens.Token
+		want    bool
+	}{
+		{name: "exact uids", match: database.MatchTypeExact, pattern: "04112233+04aabbcc+04DDEEFF", token: combo, want: true},
+		{name: "exact any order with spaces", match: database.MatchTypeExact, pattern: " 04ddeeff + 04:aa:bb:cc+04112233 ", token: combo, want: true},
+		{name: "exact uid and text", match: database.MatchTypeExact, pattern: "mario+04aabbcc+04112233", token: combo, want: true},
+		{name: "exact missing member", match: database.MatchTypeExact, pattern: "04aabbcc+04ddeeff", token: combo, want: false},
+		{name: "exact extra member", match: database.MatchTypeExact, pattern: "04aabbcc+04ddeeff+04112233+04000000", token: combo, want: false},
+		{name: "exact repeated element", match: database.MatchTypeExact, pattern: "mario+04ddeeff+04112233", token: combo, want: false},
+		{name: "partial subset", match: database.MatchTypePartial, pattern: "04aabbcc+mario", token: combo, want: true},
+		{name: "partial single", match: database.MatchTypePartial, pattern: "04112233", token: combo, want: true},
+		{name: "partial unknown", match: database.MatchTypePartial, pattern: "04aabbcc+luigi", token: combo, want: false},
+		{name: "partial too many", match: database.MatchTypePartial, pattern: "a+b+c+d", token: combo, want: false},
+		{name: "empty pattern", match: database.MatchTypePartial, pattern: " + ", token: combo, want: false},
+		{name: "regex on uid", match: database.MatchTypeRegex, pattern: "^04112233\\+04aabbcc\\+", token: combo, want: true},
+		{name: "regex no match", match: database.MatchTypeRegex, pattern: "^04ddeeff", token: combo, want: false},
+		{name: "invalid regex", match: database.MatchTypeRegex, pattern: "(", token: combo, want: false},
+		{name: "not a combination", match: database.MatchTypePartial, pattern: "04aabbcc", token: tokens.Token{UID: "04aabbcc"}, want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := database.Mapping{
+				Type:    database.MappingTypeCombination,
+				Match:   tc.match,
+				Pattern: tc.pattern,
+			}
+			if got := checkMappingCombination(m, tc.token); got != tc.want {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// testReader implements only the parts of a reader used by the service
+// tests, calling anything else will panic.
+type testReader struct {
+	readers.Reader
+	multiTag bool
+}
+
+func (r *testReader) MultiTag() bool {
+	return r.multiTag
+}
+
+func (r *testReader) Close() error {
+	return nil
+}
+
+func TestWarnSingleTag(t *testing.T) {
+	pl := &testPlatform{dataDir: t.TempDir()}
+	db := testDb(t, pl)
+	t.Setenv(config.CfgEnv, "")
+	cfg, err := config.NewConfig(t.TempDir(), config.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st, ns := state.NewState(pl)
+	go func() {
+		for range ns {
+		}
+	}()
+	st.SetReader("libnfc", &testReader{multiTag: true})
+	st.SetReader("pn532", &testReader{})
+
+	warned := make(map[string]struct{})
+
+	// no combination mappings
+	warnSingleTag(cfg, st, db, "pn532", warned)
+	if len(warned) != 0 {
+		t.Fatalf("expected no warnings, got: %v", warned)
+	}
+
+	err = db.AddMapping(database.Mapping{
+		Id:       "1",
+		Enabled:  true,
+		Type:     database.MappingTypeCombination,
+		Match:    database.MatchTypeExact,
+		Pattern:  "04aabbcc+04ddeeff",
+		Override: "**launch.random:snes",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range []string{"libnfc", "pn532", "missing"} {
+		warnSingleTag(cfg, st, db, source, warned)
+	}
+	if _, ok := warned["pn532"]; !ok || len(warned) != 1 {
+		t.Fatalf("expected only single tag reader to be warned, got: %v", warned)
+	}
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/methods"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database/gamesdb"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// wait for changes to settle before indexing, copying a large file
+	// creates a steady stream of write events
+	mediaWatchDebounce = 5 * time.Second
+	// minimum time between the start of each index batch
+	mediaWatchInterval = 30 * time.Second
+	// index anyway if changes haven't settled after this long
+	mediaWatchMaxDelay = 2 * time.Minute
+)
+
+// mediaWatcher watches the media folders of all systems and keeps the media
+// index up to date as files are added and removed.
+type mediaWatcher struct {
+	pl      platforms.Platform
+	cfg     *config.Instance
+	watcher *fsnotify.Watcher
+	roots   []string
+	// folders which had changes since the last index batch
+	pending      map[string]struct{}
+	firstPending time.Time
+	lastEvent    time.Time
+	lastIndex    time.Time
+}
+
+// Add a watch to a folder and every folder inside it.
+func (mw *mediaWatcher) addTree(root string) {
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		err = mw.watcher.Add(path)
+		if err != nil {
+			log.Warn().Err(err).Msgf("error watching folder: %s", path)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Warn().Err(err).Msgf("error walking folder: %s", root)
+	}
+}
+
+// Watch the root folders for new system folders and every existing system
+// media folder, recursively.
+func (mw *mediaWatcher) addAll() {
+	mw.roots = mw.pl.RootDirs(mw.cfg)
+	for _, root := range mw.roots {
+		if _, err := os.Stat(root); err != nil {
+			continue
+		}
+
+		err := mw.watcher.Add(root)
+		if err != nil {
+			log.Warn().Err(err).Msgf("error watching folder: %s", root)
+		}
+	}
+
+	systemPaths := gamesdb.GetSystemPaths(mw.cfg, mw.pl, mw.roots, gamesdb.AllSystems())
+	for _, sp := range systemPaths {
+		mw.addTree(sp.Path)
+	}
+
+	log.Info().Msgf("watching %d media folders", len(mw.watcher.WatchList()))
+}
+
+func (mw *mediaWatcher) isRoot(path string) bool {
+	for _, root := range mw.roots {
+		if filepath.Clean(root) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (mw *mediaWatcher) handleEvent(event fsnotify.Event) {
+	if event.Has(fsnotify.Chmod) && !event.Has(fsnotify.Create|fsnotify.Write|fsnotify.Remove|fsnotify.Rename) {
+		return
+	}
+
+	mw.lastEvent = time.Now()
+	if len(mw.pending) == 0 {
+		mw.firstPending = mw.lastEvent
+	}
+	dir := filepath.Dir(event.Name)
+
+	if event.Has(fsnotify.Create) {
+		info, err := os.Stat(event.Name)
+		if err == nil && info.IsDir() {
+			// new folders need watching too, and may already contain files
+			// if they were moved in
+			mw.addTree(event.Name)
+			if mw.isRoot(dir) {
+				// a new system folder, scan it directly
+				mw.pending[event.Name] = struct{}{}
+				return
+			}
+		}
+	}
+
+	if mw.isRoot(dir) {
+		// files directly in a root folder don't belong to any system, but
+		// a system folder may have been removed
+		if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+			mw.pending[event.Name] = struct{}{}
+		}
+		return
+	}
+
+	mw.pending[dir] = struct{}{}
+}
+
+// Return the folders to index for all pending changes, or nil to index
+// every system. Returns false if none of the changes are inside a media
+// folder.
+func (mw *mediaWatcher) pendingPaths(systems []gamesdb.System) ([]string, bool) {
+	var paths []string
+	var removed []string
+	for p := range mw.pending {
+		if _, err := os.Stat(p); err != nil {
+			removed = append(removed, p)
+		} else {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	if len(removed) > 0 {
+		// a removed folder can't be mapped to a system with its path, so
+		// fall back to an incremental index of everything
+		log.Info().Msgf("media folders removed, updating all systems: %v", removed)
+		return nil, true
+	}
+
+	// only pass paths which are inside a media folder of some system
+	systemPaths := gamesdb.GetSystemPaths(mw.cfg, mw.pl, mw.roots, systems)
+	var valid []string
+	for _, p := range paths {
+		for _, sp := range systemPaths {
+			if utils.PathWithin(p, sp.Path) || utils.PathWithin(sp.Path, p) {
+				valid = append(valid, p)
+				break
+			}
+		}
+	}
+
+	return valid, len(valid) > 0
+}
+
+// Start an index of all folders with changes. If an index is already running
+// the changes are kept for the next batch.
+func (mw *mediaWatcher) indexPending(st *state.State) {
+	if methods.IndexInstance.IsIndexing() {
+		return
+	}
+
+	systems := gamesdb.AllSystems()
+	paths, ok := mw.pendingPaths(systems)
+	if !ok {
+		mw.pending = make(map[string]struct{})
+		return
+	}
+
+	log.Info().Msgf("media folders changed, updating index: %v", paths)
+	methods.IndexInstance.GenerateIndex(
+		mw.pl,
+		mw.cfg,
+		st.Notifications,
+		gamesdb.IndexOptions{
+			Systems: systems,
+			Paths:   paths,
+		},
+	)
+
+	mw.pending = make(map[string]struct{})
+	mw.lastIndex = time.Now()
+}
+
+// Check if pending changes should be indexed now. Changes must have settled
+// or been waiting too long, and the last batch must not have been too
+// recent.
+func (mw *mediaWatcher) ready(now time.Time) bool {
+	if len(mw.pending) == 0 || now.Sub(mw.lastIndex) < mediaWatchInterval {
+		return false
+	}
+
+	return now.Sub(mw.lastEvent) >= mediaWatchDebounce ||
+		now.Sub(mw.firstPending) >= mediaWatchMaxDelay
+}
+
+// Watch media folders for changes and update the media index in batches,
+// sending the usual media indexing notifications for each batch.
+func watchMedia(
+	pl platforms.Platform,
+	cfg *config.Instance,
+	st *state.State,
+) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Error().Err(err).Msg("error creating media watcher")
+		return
+	}
+	defer func(watcher *fsnotify.Watcher) {
+		err := watcher.Close()
+		if err != nil {
+			log.Warn().Err(err).Msg("error closing media watcher")
+		}
+	}(watcher)
+
+	mw := &mediaWatcher{
+		pl:      pl,
+		cfg:     cfg,
+		watcher: watcher,
+		pending: make(map[string]struct{}),
+	}
+	mw.addAll()
+
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for !st.ShouldStopService() {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			mw.handleEvent(event)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Error().Err(err).Msg("error in media watcher")
+		case <-ticker.C:
+			if mw.ready(time.Now()) {
+				mw.indexPending(st)
+			}
+		}
+	}
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/database/gamesdb"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Create a media watcher for a root folder with an existing SNES folder. NES
+// has a launcher but no folder yet.
+func newTestWatcher(t *testing.T) (*mediaWatcher, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	mkdir(t, filepath.Join(root, "snes"))
+
+	pl := &testPlatform{
+		roots: []string{root},
+		launchers: []platforms.Launcher{
+			{SystemId: gamesdb.SystemSNES, Folders: []string{"snes"}},
+			{SystemId: gamesdb.SystemNES, Folders: []string{"nes"}},
+		},
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = watcher.Close() })
+
+	return &mediaWatcher{
+		pl:      pl,
+		watcher: watcher,
+		roots:   pl.roots,
+		pending: make(map[string]struct{}),
+	}, root
+}
+
+func mkdir(t *testing.T, path string) {
+	t.Helper()
+	err := os.MkdirAll(path, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func touch(t *testing.T, path string) {
+	t.Helper()
+	err := os.WriteFile(path, []byte{}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func pendingList(mw *mediaWatcher) []string {
+	ps := make([]string, 0, len(mw.pending))
+	for p := range mw.pending {
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+func TestMediaWatcherHandleEvent(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, root string)
+		path  string
+		op    fsnotify.Op
+		want  []string
+	}{
+		{
+			name: "chmod only",
+			setup: func(t *testing.T, root string) {
+				touch(t, filepath.Join(root, "snes", "a.sfc"))
+			},
+			path: "snes/a.sfc",
+			op:   fsnotify.Chmod,
+			want: []string{},
+		},
+		{
+			name: "file in system folder",
+			setup: func(t *testing.T, root string) {
+				touch(t, filepath.Join(root, "snes", "a.sfc"))
+			},
+			path: "snes/a.sfc",
+			op:   fsnotify.Create,
+			want: []string{"snes"},
+		},
+		{
+			name: "file removed from system folder",
+			path: "snes/a.sfc",
+			op:   fsnotify.Remove,
+			want: []string{"snes"},
+		},
+		{
+			name: "file created in root",
+			setup: func(t *testing.T, root string) {
+				touch(t, filepath.Join(root, "readme.txt"))
+			},
+			path: "readme.txt",
+			op:   fsnotify.Create,
+			want: []string{},
+		},
+		{
+			name: "system folder removed from root",
+			path: "gba",
+			op:   fsnotify.Remove,
+			want: []string{"gba"},
+		},
+		{
+			name: "system folder renamed in root",
+			path: "gba",
+			op:   fsnotify.Rename,
+			want: []string{"gba"},
+		},
+		{
+			name: "new system folder",
+			setup: func(t *testing.T, root string) {
+				mkdir(t, filepath.Join(root, "nes", "sub"))
+				touch(t, filepath.Join(root, "nes", "sub", "a.nes"))
+			},
+			path: "nes",
+			op:   fsnotify.Create,
+			want: []string{"nes"},
+		},
+		{
+			name: "new folder in system folder",
+			setup: func(t *testing.T, root string) {
+				mkdir(t, filepath.Join(root, "snes", "hacks"))
+			},
+			path: "snes/hacks",
+			op:   fsnotify.Create,
+			want: []string{"snes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, root := newTestWatcher(t)
+			if tt.setup != nil {
+				tt.setup(t, root)
+			}
+
+			mw.handleEvent(fsnotify.Event{
+				Name: filepath.Join(root, filepath.FromSlash(tt.path)),
+				Op:   tt.op,
+			})
+
+			want := make([]string, len(tt.want))
+			for i, p := range tt.want {
+				want[i] = filepath.Join(root, filepath.FromSlash(p))
+			}
+
+			got := pendingList(mw)
+			if !slices.Equal(got, want) {
+				t.Fatalf("expected: %v, got: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestMediaWatcherWatchesNewFolders(t *testing.T) {
+	mw, root := newTestWatcher(t)
+	mkdir(t, filepath.Join(root, "nes", "sub"))
+
+	mw.handleEvent(fsnotify.Event{
+		Name: filepath.Join(root, "nes"),
+		Op:   fsnotify.Create,
+	})
+
+	watched := mw.watcher.WatchList()
+	for _, p := range []string{"nes", filepath.Join("nes", "sub")} {
+		if !slices.Contains(watched, filepath.Join(root, p)) {
+			t.Fatalf("expected %q to be watched, got: %v", p, watched)
+		}
+	}
+}
+
+func TestMediaWatcherPendingTimes(t *testing.T) {
+	mw, root := newTestWatcher(t)
+	touch(t, filepath.Join(root, "snes", "a.sfc"))
+	ev := fsnotify.Event{Name: filepath.Join(root, "snes", "a.sfc"), Op: fsnotify.Write}
+
+	mw.handleEvent(ev)
+	first := mw.firstPending
+	if first.IsZero() || !mw.lastEvent.Equal(first) {
+		t.Fatalf("expected first and last event to match, got: %s, %s", first, mw.lastEvent)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	mw.handleEvent(ev)
+	if !mw.firstPending.Equal(first) {
+		t.Fatalf("expected first pending to be kept: %s, got: %s", first, mw.firstPending)
+	} else if !mw.lastEvent.After(first) {
+		t.Fatalf("expected last event after %s, got: %s", first, mw.lastEvent)
+	}
+}
+
+func TestMediaWatcherPendingPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, root string)
+		pending []string
+		want    []string
+		wantOk  bool
+	}{
+		{
+			name:    "system folder",
+			pending: []string{"snes"},
+			want:    []string{"snes"},
+			wantOk:  true,
+		},
+		{
+			name: "inside system folder",
+			setup: func(t *testing.T, root string) {
+				mkdir(t, filepath.Join(root, "snes", "hacks"))
+			},
+			pending: []string{"snes/hacks", "snes"},
+			want:    []string{"snes", "snes/hacks"},
+			wantOk:  true,
+		},
+		{
+			name: "not a system folder",
+			setup: func(t *testing.T, root string) {
+				mkdir(t, filepath.Join(root, "other"))
+			},
+			pending: []string{"other"},
+			wantOk:  false,
+		},
+		{
+			name: "mixed system and other folders",
+			setup: func(t *testing.T, root string) {
+				mkdir(t, filepath.Join(root, "other"))
+			},
+			pending: []string{"other", "snes"},
+			want:    []string{"snes"},
+			wantOk:  true,
+		},
+		{
+			name: "new system folder",
+			setup: func(t *testing.T, root string) {
+				mkdir(t, filepath.Join(root, "nes"))
+			},
+			pending: []string{"nes"},
+			want:    []string{"nes"},
+			wantOk:  true,
+		},
+		{
+			name:    "removed folder indexes everything",
+			pending: []string{"snes", "gba"},
+			want:    nil,
+			wantOk:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw, root := newTestWatcher(t)
+			if tt.setup != nil {
+				tt.setup(t, root)
+			}
+			for _, p := range tt.pending {
+				mw.pending[filepath.Join(root, filepath.FromSlash(p))] = struct{}{}
+			}
+
+			var want []string
+			for _, p := range tt.want {
+				want = append(want, filepath.Join(root, filepath.FromSlash(p)))
+			}
+
+			got, ok := mw.pendingPaths(gamesdb.AllSystems())
+			if ok != tt.wantOk {
+				t.Fatalf("expected: %v, got: %v", tt.wantOk, ok)
+			} else if !slices.Equal(got, want) {
+				t.Fatalf("expected: %v, got: %v", want, got)
+			}
+		})
+	}
+}
+
+func TestMediaWatcherReady(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name         string
+		pending      bool
+		firstPending time.Duration
+		lastEvent    time.Duration
+		lastIndex    time.Duration
+		want         bool
+	}{
+		{
+			name:         "nothing pending",
+			firstPending: time.Hour,
+			lastEvent:    time.Hour,
+			lastIndex:    time.Hour,
+			want:         false,
+		},
+		{
+			name:         "settled",
+			pending:      true,
+			firstPending: mediaWatchDebounce,
+			lastEvent:    mediaWatchDebounce,
+			lastIndex:    time.Hour,
+			want:         true,
+		},
+		{
+			name:         "still changing",
+			pending:      true,
+			firstPending: 10 * time.Second,
+			lastEvent:    time.Second,
+			lastIndex:    time.Hour,
+			want:         false,
+		},
+		{
+			name:         "changing past max delay",
+			pending:      true,
+			firstPending: mediaWatchMaxDelay,
+			lastEvent:    time.Second,
+			lastIndex:    time.Hour,
+			want:         true,
+		},
+		{
+			name:         "settled but indexed recently",
+			pending:      true,
+			firstPending: time.Minute,
+			lastEvent:    time.Minute,
+			lastIndex:    mediaWatchInterval - time.Second,
+			want:         false,
+		},
+		{
+			name:         "past max delay but indexed recently",
+			pending:      true,
+			firstPending: mediaWatchMaxDelay,
+			lastEvent:    time.Second,
+			lastIndex:    time.Second,
+			want:         false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mw := &mediaWatcher{
+				pending:      make(map[string]struct{}),
+				firstPending: now.Add(-tt.firstPending),
+				lastEvent:    now.Add(-tt.lastEvent),
+				lastIndex:    now.Add(-tt.lastIndex),
+			}
+			if tt.pending {
+				mw.pending["snes"] = struct{}{}
+			}
+
+			if got := mw.ready(now); got != tt.want {
+				t.Fatalf("expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
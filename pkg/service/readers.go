@@ -7,8 +7,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/sdm"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
 	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
 	"github.com/rs/zerolog/log"
@@ -120,10 +122,33 @@ func connectReaders(
 	return nil
 }
 
+// Log once per reader if combination mappings are set up but the reader can
+// only see one tag at a time, so they will never match its scans.
+func warnSingleTag(
+	cfg *config.Instance,
+	st *state.State,
+	db *database.Database,
+	source string,
+	warned map[string]struct{},
+) {
+	if _, ok := warned[source]; ok {
+		return
+	}
+
+	r, ok := st.GetReader(source)
+	if !ok || r == nil || r.MultiTag() || !hasCombinationMappings(cfg, db) {
+		return
+	}
+
+	warned[source] = struct{}{}
+	log.Warn().Msgf("reader only reads one tag at a time, combination mappings will not match: %s", source)
+}
+
 func readerManager(
 	pl platforms.Platform,
 	cfg *config.Instance,
 	st *state.State,
+	db *database.Database,
 	itq chan<- tokens.Token,
 	lsq chan *tokens.Token,
 ) {
@@ -134,6 +159,8 @@ func readerManager(
 
 	var prevToken *tokens.Token
 	var exitTimer *time.Timer
+	singleTagWarned := make(map[string]struct{})
+	verifier := sdm.NewVerifier(db)
 
 	readerTicker := time.NewTicker(1 * time.Second)
 	stopService := make(chan bool)
@@ -232,10 +259,21 @@ func readerManager(
 			continue
 		}
 
+		// tags were taken off a reader with a combination on it, the rest
+		// of the combination is still there but has already been launched
+		if scan != nil && prevToken != nil && scan.IsSubsetOf(*prevToken) {
+			log.Info().Msgf("token removed from combination, remaining: %v", scan)
+			prevToken = scan
+			st.SetActiveCard(*scan)
+			continue
+		}
+
 		prevToken = scan
 
 		if scan != nil {
 			log.Info().Msgf("new token scanned: %v", scan)
+			warnSingleTag(cfg, st, db, scan.Source, singleTagWarned)
+			verifyToken(cfg, verifier, scan)
 			st.SetActiveCard(*scan)
 
 			if !st.CanRunZapScript() {
@@ -263,6 +301,13 @@ func readerManager(
 				st.SetWroteToken(nil)
 			}
 
+			if cfg.ReadersScan().VerifiedOnly && !scan.Verified {
+				log.Warn().Msg("skipping token, only verified tokens are allowed")
+				playFail()
+				lastError = time.Now()
+				continue
+			}
+
 			log.Info().Msgf("sending token: %v", scan)
 			pl.PlaySuccessSound(cfg)
 			itq <- *scan
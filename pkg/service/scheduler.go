@@ -0,0 +1,161 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/schedules"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/rs/zerolog/log"
+)
+
+const schedulerTick = 1 * time.Second
+
+type activeSchedule struct {
+	key       string
+	cron      *schedules.Cron
+	idle      time.Duration
+	zapScript string
+}
+
+// Collect all enabled schedules from the config file and user database.
+// Config schedules are keyed by their index, database schedules by their ID.
+func loadSchedules(cfg *config.Instance, db *database.Database) []activeSchedule {
+	var ss []activeSchedule
+
+	add := func(key string, cronExpr string, idle int, zapScript string) {
+		s := activeSchedule{
+			key:       key,
+			idle:      time.Duration(idle) * time.Minute,
+			zapScript: zapScript,
+		}
+
+		if cronExpr != "" {
+			c, err := schedules.ParseCron(cronExpr)
+			if err != nil {
+				log.Error().Err(err).Msgf("invalid schedule: %s", key)
+				return
+			}
+			s.cron = c
+		} else if idle <= 0 {
+			log.Error().Msgf("schedule has no cron or idle value: %s", key)
+			return
+		}
+
+		ss = append(ss, s)
+	}
+
+	for i, e := range cfg.Schedules() {
+		add(fmt.Sprintf("config:%d", i), e.Cron, e.Idle, e.ZapScript)
+	}
+
+	dbs, err := db.GetAllSchedules()
+	if err != nil {
+		log.Error().Err(err).Msg("error getting schedules")
+	}
+	for _, s := range dbs {
+		if s.Enabled {
+			add("db:"+s.Id, s.Cron, s.Idle, s.ZapScript)
+		}
+	}
+
+	return ss
+}
+
+func runSchedule(
+	st *state.State,
+	itq chan<- tokens.Token,
+	stop <-chan struct{},
+	s activeSchedule,
+) {
+	if !st.CanRunZapScript() {
+		log.Debug().Msgf("zapscript disabled, skipping schedule: %s", s.key)
+		return
+	}
+
+	log.Info().Msgf("running schedule: %s", s.key)
+	t := tokens.Token{
+		UID:      "schedule:" + s.key,
+		Text:     s.zapScript,
+		ScanTime: time.Now(),
+		Remote:   true,
+		Source:   tokens.SourceScheduler,
+	}
+
+	select {
+	case itq <- t:
+	case <-stop:
+		log.Debug().Msgf("service stopping, dropping schedule: %s", s.key)
+	}
+}
+
+// Run scheduled ZapScript. Cron schedules run once at the start of each
+// matching minute, idle schedules run once after the given amount of time
+// has passed without a token being scanned and won't run again until
+// there's been another scan.
+//
+// The scheduler exits when stop is closed and closes done once it's
+// finished, after which it's safe to close the token queue.
+func runScheduler(
+	cfg *config.Instance,
+	st *state.State,
+	db *database.Database,
+	itq chan<- tokens.Token,
+	stop <-chan struct{},
+	done chan<- struct{},
+) {
+	defer close(done)
+
+	started := time.Now()
+	lastMinute := started.Truncate(time.Minute)
+	ss := loadSchedules(cfg, db)
+	idleRan := make(map[string]time.Time)
+
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		now := time.Now()
+		minute := now.Truncate(time.Minute)
+
+		if minute.After(lastMinute) {
+			lastMinute = minute
+			ss = loadSchedules(cfg, db)
+
+			for _, s := range ss {
+				if s.cron != nil && s.cron.Matches(now) {
+					runSchedule(st, itq, stop, s)
+				}
+			}
+		}
+
+		lastActivity := st.GetLastScanned().ScanTime
+		if lastActivity.IsZero() {
+			lastActivity = started
+		}
+
+		for _, s := range ss {
+			if s.cron != nil {
+				continue
+			}
+
+			if idleRan[s.key].Equal(lastActivity) {
+				continue
+			}
+
+			if now.Sub(lastActivity) >= s.idle {
+				idleRan[s.key] = lastActivity
+				runSchedule(st, itq, stop, s)
+			}
+		}
+	}
+}
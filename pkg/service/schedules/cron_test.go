@@ -0,0 +1,148 @@
+package schedules
+
+import (
+	"testing"
+	"time"
+)
+
+func date(s string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02 15:04", s, time.Local)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"a-5 * * * *",
+		"1-b * * * *",
+		"mon * * * *",
+		"@never",
+	}
+
+	for _, expr := range tests {
+		if _, err := ParseCron(expr); err == nil {
+			t.Fatalf("expected error for: %q", expr)
+		}
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// 2024-01-01 is a monday
+	tests := []struct {
+		expr  string
+		match []string
+		miss  []string
+	}{
+		{
+			expr:  "* * * * *",
+			match: []string{"2024-01-01 00:00", "2024-06-15 13:37"},
+		},
+		{
+			expr:  "30 8 * * *",
+			match: []string{"2024-01-01 08:30", "2024-12-31 08:30"},
+			miss:  []string{"2024-01-01 08:31", "2024-01-01 09:30"},
+		},
+		{
+			expr:  "*/15 * * * *",
+			match: []string{"2024-01-01 10:00", "2024-01-01 10:15", "2024-01-01 10:45"},
+			miss:  []string{"2024-01-01 10:10", "2024-01-01 10:59"},
+		},
+		{
+			expr:  "5/20 * * * *",
+			match: []string{"2024-01-01 10:05", "2024-01-01 10:25", "2024-01-01 10:45"},
+			miss:  []string{"2024-01-01 10:00", "2024-01-01 10:20"},
+		},
+		{
+			expr:  "0 9-17/4 * * *",
+			match: []string{"2024-01-01 09:00", "2024-01-01 13:00", "2024-01-01 17:00"},
+			miss:  []string{"2024-01-01 10:00", "2024-01-01 21:00"},
+		},
+		{
+			expr:  "0,30 12 * * *",
+			match: []string{"2024-01-01 12:00", "2024-01-01 12:30"},
+			miss:  []string{"2024-01-01 12:15"},
+		},
+		{
+			// weekdays only
+			expr:  "0 7 * * 1-5",
+			match: []string{"2024-01-01 07:00", "2024-01-05 07:00"},
+			miss:  []string{"2024-01-06 07:00", "2024-01-07 07:00"},
+		},
+		{
+			// both 0 and 7 are sunday
+			expr:  "0 0 * * 7",
+			match: []string{"2024-01-07 00:00"},
+			miss:  []string{"2024-01-06 00:00"},
+		},
+		{
+			expr:  "0 0 1 1 *",
+			match: []string{"2024-01-01 00:00", "2025-01-01 00:00"},
+			miss:  []string{"2024-02-01 00:00"},
+		},
+		{
+			// restricted day of month and day of week match either
+			expr:  "0 0 15 * 5",
+			match: []string{"2024-01-15 00:00", "2024-01-05 00:00"},
+			miss:  []string{"2024-01-16 00:00"},
+		},
+		{
+			expr:  "@hourly",
+			match: []string{"2024-01-01 01:00", "2024-01-01 23:00"},
+			miss:  []string{"2024-01-01 01:01"},
+		},
+		{
+			expr:  "@weekly",
+			match: []string{"2024-01-07 00:00"},
+			miss:  []string{"2024-01-01 00:00"},
+		},
+		{
+			expr:  " @DAILY ",
+			match: []string{"2024-03-10 00:00"},
+			miss:  []string{"2024-03-10 12:00"},
+		},
+	}
+
+	for _, tc := range tests {
+		c, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: %s", tc.expr, err)
+		}
+
+		for _, s := range tc.match {
+			if !c.Matches(date(s)) {
+				t.Fatalf("%q: expected match: %s", tc.expr, s)
+			}
+		}
+
+		for _, s := range tc.miss {
+			if c.Matches(date(s)) {
+				t.Fatalf("%q: expected no match: %s", tc.expr, s)
+			}
+		}
+	}
+}
+
+func TestCronMatchesSeconds(t *testing.T) {
+	c, err := ParseCron("30 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.Matches(date("2024-01-01 08:30").Add(59 * time.Second)) {
+		t.Fatalf("expected any second in a matching minute to match")
+	}
+}
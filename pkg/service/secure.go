@@ -0,0 +1,89 @@
+/*
+Zaparoo Core
+Copyright (C) 2023 Gareth Jones
+Copyright (C) 2023, 2024 Callan Barrett
+
+This file is part of Zaparoo Core.
+
+Zaparoo Core is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Zaparoo Core is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package service
+
+import (
+	"encoding/hex"
+	"errors"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/sdm"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+	"github.com/rs/zerolog/log"
+)
+
+func sdmKeysFromConfig(cfg *config.Instance) []sdm.Keys {
+	var keys []sdm.Keys
+	for _, k := range cfg.SdmKeys() {
+		sk, err := sdm.ParseKeys(k.MetaReadKey, k.FileReadKey)
+		if err != nil {
+			log.Error().Err(err).Msg("invalid sdm keys")
+			continue
+		}
+		keys = append(keys, sk)
+	}
+	return keys
+}
+
+// Check the SUN message of a token scanned from an NTAG 424 DNA tag and mark
+// it as verified if it's valid for one of the configured keys. Messages
+// which could be replayed are only verified if the config allows them.
+func verifyToken(cfg *config.Instance, v *sdm.Verifier, t *tokens.Token) {
+	t.Verified = false
+
+	// a combination is only verified if every tag in it is
+	if len(t.Tokens) > 0 {
+		t.Verified = true
+		for i := range t.Tokens {
+			verifyToken(cfg, v, &t.Tokens[i])
+			t.Verified = t.Verified && t.Tokens[i].Verified
+		}
+		return
+	}
+
+	keys := sdmKeysFromConfig(cfg)
+	if len(keys) == 0 {
+		return
+	}
+
+	uid, _ := hex.DecodeString(t.UID)
+	p, err := v.Verify(keys, t.Text, uid)
+	if errors.Is(err, sdm.ErrNoMessage) {
+		log.Debug().Msg("token has no SUN message, not verified")
+		return
+	} else if errors.Is(err, sdm.ErrNoCounter) || errors.Is(err, sdm.ErrNoUID) {
+		if !cfg.SdmAllowReplay() {
+			log.Warn().Err(err).Msgf("secure tag could be a copy, not verified: %x", p.UID)
+			return
+		}
+		log.Debug().Err(err).Msg("allowing secure tag which could be a copy")
+	} else if err != nil {
+		log.Warn().Err(err).Msgf("secure tag failed verification: %s", t.UID)
+		return
+	}
+
+	log.Info().Msgf("verified secure tag %x, read counter: %d", p.UID, p.Counter)
+	t.Verified = true
+	if t.UID == "" {
+		t.UID = hex.EncodeToString(p.UID)
+	}
+}
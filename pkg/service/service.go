@@ -24,6 +24,7 @@ package service
 import (
 	"fmt"
 	"github.com/ZaparooProject/zaparoo-core/pkg/api"
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/playlists"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 	"os"
@@ -36,6 +37,7 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/database"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/readers/toys"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
 	"github.com/ZaparooProject/zaparoo-core/pkg/zapscript"
 	"github.com/rs/zerolog/log"
@@ -49,28 +51,53 @@ func inExitGameBlocklist(platform platforms.Platform, cfg *config.Instance) bool
 	return slices.Contains(blocklist, strings.ToLower(platform.GetActiveLauncher()))
 }
 
+// Details of a token launch, recorded in the history.
+type launchResult struct {
+	mapping   string
+	zapScript string
+	systemId  string
+	mediaPath string
+}
+
 func launchToken(
 	platform platforms.Platform,
 	cfg *config.Instance,
+	st *state.State,
 	token tokens.Token,
 	db *database.Database,
 	lsq chan<- *tokens.Token,
 	plsc playlists.PlaylistController,
-) error {
+) (launchResult, error) {
+	var res launchResult
 	text := token.Text
 
-	mappingText, mapped := getMapping(cfg, db, platform, token)
+	mappingText, mappingId, mapped := getMapping(cfg, db, platform, token)
 	if mapped {
 		log.Info().Msgf("found mapping: %s", mappingText)
 		text = mappingText
+		res.mapping = mappingId
 	}
 
 	if text == "" {
-		return fmt.Errorf("no text NDEF found in card or mappings")
+		return res, fmt.Errorf("no text NDEF found in card or mappings")
 	}
 
 	log.Info().Msgf("launching with text: %s", text)
+	res.zapScript = text
 	cmds := strings.Split(text, "||")
+	vars := make(map[string]string)
+
+	// media started while this token is launching is recorded as a play
+	// session of the token, unless none of its commands change software
+	if !token.Remote {
+		st.SetLaunchToken(&token)
+	}
+	swapped := false
+	defer func() {
+		if !swapped {
+			st.ClearLaunchToken(&token)
+		}
+	}()
 
 	for i, cmd := range cmds {
 		err, softwareSwap := zapscript.LaunchToken(
@@ -82,18 +109,45 @@ func launchToken(
 			cmd,
 			len(cmds),
 			i,
+			vars,
 		)
+
+		res.systemId = vars[zapscript.VarMediaSystem]
+		res.mediaPath = vars[zapscript.VarMediaPath]
+
 		if err != nil {
-			return err
+			return res, err
 		}
 
 		if softwareSwap && !token.Remote {
+			swapped = true
 			log.Info().Msgf("current software launched set to: %s", token.UID)
 			lsq <- &token
 		}
 	}
 
-	return nil
+	return res, nil
+}
+
+// Launch a token and record the result in the history.
+func runToken(
+	platform platforms.Platform,
+	cfg *config.Instance,
+	st *state.State,
+	token tokens.Token,
+	db *database.Database,
+	lsq chan<- *tokens.Token,
+	plsc playlists.PlaylistController,
+) {
+	res, err := launchToken(platform, cfg, st, token, db, lsq, plsc)
+	if err != nil {
+		log.Error().Err(err).Msgf("error launching token")
+	}
+
+	err = db.AddHistory(historyEntry(token, res, err))
+	if err != nil {
+		log.Error().Err(err).Msgf("error adding history")
+	}
 }
 
 func processTokenQueue(
@@ -133,10 +187,7 @@ func processTokenQueue(
 						Active: activePlaylist,
 						Queue:  plq,
 					}
-					err := launchToken(platform, cfg, t, db, lsq, plsc)
-					if err != nil {
-						log.Error().Err(err).Msgf("error launching token")
-					}
+					runToken(platform, cfg, st, t, db, lsq, plsc)
 				}()
 				continue
 			} else {
@@ -157,10 +208,7 @@ func processTokenQueue(
 						Active: activePlaylist,
 						Queue:  plq,
 					}
-					err := launchToken(platform, cfg, t, db, lsq, plsc)
-					if err != nil {
-						log.Error().Err(err).Msgf("error launching token")
-					}
+					runToken(platform, cfg, st, t, db, lsq, plsc)
 				}()
 				continue
 			}
@@ -178,15 +226,11 @@ func processTokenQueue(
 				log.Error().Err(err).Msgf("error writing tmp scan result")
 			}
 
-			he := database.HistoryEntry{
-				Time: t.ScanTime,
-				Type: t.Type,
-				UID:  t.UID,
-				Text: t.Text,
-				Data: t.Data,
-			}
-
-			if st.CanRunZapScript() {
+			// only record the scan when running ZapScript is disabled
+			if !st.CanRunZapScript() {
+				he := historyEntry(t, launchResult{}, nil)
+				he.Success = false
+				he.Error = "run ZapScript disabled"
 				err = db.AddHistory(he)
 				if err != nil {
 					log.Error().Err(err).Msgf("error adding history")
@@ -200,17 +244,7 @@ func processTokenQueue(
 					Active: activePlaylist,
 					Queue:  plq,
 				}
-
-				err = launchToken(platform, cfg, t, db, lsq, plsc)
-				if err != nil {
-					log.Error().Err(err).Msgf("error launching token")
-				}
-
-				he.Success = err == nil
-				err = db.AddHistory(he)
-				if err != nil {
-					log.Error().Err(err).Msgf("error adding history")
-				}
+				runToken(platform, cfg, st, t, db, lsq, plsc)
 			}()
 		case <-time.After(100 * time.Millisecond):
 			if st.ShouldStopService() {
@@ -228,6 +262,7 @@ func Start(
 		pl.DataDir(),
 		pl.TempDir(),
 		filepath.Join(pl.DataDir(), platforms.MappingsDir),
+		filepath.Join(pl.DataDir(), platforms.DatsDir),
 	}
 	for _, dir := range dirs {
 		err := os.MkdirAll(dir, 0755)
@@ -264,17 +299,43 @@ func Start(
 		return nil, err
 	}
 
+	amiiboDb := filepath.Join(pl.DataDir(), platforms.AmiiboDbFile)
+	if _, err := os.Stat(amiiboDb); err == nil {
+		log.Info().Msg("loading amiibo database")
+		err = toys.LoadAmiiboDatabase(amiiboDb)
+		if err != nil {
+			log.Error().Err(err).Msgf("error loading amiibo database")
+		}
+	}
+
 	log.Info().Msg("starting API service")
 	go api.Start(pl, cfg, st, itq, db, ns)
 
 	log.Info().Msg("starting reader manager")
-	go readerManager(pl, cfg, st, itq, lsq)
+	go readerManager(pl, cfg, st, db, itq, lsq)
 
 	log.Info().Msg("starting input token queue manager")
 	go processTokenQueue(pl, cfg, st, itq, db, lsq, plq)
 
+	log.Info().Msg("starting history pruning")
+	go pruneHistory(cfg, st, db)
+
+	log.Info().Msg("starting scheduler")
+	stopScheduler := make(chan struct{})
+	schedulerDone := make(chan struct{})
+	go runScheduler(cfg, st, db, itq, stopScheduler, schedulerDone)
+
+	if cfg.MediaWatch() {
+		log.Info().Msg("starting media watcher")
+		go watchMedia(pl, cfg, st)
+	}
+
+	log.Info().Msg("starting session tracker")
+	pns := make(chan models.Notification)
+	go trackSessions(pl, st, db, pns)
+
 	log.Info().Msg("running platform post start")
-	err = pl.StartPost(cfg, st.Notifications)
+	err = pl.StartPost(cfg, pns)
 	if err != nil {
 		log.Error().Err(err).Msg("platform post start error")
 		return nil, err
@@ -286,6 +347,8 @@ func Start(
 			log.Warn().Msgf("error stopping platform: %s", err)
 		}
 		st.StopService()
+		close(stopScheduler)
+		<-schedulerDone
 		close(plq)
 		close(lsq)
 		close(itq)
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database/gamesdb"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/rs/zerolog/log"
+)
+
+// How long after a token starts launching that media started on the platform
+// is still attributed to it.
+const sessionLaunchTimeout = 30 * time.Second
+
+type sessionTracker struct {
+	pl     platforms.Platform
+	st     *state.State
+	db     *database.Database
+	active *database.Session
+}
+
+func (t *sessionTracker) stop() {
+	if t.active == nil {
+		return
+	}
+
+	t.active.End = time.Now()
+	err := t.db.UpdateSession(*t.active)
+	if err != nil {
+		log.Error().Err(err).Msg("error updating session")
+	}
+
+	log.Debug().Msgf("session ended: %s", t.active.MediaPath)
+	t.active = nil
+}
+
+func (t *sessionTracker) start(p models.MediaStartedParams) {
+	t.stop()
+
+	s := database.Session{
+		SystemId:   p.SystemId,
+		SystemName: p.SystemName,
+		MediaPath:  p.MediaPath,
+		MediaName:  p.MediaName,
+		Start:      time.Now(),
+	}
+
+	// the launch token is only set while a token is launching media and
+	// is cleared once used, if it's not available the media was launched
+	// some other way
+	if tok := t.st.TakeLaunchToken(sessionLaunchTimeout); tok != nil {
+		s.TokenUID = tok.UID
+	}
+
+	s, err := t.db.AddSession(s)
+	if err != nil {
+		log.Error().Err(err).Msg("error adding session")
+		return
+	}
+
+	log.Debug().Msgf("session started: %s", s.MediaPath)
+	t.active = &s
+}
+
+// Replace the media name with its canonical title if the media has been
+// matched to an imported DAT.
+func (t *sessionTracker) canonical(p models.MediaStartedParams) models.MediaStartedParams {
+	if p.SystemId == "" || p.MediaPath == "" || !gamesdb.Exists(t.pl) {
+		return p
+	}
+
+	r, err := gamesdb.GetMedia(t.pl, p.SystemId, p.MediaPath)
+	if err != nil {
+		log.Debug().Err(err).Msgf("error looking up media: %s", p.MediaPath)
+		return p
+	} else if r != nil && r.Dat != nil {
+		p.MediaName = r.Title()
+	}
+
+	return p
+}
+
+// Handle a platform notification, returning the notification to forward.
+func (t *sessionTracker) handle(n models.Notification) models.Notification {
+	switch n.Method {
+	case models.MediaStarted:
+		var p models.MediaStartedParams
+		switch v := n.Params.(type) {
+		case models.MediaStartedParams:
+			p = v
+		case *models.MediaStartedParams:
+			p = *v
+		default:
+			return n
+		}
+
+		p = t.canonical(p)
+		t.start(p)
+		n.Params = p
+	case models.MediaStopped:
+		t.stop()
+	}
+
+	return n
+}
+
+// Record play sessions from platform notifications and forward all
+// notifications on to the API, with canonical media titles where known.
+func trackSessions(
+	pl platforms.Platform,
+	st *state.State,
+	db *database.Database,
+	pns <-chan models.Notification,
+) {
+	t := &sessionTracker{pl: pl, st: st, db: db}
+
+	for !st.ShouldStopService() {
+		select {
+		case n := <-pns:
+			st.Notifications <- t.handle(n)
+		case <-time.After(500 * time.Millisecond):
+			continue
+		}
+	}
+
+	t.stop()
+
+	// the platform may still send notifications while it's stopping, keep
+	// reading them so it doesn't block
+	go func() {
+		for range pns {
+		}
+	}()
+}
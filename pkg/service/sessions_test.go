@@ -0,0 +1,173 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
+	"github.com/ZaparooProject/zaparoo-core/pkg/database"
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/state"
+	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
+)
+
+// testPlatform implements only the parts of a platform used by the service
+// tests, calling anything else will panic.
+type testPlatform struct {
+	platforms.Platform
+	dataDir   string
+	roots     []string
+	launchers []platforms.Launcher
+}
+
+func (p *testPlatform) DataDir() string {
+	return p.dataDir
+}
+
+func (p *testPlatform) RootDirs(*config.Instance) []string {
+	return p.roots
+}
+
+func (p *testPlatform) Launchers(*config.Instance) []platforms.Launcher {
+	return p.launchers
+}
+
+func testDb(t *testing.T, pl platforms.Platform) *database.Database {
+	t.Helper()
+	db, err := database.Open(pl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestTrackSessions(t *testing.T) {
+	pl := &testPlatform{dataDir: t.TempDir()}
+	db := testDb(t, pl)
+	st, ns := state.NewState(pl)
+	pns := make(chan models.Notification)
+
+	done := make(chan struct{})
+	go func() {
+		trackSessions(pl, st, db, pns)
+		close(done)
+	}()
+
+	send := func(n models.Notification) {
+		t.Helper()
+		pns <- n
+		select {
+		case got := <-ns:
+			if got.Method != n.Method {
+				t.Fatalf("expected: %q, got: %q", n.Method, got.Method)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("notification not forwarded: %s", n.Method)
+		}
+	}
+
+	started := func(path string) models.Notification {
+		return models.Notification{
+			Method: models.MediaStarted,
+			Params: models.MediaStartedParams{
+				SystemId:   "SNES",
+				SystemName: "Super Nintendo",
+				MediaPath:  path,
+				MediaName:  path,
+			},
+		}
+	}
+	stopped := models.Notification{Method: models.MediaStopped}
+
+	// launched by a token
+	token := tokens.Token{UID: "04aabbcc"}
+	st.SetLaunchToken(&token)
+	send(started("a.sfc"))
+	send(stopped)
+
+	// launched some other way, the token was used by the first session
+	send(started("b.sfc"))
+	send(stopped)
+
+	// a new launch replaces the previous session without a stop
+	send(started("c.sfc"))
+	send(started("d.sfc"))
+
+	st.StopService()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("session tracker did not stop")
+	}
+
+	// platform notifications sent after stopping must not block
+	select {
+	case pns <- stopped:
+	case <-time.After(time.Second):
+		t.Fatal("notification blocked after stopping")
+	}
+
+	ss, err := db.GetSessions(nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		path string
+		uid  string
+	}{
+		{"d.sfc", ""},
+		{"c.sfc", ""},
+		{"b.sfc", ""},
+		{"a.sfc", "04aabbcc"},
+	}
+
+	if len(ss) != len(want) {
+		t.Fatalf("expected: %d sessions, got: %d", len(want), len(ss))
+	}
+
+	for i, w := range want {
+		s := ss[i]
+		if s.MediaPath != w.path {
+			t.Fatalf("expected: %q, got: %q", w.path, s.MediaPath)
+		} else if s.TokenUID != w.uid {
+			t.Fatalf("%s: expected uid: %q, got: %q", w.path, w.uid, s.TokenUID)
+		} else if s.End.IsZero() || s.End.Before(s.Start) {
+			t.Fatalf("%s: expected session to be ended, got: %v", w.path, s)
+		}
+	}
+}
+
+func TestTakeLaunchToken(t *testing.T) {
+	st, _ := state.NewState(&testPlatform{})
+	token := tokens.Token{UID: "04aabbcc"}
+
+	st.SetLaunchToken(&token)
+	if got := st.TakeLaunchToken(time.Minute); got != &token {
+		t.Fatalf("expected: %v, got: %v", &token, got)
+	}
+	if got := st.TakeLaunchToken(time.Minute); got != nil {
+		t.Fatalf("expected token to be consumed, got: %v", got)
+	}
+
+	// only the token which set it can clear it
+	st.SetLaunchToken(&token)
+	st.ClearLaunchToken(&tokens.Token{UID: "04aabbcc"})
+	if got := st.TakeLaunchToken(time.Minute); got != &token {
+		t.Fatalf("expected: %v, got: %v", &token, got)
+	}
+
+	st.SetLaunchToken(&token)
+	st.ClearLaunchToken(&token)
+	if got := st.TakeLaunchToken(time.Minute); got != nil {
+		t.Fatalf("expected token to be cleared, got: %v", got)
+	}
+
+	st.SetLaunchToken(&token)
+	time.Sleep(10 * time.Millisecond)
+	if got := st.TakeLaunchToken(time.Millisecond); got != nil {
+		t.Fatalf("expected old token to be ignored, got: %v", got)
+	}
+}
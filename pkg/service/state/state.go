@@ -4,6 +4,7 @@ import (
 	"github.com/ZaparooProject/zaparoo-core/pkg/api/models"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/tokens"
 	"sync"
+	"time"
 
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/ZaparooProject/zaparoo-core/pkg/readers"
@@ -21,6 +22,8 @@ type State struct {
 	readers       map[string]readers.Reader
 	softwareToken *tokens.Token
 	wroteToken    *tokens.Token
+	launchToken   *tokens.Token
+	launchTime    time.Time
 	Notifications chan<- models.Notification // TODO: move outside state
 }
 
@@ -56,6 +59,7 @@ func (s *State) SetActiveCard(card tokens.Token) {
 			Text:     card.Text,
 			Data:     card.Data,
 			ScanTime: card.ScanTime,
+			Verified: card.Verified,
 		},
 	}
 	s.mu.Unlock()
@@ -164,6 +168,38 @@ func (s *State) GetSoftwareToken() *tokens.Token {
 	return s.softwareToken
 }
 
+// Set the token which is currently launching media, so the media it starts
+// can be attributed to it.
+func (s *State) SetLaunchToken(token *tokens.Token) {
+	s.mu.Lock()
+	s.launchToken = token
+	s.launchTime = time.Now()
+	s.mu.Unlock()
+}
+
+// Clear the launching token if it's still the given token.
+func (s *State) ClearLaunchToken(token *tokens.Token) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.launchToken == token {
+		s.launchToken = nil
+	}
+}
+
+// Return the token which launched media and clear it, so it's only used for
+// the first media started after the launch. Tokens set longer ago than maxAge
+// are ignored.
+func (s *State) TakeLaunchToken(maxAge time.Duration) *tokens.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token := s.launchToken
+	s.launchToken = nil
+	if token == nil || time.Since(s.launchTime) > maxAge {
+		return nil
+	}
+	return token
+}
+
 func (s *State) SetWroteToken(token *tokens.Token) {
 	s.mu.Lock()
 	s.wroteToken = token
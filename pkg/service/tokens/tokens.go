@@ -1,15 +1,21 @@
 package tokens
 
 import (
+	"sort"
+	"strings"
 	"time"
 )
 
 const (
 	TypeNTAG           = "NTAG"
 	TypeMifare         = "MIFARE"
+	TypeType4          = "Type4"
 	TypeAmiibo         = "Amiibo"
 	TypeLegoDimensions = "LegoDimensions"
+	TypeCombination    = "Combination"
 	SourcePlaylist     = "Playlist"
+	SourceScheduler    = "Scheduler"
+	SourceApi          = "API"
 )
 
 type Token struct {
@@ -20,4 +26,64 @@ type Token struct {
 	ScanTime time.Time
 	Remote   bool // TODO: wtf does this even do now
 	Source   string
+	// Set if the token came from a secure tag with a valid SUN message.
+	Verified bool
+	// The tokens that make up a combination token, when more than one tag
+	// is on a reader at the same time.
+	Tokens []Token
+}
+
+// CombinationSep separates the member UIDs in a combination token's UID.
+const CombinationSep = "+"
+
+// NewCombination creates a token for a set of tags which are on a reader at
+// the same time. The UID is made from the sorted member UIDs so it doesn't
+// depend on the order the tags were read, and the text is the members' text
+// joined as separate commands in the order given.
+func NewCombination(members []Token) Token {
+	uids := make([]string, 0, len(members))
+	var texts []string
+	for _, m := range members {
+		uids = append(uids, m.UID)
+		if m.Text != "" {
+			texts = append(texts, m.Text)
+		}
+	}
+	sort.Strings(uids)
+
+	return Token{
+		Type:     TypeCombination,
+		UID:      strings.Join(uids, CombinationSep),
+		Text:     strings.Join(texts, "||"),
+		ScanTime: time.Now(),
+		Tokens:   members,
+	}
+}
+
+// HasMember returns true if a token is a combination containing a token
+// with the given UID.
+func (t Token) HasMember(uid string) bool {
+	for _, m := range t.Tokens {
+		if m.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSubsetOf returns true if every tag in a token, or the token itself if
+// it's not a combination, is a member of another combination token.
+func (t Token) IsSubsetOf(other Token) bool {
+	if len(other.Tokens) == 0 {
+		return false
+	} else if len(t.Tokens) == 0 {
+		return other.HasMember(t.UID)
+	}
+
+	for _, m := range t.Tokens {
+		if !other.HasMember(m.UID) {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,78 @@
+package tokens
+
+import "testing"
+
+func members(uids ...string) []Token {
+	ts := make([]Token, len(uids))
+	for i, uid := range uids {
+		ts[i] = Token{UID: uid, Text: "**launch:" + uid}
+	}
+	return ts
+}
+
+func TestNewCombination(t *testing.T) {
+	c := NewCombination(members("cc", "aa", "bb"))
+
+	if c.Type != TypeCombination {
+		t.Fatalf("expected type %s, got: %s", TypeCombination, c.Type)
+	} else if c.UID != "aa+bb+cc" {
+		t.Fatalf("expected sorted uid, got: %s", c.UID)
+	} else if c.Text != "**launch:cc||**launch:aa||**launch:bb" {
+		t.Fatalf("expected text in placed order, got: %s", c.Text)
+	} else if len(c.Tokens) != 3 || c.ScanTime.IsZero() {
+		t.Fatalf("unexpected combination: %+v", c)
+	}
+
+	// order doesn't change the uid
+	if other := NewCombination(members("bb", "cc", "aa")); other.UID != c.UID {
+		t.Fatalf("expected: %s, got: %s", c.UID, other.UID)
+	}
+
+	// tags without text are left out of the text
+	ms := members("aa", "bb")
+	ms[0].Text = ""
+	if c := NewCombination(ms); c.Text != "**launch:bb" {
+		t.Fatalf("unexpected text: %q", c.Text)
+	}
+}
+
+func TestHasMember(t *testing.T) {
+	c := NewCombination(members("aa", "bb"))
+
+	if !c.HasMember("aa") || !c.HasMember("bb") {
+		t.Fatalf("expected members in: %s", c.UID)
+	} else if c.HasMember("cc") || c.HasMember("aa+bb") {
+		t.Fatalf("unexpected member in: %s", c.UID)
+	}
+
+	if (Token{UID: "aa"}).HasMember("aa") {
+		t.Fatalf("single token has no members")
+	}
+}
+
+func TestIsSubsetOf(t *testing.T) {
+	three := NewCombination(members("aa", "bb", "cc"))
+
+	tests := []struct {
+		name  string
+		token Token
+		other Token
+		want  bool
+	}{
+		{name: "single member", token: Token{UID: "bb"}, other: three, want: true},
+		{name: "single non-member", token: Token{UID: "dd"}, other: three, want: false},
+		{name: "two of three", token: NewCombination(members("aa", "cc")), other: three, want: true},
+		{name: "same set", token: NewCombination(members("cc", "bb", "aa")), other: three, want: true},
+		{name: "new tag added", token: NewCombination(members("aa", "dd")), other: three, want: false},
+		{name: "superset", token: three, other: NewCombination(members("aa", "bb")), want: false},
+		{name: "not a combination", token: Token{UID: "aa"}, other: Token{UID: "aa"}, want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.token.IsSubsetOf(tc.other); got != tc.want {
+				t.Fatalf("expected: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
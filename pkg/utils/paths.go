@@ -10,6 +10,12 @@ import (
 	"strings"
 )
 
+// PathWithin returns true if path is the same as or inside the folder dir.
+func PathWithin(path string, dir string) bool {
+	dir = strings.TrimSuffix(dir, string(filepath.Separator))
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
 // PathIsLauncher returns true if a given path matches against any of the
 // criteria defined in a launcher.
 func PathIsLauncher(
@@ -61,6 +67,16 @@ func PathIsLauncher(
 		}
 	}
 
+	// check extra extensions from the config's system overrides, only for
+	// launchers which already handle files
+	if cfg != nil && (len(l.Folders) > 0 || len(l.Extensions) > 0) {
+		for _, ext := range cfg.SystemOverride(l.SystemId).Extensions {
+			if strings.HasSuffix(lp, strings.ToLower(ext)) {
+				return true
+			}
+		}
+	}
+
 	return false
 }
 
@@ -71,7 +87,7 @@ func MatchSystemFile(
 	systemId string,
 	path string,
 ) bool {
-	for _, l := range pl.Launchers() {
+	for _, l := range pl.Launchers(cfg) {
 		if l.SystemId == systemId {
 			if PathIsLauncher(cfg, pl, l, path) {
 				return true
@@ -89,7 +105,7 @@ func PathToLaunchers(
 	path string,
 ) []platforms.Launcher {
 	var launchers []platforms.Launcher
-	for _, l := range pl.Launchers() {
+	for _, l := range pl.Launchers(cfg) {
 		if PathIsLauncher(cfg, pl, l, path) {
 			launchers = append(launchers, l)
 		}
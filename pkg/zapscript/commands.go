@@ -21,6 +21,7 @@ along with Zaparoo Core.  If not, see <http://www.gnu.org/licenses/>.
 package zapscript
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/service/playlists"
@@ -28,6 +29,7 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"golang.org/x/exp/slices"
@@ -38,13 +40,13 @@ import (
 )
 
 // TODO: adding some logging for each command
-// TODO: game file by hash
 
 var commandMappings = map[string]func(platforms.Platform, platforms.CmdEnv) error{
 	"launch":        cmdLaunch,
 	"launch.system": cmdSystem,
 	"launch.random": cmdRandom,
 	"launch.search": cmdSearch,
+	"launch.hash":   cmdHash,
 
 	"playlist.play":     cmdPlaylistPlay,
 	"playlist.next":     cmdPlaylistNext,
@@ -58,8 +60,9 @@ var commandMappings = map[string]func(platforms.Platform, platforms.CmdEnv) erro
 	"mister.script": forwardCmd,
 	"mister.mgl":    forwardCmd,
 
-	"http.get":  cmdHttpGet,
-	"http.post": cmdHttpPost,
+	"http.get":     cmdHttpGet,
+	"http.post":    cmdHttpPost,
+	"http.request": cmdHttpRequest,
 
 	"input.keyboard": cmdKeyboard,
 	"input.gamepad":  cmdGamepad,
@@ -84,10 +87,90 @@ var softwareChangeCommands = []string{
 	"launch.system",
 	"launch.random",
 	"launch.search",
+	"launch.hash",
 	"mister.core",
 	"mister.mgl",
 }
 
+// Commands which never have chain variables expanded in their arguments, so
+// values from outside sources like HTTP responses can't be run as part of a
+// command or get around the execute allow list.
+var noVarsCommands = []string{
+	"execute",
+	"shell",   // DEPRECATED
+	"command", // DEPRECATED
+}
+
+// Chain variables set by built-in commands.
+const (
+	VarMediaPath   = "media.path"
+	VarMediaSystem = "media.system"
+	VarHttpStatus  = "http.status"
+	VarHttpBody    = "http.body"
+)
+
+var varsRe = regexp.MustCompile(`\$\{([a-zA-Z0-9_.\-]+)\}`)
+
+// Replace any ${name} references in text with values set by earlier commands
+// in the chain. Unknown names are left as-is.
+func expandVars(text string, vars map[string]string) string {
+	return replaceVars(text, vars, func(v string) string {
+		return v
+	})
+}
+
+// Replace any ${name} references in JSON arguments. Values are escaped so
+// they can't end the string they're in and add or change fields.
+func expandJsonVars(text string, vars map[string]string) string {
+	return replaceVars(text, vars, func(v string) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(b[1 : len(b)-1])
+	})
+}
+
+// Expand chain variables in the arguments of a command, escaping values if
+// the arguments are JSON.
+func expandArgs(cmd string, args string, jsonArgs bool, vars map[string]string) string {
+	if slices.Contains(noVarsCommands, cmd) {
+		return args
+	} else if jsonArgs {
+		return expandJsonVars(args, vars)
+	}
+	return expandVars(args, vars)
+}
+
+func replaceVars(text string, vars map[string]string, escape func(string) string) string {
+	if len(vars) == 0 || !strings.Contains(text, "${") {
+		return text
+	}
+
+	return varsRe.ReplaceAllStringFunc(text, func(m string) string {
+		name := varsRe.FindStringSubmatch(m)[1]
+		if v, ok := vars[name]; ok {
+			return escape(v)
+		}
+		return m
+	})
+}
+
+// Commands with a JSON object as arguments don't support named args, so
+// query-like strings in the JSON can be passed through untouched.
+func hasJsonArgs(text string) bool {
+	if !strings.HasPrefix(text, "**") {
+		return false
+	}
+
+	ps := strings.SplitN(text, ":", 2)
+	if len(ps) < 2 {
+		return false
+	}
+
+	return strings.HasPrefix(strings.TrimSpace(ps[1]), "{")
+}
+
 func forwardCmd(pl platforms.Platform, env platforms.CmdEnv) error {
 	return pl.ForwardCmd(env)
 }
@@ -139,9 +222,11 @@ func LaunchToken(
 	text string,
 	totalCommands int,
 	currentIndex int,
+	vars map[string]string,
 ) (error, bool) {
 	namedArgs := make(map[string]string)
-	if i := strings.LastIndex(text, "?"); i != -1 {
+	jsonArgs := hasJsonArgs(text)
+	if i := strings.LastIndex(text, "?"); i != -1 && !jsonArgs {
 		u, err := url.Parse(text[i:])
 		if err != nil {
 			return err, false
@@ -155,7 +240,7 @@ func LaunchToken(
 		text = text[:i]
 
 		for k, v := range qs {
-			namedArgs[k] = v[0]
+			namedArgs[k] = expandVars(v[0], vars)
 		}
 	}
 	log.Debug().Msgf("named args: %v", namedArgs)
@@ -174,6 +259,7 @@ func LaunchToken(
 		}
 
 		cmd, args := strings.ToLower(strings.TrimSpace(ps[0])), strings.TrimSpace(ps[1])
+		args = expandArgs(cmd, args, jsonArgs, vars)
 
 		env := platforms.CmdEnv{
 			Cmd:           cmd,
@@ -185,6 +271,7 @@ func LaunchToken(
 			Text:          text,
 			TotalCommands: totalCommands,
 			CurrentIndex:  currentIndex,
+			Vars:          vars,
 		}
 
 		if f, ok := commandMappings[cmd]; ok {
@@ -210,6 +297,7 @@ func LaunchToken(
 	}
 
 	// if it's not a command, treat it as a generic launch command
+	text = expandVars(text, vars)
 	return cmdLaunch(pl, platforms.CmdEnv{
 		Cmd:           "launch",
 		Args:          text,
@@ -219,5 +307,6 @@ func LaunchToken(
 		Text:          text,
 		TotalCommands: totalCommands,
 		CurrentIndex:  currentIndex,
+		Vars:          vars,
 	}), true
 }
@@ -1,9 +1,14 @@
 package zapscript
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -50,3 +55,188 @@ func cmdHttpPost(pl platforms.Platform, env platforms.CmdEnv) error {
 
 	return nil
 }
+
+const (
+	defaultHttpTimeout = 10 * time.Second
+	maxHttpResponse    = 1024 * 1024
+)
+
+type httpRequestArgs struct {
+	Url     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+	// Request timeout in milliseconds.
+	Timeout int `json:"timeout"`
+	// Expected response status code. If zero, any 2xx status is accepted.
+	Status int `json:"status"`
+	// Wait for the response before running the next command.
+	Wait bool `json:"wait"`
+	// Extra variable name to store the response body in.
+	Save string `json:"save"`
+}
+
+// Parse the http.request arguments. Arguments can either be a URL followed
+// by named args, or a JSON object which allows setting multiple headers and
+// bodies containing reserved characters.
+func parseHttpRequestArgs(env platforms.CmdEnv) (httpRequestArgs, error) {
+	var args httpRequestArgs
+
+	if strings.HasPrefix(env.Args, "{") {
+		err := json.Unmarshal([]byte(env.Args), &args)
+		if err != nil {
+			return args, fmt.Errorf("invalid request arguments: %w", err)
+		}
+	} else {
+		args.Url = env.Args
+		args.Method = env.NamedArgs["method"]
+		args.Save = env.NamedArgs["save"]
+		args.Wait = env.NamedArgs["wait"] == "yes" || env.NamedArgs["wait"] == "true"
+
+		if body, ok := env.NamedArgs["body"]; ok {
+			bs, err := json.Marshal(body)
+			if err != nil {
+				return args, err
+			}
+			args.Body = bs
+		}
+
+		args.Headers = make(map[string]string)
+		if ct, ok := env.NamedArgs["type"]; ok {
+			args.Headers["Content-Type"] = ct
+		}
+		if h, ok := env.NamedArgs["header"]; ok {
+			k, v, found := strings.Cut(h, ":")
+			if !found {
+				return args, fmt.Errorf("invalid header: %s", h)
+			}
+			args.Headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+
+		if t, ok := env.NamedArgs["timeout"]; ok {
+			timeout, err := strconv.Atoi(t)
+			if err != nil {
+				return args, fmt.Errorf("invalid timeout: %s", t)
+			}
+			args.Timeout = timeout
+		}
+
+		if s, ok := env.NamedArgs["status"]; ok {
+			status, err := strconv.Atoi(s)
+			if err != nil {
+				return args, fmt.Errorf("invalid status: %s", s)
+			}
+			args.Status = status
+		}
+	}
+
+	if args.Url == "" {
+		return args, fmt.Errorf("no url specified")
+	}
+
+	if args.Method == "" {
+		args.Method = http.MethodGet
+	} else {
+		args.Method = strings.ToUpper(args.Method)
+	}
+
+	// a saved response is only useful if later commands wait for it
+	if args.Save != "" {
+		args.Wait = true
+	}
+
+	return args, nil
+}
+
+// Raw JSON string bodies are sent as the decoded string, anything else is
+// sent as the literal JSON.
+func (a httpRequestArgs) body() io.Reader {
+	if len(a.Body) == 0 {
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(a.Body, &s); err == nil {
+		return strings.NewReader(s)
+	}
+
+	return bytes.NewReader(a.Body)
+}
+
+func doHttpRequest(args httpRequestArgs) (int, string, error) {
+	timeout := defaultHttpTimeout
+	if args.Timeout > 0 {
+		timeout = time.Duration(args.Timeout) * time.Millisecond
+	}
+
+	req, err := http.NewRequest(args.Method, args.Url, args.body())
+	if err != nil {
+		return 0, "", err
+	}
+
+	for k, v := range args.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func(b io.ReadCloser) {
+		err := b.Close()
+		if err != nil {
+			log.Error().Err(err).Msgf("closing body")
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHttpResponse))
+	if err != nil {
+		return resp.StatusCode, "", err
+	}
+
+	if args.Status != 0 && resp.StatusCode != args.Status {
+		return resp.StatusCode, string(body), fmt.Errorf(
+			"unexpected status: %d (expected %d)",
+			resp.StatusCode,
+			args.Status,
+		)
+	} else if args.Status == 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+		return resp.StatusCode, string(body), fmt.Errorf(
+			"unexpected status: %d",
+			resp.StatusCode,
+		)
+	}
+
+	return resp.StatusCode, string(body), nil
+}
+
+func cmdHttpRequest(_ platforms.Platform, env platforms.CmdEnv) error {
+	args, err := parseHttpRequestArgs(env)
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msgf("http request: %s %s", args.Method, args.Url)
+
+	if !args.Wait {
+		go func() {
+			_, _, err := doHttpRequest(args)
+			if err != nil {
+				log.Error().Err(err).Msgf("http request: %s", args.Url)
+			}
+		}()
+		return nil
+	}
+
+	status, body, err := doHttpRequest(args)
+	if env.Vars != nil && status != 0 {
+		env.Vars[VarHttpStatus] = strconv.Itoa(status)
+		env.Vars[VarHttpBody] = body
+		if args.Save != "" {
+			env.Vars[args.Save] = body
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,241 @@
+package zapscript
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
+)
+
+func TestParseHttpRequestArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     platforms.CmdEnv
+		want    httpRequestArgs
+		body    string
+		wantErr bool
+	}{
+		{
+			name: "url only",
+			env:  platforms.CmdEnv{Args: "http://localhost/api"},
+			want: httpRequestArgs{Url: "http://localhost/api", Method: http.MethodGet},
+		},
+		{
+			name: "named args",
+			env: platforms.CmdEnv{
+				Args: "http://localhost/api",
+				NamedArgs: map[string]string{
+					"method":  "post",
+					"body":    "hello",
+					"type":    "text/plain",
+					"header":  "X-Token: abc",
+					"timeout": "500",
+					"status":  "201",
+					"wait":    "yes",
+				},
+			},
+			want: httpRequestArgs{
+				Url:    "http://localhost/api",
+				Method: http.MethodPost,
+				Headers: map[string]string{
+					"Content-Type": "text/plain",
+					"X-Token":      "abc",
+				},
+				Timeout: 500,
+				Status:  201,
+				Wait:    true,
+			},
+			body: "hello",
+		},
+		{
+			name: "save implies wait",
+			env: platforms.CmdEnv{
+				Args:      "http://localhost/api",
+				NamedArgs: map[string]string{"save": "result"},
+			},
+			want: httpRequestArgs{Url: "http://localhost/api", Method: http.MethodGet, Save: "result", Wait: true},
+		},
+		{
+			name: "json args",
+			env: platforms.CmdEnv{
+				Args: `{"url":"http://localhost/api","method":"put","headers":{"A":"1","B":"2"},"body":{"x":1}}`,
+			},
+			want: httpRequestArgs{
+				Url:     "http://localhost/api",
+				Method:  http.MethodPut,
+				Headers: map[string]string{"A": "1", "B": "2"},
+			},
+			body: `{"x":1}`,
+		},
+		{
+			name:    "invalid json",
+			env:     platforms.CmdEnv{Args: `{"url":`},
+			wantErr: true,
+		},
+		{
+			name:    "no url",
+			env:     platforms.CmdEnv{Args: `{"method":"get"}`},
+			wantErr: true,
+		},
+		{
+			name: "invalid header",
+			env: platforms.CmdEnv{
+				Args:      "http://localhost/api",
+				NamedArgs: map[string]string{"header": "nocolon"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid timeout",
+			env: platforms.CmdEnv{
+				Args:      "http://localhost/api",
+				NamedArgs: map[string]string{"timeout": "soon"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseHttpRequestArgs(tc.env)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got: %+v", got)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Url != tc.want.Url || got.Method != tc.want.Method ||
+				got.Timeout != tc.want.Timeout || got.Status != tc.want.Status ||
+				got.Wait != tc.want.Wait || got.Save != tc.want.Save {
+				t.Fatalf("expected: %+v, got: %+v", tc.want, got)
+			}
+
+			for k, v := range tc.want.Headers {
+				if got.Headers[k] != v {
+					t.Fatalf("expected header %s: %q, got: %q", k, v, got.Headers[k])
+				}
+			}
+
+			var body string
+			if r := got.body(); r != nil {
+				b, _ := io.ReadAll(r)
+				body = string(b)
+			}
+			if body != tc.body {
+				t.Fatalf("expected body: %q, got: %q", tc.body, body)
+			}
+		})
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	vars := map[string]string{
+		VarHttpStatus: "200",
+		VarHttpBody:   `{"name":"Mario"}`,
+		"media.path":  "/games/snes/mario.sfc",
+	}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "no vars", want: "no vars"},
+		{text: "${media.path}", want: "/games/snes/mario.sfc"},
+		{text: "status=${http.status}&p=${media.path}", want: "status=200&p=/games/snes/mario.sfc"},
+		{text: "${unknown}", want: "${unknown}"},
+		{text: "${http.body}", want: `{"name":"Mario"}`},
+	}
+
+	for _, tc := range tests {
+		got := expandVars(tc.text, vars)
+		if got != tc.want {
+			t.Fatalf("%q: expected: %q, got: %q", tc.text, tc.want, got)
+		}
+	}
+
+	if got := expandVars("${media.path}", nil); got != "${media.path}" {
+		t.Fatalf("expected no change without vars, got: %q", got)
+	}
+}
+
+func TestExpandJsonVars(t *testing.T) {
+	vars := map[string]string{
+		VarHttpBody:   `bad", "url": "http://evil/`,
+		VarHttpStatus: "200",
+		"multiline":   "a\nb\\c",
+	}
+
+	args := `{"url":"http://localhost/api","body":"${http.body}","status":${http.status},"m":"${multiline}"}`
+	expanded := expandJsonVars(args, vars)
+
+	var got struct {
+		Url    string `json:"url"`
+		Body   string `json:"body"`
+		Status int    `json:"status"`
+		M      string `json:"m"`
+	}
+	err := json.Unmarshal([]byte(expanded), &got)
+	if err != nil {
+		t.Fatalf("expanded args are not valid json: %s: %s", expanded, err)
+	}
+
+	if got.Url != "http://localhost/api" {
+		t.Fatalf("value changed url field: %q", got.Url)
+	} else if got.Body != vars[VarHttpBody] {
+		t.Fatalf("expected body %q, got: %q", vars[VarHttpBody], got.Body)
+	} else if got.Status != 200 {
+		t.Fatalf("expected status 200, got: %d", got.Status)
+	} else if got.M != "a\nb\\c" {
+		t.Fatalf("expected escaped multiline value, got: %q", got.M)
+	}
+}
+
+func TestExpandArgs(t *testing.T) {
+	vars := map[string]string{
+		VarHttpBody: "rm -rf /",
+	}
+
+	tests := []struct {
+		cmd      string
+		args     string
+		jsonArgs bool
+		want     string
+	}{
+		{"launch", "${http.body}", false, "rm -rf /"},
+		{"http.post", `{"body":"${http.body}"}`, true, `{"body":"rm -rf /"}`},
+		{"execute", "${http.body}", false, "${http.body}"},
+		{"shell", "echo ${http.body}", false, "echo ${http.body}"},
+		{"command", "${http.body}", false, "${http.body}"},
+	}
+
+	for _, tt := range tests {
+		got := expandArgs(tt.cmd, tt.args, tt.jsonArgs, vars)
+		if got != tt.want {
+			t.Fatalf("%s: expected: %q, got: %q", tt.cmd, tt.want, got)
+		}
+	}
+}
+
+func TestHasJsonArgs(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: `**http.request:{"url":"http://localhost"}`, want: true},
+		{text: `**http.request: {"url":"http://localhost"}`, want: true},
+		{text: "**http.request:http://localhost?method=post", want: false},
+		{text: "snes/mario.sfc", want: false},
+		{text: "**launch.random", want: false},
+	}
+
+	for _, tc := range tests {
+		if got := hasJsonArgs(tc.text); got != tc.want {
+			t.Fatalf("%q: expected %v, got %v", tc.text, tc.want, got)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/rs/zerolog/log"
 
+	"github.com/ZaparooProject/zaparoo-core/pkg/config"
 	"github.com/ZaparooProject/zaparoo-core/pkg/database/gamesdb"
 	"github.com/ZaparooProject/zaparoo-core/pkg/platforms"
 	"github.com/ZaparooProject/zaparoo-core/pkg/utils"
@@ -22,6 +23,10 @@ func cmdSystem(pl platforms.Platform, env platforms.CmdEnv) error {
 		return pl.KillLauncher()
 	}
 
+	if env.Vars != nil {
+		env.Vars[VarMediaSystem] = env.Args
+	}
+
 	return pl.LaunchSystem(env.Cfg, env.Args)
 }
 
@@ -36,12 +41,12 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) error {
 	}
 
 	if env.Args == "all" {
-		game, err := gamesdb.RandomGame(pl, gamesdb.AllSystems())
+		game, err := gamesdb.RandomGame(pl, gamesdb.AllSystems(), searchFilter(env))
 		if err != nil {
 			return err
 		}
 
-		return launch(game.Path)
+		return launch(preferredVersion(pl, env, game).Path)
 	}
 
 	// absolute path, try read dir and pick random file
@@ -75,7 +80,7 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) error {
 	if len(ps) == 2 {
 		systemId, query := ps[0], ps[1]
 
-		system, err := gamesdb.LookupSystem(systemId)
+		system, err := gamesdb.LookupSystem(env.Cfg, systemId)
 		if err != nil {
 			return err
 		} else if system == nil {
@@ -84,7 +89,7 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) error {
 
 		query = strings.ToLower(query)
 
-		res, err := gamesdb.SearchNamesGlob(pl, []gamesdb.System{*system}, query)
+		res, err := searchMedia(pl, env, []gamesdb.System{*system}, query)
 		if err != nil {
 			return err
 		}
@@ -98,14 +103,14 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) error {
 			return err
 		}
 
-		return launch(game.Path)
+		return launch(preferredVersion(pl, env, game).Path)
 	}
 
 	systemIds := strings.Split(env.Args, ",")
 	systems := make([]gamesdb.System, 0, len(systemIds))
 
 	for _, id := range systemIds {
-		system, err := gamesdb.LookupSystem(id)
+		system, err := gamesdb.LookupSystem(env.Cfg, id)
 		if err != nil {
 			log.Error().Err(err).Msgf("error looking up system: %s", id)
 			continue
@@ -114,12 +119,12 @@ func cmdRandom(pl platforms.Platform, env platforms.CmdEnv) error {
 		systems = append(systems, *system)
 	}
 
-	game, err := gamesdb.RandomGame(pl, systems)
+	game, err := gamesdb.RandomGame(pl, systems, searchFilter(env))
 	if err != nil {
 		return err
 	}
 
-	return launch(game.Path)
+	return launch(preferredVersion(pl, env, game).Path)
 }
 
 func getAltLauncher(
@@ -129,7 +134,7 @@ func getAltLauncher(
 	if env.NamedArgs["launcher"] != "" {
 		var launcher platforms.Launcher
 
-		for _, l := range pl.Launchers() {
+		for _, l := range pl.Launchers(env.Cfg) {
 			if l.Id == env.NamedArgs["launcher"] {
 				launcher = l
 				break
@@ -143,15 +148,31 @@ func getAltLauncher(
 		log.Info().Msgf("launching with alt launcher: %s", env.NamedArgs["launcher"])
 
 		return func(args string) error {
+			setLaunchVars(env, args, launcher.SystemId)
 			return launcher.Launch(env.Cfg, args)
 		}, nil
 	} else {
 		return func(args string) error {
+			systemId := ""
+			if ls := utils.PathToLaunchers(env.Cfg, pl, args); len(ls) > 0 {
+				systemId = ls[0].SystemId
+			}
+			setLaunchVars(env, args, systemId)
 			return pl.LaunchFile(env.Cfg, args)
 		}, nil
 	}
 }
 
+// Record the media being launched so it's available to later commands and
+// the token history.
+func setLaunchVars(env platforms.CmdEnv, path string, systemId string) {
+	if env.Vars == nil {
+		return
+	}
+	env.Vars[VarMediaPath] = path
+	env.Vars[VarMediaSystem] = systemId
+}
+
 var reUri = regexp.MustCompile(`^.+://`)
 
 func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) error {
@@ -160,6 +181,12 @@ func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) error {
 		return err
 	}
 
+	// launch by content hash, e.g. hash:1a2b3c4d
+	if strings.HasPrefix(strings.ToLower(env.Args), hashPrefix) {
+		env.Args = env.Args[len(hashPrefix):]
+		return cmdHash(pl, env)
+	}
+
 	// if it's an absolute path, just try launch it
 	if filepath.IsAbs(env.Args) {
 		log.Debug().Msgf("launching absolute path: %s", env.Args)
@@ -189,30 +216,14 @@ func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) error {
 
 	systemId, path := ps[0], ps[1]
 
-	system, err := gamesdb.LookupSystem(systemId)
+	system, err := gamesdb.LookupSystem(env.Cfg, systemId)
 	if err != nil {
 		return err
 	}
 
 	log.Info().Msgf("launching system: %s, path: %s", systemId, path)
 
-	var launchers []platforms.Launcher
-	for _, l := range pl.Launchers() {
-		if l.SystemId == system.Id {
-			launchers = append(launchers, l)
-		}
-	}
-
-	var folders []string
-	for _, l := range launchers {
-		for _, folder := range l.Folders {
-			if !utils.Contains(folders, folder) {
-				folders = append(folders, folder)
-			}
-		}
-	}
-
-	for _, f := range folders {
+	for _, f := range gamesdb.SystemFolders(env.Cfg, pl, system.Id) {
 		systemPath := filepath.Join(f, path)
 		if fp, err := findFile(pl, env.Cfg, systemPath); err == nil {
 			log.Debug().Msgf("launching found system path: %s", fp)
@@ -231,6 +242,35 @@ func cmdLaunch(pl platforms.Platform, env platforms.CmdEnv) error {
 	return fmt.Errorf("file not found: %s", env.Args)
 }
 
+const hashPrefix = "hash:"
+
+// Launch an indexed media file by its CRC32, MD5 or SHA1 hash, wherever it
+// is and whatever it's named.
+func cmdHash(pl platforms.Platform, env platforms.CmdEnv) error {
+	hash := strings.TrimSpace(env.Args)
+	if hash == "" {
+		return fmt.Errorf("no hash specified")
+	}
+
+	launch, err := getAltLauncher(pl, env)
+	if err != nil {
+		return err
+	}
+
+	res, err := gamesdb.SearchHash(pl, hash)
+	if err != nil {
+		return err
+	}
+
+	if len(res) == 0 {
+		return fmt.Errorf("no media found with hash: %s", hash)
+	}
+
+	res = gamesdb.PreferMedia(res, mediaPreferences(env.Cfg))
+
+	return launch(res[0].Path)
+}
+
 func cmdSearch(pl platforms.Platform, env platforms.CmdEnv) error {
 	if env.Args == "" {
 		return fmt.Errorf("no query specified")
@@ -246,7 +286,7 @@ func cmdSearch(pl platforms.Platform, env platforms.CmdEnv) error {
 
 	if !strings.Contains(env.Args, "/") {
 		// search all systems
-		res, err := gamesdb.SearchNamesGlob(pl, gamesdb.AllSystems(), query)
+		res, err := searchMedia(pl, env, gamesdb.AllSystems(), query)
 		if err != nil {
 			return err
 		}
@@ -274,7 +314,7 @@ func cmdSearch(pl platforms.Platform, env platforms.CmdEnv) error {
 	if systemId == "all" {
 		systems = gamesdb.AllSystems()
 	} else {
-		system, err := gamesdb.LookupSystem(systemId)
+		system, err := gamesdb.LookupSystem(env.Cfg, systemId)
 		if err != nil {
 			return err
 		}
@@ -282,7 +322,7 @@ func cmdSearch(pl platforms.Platform, env platforms.CmdEnv) error {
 		systems = append(systems, *system)
 	}
 
-	res, err := gamesdb.SearchNamesGlob(pl, systems, query)
+	res, err := searchMedia(pl, env, systems, query)
 	if err != nil {
 		return err
 	}
@@ -294,6 +334,91 @@ func cmdSearch(pl platforms.Platform, env platforms.CmdEnv) error {
 	return launch(res[0].Path)
 }
 
+// Filter for search results from the region and exclude named args, e.g.
+// ?region=USA,Europe&exclude=beta,hack
+func searchFilter(env platforms.CmdEnv) gamesdb.SearchFilter {
+	return gamesdb.ParseSearchFilter(env.NamedArgs["region"], env.NamedArgs["exclude"])
+}
+
+// Preferences for picking between versions of the same media.
+func mediaPreferences(cfg *config.Instance) gamesdb.MediaPreferences {
+	m := cfg.MediaPreferences()
+	return gamesdb.MediaPreferences{
+		Regions:   m.PreferRegion,
+		Languages: m.PreferLanguage,
+		Verified:  m.PreferVerified,
+		Avoid:     m.Avoid,
+	}
+}
+
+// Search for media with the ranked search, or with a glob if the query
+// contains any wildcards. Results are sorted best match first, with the
+// user's preferred versions first among equal matches.
+func searchMedia(
+	pl platforms.Platform,
+	env platforms.CmdEnv,
+	systems []gamesdb.System,
+	query string,
+) ([]gamesdb.SearchResult, error) {
+	filter := searchFilter(env)
+	prefs := mediaPreferences(env.Cfg)
+
+	if !strings.ContainsAny(query, "*?[") {
+		res, err := gamesdb.SearchMedia(pl, systems, query, filter)
+		if err != nil {
+			return nil, err
+		}
+		return gamesdb.PreferMedia(res, prefs), nil
+	}
+
+	res, err := gamesdb.SearchNamesGlob(pl, systems, query)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]gamesdb.SearchResult, 0, len(res))
+	for _, r := range res {
+		if filter.Match(gamesdb.ParseTags(r.Name)) {
+			filtered = append(filtered, r)
+		}
+	}
+
+	ranked := gamesdb.RankResults(strings.NewReplacer("*", " ", "?", " ").Replace(query), filtered)
+	return gamesdb.PreferMedia(ranked, prefs), nil
+}
+
+// Swap a randomly picked media for the user's preferred version of it, e.g.
+// a different region of the same game.
+func preferredVersion(
+	pl platforms.Platform,
+	env platforms.CmdEnv,
+	media gamesdb.SearchResult,
+) gamesdb.SearchResult {
+	system, err := gamesdb.GetSystem(media.SystemId)
+	if err != nil || media.Tags.Title == "" {
+		return media
+	}
+
+	res, err := gamesdb.SearchMedia(pl, []gamesdb.System{*system}, media.Tags.Title, searchFilter(env))
+	if err != nil {
+		log.Warn().Err(err).Msgf("error searching for versions of: %s", media.Name)
+		return media
+	}
+
+	var versions []gamesdb.SearchResult
+	for _, r := range res {
+		if strings.EqualFold(r.Tags.Title, media.Tags.Title) {
+			versions = append(versions, r)
+		}
+	}
+
+	if len(versions) == 0 {
+		return media
+	}
+
+	return gamesdb.PreferMedia(versions, mediaPreferences(env.Cfg))[0]
+}
+
 func cmdPlaylistPlay(_ platforms.Platform, env platforms.CmdEnv) error {
 	if env.Args == "" {
 		return fmt.Errorf("no playlist path specified")
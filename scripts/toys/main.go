@@ -0,0 +1,141 @@
+// Generates the toys-to-life databases bundled in pkg/assets/toys from
+// their upstream sources. Run with go generate ./pkg/assets.
+//
+// Amiibo: AmiiboAPI (https://github.com/N3evin/AmiiboAPI), MIT licence.
+// LEGO Dimensions: LD-ToyPad-Emulator
+// (https://github.com/Berny23/LD-ToyPad-Emulator), MIT licence.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	amiiboUrl     = "https://raw.githubusercontent.com/N3evin/AmiiboAPI/master/database/amiibo.json"
+	legoCharsUrl  = "https://raw.githubusercontent.com/Berny23/LD-ToyPad-Emulator/master/server/json/charactermap.json"
+	legoTokensUrl = "https://raw.githubusercontent.com/Berny23/LD-ToyPad-Emulator/master/server/json/tokenmap.json"
+)
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+func fetch(url string, v any) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// Write JSON with sorted keys and a trailing newline, so regenerated files
+// diff cleanly.
+func writeJson(path string, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Only the fields used to name toys are kept from the AmiiboAPI database.
+type amiiboDatabase struct {
+	AmiiboSeries map[string]string `json:"amiibo_series"`
+	Amiibos      map[string]struct {
+		Name string `json:"name"`
+	} `json:"amiibos"`
+	Characters map[string]string `json:"characters"`
+	GameSeries map[string]string `json:"game_series"`
+	Types      map[string]string `json:"types"`
+}
+
+func generateAmiibo(out string) error {
+	var db amiiboDatabase
+	err := fetch(amiiboUrl, &db)
+	if err != nil {
+		return err
+	} else if len(db.Amiibos) == 0 || len(db.Characters) == 0 {
+		return errors.New("amiibo database is empty")
+	}
+
+	return writeJson(filepath.Join(out, "amiibo.json"), db)
+}
+
+type legoEntry struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func legoNames(url string) (map[string]string, error) {
+	var entries []legoEntry
+	err := fetch(url, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if e.Id > 0 && e.Name != "" {
+			names[strconv.Itoa(e.Id)] = e.Name
+		}
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no entries found in %s", url)
+	}
+
+	return names, nil
+}
+
+func generateLego(out string) error {
+	chars, err := legoNames(legoCharsUrl)
+	if err != nil {
+		return err
+	}
+
+	vehicles, err := legoNames(legoTokensUrl)
+	if err != nil {
+		return err
+	}
+
+	return writeJson(filepath.Join(out, "lego_dimensions.json"), map[string]any{
+		"characters": chars,
+		"vehicles":   vehicles,
+	})
+}
+
+func main() {
+	out := flag.String("out", "toys", "directory to write the databases to")
+	flag.Parse()
+
+	for _, gen := range []func(string) error{generateAmiibo, generateLego} {
+		err := gen(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}